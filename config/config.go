@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"time"
 )
 
@@ -10,6 +11,18 @@ type Config struct {
 	JWTSecret     string
 	JWTExpiration time.Duration
 	ServerPort    string
+
+	// Stockage des fichiers (images produits)
+	StorageDriver    string // "local" ou "s3"
+	StorageLocalDir  string
+	StoragePublicURL string // URL publique de base des fichiers (vide = déduite du driver)
+	MaxUploadSize    int64  // En octets
+	S3Endpoint       string
+	S3Bucket         string
+	S3AccessKey      string
+	S3SecretKey      string
+	S3UseSSL         bool
+	S3Region         string
 }
 
 // AppConfig est l'instance globale de configuration
@@ -26,6 +39,20 @@ func Load() {
 
 		// Port du serveur
 		ServerPort: getEnv("PORT", "8080"),
+
+		// Stockage: disque local par défaut, servi sur /uploads
+		StorageDriver:    getEnv("STORAGE_DRIVER", "local"),
+		StorageLocalDir:  getEnv("STORAGE_LOCAL_DIR", "uploads"),
+		StoragePublicURL: getEnv("STORAGE_PUBLIC_URL", ""),
+		MaxUploadSize:    int64(getEnvInt("MAX_UPLOAD_SIZE_MB", 5)) << 20,
+
+		// Stockage S3 compatible (AWS, MinIO...)
+		S3Endpoint:  getEnv("S3_ENDPOINT", "localhost:9000"),
+		S3Bucket:    getEnv("S3_BUCKET", "electronic-shop"),
+		S3AccessKey: getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey: getEnv("S3_SECRET_KEY", ""),
+		S3UseSSL:    getEnv("S3_USE_SSL", "false") == "true",
+		S3Region:    getEnv("S3_REGION", "us-east-1"),
 	}
 }
 
@@ -35,4 +62,12 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
+}
+
+// getEnvInt récupère une variable d'environnement entière ou retourne une valeur par défaut
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(getEnv(key, "")); err == nil {
+		return value
+	}
+	return defaultValue
 }
\ No newline at end of file
@@ -2,7 +2,9 @@ package database
 
 import (
 	"electronic-shop-api/models"
+	"errors"
 	"log"
+	"strings"
 
 	"github.com/glebarez/sqlite" // Driver SQLite pure Go (pas de CGO)
 	"gorm.io/gorm"
@@ -23,10 +25,19 @@ func Connect() {
 
 	log.Println("✅ Connecté à la base de données SQLite")
 
+	// Montants en centimes (anciennes bases: REAL en unités)
+	if err := migrateMoney(DB); err != nil {
+		log.Fatal("❌ Échec de conversion des montants:", err)
+	}
+
+	// Coût des ventes à reprendre une seule fois: colonne créée par cette migration
+	newSaleCosts := DB.Migrator().HasTable(&models.Transaction{}) && !DB.Migrator().HasColumn(&models.Transaction{}, "Cost")
+
 	// Migration automatique des tables
 	err = DB.AutoMigrate(
 		&models.Shop{},
 		&models.User{},
+		&models.Category{},
 		&models.Product{},
 		&models.Transaction{},
 	)
@@ -34,10 +45,130 @@ func Connect() {
 		log.Fatal("❌ Échec de migration:", err)
 	}
 
+	// Normalisation des catégories texte libre (avant la migration des fiches techniques)
+	if err := normalizeCategories(DB); err != nil {
+		log.Fatal("❌ Échec de normalisation des catégories:", err)
+	}
+
+	// Reprise des paiements à n'exécuter qu'une fois: table ou colonne créée par cette migration
+	migrator := DB.Migrator()
+	newPayments := !migrator.HasTable(&models.Payment{})
+	newPaymentSessions := !migrator.HasColumn(&models.Payment{}, "RegisterSessionID")
+
+	err = DB.AutoMigrate(
+		&models.CategoryAttribute{},
+		&models.ProductAttribute{},
+		&models.ProductImage{},
+		&models.ImportJob{},
+		&models.PriceHistory{},
+		&models.PriceSchedule{},
+		&models.Promotion{},
+		&models.TransactionPromotion{},
+		&models.Coupon{},
+		&models.CouponRedemption{},
+		&models.TaxRate{},
+		&models.ExchangeRate{},
+		&models.ExpenseCategory{},
+		&models.ExpenseAttachment{},
+		&models.RecurringExpense{},
+		&models.RegisterSession{},
+		&models.PaymentMethod{},
+		&models.Payment{},
+		&models.Customer{},
+		&models.Layaway{},
+		&models.LayawayInstallment{},
+		&models.LoyaltyProgram{},
+		&models.LoyaltyEntry{},
+		&models.LoyaltyAllocation{},
+		&models.GiftCard{},
+		&models.GiftCardEntry{},
+	)
+	if err != nil {
+		log.Fatal("❌ Échec de migration:", err)
+	}
+
+	// Catégories de dépenses par défaut et anciennes catégories texte libre
+	if err := normalizeExpenseCategories(DB); err != nil {
+		log.Fatal("❌ Échec de normalisation des catégories de dépenses:", err)
+	}
+
+	// Moyens de paiement par défaut et règlement en espèces des transactions antérieures
+	if err := backfillPayments(DB, newPayments, newPaymentSessions); err != nil {
+		log.Fatal("❌ Échec de migration des paiements:", err)
+	}
+
+	// Les imports en cours au moment d'un arrêt ne reprendront pas
+	DB.Model(&models.ImportJob{}).
+		Where("status IN ?", []models.ImportStatus{models.ImportPending, models.ImportRunning}).
+		Updates(map[string]interface{}{"status": models.ImportFailed, "message": "Import interrompu par un redémarrage du serveur"})
+
+	// Ventes antérieures aux promotions: montant avant remise = montant encaissé
+	DB.Model(&models.Transaction{}).
+		Where("type = ? AND COALESCE(subtotal, 0) = 0 AND COALESCE(discount, 0) = 0", models.TypeSale).
+		Update("subtotal", gorm.Expr("amount"))
+
+	// Ventes antérieures au coût enregistré
+	if newSaleCosts {
+		if err := backfillSaleCosts(DB); err != nil {
+			log.Fatal("❌ Échec de reprise du coût des ventes:", err)
+		}
+	}
+
 	log.Println("✅ Migration des tables terminée")
 }
 
 // GetDB retourne l'instance de la base de données
 func GetDB() *gorm.DB {
 	return DB
+}
+
+// SeedExpenseCategories crée les catégories de dépenses par défaut d'un shop qui n'en a aucune
+func SeedExpenseCategories(db *gorm.DB, shopID uint) error {
+	var count int64
+	if err := db.Model(&models.ExpenseCategory{}).Where("shop_id = ?", shopID).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+
+	categories := make([]models.ExpenseCategory, 0, len(models.DefaultExpenseCategories))
+	for _, name := range models.DefaultExpenseCategories {
+		categories = append(categories, models.ExpenseCategory{Name: name, Slug: models.Slugify(name), ShopID: shopID})
+	}
+	return db.Create(&categories).Error
+}
+
+// SeedPaymentMethods crée les moyens de paiement par défaut d'un shop qui n'en a aucun
+func SeedPaymentMethods(db *gorm.DB, shopID uint) error {
+	var count int64
+	if err := db.Model(&models.PaymentMethod{}).Where("shop_id = ?", shopID).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+
+	methods := make([]models.PaymentMethod, 0, len(models.DefaultPaymentMethods))
+	for _, method := range models.DefaultPaymentMethods {
+		methods = append(methods, models.PaymentMethod{Name: method.Name, Kind: method.Kind, Active: true, ShopID: shopID})
+	}
+	return db.Create(&methods).Error
+}
+
+// FindOrCreateExpenseCategory retrouve une catégorie de dépenses par son nom
+// ("Loyers", "loyer" et "LOYER" sont la même catégorie) ou la crée
+func FindOrCreateExpenseCategory(db *gorm.DB, shopID uint, name string) (models.ExpenseCategory, error) {
+	name = strings.TrimSpace(name)
+	key := models.CategoryKey(name)
+	if key == "" {
+		return models.ExpenseCategory{}, errors.New("nom de catégorie de dépenses invalide")
+	}
+
+	var categories []models.ExpenseCategory
+	if err := db.Where("shop_id = ?", shopID).Find(&categories).Error; err != nil {
+		return models.ExpenseCategory{}, err
+	}
+	for _, category := range categories {
+		if models.CategoryKey(category.Name) == key || category.Slug == models.Slugify(name) {
+			return category, nil
+		}
+	}
+
+	category := models.ExpenseCategory{Name: name, Slug: models.Slugify(name), ShopID: shopID}
+	return category, db.Create(&category).Error
 }
\ No newline at end of file
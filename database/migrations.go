@@ -0,0 +1,347 @@
+package database
+
+import (
+	"electronic-shop-api/models"
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// normalizeCategories convertit les anciennes catégories texte libre ("Phones", "phone"...)
+// en entités Category et rattache produits et attributs à leur ID
+func normalizeCategories(db *gorm.DB) error {
+	resolver := newCategoryResolver(db)
+
+	// 1. Produits sans category_id mais avec une catégorie texte
+	var products []models.Product
+	if err := db.Where("category_id IS NULL AND category <> ''").Find(&products).Error; err != nil {
+		return err
+	}
+	for _, p := range products {
+		resolver.observe(p.ShopID, p.Category)
+	}
+	for _, p := range products {
+		category, err := resolver.resolve(p.ShopID, p.Category)
+		if err != nil {
+			return err
+		}
+		if err := db.Model(&models.Product{}).Where("id = ?", p.ID).
+			Updates(map[string]interface{}{"category_id": category.ID, "category": category.Name}).Error; err != nil {
+			return err
+		}
+	}
+	if len(products) > 0 {
+		log.Printf("✅ %d produits rattachés à une catégorie", len(products))
+	}
+
+	// 2. Anciennes fiches techniques indexées par nom de catégorie
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.CategoryAttribute{}) || !migrator.HasColumn(&models.CategoryAttribute{}, "category") {
+		return nil
+	}
+
+	if !migrator.HasColumn(&models.CategoryAttribute{}, "category_id") {
+		if err := migrator.AddColumn(&models.CategoryAttribute{}, "CategoryID"); err != nil {
+			return err
+		}
+	}
+
+	type legacyAttribute struct {
+		ID       uint
+		ShopID   uint
+		Category string
+		Name     string
+	}
+	var attributes []legacyAttribute
+	if err := db.Table("category_attributes").Select("id, shop_id, category, name").Order("id").Scan(&attributes).Error; err != nil {
+		return err
+	}
+
+	kept := map[uint]map[string]uint{} // category_id -> nom -> attribut conservé
+	for _, a := range attributes {
+		category, err := resolver.resolve(a.ShopID, a.Category)
+		if err != nil {
+			return err
+		}
+		if kept[category.ID] == nil {
+			kept[category.ID] = map[string]uint{}
+		}
+
+		// Doublon après normalisation ("ram" dans "Phones" et "phone"): fusionner les valeurs
+		if keptID, ok := kept[category.ID][a.Name]; ok {
+			if err := db.Exec("UPDATE product_attributes SET attribute_id = ? WHERE attribute_id = ?", keptID, a.ID).Error; err != nil {
+				return err
+			}
+			if err := db.Exec("DELETE FROM category_attributes WHERE id = ?", a.ID).Error; err != nil {
+				return err
+			}
+			continue
+		}
+
+		kept[category.ID][a.Name] = a.ID
+		if err := db.Exec("UPDATE category_attributes SET category_id = ? WHERE id = ?", category.ID, a.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	if migrator.HasIndex(&models.CategoryAttribute{}, "idx_shop_category_attribute") {
+		if err := migrator.DropIndex(&models.CategoryAttribute{}, "idx_shop_category_attribute"); err != nil {
+			return err
+		}
+	}
+	if err := migrator.DropColumn(&models.CategoryAttribute{}, "category"); err != nil {
+		return err
+	}
+
+	log.Printf("✅ %d attributs de fiche technique rattachés à une catégorie", len(attributes))
+	return nil
+}
+
+// categoryResolver rapproche les noms de catégorie par shop via models.CategoryKey
+type categoryResolver struct {
+	db       *gorm.DB
+	cache    map[uint]map[string]*models.Category
+	spelling map[uint]map[string]map[string]int // Fréquence de chaque orthographe
+}
+
+func newCategoryResolver(db *gorm.DB) *categoryResolver {
+	return &categoryResolver{
+		db:       db,
+		cache:    map[uint]map[string]*models.Category{},
+		spelling: map[uint]map[string]map[string]int{},
+	}
+}
+
+// observe comptabilise une orthographe pour choisir le nom le plus fréquent
+func (r *categoryResolver) observe(shopID uint, name string) {
+	key := models.CategoryKey(name)
+	if r.spelling[shopID] == nil {
+		r.spelling[shopID] = map[string]map[string]int{}
+	}
+	if r.spelling[shopID][key] == nil {
+		r.spelling[shopID][key] = map[string]int{}
+	}
+	r.spelling[shopID][key][name]++
+}
+
+// resolve retourne la catégorie correspondante, créée à la racine si besoin
+func (r *categoryResolver) resolve(shopID uint, name string) (*models.Category, error) {
+	key := models.CategoryKey(name)
+
+	if r.cache[shopID] == nil {
+		var existing []models.Category
+		if err := r.db.Where("shop_id = ?", shopID).Find(&existing).Error; err != nil {
+			return nil, err
+		}
+		r.cache[shopID] = map[string]*models.Category{}
+		for i := range existing {
+			r.cache[shopID][models.CategoryKey(existing[i].Name)] = &existing[i]
+		}
+	}
+	if category, ok := r.cache[shopID][key]; ok {
+		return category, nil
+	}
+
+	// Nom le plus fréquent parmi les variantes observées
+	best, bestCount := name, 0
+	for spelling, count := range r.spelling[shopID][key] {
+		if count > bestCount || (count == bestCount && spelling < best) {
+			best, bestCount = spelling, count
+		}
+	}
+
+	category := &models.Category{Name: best, Slug: models.Slugify(best), ShopID: shopID}
+	if err := r.db.Create(category).Error; err != nil {
+		return nil, err
+	}
+	r.cache[shopID][key] = category
+	return category, nil
+}
+
+// normalizeExpenseCategories crée les catégories de dépenses par défaut des shops
+// et rattache les dépenses saisies avec une catégorie texte libre
+func normalizeExpenseCategories(db *gorm.DB) error {
+	var shopIDs []uint
+	if err := db.Model(&models.Shop{}).Pluck("id", &shopIDs).Error; err != nil {
+		return err
+	}
+	for _, shopID := range shopIDs {
+		if err := SeedExpenseCategories(db, shopID); err != nil {
+			return err
+		}
+	}
+
+	var expenses []models.Transaction
+	if err := db.Where("type = ? AND expense_category_id IS NULL AND COALESCE(expense_category, '') <> ''", models.TypeExpense).
+		Find(&expenses).Error; err != nil {
+		return err
+	}
+	for _, t := range expenses {
+		category, err := FindOrCreateExpenseCategory(db, t.ShopID, t.ExpenseCategory)
+		if err != nil {
+			return err
+		}
+		if err := db.Model(&models.Transaction{}).Where("id = ?", t.ID).
+			Updates(map[string]interface{}{"expense_category_id": category.ID, "expense_category": category.Name}).Error; err != nil {
+			return err
+		}
+	}
+	if len(expenses) > 0 {
+		log.Printf("✅ %d dépenses rattachées à une catégorie", len(expenses))
+	}
+	return nil
+}
+
+// backfillPayments crée les moyens de paiement par défaut des shops. À la création de la
+// table des paiements (newPayments), il enregistre un règlement en espèces pour les
+// transactions antérieures (hors dépenses récurrentes, réglées hors caisse, ventes à crédit
+// et montants nuls). À l'ajout de la session de caisse des règlements (newSessions), les
+// règlements existants reprennent celle de leur transaction. Les transactions créées
+// ensuite portent leurs propres règlements: la reprise n'est jamais rejouée.
+func backfillPayments(db *gorm.DB, newPayments, newSessions bool) error {
+	var shopIDs []uint
+	if err := db.Model(&models.Shop{}).Pluck("id", &shopIDs).Error; err != nil {
+		return err
+	}
+	for _, shopID := range shopIDs {
+		if err := SeedPaymentMethods(db, shopID); err != nil {
+			return err
+		}
+	}
+
+	if newPayments {
+		if err := backfillCashPayments(db); err != nil {
+			return err
+		}
+	}
+	if !newSessions {
+		return nil
+	}
+	return db.Exec(`UPDATE payments SET register_session_id =
+		(SELECT t.register_session_id FROM transactions t WHERE t.id = payments.transaction_id)
+		WHERE register_session_id IS NULL`).Error
+}
+
+// backfillSaleCosts reprend le coût des ventes antérieures à la colonne cost (une seule fois,
+// à sa création): prix d'achat actuel, comme le calculait le dashboard. Les produits achetés
+// en devise étrangère sont laissés de côté faute de taux à la date de la vente.
+func backfillSaleCosts(db *gorm.DB) error {
+	result := db.Model(&models.Transaction{}).
+		Where("type = ? AND COALESCE(cost, 0) = 0 AND product_id IN (SELECT id FROM products WHERE COALESCE(purchase_currency, '') = '')", models.TypeSale).
+		Update("cost", gorm.Expr("quantity * (SELECT purchase_price FROM products WHERE products.id = transactions.product_id)"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("✅ %d ventes: coût repris du prix d'achat", result.RowsAffected)
+	}
+	return nil
+}
+
+// backfillCashPayments règle en espèces les transactions antérieures aux paiements
+func backfillCashPayments(db *gorm.DB) error {
+	var transactions []models.Transaction
+	if err := db.Where("recurring_expense_id IS NULL AND on_account = ? AND amount > 0 AND id NOT IN (SELECT transaction_id FROM payments)", false).
+		Find(&transactions).Error; err != nil {
+		return err
+	}
+
+	cash := map[uint]models.PaymentMethod{}
+	payments := make([]models.Payment, 0, len(transactions))
+	for _, t := range transactions {
+		method, ok := cash[t.ShopID]
+		if !ok {
+			if err := db.Where("shop_id = ? AND kind = ?", t.ShopID, models.PaymentCash).Order("id").First(&method).Error; err != nil {
+				continue
+			}
+			cash[t.ShopID] = method
+		}
+		payments = append(payments, models.Payment{
+			TransactionID:     t.ID,
+			PaymentMethodID:   method.ID,
+			Method:            method.Kind,
+			MethodName:        method.Name,
+			Amount:            t.Amount,
+			RegisterSessionID: t.RegisterSessionID,
+			ShopID:            t.ShopID,
+			CreatedAt:         t.CreatedAt,
+		})
+	}
+	if len(payments) > 0 {
+		if err := db.CreateInBatches(&payments, 500).Error; err != nil {
+			return err
+		}
+		log.Printf("✅ %d transactions réglées en espèces", len(payments))
+	}
+	return nil
+}
+
+// moneyColumns - Montants autrefois stockés en REAL (unités), désormais en centimes (models.Money)
+var moneyColumns = []struct {
+	model  interface{}
+	fields []string
+}{
+	{&models.Product{}, []string{"PurchasePrice", "SellingPrice"}},
+	{&models.Transaction{}, []string{"Subtotal", "Discount", "Amount", "CouponDiscount", "TaxAmount"}},
+	{&models.PriceHistory{}, []string{"OldPrice", "NewPrice"}},
+	{&models.PriceSchedule{}, []string{"SellingPrice", "PreviousPrice"}},
+	{&models.Promotion{}, []string{"Value", "MinBasket"}},
+	{&models.TransactionPromotion{}, []string{"Discount"}},
+	{&models.Coupon{}, []string{"Value", "MinBasket"}},
+	{&models.CouponRedemption{}, []string{"Discount"}},
+}
+
+// migrateMoney convertit les montants REAL en centimes entiers (avant AutoMigrate).
+// Une colonne déjà entière est ignorée: la migration peut être rejouée sans effet.
+func migrateMoney(db *gorm.DB) error {
+	migrator := db.Migrator()
+	for _, table := range moneyColumns {
+		if !migrator.HasTable(table.model) {
+			continue
+		}
+
+		columnTypes, err := migrator.ColumnTypes(table.model)
+		if err != nil {
+			return err
+		}
+		legacy := map[string]bool{}
+		for _, column := range columnTypes {
+			if strings.EqualFold(column.DatabaseTypeName(), "real") {
+				legacy[column.Name()] = true
+			}
+		}
+
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(table.model); err != nil {
+			return err
+		}
+
+		var converted []string
+		err = db.Transaction(func(tx *gorm.DB) error {
+			for _, name := range table.fields {
+				field := stmt.Schema.LookUpField(name)
+				if field == nil || !legacy[field.DBName] {
+					continue
+				}
+				column := field.DBName
+				if err := tx.Exec(fmt.Sprintf("UPDATE %s SET %s = CAST(ROUND(%s * 100) AS INTEGER)", stmt.Schema.Table, column, column)).Error; err != nil {
+					return err
+				}
+				if err := tx.Migrator().AlterColumn(table.model, name); err != nil {
+					return err
+				}
+				converted = append(converted, column)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if len(converted) > 0 {
+			log.Printf("✅ %s: montants convertis en centimes (%s)", stmt.Schema.Table, strings.Join(converted, ", "))
+		}
+	}
+	return nil
+}
\ No newline at end of file
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"electronic-shop-api/models"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// Ancienne base: montants REAL en unités, convertis en centimes entiers une seule fois
+func TestMigrateMoney(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.SetMaxOpenConns(1) // Base en mémoire: une seule connexion
+	}
+
+	if err := db.Exec(`CREATE TABLE products (id integer PRIMARY KEY, name text NOT NULL, ` +
+		`purchase_price real NOT NULL, selling_price real NOT NULL, stock integer, shop_id integer NOT NULL)`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(`INSERT INTO products (id, name, purchase_price, selling_price, stock, shop_id) VALUES ` +
+		`(1, 'Câble', 12.5, 19.99, 3, 1), (2, 'Chargeur', 0.1, 0.3, 1, 1), (3, 'Avoir', -4.05, 0, 0, 1)`).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[uint][2]models.Money{1: {1250, 1999}, 2: {10, 30}, 3: {-405, 0}}
+	check := func() {
+		t.Helper()
+		var rows []struct {
+			ID            uint
+			PurchasePrice models.Money
+			SellingPrice  models.Money
+		}
+		if err := db.Table("products").Order("id").Scan(&rows).Error; err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != len(want) {
+			t.Fatalf("%d produits, attendu %d", len(rows), len(want))
+		}
+		for _, row := range rows {
+			if got := [2]models.Money{row.PurchasePrice, row.SellingPrice}; got != want[row.ID] {
+				t.Errorf("produit %d: %v, attendu %v", row.ID, got, want[row.ID])
+			}
+		}
+
+		columns, err := db.Migrator().ColumnTypes(&models.Product{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, column := range columns {
+			if strings.EqualFold(column.DatabaseTypeName(), "real") {
+				t.Errorf("colonne %s encore REAL", column.Name())
+			}
+		}
+	}
+
+	if err := migrateMoney(db); err != nil {
+		t.Fatal(err)
+	}
+	check()
+
+	// Rejouée au démarrage suivant: sans effet
+	if err := migrateMoney(db); err != nil {
+		t.Fatal(err)
+	}
+	check()
+}
+
+// Coût des ventes antérieures: prix d'achat actuel, sauf produits achetés en devise étrangère
+func TestBackfillSaleCosts(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.SetMaxOpenConns(1) // Base en mémoire: une seule connexion
+	}
+	if err := db.AutoMigrate(&models.Product{}, &models.Transaction{}); err != nil {
+		t.Fatal(err)
+	}
+
+	products := []models.Product{
+		{Name: "Câble", PurchasePrice: 450, SellingPrice: 900, ShopID: 1},
+		{Name: "Chargeur", PurchasePrice: 12, PurchaseCurrency: "USD", SellingPrice: 2500, ShopID: 1},
+	}
+	if err := db.Create(&products).Error; err != nil {
+		t.Fatal(err)
+	}
+	transactions := []models.Transaction{
+		{Type: models.TypeSale, ProductID: &products[0].ID, Quantity: 3, Amount: 2700, ShopID: 1},
+		{Type: models.TypeSale, ProductID: &products[0].ID, Quantity: 1, Amount: 900, Cost: 400, ShopID: 1},
+		{Type: models.TypeSale, ProductID: &products[1].ID, Quantity: 2, Amount: 5000, ShopID: 1},
+		{Type: models.TypeExpense, Amount: 1000, ShopID: 1},
+	}
+	if err := db.Create(&transactions).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := backfillSaleCosts(db); err != nil {
+		t.Fatal(err)
+	}
+	want := []models.Money{1350, 400, 0, 0}
+	for i, transaction := range transactions {
+		var cost models.Money
+		db.Model(&models.Transaction{}).Where("id = ?", transaction.ID).Select("cost").Scan(&cost)
+		if cost != want[i] {
+			t.Errorf("transaction %d: coût %d, attendu %d", i+1, cost, want[i])
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,229 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format - Format de fichier d'export
+type Format string
+
+const (
+	CSV       Format = "csv"
+	XLSX      Format = "xlsx"
+	JSONLines Format = "jsonl"
+	PDF       Format = "pdf"
+)
+
+// ErrUnknownFormat - Format demandé non supporté
+var ErrUnknownFormat = errors.New("format d'export inconnu (csv, xlsx, jsonl ou pdf)")
+
+// dateLayout - Format des dates dans les fichiers tabulaires
+const dateLayout = "2006-01-02 15:04:05"
+
+// ParseFormat lit le paramètre format (csv par défaut)
+func ParseFormat(value string) (Format, error) {
+	switch strings.ToLower(value) {
+	case "", "csv":
+		return CSV, nil
+	case "xlsx", "excel":
+		return XLSX, nil
+	case "jsonl", "ndjson", "json":
+		return JSONLines, nil
+	case "pdf":
+		return PDF, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// ContentType retourne le type MIME du format
+func (f Format) ContentType() string {
+	switch f {
+	case XLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case JSONLines:
+		return "application/x-ndjson"
+	case PDF:
+		return "application/pdf"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Extension retourne l'extension de fichier du format
+func (f Format) Extension() string {
+	return "." + string(f)
+}
+
+// Writer écrit un export ligne par ligne
+type Writer interface {
+	// WriteRow écrit une ligne (une valeur par colonne)
+	WriteRow(values ...interface{}) error
+	// Flush envoie au client les lignes en attente
+	Flush() error
+	// Close termine le fichier
+	Close() error
+	// Abort libère les ressources sans terminer le fichier (export interrompu)
+	Abort()
+}
+
+// Options - Réglages facultatifs d'un export
+type Options struct {
+	Sheet string // Nom de la feuille XLSX
+	Comma rune   // Séparateur CSV (',' par défaut)
+	Title string // Titre du document PDF (Sheet par défaut)
+}
+
+// NewWriter crée un writer du format demandé; l'en-tête est écrit immédiatement
+func NewWriter(w io.Writer, format Format, columns []string, opts Options) (Writer, error) {
+	switch format {
+	case CSV:
+		return newCSVWriter(w, columns, opts)
+	case XLSX:
+		return newXLSXWriter(w, columns, opts)
+	case JSONLines:
+		return &jsonLinesWriter{out: bufio.NewWriter(w), dest: w, columns: columns}, nil
+	case PDF:
+		return newPDFWriter(w, columns, opts), nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// ========================================
+// CSV
+// ========================================
+
+type csvWriter struct {
+	csv  *csv.Writer
+	dest io.Writer
+}
+
+func newCSVWriter(w io.Writer, columns []string, opts Options) (*csvWriter, error) {
+	// BOM UTF-8: Excel affiche correctement les accents
+	if _, err := io.WriteString(w, "\xef\xbb\xbf"); err != nil {
+		return nil, err
+	}
+
+	writer := &csvWriter{csv: csv.NewWriter(w), dest: w}
+	if opts.Comma != 0 {
+		writer.csv.Comma = opts.Comma
+	}
+	return writer, writer.csv.Write(columns)
+}
+
+func (w *csvWriter) WriteRow(values ...interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = formatCell(value)
+	}
+	return w.csv.Write(record)
+}
+
+func (w *csvWriter) Flush() error {
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		return err
+	}
+	return flush(w.dest)
+}
+
+func (w *csvWriter) Close() error {
+	return w.Flush()
+}
+
+func (w *csvWriter) Abort() {}
+
+// ========================================
+// JSON LINES
+// ========================================
+
+type jsonLinesWriter struct {
+	out     *bufio.Writer
+	dest    io.Writer
+	columns []string
+}
+
+// WriteRow écrit un objet JSON par ligne, clés dans l'ordre des colonnes
+func (w *jsonLinesWriter) WriteRow(values ...interface{}) error {
+	w.out.WriteByte('{')
+	for i, value := range values {
+		if i >= len(w.columns) {
+			break
+		}
+		if i > 0 {
+			w.out.WriteByte(',')
+		}
+		key, _ := json.Marshal(w.columns[i])
+		w.out.Write(key)
+		w.out.WriteByte(':')
+
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("colonne %s: %w", w.columns[i], err)
+		}
+		w.out.Write(data)
+	}
+	w.out.WriteString("}\n")
+	return nil
+}
+
+func (w *jsonLinesWriter) Flush() error {
+	if err := w.out.Flush(); err != nil {
+		return err
+	}
+	return flush(w.dest)
+}
+
+func (w *jsonLinesWriter) Close() error {
+	return w.Flush()
+}
+
+func (w *jsonLinesWriter) Abort() {}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// formatCell convertit une valeur en texte pour les formats tabulaires
+func formatCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case *string:
+		if v == nil {
+			return ""
+		}
+		return *v
+	case *uint:
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatUint(uint64(*v), 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(dateLayout)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.Format(dateLayout)
+	}
+	return fmt.Sprint(value)
+}
+
+// flush pousse les données vers le client si la destination le permet (http.Flusher)
+func flush(w io.Writer) error {
+	if f, ok := w.(interface{ Flush() }); ok {
+		f.Flush()
+	}
+	return nil
+}
\ No newline at end of file
@@ -0,0 +1,140 @@
+package export
+
+import (
+	"io"
+	"math"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// Mise en page des exports PDF (mm)
+const (
+	pdfMargin    = 10.0
+	pdfRowHeight = 6.0
+	pdfMaxFont   = 9.0
+	pdfMinFont   = 6.0
+)
+
+// pdfWriter présente les lignes sous forme de tableau (A4, paysage au-delà de 6 colonnes).
+// Les lignes sont gardées en mémoire jusqu'à la fermeture: format prévu pour les rapports.
+type pdfWriter struct {
+	dest    io.Writer
+	title   string
+	columns []string
+	rows    [][]interface{}
+}
+
+func newPDFWriter(w io.Writer, columns []string, opts Options) *pdfWriter {
+	title := opts.Title
+	if title == "" {
+		title = opts.Sheet
+	}
+	return &pdfWriter{dest: w, title: title, columns: columns}
+}
+
+func (w *pdfWriter) WriteRow(values ...interface{}) error {
+	w.rows = append(w.rows, values)
+	return nil
+}
+
+// Flush est sans effet: le document est généré à la fermeture
+func (w *pdfWriter) Flush() error {
+	return nil
+}
+
+func (w *pdfWriter) Close() error {
+	orientation := "P"
+	if len(w.columns) > 6 {
+		orientation = "L"
+	}
+	pdf := fpdf.New(orientation, "mm", "A4", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(false, pdfMargin)
+	tr := pdf.UnicodeTranslatorFromDescriptor("") // Polices standard en cp1252 (accents)
+	pdf.AddPage()
+
+	// Titre
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(0, 8, tr(w.title), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 8)
+	pdf.CellFormat(0, 5, tr("Généré le "+time.Now().Format(dateLayout)), "", 1, "L", false, 0, "")
+	pdf.Ln(3)
+
+	widths := w.columnWidths(pdf)
+	fontSize := math.Max(pdfMinFont, math.Min(pdfMaxFont, 72/float64(len(w.columns))))
+
+	header := func() {
+		pdf.SetFont("Helvetica", "B", fontSize)
+		pdf.SetFillColor(230, 230, 230)
+		for i, column := range w.columns {
+			pdf.CellFormat(widths[i], pdfRowHeight, tr(fitText(pdf, tr, column, widths[i])), "1", 0, "C", true, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont("Helvetica", "", fontSize)
+	}
+	header()
+
+	_, pageHeight := pdf.GetPageSize()
+	for _, row := range w.rows {
+		if pdf.GetY()+pdfRowHeight > pageHeight-pdfMargin {
+			pdf.AddPage()
+			header()
+		}
+		for i := range w.columns {
+			var value interface{}
+			if i < len(row) {
+				value = row[i]
+			}
+			align := "L"
+			if isNumber(value) {
+				align = "R"
+			}
+			text := fitText(pdf, tr, formatCell(value), widths[i])
+			pdf.CellFormat(widths[i], pdfRowHeight, tr(text), "1", 0, align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	return pdf.Output(w.dest)
+}
+
+func (w *pdfWriter) Abort() {}
+
+// columnWidths - Première colonne (libellés) plus large, les autres à parts égales
+func (w *pdfWriter) columnWidths(pdf *fpdf.Fpdf) []float64 {
+	pageWidth, _ := pdf.GetPageSize()
+	available := pageWidth - 2*pdfMargin
+	widths := make([]float64, len(w.columns))
+	if len(widths) == 0 {
+		return widths
+	}
+
+	first := available / float64(len(widths))
+	if len(widths) > 2 {
+		first = available * 0.28
+	}
+	widths[0] = first
+	for i := 1; i < len(widths); i++ {
+		widths[i] = (available - first) / float64(len(widths)-1)
+	}
+	return widths
+}
+
+// fitText tronque le texte à la largeur de la cellule
+func fitText(pdf *fpdf.Fpdf, tr func(string) string, text string, width float64) string {
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(tr(string(runes)))+2 > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes)
+}
+
+// isNumber indique une valeur numérique (alignée à droite)
+func isNumber(value interface{}) bool {
+	switch value.(type) {
+	case int, int64, uint, float64, interface{ Float64() float64 }:
+		return true
+	}
+	return false
+}
\ No newline at end of file
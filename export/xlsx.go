@@ -0,0 +1,101 @@
+package export
+
+import (
+	"io"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// xlsxWriter utilise le StreamWriter d'excelize: les lignes sont écrites au fil de l'eau
+// dans un fichier temporaire (mémoire bornée), le classeur est envoyé à la fermeture
+type xlsxWriter struct {
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	dest   io.Writer
+	row    int
+}
+
+func newXLSXWriter(w io.Writer, columns []string, opts Options) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+
+	sheet := "Sheet1"
+	if opts.Sheet != "" {
+		sheet = opts.Sheet
+		if len(sheet) > 31 {
+			sheet = sheet[:31]
+		}
+		if err := file.SetSheetName("Sheet1", sheet); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	stream, err := file.NewStreamWriter(sheet)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// En-tête en gras
+	bold, err := file.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	header := make([]interface{}, len(columns))
+	for i, column := range columns {
+		header[i] = excelize.Cell{StyleID: bold, Value: column}
+	}
+	if err := stream.SetRow("A1", header, excelize.RowOpts{Height: 18}); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &xlsxWriter{file: file, stream: stream, dest: w, row: 1}, nil
+}
+
+func (w *xlsxWriter) WriteRow(values ...interface{}) error {
+	w.row++
+	cells := make([]interface{}, len(values))
+	for i, value := range values {
+		cells[i] = xlsxValue(value)
+	}
+
+	cell, err := excelize.CoordinatesToCellName(1, w.row)
+	if err != nil {
+		return err
+	}
+	return w.stream.SetRow(cell, cells)
+}
+
+// Flush est sans effet: le classeur n'est valide qu'une fois complet
+func (w *xlsxWriter) Flush() error {
+	return nil
+}
+
+func (w *xlsxWriter) Close() error {
+	defer w.file.Close()
+	if err := w.stream.Flush(); err != nil {
+		return err
+	}
+	_, err := w.file.WriteTo(w.dest)
+	return err
+}
+
+func (w *xlsxWriter) Abort() {
+	w.file.Close()
+}
+
+// xlsxValue garde les nombres en cellules numériques et convertit le reste en texte
+func xlsxValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int, int64, uint, float64, bool:
+		return v
+	case interface{ Float64() float64 }: // Montants (models.Money)
+		return v.Float64()
+	case time.Time:
+		return v.Format(dateLayout)
+	}
+	return formatCell(value)
+}
\ No newline at end of file
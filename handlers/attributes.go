@@ -0,0 +1,440 @@
+package handlers
+
+import (
+	"electronic-shop-api/database"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// STRUCTURES DE REQUÊTE
+// ========================================
+
+type CreateCategoryAttributeInput struct {
+	CategoryID    uint     `json:"category_id" binding:"required"`
+	Name          string   `json:"name" binding:"required"`
+	Type          string   `json:"type" binding:"required,oneof=number text boolean enum"`
+	Unit          string   `json:"unit"`
+	AllowedValues []string `json:"allowed_values"`
+	Required      bool     `json:"required"`
+}
+
+type UpdateCategoryAttributeInput struct {
+	Name          string   `json:"name"`
+	Unit          *string  `json:"unit"`
+	AllowedValues []string `json:"allowed_values"`
+	Required      *bool    `json:"required"`
+}
+
+// ========================================
+// GET CATEGORY ATTRIBUTES
+// ========================================
+
+func GetCategoryAttributes(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+
+	var attributes []models.CategoryAttribute
+	query := db.Where("shop_id = ?", shopID).Order("category_id ASC, name ASC")
+
+	// Filtre par catégorie (optionnel): attributs propres et hérités des parents
+	if categoryID, err := strconv.ParseUint(c.Query("category_id"), 10, 32); err == nil {
+		categories, err := loadShopCategories(db, shopID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des attributs"})
+			return
+		}
+		query = query.Where("category_id IN ?", categoryAncestorIDs(categories, uint(categoryID)))
+	}
+
+	if err := query.Find(&attributes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des attributs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"attributes": attributes, "count": len(attributes)})
+}
+
+// ========================================
+// CREATE CATEGORY ATTRIBUTE (SuperAdmin)
+// ========================================
+
+func CreateCategoryAttribute(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	var input CreateCategoryAttributeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	attributeType := models.AttributeType(input.Type)
+	if attributeType == models.AttributeEnum && len(input.AllowedValues) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "allowed_values est requis pour un attribut de type enum"})
+		return
+	}
+	if attributeType != models.AttributeEnum && len(input.AllowedValues) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "allowed_values n'est accepté que pour un attribut de type enum"})
+		return
+	}
+
+	db := database.GetDB()
+
+	// Vérifier la catégorie (MULTI-TENANT)
+	var category models.Category
+	if err := db.Where("id = ? AND shop_id = ?", input.CategoryID, shopID).First(&category).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Catégorie non trouvée"})
+		return
+	}
+
+	// Vérifier l'unicité du nom dans la catégorie
+	var existing models.CategoryAttribute
+	if err := db.Where("category_id = ? AND name = ?", category.ID, strings.TrimSpace(input.Name)).
+		First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cet attribut existe déjà pour cette catégorie"})
+		return
+	}
+
+	attribute := models.CategoryAttribute{
+		CategoryID:    category.ID,
+		Name:          strings.TrimSpace(input.Name),
+		Type:          attributeType,
+		Unit:          input.Unit,
+		AllowedValues: input.AllowedValues,
+		Required:      input.Required,
+		ShopID:        shopID,
+	}
+
+	if err := db.Create(&attribute).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création de l'attribut"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Attribut créé", "attribute": attribute})
+}
+
+// ========================================
+// UPDATE CATEGORY ATTRIBUTE (SuperAdmin)
+// ========================================
+
+func UpdateCategoryAttribute(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	attributeID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID d'attribut invalide"})
+		return
+	}
+
+	var input UpdateCategoryAttributeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	var attribute models.CategoryAttribute
+
+	// MULTI-TENANT
+	if err := db.Where("id = ? AND shop_id = ?", attributeID, shopID).First(&attribute).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attribut non trouvé"})
+		return
+	}
+
+	if name := strings.TrimSpace(input.Name); name != "" && name != attribute.Name {
+		// Même règle d'unicité qu'à la création
+		var existing int64
+		db.Model(&models.CategoryAttribute{}).Where("category_id = ? AND name = ? AND id <> ?", attribute.CategoryID, name, attribute.ID).Count(&existing)
+		if existing > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cet attribut existe déjà pour cette catégorie"})
+			return
+		}
+		attribute.Name = name
+	}
+	if input.Unit != nil {
+		attribute.Unit = *input.Unit
+	}
+	if input.Required != nil {
+		attribute.Required = *input.Required
+	}
+	if input.AllowedValues != nil {
+		if attribute.Type != models.AttributeEnum {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "allowed_values n'est accepté que pour un attribut de type enum"})
+			return
+		}
+		if len(input.AllowedValues) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "allowed_values ne peut pas être vide"})
+			return
+		}
+		// Une valeur retirée ne doit plus être utilisée par un produit
+		var products []struct {
+			ID    uint   `json:"id"`
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		}
+		if err := db.Table("product_attributes pa").Select("p.id, p.name, pa.value").
+			Joins("JOIN products p ON p.id = pa.product_id").
+			Where("pa.attribute_id = ? AND pa.value NOT IN ?", attribute.ID, input.AllowedValues).
+			Order("p.id ASC").Scan(&products).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour"})
+			return
+		}
+		if len(products) > 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":    "Des produits utilisent une valeur retirée: les modifier d'abord",
+				"products": products,
+			})
+			return
+		}
+		attribute.AllowedValues = input.AllowedValues
+	}
+
+	if err := db.Save(&attribute).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attribut mis à jour", "attribute": attribute})
+}
+
+// ========================================
+// DELETE CATEGORY ATTRIBUTE (SuperAdmin)
+// ========================================
+
+func DeleteCategoryAttribute(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	attributeID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID d'attribut invalide"})
+		return
+	}
+
+	db := database.GetDB()
+	var attribute models.CategoryAttribute
+
+	if err := db.Where("id = ? AND shop_id = ?", attributeID, shopID).First(&attribute).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attribut non trouvé"})
+		return
+	}
+
+	// Supprimer l'attribut et les valeurs associées
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("attribute_id = ?", attribute.ID).Delete(&models.ProductAttribute{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&attribute).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attribut supprimé"})
+}
+
+// ========================================
+// VALIDATION DES VALEURS D'ATTRIBUTS
+// ========================================
+
+// buildProductAttributes valide les valeurs fournies contre la fiche technique de la catégorie
+// (attributs hérités des catégories parentes inclus).
+// Si requireAll est vrai, les attributs obligatoires absents provoquent une erreur.
+func buildProductAttributes(db *gorm.DB, shopID uint, categoryID *uint, values map[string]interface{}, requireAll bool) ([]models.ProductAttribute, error) {
+	schema, err := categorySchema(db, shopID, categoryID)
+	if err != nil {
+		return nil, fmt.Errorf("impossible de charger la fiche technique de la catégorie")
+	}
+
+	byName := make(map[string]models.CategoryAttribute, len(schema))
+	for _, a := range schema {
+		byName[a.Name] = a
+	}
+
+	for name := range values {
+		if _, ok := byName[name]; !ok {
+			return nil, fmt.Errorf("attribut inconnu pour cette catégorie: %s", name)
+		}
+	}
+
+	result := make([]models.ProductAttribute, 0, len(values))
+	for _, attribute := range schema {
+		raw, provided := values[attribute.Name]
+		if !provided || raw == nil {
+			if requireAll && attribute.Required {
+				return nil, fmt.Errorf("l'attribut %s est obligatoire", attribute.Name)
+			}
+			continue
+		}
+
+		value, err := parseAttributeValue(attribute, raw)
+		if err != nil {
+			return nil, err
+		}
+		value.AttributeID = attribute.ID
+		result = append(result, value)
+	}
+
+	return result, nil
+}
+
+// parseAttributeValue convertit une valeur JSON selon le type de l'attribut
+func parseAttributeValue(attribute models.CategoryAttribute, raw interface{}) (models.ProductAttribute, error) {
+	switch attribute.Type {
+	case models.AttributeNumber:
+		number, ok := raw.(float64)
+		if !ok {
+			return models.ProductAttribute{}, fmt.Errorf("l'attribut %s doit être un nombre", attribute.Name)
+		}
+		return models.ProductAttribute{
+			Value:       strconv.FormatFloat(number, 'f', -1, 64),
+			NumberValue: &number,
+		}, nil
+
+	case models.AttributeBoolean:
+		flag, ok := raw.(bool)
+		if !ok {
+			return models.ProductAttribute{}, fmt.Errorf("l'attribut %s doit être un booléen", attribute.Name)
+		}
+		return models.ProductAttribute{Value: strconv.FormatBool(flag)}, nil
+
+	case models.AttributeEnum:
+		text, ok := raw.(string)
+		if !ok {
+			return models.ProductAttribute{}, fmt.Errorf("l'attribut %s doit être une chaîne", attribute.Name)
+		}
+		for _, allowed := range attribute.AllowedValues {
+			if allowed == text {
+				return models.ProductAttribute{Value: text}, nil
+			}
+		}
+		return models.ProductAttribute{}, fmt.Errorf("valeur non autorisée pour %s: %s (valeurs possibles: %s)",
+			attribute.Name, text, strings.Join(attribute.AllowedValues, ", "))
+
+	default:
+		text, ok := raw.(string)
+		if !ok {
+			return models.ProductAttribute{}, fmt.Errorf("l'attribut %s doit être une chaîne", attribute.Name)
+		}
+		return models.ProductAttribute{Value: text}, nil
+	}
+}
+
+// replaceProductAttributes remplace les valeurs d'attributs d'un produit
+func replaceProductAttributes(tx *gorm.DB, productID uint, attributes []models.ProductAttribute) error {
+	if err := tx.Where("product_id = ?", productID).Delete(&models.ProductAttribute{}).Error; err != nil {
+		return err
+	}
+	if len(attributes) == 0 {
+		return nil
+	}
+	for i := range attributes {
+		attributes[i].ProductID = productID
+	}
+	return tx.Create(&attributes).Error
+}
+
+// ========================================
+// FILTRES PAR ATTRIBUTS
+// ========================================
+
+// applyAttributeFilters ajoute les filtres de fiche technique à la requête:
+//   - attr.<nom>=<valeur>  : égalité (texte, enum, booléen ou nombre)
+//   - attr.<nom>.min=<n>   : borne inférieure (attributs numériques)
+//   - attr.<nom>.max=<n>   : borne supérieure (attributs numériques)
+func applyAttributeFilters(query *gorm.DB, shopID uint, params url.Values) (*gorm.DB, error) {
+	const subquery = `id IN (
+		SELECT pa.product_id FROM product_attributes pa
+		JOIN category_attributes ca ON ca.id = pa.attribute_id
+		WHERE ca.shop_id = ? AND ca.name = ? AND %s)`
+
+	for key, values := range params {
+		if !strings.HasPrefix(key, "attr.") || len(values) == 0 || values[0] == "" {
+			continue
+		}
+		name := strings.TrimPrefix(key, "attr.")
+		value := values[0]
+
+		switch {
+		case strings.HasSuffix(name, ".min"), strings.HasSuffix(name, ".max"):
+			bound, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("valeur numérique invalide pour %s", key)
+			}
+			operator := ">="
+			if strings.HasSuffix(name, ".max") {
+				operator = "<="
+			}
+			name = name[:len(name)-len(".min")]
+			query = query.Where(fmt.Sprintf(subquery, "pa.number_value "+operator+" ?"), shopID, name, bound)
+
+		default:
+			query = query.Where(fmt.Sprintf(subquery, "pa.value = ?"), shopID, name, value)
+		}
+	}
+
+	return query, nil
+}
+
+// keptAttributeValues retourne les valeurs actuelles d'un produit dont le nom existe dans la catégorie cible
+func keptAttributeValues(db *gorm.DB, shopID uint, productID uint, categoryID *uint) (map[string]interface{}, error) {
+	var current []models.ProductAttribute
+	if err := db.Where("product_id = ?", productID).Preload("Attribute").Find(&current).Error; err != nil {
+		return nil, err
+	}
+
+	schema, err := categorySchema(db, shopID, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	types := make(map[string]models.AttributeType, len(schema))
+	for _, a := range schema {
+		types[a.Name] = a.Type
+	}
+
+	values := map[string]interface{}{}
+	for _, pa := range current {
+		if pa.Attribute == nil {
+			continue
+		}
+		targetType, ok := types[pa.Attribute.Name]
+		if !ok || targetType != pa.Attribute.Type {
+			continue
+		}
+		switch {
+		case pa.NumberValue != nil:
+			values[pa.Attribute.Name] = *pa.NumberValue
+		case pa.Attribute.Type == models.AttributeBoolean:
+			values[pa.Attribute.Name] = pa.Value == "true"
+		default:
+			values[pa.Attribute.Name] = pa.Value
+		}
+	}
+	return values, nil
+}
+
+// categorySchema charge les attributs d'une catégorie et de ses ancêtres
+func categorySchema(db *gorm.DB, shopID uint, categoryID *uint) ([]models.CategoryAttribute, error) {
+	if categoryID == nil {
+		return nil, nil
+	}
+
+	categories, err := loadShopCategories(db, shopID)
+	if err != nil {
+		return nil, err
+	}
+
+	var schema []models.CategoryAttribute
+	err = db.Where("shop_id = ? AND category_id IN ?", shopID, categoryAncestorIDs(categories, *categoryID)).
+		Order("category_id ASC, name ASC").
+		Find(&schema).Error
+	return schema, err
+}
\ No newline at end of file
@@ -5,6 +5,7 @@ import (
 	"electronic-shop-api/database"
 	"electronic-shop-api/middleware"
 	"electronic-shop-api/models"
+	"log"
 	"net/http"
 	"time"
 
@@ -84,6 +85,14 @@ func Register(c *gin.Context) {
 			return
 		}
 		shopID = newShop.ID
+
+		// Catégories de dépenses et moyens de paiement par défaut
+		if err := database.SeedExpenseCategories(db, shopID); err != nil {
+			log.Printf("❌ Catégories de dépenses du shop %d: %v", shopID, err)
+		}
+		if err := database.SeedPaymentMethods(db, shopID); err != nil {
+			log.Printf("❌ Moyens de paiement du shop %d: %v", shopID, err)
+		}
 	}
 
 	// Hasher le mot de passe
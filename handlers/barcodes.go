@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"electronic-shop-api/database"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// STRUCTURE DE REQUÊTE
+// ========================================
+
+type GenerateBarcodesInput struct {
+	ProductIDs []uint `json:"product_ids"` // Vide = tous les produits sans code-barres
+}
+
+// ========================================
+// LOOKUP PRODUCT BY CODE (Scan)
+// ========================================
+
+func LookupProduct(c *gin.Context) {
+	_, shopID, role := middleware.GetUserFromContext(c)
+
+	code := normalizeCode(c.Query("code"))
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le paramètre code est requis"})
+		return
+	}
+
+	db := database.GetDB()
+	product, err := findProductByCode(db, shopID, code)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Aucun produit pour ce code", "code": code})
+		return
+	}
+
+	// Si Admin, masquer PurchasePrice
+	if role == models.RoleAdmin {
+		c.JSON(http.StatusOK, gin.H{"product": productWithoutPurchasePrice(product)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"product": product})
+}
+
+// ========================================
+// GENERATE INTERNAL BARCODES
+// ========================================
+
+func GenerateBarcodes(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	var input GenerateBarcodesInput
+	// Corps optionnel
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	// MULTI-TENANT: uniquement les produits du shop sans code-barres
+	query := db.Where("shop_id = ? AND (barcode IS NULL OR barcode = '')", shopID)
+	if len(input.ProductIDs) > 0 {
+		query = query.Where("id IN ?", input.ProductIDs)
+	}
+
+	var products []models.Product
+	if err := query.Find(&products).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des produits"})
+		return
+	}
+
+	generated := make([]gin.H, 0, len(products))
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, p := range products {
+			barcode := models.InternalBarcode(shopID, p.ID)
+			if err := tx.Model(&models.Product{}).Where("id = ?", p.ID).Update("barcode", barcode).Error; err != nil {
+				return err
+			}
+			generated = append(generated, gin.H{"id": p.ID, "name": p.Name, "barcode": barcode})
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la génération des codes-barres"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Codes-barres générés",
+		"products": generated,
+		"count":    len(generated),
+	})
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// normalizeCode nettoie un code saisi ou scanné (espaces et retours ajoutés par les douchettes)
+func normalizeCode(code string) string {
+	return strings.TrimSpace(code)
+}
+
+// codeVariants retourne les formes équivalentes d'un code: un UPC-A (12) est un EAN-13 préfixé de 0
+func codeVariants(code string) []string {
+	variants := []string{code}
+	if models.IsValidGTIN(code) {
+		switch {
+		case len(code) == 12:
+			variants = append(variants, "0"+code)
+		case len(code) == 13 && code[0] == '0':
+			variants = append(variants, code[1:])
+		}
+	}
+	return variants
+}
+
+// errProductArchived - Produit retiré de la vente (archivé)
+var errProductArchived = errors.New("produit archivé: il ne peut plus être vendu")
+
+// findProductByCode cherche un produit du shop non archivé par code-barres ou SKU
+func findProductByCode(db *gorm.DB, shopID uint, code string) (models.Product, error) {
+	var product models.Product
+	err := db.Where("shop_id = ? AND (barcode IN ? OR sku = ?) AND archived_at IS NULL", shopID, codeVariants(code), code).
+		Scopes(productDetails).
+		First(&product).Error
+	return product, err
+}
+
+// checkProductCodes valide le SKU et le code-barres d'un produit et vérifie leur unicité dans le shop.
+// Retourne le code HTTP à renvoyer en cas d'erreur.
+func checkProductCodes(db *gorm.DB, shopID, productID uint, sku, barcode string) (int, error) {
+	if barcode != "" && !models.IsValidGTIN(barcode) {
+		return http.StatusBadRequest, errors.New("code-barres invalide: GTIN-8, UPC-A, EAN-13 ou GTIN-14 attendu")
+	}
+
+	var existing models.Product
+	if sku != "" {
+		if err := db.Where("shop_id = ? AND sku = ? AND id != ?", shopID, sku, productID).First(&existing).Error; err == nil {
+			return http.StatusConflict, errors.New("ce SKU est déjà utilisé par " + existing.Name)
+		}
+	}
+	if barcode != "" {
+		if err := db.Where("shop_id = ? AND barcode IN ? AND id != ?", shopID, codeVariants(barcode), productID).First(&existing).Error; err == nil {
+			return http.StatusConflict, errors.New("ce code-barres est déjà utilisé par " + existing.Name)
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// optionalCode convertit un code vide en NULL (plusieurs produits sans code dans un shop)
+func optionalCode(code string) *string {
+	if code == "" {
+		return nil
+	}
+	return &code
+}
\ No newline at end of file
@@ -0,0 +1,447 @@
+package handlers
+
+import (
+	"electronic-shop-api/database"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// STRUCTURES DE REQUÊTE
+// ========================================
+
+// Actions disponibles pour les opérations en masse
+const (
+	bulkSetPrice    = "set_price"
+	bulkAdjustPrice = "adjust_price"
+	bulkSetCategory = "set_category"
+	bulkArchive     = "archive"
+	bulkUnarchive   = "unarchive"
+	bulkAdjustStock = "adjust_stock"
+)
+
+// maxBulkProducts - Nombre maximal de produits modifiés en une opération
+const maxBulkProducts = 5000
+
+// errBulkRollback annule la transaction (aperçu ou ligne en erreur)
+var errBulkRollback = errors.New("opération annulée")
+
+type BulkProductInput struct {
+	// Sélection: liste d'IDs et/ou filtres de GET /products ({"category": "phones", "attr.ram.min": "8"})
+	ProductIDs []uint             `json:"product_ids"`
+	Filter     *map[string]string `json:"filter"`
+
+	Action string `json:"action" binding:"required,oneof=set_price adjust_price set_category archive unarchive adjust_stock"`
+
+	// Prix: champ concerné, mode (montant ou pourcentage) et valeur (négative pour une baisse)
+	Field string   `json:"field" binding:"omitempty,oneof=selling_price purchase_price"`
+	Mode  string   `json:"mode" binding:"omitempty,oneof=amount percent"`
+	Value *float64 `json:"value"`
+
+	// Catégorie cible (set_category)
+	CategoryID *uint  `json:"category_id"`
+	Category   string `json:"category"`
+
+	// Aperçu: calcule les changements sans rien enregistrer
+	Preview bool `json:"preview"`
+}
+
+// BulkChange - Valeur avant/après d'un champ modifié
+type BulkChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// BulkProductResult - Changements prévus ou appliqués pour un produit
+type BulkProductResult struct {
+	ID      uint                  `json:"id"`
+	Name    string                `json:"name"`
+	SKU     *string               `json:"sku"`
+	Changes map[string]BulkChange `json:"changes,omitempty"`
+	Error   string                `json:"error,omitempty"`
+
+	product    models.Product // État avant modification (historique des prix)
+	updates    map[string]interface{}
+	attributes []models.ProductAttribute // Fiche technique revalidée après changement de catégorie
+}
+
+// ========================================
+// BULK UPDATE PRODUCTS
+// ========================================
+
+// BulkUpdateProducts applique une action à une sélection de produits dans une seule transaction:
+// si un produit ne peut pas être modifié, rien n'est enregistré
+func BulkUpdateProducts(c *gin.Context) {
+	userID, shopID, role := middleware.GetUserFromContext(c)
+
+	var input BulkProductInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+	if len(input.ProductIDs) == 0 && input.Filter == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "product_ids ou filter requis"})
+		return
+	}
+	if status, err := input.validate(role); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	// Catégorie cible: doit exister (pas de création implicite en masse)
+	var category *models.Category
+	if input.Action == bulkSetCategory {
+		categories, err := loadShopCategories(db, shopID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des catégories"})
+			return
+		}
+		value := input.Category
+		if input.CategoryID != nil {
+			value = strconv.FormatUint(uint64(*input.CategoryID), 10)
+		}
+		found, ok := findCategory(categories, value)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "catégorie non trouvée"})
+			return
+		}
+		category = &found
+	}
+
+	now := time.Now()
+	var results, failures []BulkProductResult
+	status := http.StatusOK
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		products, code, err := selectBulkProducts(tx, shopID, input)
+		if err != nil {
+			status = code
+			return err
+		}
+
+		for _, product := range products {
+			result, err := input.plan(tx, shopID, product, category, now)
+			if err != nil {
+				result.Error = err.Error()
+				failures = append(failures, result)
+				continue
+			}
+			results = append(results, result)
+		}
+
+		if len(failures) > 0 || input.Preview {
+			return errBulkRollback
+		}
+
+		for _, result := range results {
+			if len(result.updates) == 0 {
+				continue
+			}
+			if err := recordPriceChanges(tx, result.product, result.updates, models.PriceHistory{Source: models.PriceSourceBulk, UserID: &userID}); err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Product{}).Where("id = ?", result.ID).Updates(result.updates).Error; err != nil {
+				return err
+			}
+			if result.attributes != nil {
+				if err := replaceProductAttributes(tx, result.ID, result.attributes); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBulkRollback) {
+		if status == http.StatusOK {
+			status = http.StatusInternalServerError
+			err = errors.New("Erreur lors de la mise à jour des produits")
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	summary := input.summary(results, failures, userID, now)
+	applied := err == nil
+
+	if applied {
+		log.Printf("📦 Opération en masse %s (shop %d, user %d): %d produit(s) modifié(s)",
+			input.Action, shopID, userID, summary["changed"])
+	}
+
+	response := gin.H{
+		"preview":  input.Preview,
+		"applied":  applied,
+		"summary":  summary,
+		"products": changedResults(results),
+	}
+	if len(failures) > 0 {
+		response["errors"] = failures
+		if !input.Preview {
+			response["error"] = "Aucune modification enregistrée: certains produits ne peuvent pas être modifiés"
+			c.JSON(http.StatusUnprocessableEntity, response)
+			return
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// validate contrôle les paramètres requis par l'action
+func (input *BulkProductInput) validate(role models.Role) (int, error) {
+	switch input.Action {
+	case bulkSetPrice, bulkAdjustPrice:
+		if input.Field == "" {
+			input.Field = "selling_price"
+		}
+		if input.Mode == "" {
+			input.Mode = "amount"
+		}
+		// Le prix d'achat n'est jamais visible des Admin
+		if input.Field == "purchase_price" && role != models.RoleSuperAdmin {
+			return http.StatusForbidden, errors.New("seul un SuperAdmin peut modifier le prix d'achat")
+		}
+		if input.Value == nil {
+			return http.StatusBadRequest, errors.New("value requis")
+		}
+		if input.Action == bulkSetPrice && *input.Value <= 0 {
+			return http.StatusBadRequest, errors.New("le prix doit être supérieur à 0")
+		}
+		if input.Action == bulkAdjustPrice && input.Mode == "percent" && *input.Value <= -100 {
+			return http.StatusBadRequest, errors.New("une baisse doit être inférieure à 100%")
+		}
+
+	case bulkSetCategory:
+		if input.CategoryID == nil && input.Category == "" {
+			return http.StatusBadRequest, errors.New("category_id ou category requis")
+		}
+
+	case bulkAdjustStock:
+		if input.Value == nil || *input.Value != math.Trunc(*input.Value) {
+			return http.StatusBadRequest, errors.New("value (entier) requis")
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// selectBulkProducts charge les produits visés (IDs et filtres combinés)
+func selectBulkProducts(tx *gorm.DB, shopID uint, input BulkProductInput) ([]models.Product, int, error) {
+	params := url.Values{}
+	if input.Filter != nil {
+		for key, value := range *input.Filter {
+			params.Set(key, value)
+		}
+	}
+	// Une liste d'IDs explicite inclut les produits archivés
+	if len(input.ProductIDs) > 0 && params.Get("archived") == "" {
+		params.Set("archived", "all")
+	}
+
+	query, status, err := filterProducts(tx, shopID, params)
+	if err != nil {
+		return nil, status, err
+	}
+	if len(input.ProductIDs) > 0 {
+		query = query.Where("id IN ?", input.ProductIDs)
+	}
+
+	var products []models.Product
+	if err := query.Order("id ASC").Limit(maxBulkProducts + 1).Find(&products).Error; err != nil {
+		return nil, http.StatusInternalServerError, errors.New("Erreur lors de la récupération des produits")
+	}
+	if len(products) > maxBulkProducts {
+		return nil, http.StatusBadRequest, fmt.Errorf("sélection trop large (%d produits maximum)", maxBulkProducts)
+	}
+	return products, http.StatusOK, nil
+}
+
+// plan calcule les modifications d'un produit selon les règles de CreateProduct/UpdateProduct
+func (input BulkProductInput) plan(tx *gorm.DB, shopID uint, p models.Product, category *models.Category, now time.Time) (BulkProductResult, error) {
+	result := BulkProductResult{
+		ID:      p.ID,
+		Name:    p.Name,
+		SKU:     p.SKU,
+		Changes: map[string]BulkChange{},
+		product: p,
+		updates: map[string]interface{}{},
+	}
+
+	switch input.Action {
+	case bulkSetPrice, bulkAdjustPrice:
+		current := p.SellingPrice
+		if input.Field == "purchase_price" {
+			current = p.PurchasePrice
+		}
+
+		price := models.MoneyFromFloat(*input.Value)
+		if input.Action == bulkAdjustPrice {
+			if input.Mode == "percent" {
+				price = current + current.Percent(*input.Value)
+			} else {
+				price = current + models.MoneyFromFloat(*input.Value)
+			}
+		}
+
+		if price <= 0 {
+			return result, errors.New("le nouveau prix doit être supérieur à 0")
+		}
+		selling, purchase := p.SellingPrice, p.PurchasePrice
+		if input.Field == "purchase_price" {
+			purchase = price
+		} else {
+			selling = price
+		}
+		cost, _, err := toShopCurrency(tx, shopID, purchase, p.PurchaseCurrency, now)
+		if err != nil {
+			return result, err
+		}
+		if selling < cost {
+			return result, errors.New("Le prix de vente doit être supérieur au prix d'achat")
+		}
+
+		if price != current {
+			result.Changes[input.Field] = BulkChange{From: current, To: price}
+			result.updates[input.Field] = price
+			if input.Field == "selling_price" {
+				result.updates["price_updated_at"] = now
+			}
+		}
+
+	case bulkSetCategory:
+		if p.CategoryID != nil && *p.CategoryID == category.ID {
+			break
+		}
+		result.Changes["category"] = BulkChange{From: p.Category, To: category.Name}
+		result.updates["category_id"] = category.ID
+		result.updates["category"] = category.Name
+
+		// Conserver les valeurs de fiche technique encore valides dans la nouvelle catégorie
+		values, err := keptAttributeValues(tx, shopID, p.ID, &category.ID)
+		if err != nil {
+			return result, err
+		}
+		if result.attributes, err = buildProductAttributes(tx, shopID, &category.ID, values, false); err != nil {
+			return result, err
+		}
+		if result.attributes == nil {
+			result.attributes = []models.ProductAttribute{}
+		}
+
+	case bulkArchive, bulkUnarchive:
+		archive := input.Action == bulkArchive
+		if (p.ArchivedAt != nil) == archive {
+			break
+		}
+		result.Changes["archived"] = BulkChange{From: !archive, To: archive}
+		if archive {
+			result.updates["archived_at"] = now
+		} else {
+			result.updates["archived_at"] = nil
+		}
+
+	case bulkAdjustStock:
+		delta := int(*input.Value)
+		stock := p.Stock + delta
+		if stock < 0 {
+			return result, fmt.Errorf("stock insuffisant (%d en stock)", p.Stock)
+		}
+		if stock < p.Reserved {
+			return result, fmt.Errorf("stock inférieur aux unités réservées (%d)", p.Reserved)
+		}
+		if delta != 0 {
+			result.Changes["stock"] = BulkChange{From: p.Stock, To: stock}
+			result.updates["stock"] = stock
+		}
+	}
+
+	return result, nil
+}
+
+// summary résume l'opération pour l'historique (paramètres, volumes et totaux avant/après)
+func (input BulkProductInput) summary(results, failures []BulkProductResult, userID uint, now time.Time) gin.H {
+	changed := 0
+	totals := map[string]*BulkChange{}
+	for _, result := range results {
+		if len(result.Changes) == 0 {
+			continue
+		}
+		changed++
+		for field, change := range result.Changes {
+			from, fromOK := toNumber(change.From)
+			to, toOK := toNumber(change.To)
+			if !fromOK || !toOK {
+				continue
+			}
+			if totals[field] == nil {
+				totals[field] = &BulkChange{From: 0.0, To: 0.0}
+			}
+			totals[field].From = math.Round((totals[field].From.(float64)+from)*100) / 100
+			totals[field].To = math.Round((totals[field].To.(float64)+to)*100) / 100
+		}
+	}
+
+	parameters := gin.H{}
+	switch input.Action {
+	case bulkSetPrice, bulkAdjustPrice:
+		parameters["field"], parameters["value"] = input.Field, *input.Value
+		if input.Action == bulkAdjustPrice {
+			parameters["mode"] = input.Mode
+		}
+	case bulkSetCategory:
+		parameters["category_id"], parameters["category"] = input.CategoryID, input.Category
+	case bulkAdjustStock:
+		parameters["value"] = int(*input.Value)
+	}
+
+	return gin.H{
+		"action":       input.Action,
+		"parameters":   parameters,
+		"selection":    gin.H{"product_ids": input.ProductIDs, "filter": input.Filter},
+		"matched":      len(results) + len(failures),
+		"changed":      changed,
+		"unchanged":    len(results) - changed,
+		"failed":       len(failures),
+		"totals":       totals,
+		"performed_by": userID,
+		"performed_at": now,
+	}
+}
+
+// changedResults ne garde que les produits réellement modifiés
+func changedResults(results []BulkProductResult) []BulkProductResult {
+	changed := make([]BulkProductResult, 0, len(results))
+	for _, result := range results {
+		if len(result.Changes) > 0 {
+			changed = append(changed, result)
+		}
+	}
+	return changed
+}
+
+// toNumber convertit une valeur de BulkChange en nombre (prix, stock)
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case models.Money:
+		return v.Float64(), true
+	}
+	return 0, false
+}
\ No newline at end of file
@@ -0,0 +1,520 @@
+package handlers
+
+import (
+	"electronic-shop-api/database"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// STRUCTURES DE REQUÊTE / RÉPONSE
+// ========================================
+
+type CreateCategoryInput struct {
+	Name     string `json:"name" binding:"required"`
+	Slug     string `json:"slug"`
+	ParentID *uint  `json:"parent_id"`
+	Position int    `json:"position"`
+	ImageURL string `json:"image_url"`
+}
+
+type UpdateCategoryInput struct {
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	ParentID *uint  `json:"parent_id"` // 0 = remonter à la racine
+	Position *int   `json:"position"`
+	ImageURL string `json:"image_url"`
+}
+
+// CategoryNode - Catégorie avec ses sous-catégories et son nombre de produits
+type CategoryNode struct {
+	ID           uint           `json:"id"`
+	Name         string         `json:"name"`
+	Slug         string         `json:"slug"`
+	ParentID     *uint          `json:"parent_id"`
+	Position     int            `json:"position"`
+	ImageURL     string         `json:"image_url"`
+	ProductCount int64          `json:"product_count"` // Sous-catégories incluses
+	Children     []CategoryNode `json:"children"`
+}
+
+// ========================================
+// GET CATEGORIES (Private)
+// ========================================
+
+func GetCategories(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+
+	categories, err := loadShopCategories(db, shopID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des catégories"})
+		return
+	}
+
+	// Liste à plat (optionnel)
+	if c.Query("flat") == "true" {
+		c.JSON(http.StatusOK, gin.H{"categories": categories, "count": len(categories)})
+		return
+	}
+
+	counts, err := countProductsByCategory(db, shopID, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du comptage des produits"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"categories": buildCategoryTree(categories, counts), "count": len(categories)})
+}
+
+// ========================================
+// CREATE CATEGORY (SuperAdmin)
+// ========================================
+
+func CreateCategory(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	var input CreateCategoryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	slug := models.Slugify(input.Slug)
+	if slug == "" {
+		slug = models.Slugify(input.Name)
+	}
+	if slug == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nom de catégorie invalide"})
+		return
+	}
+
+	// Vérifier le parent (MULTI-TENANT)
+	if input.ParentID != nil {
+		var parent models.Category
+		if err := db.Where("id = ? AND shop_id = ?", *input.ParentID, shopID).First(&parent).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Catégorie parente non trouvée"})
+			return
+		}
+	}
+
+	// Vérifier l'unicité du slug
+	var existing models.Category
+	if err := db.Where("shop_id = ? AND slug = ?", shopID, slug).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Une catégorie avec ce slug existe déjà", "category": existing})
+		return
+	}
+
+	category := models.Category{
+		Name:     strings.TrimSpace(input.Name),
+		Slug:     slug,
+		ParentID: input.ParentID,
+		Position: input.Position,
+		ImageURL: input.ImageURL,
+		ShopID:   shopID,
+	}
+
+	if err := db.Create(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création de la catégorie"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Catégorie créée", "category": category})
+}
+
+// ========================================
+// UPDATE CATEGORY (SuperAdmin)
+// ========================================
+
+func UpdateCategory(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	categoryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de catégorie invalide"})
+		return
+	}
+
+	var input UpdateCategoryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	var category models.Category
+
+	// MULTI-TENANT
+	if err := db.Where("id = ? AND shop_id = ?", categoryID, shopID).First(&category).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Catégorie non trouvée"})
+		return
+	}
+
+	nameChanged := false
+	if input.Name != "" && strings.TrimSpace(input.Name) != category.Name {
+		category.Name = strings.TrimSpace(input.Name)
+		nameChanged = true
+	}
+	if input.Slug != "" {
+		slug := models.Slugify(input.Slug)
+		var existing models.Category
+		if err := db.Where("shop_id = ? AND slug = ? AND id != ?", shopID, slug, category.ID).First(&existing).Error; err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Une catégorie avec ce slug existe déjà"})
+			return
+		}
+		category.Slug = slug
+	}
+	if input.Position != nil {
+		category.Position = *input.Position
+	}
+	if input.ImageURL != "" {
+		category.ImageURL = input.ImageURL
+	}
+
+	// Déplacement dans l'arbre: interdire les cycles
+	if input.ParentID != nil {
+		if *input.ParentID == 0 {
+			category.ParentID = nil
+		} else {
+			categories, err := loadShopCategories(db, shopID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour"})
+				return
+			}
+			if !containsCategory(categories, *input.ParentID) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Catégorie parente non trouvée"})
+				return
+			}
+			for _, id := range categoryDescendantIDs(categories, category.ID) {
+				if id == *input.ParentID {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Une catégorie ne peut pas être déplacée sous elle-même"})
+					return
+				}
+			}
+			category.ParentID = input.ParentID
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&category).Error; err != nil {
+			return err
+		}
+		// Garder le nom dénormalisé des produits à jour
+		if nameChanged {
+			return tx.Model(&models.Product{}).
+				Where("shop_id = ? AND category_id = ?", shopID, category.ID).
+				Update("category", category.Name).Error
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Catégorie mise à jour", "category": category})
+}
+
+// ========================================
+// DELETE CATEGORY (SuperAdmin)
+// ========================================
+
+func DeleteCategory(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	categoryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de catégorie invalide"})
+		return
+	}
+
+	db := database.GetDB()
+	var category models.Category
+
+	if err := db.Where("id = ? AND shop_id = ?", categoryID, shopID).First(&category).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Catégorie non trouvée"})
+		return
+	}
+
+	// Refuser si la catégorie a des sous-catégories
+	var childrenCount int64
+	db.Model(&models.Category{}).Where("shop_id = ? AND parent_id = ?", shopID, category.ID).Count(&childrenCount)
+	if childrenCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Supprimez ou déplacez d'abord les sous-catégories"})
+		return
+	}
+
+	// Les produits sont rattachés à la catégorie parente
+	parentName := ""
+	if category.ParentID != nil {
+		var parent models.Category
+		if err := db.First(&parent, *category.ParentID).Error; err == nil {
+			parentName = parent.Name
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Product{}).
+			Where("shop_id = ? AND category_id = ?", shopID, category.ID).
+			Updates(map[string]interface{}{"category_id": category.ParentID, "category": parentName}).Error; err != nil {
+			return err
+		}
+
+		// Supprimer les attributs propres à la catégorie et leurs valeurs
+		attributeIDs := tx.Model(&models.CategoryAttribute{}).Select("id").Where("category_id = ?", category.ID)
+		if err := tx.Where("attribute_id IN (?)", attributeIDs).Delete(&models.ProductAttribute{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("category_id = ?", category.ID).Delete(&models.CategoryAttribute{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&category).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Catégorie supprimée"})
+}
+
+// ========================================
+// PUBLIC: GET SHOP CATEGORIES (Guest)
+// ========================================
+
+func GetPublicCategories(c *gin.Context) {
+	shopID, err := strconv.ParseUint(c.Param("shopID"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de shop invalide"})
+		return
+	}
+
+	db := database.GetDB()
+
+	// Vérifier que le shop existe et est actif
+	var shop models.Shop
+	if err := db.Where("id = ? AND active = ?", shopID, true).First(&shop).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop non trouvé ou inactif"})
+		return
+	}
+
+	categories, err := loadShopCategories(db, shop.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des catégories"})
+		return
+	}
+
+	counts, err := countProductsByCategory(db, shop.ID, c.Query("in_stock") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du comptage des produits"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"shop": gin.H{
+			"id":   shop.ID,
+			"name": shop.Name,
+		},
+		"categories": buildCategoryTree(categories, counts),
+		"count":      len(categories),
+	})
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// loadShopCategories charge toutes les catégories d'un shop dans l'ordre d'affichage
+func loadShopCategories(db *gorm.DB, shopID uint) ([]models.Category, error) {
+	var categories []models.Category
+	err := db.Where("shop_id = ?", shopID).Order("position ASC, name ASC").Find(&categories).Error
+	return categories, err
+}
+
+// countProductsByCategory compte les produits actifs directement rattachés à chaque catégorie
+func countProductsByCategory(db *gorm.DB, shopID uint, inStockOnly bool) (map[uint]int64, error) {
+	type row struct {
+		CategoryID uint
+		Total      int64
+	}
+	var rows []row
+
+	query := db.Model(&models.Product{}).
+		Select("category_id, COUNT(*) as total").
+		Where("shop_id = ? AND category_id IS NOT NULL AND archived_at IS NULL", shopID)
+	if inStockOnly {
+		query = query.Where("stock - reserved > 0") // Unités réservées non vendables
+	}
+	if err := query.Group("category_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, r := range rows {
+		counts[r.CategoryID] = r.Total
+	}
+	return counts, nil
+}
+
+// buildCategoryTree construit l'arbre des catégories avec les totaux cumulés des descendants
+func buildCategoryTree(categories []models.Category, counts map[uint]int64) []CategoryNode {
+	children := map[uint][]models.Category{}
+	var roots []models.Category
+	for _, cat := range categories {
+		if cat.ParentID == nil {
+			roots = append(roots, cat)
+		} else {
+			children[*cat.ParentID] = append(children[*cat.ParentID], cat)
+		}
+	}
+
+	var build func(cat models.Category) CategoryNode
+	build = func(cat models.Category) CategoryNode {
+		node := CategoryNode{
+			ID:           cat.ID,
+			Name:         cat.Name,
+			Slug:         cat.Slug,
+			ParentID:     cat.ParentID,
+			Position:     cat.Position,
+			ImageURL:     cat.ImageURL,
+			ProductCount: counts[cat.ID],
+			Children:     []CategoryNode{},
+		}
+		for _, child := range children[cat.ID] {
+			childNode := build(child)
+			node.ProductCount += childNode.ProductCount
+			node.Children = append(node.Children, childNode)
+		}
+		return node
+	}
+
+	tree := make([]CategoryNode, 0, len(roots))
+	for _, root := range roots {
+		tree = append(tree, build(root))
+	}
+	return tree
+}
+
+// categoryDescendantIDs retourne l'ID de la catégorie et ceux de tous ses descendants
+func categoryDescendantIDs(categories []models.Category, rootID uint) []uint {
+	ids := []uint{rootID}
+	for i := 0; i < len(ids); i++ {
+		for _, cat := range categories {
+			if cat.ParentID != nil && *cat.ParentID == ids[i] {
+				ids = append(ids, cat.ID)
+			}
+		}
+	}
+	return ids
+}
+
+// categoryAncestorIDs retourne l'ID de la catégorie et ceux de ses ancêtres
+func categoryAncestorIDs(categories []models.Category, id uint) []uint {
+	byID := make(map[uint]models.Category, len(categories))
+	for _, cat := range categories {
+		byID[cat.ID] = cat
+	}
+
+	var ids []uint
+	current, ok := byID[id]
+	for ok && len(ids) <= len(categories) {
+		ids = append(ids, current.ID)
+		if current.ParentID == nil {
+			break
+		}
+		current, ok = byID[*current.ParentID]
+	}
+	return ids
+}
+
+func containsCategory(categories []models.Category, id uint) bool {
+	for _, cat := range categories {
+		if cat.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// findCategory cherche une catégorie par ID, slug ou nom
+func findCategory(categories []models.Category, value string) (models.Category, bool) {
+	if id, err := strconv.ParseUint(value, 10, 32); err == nil {
+		for _, cat := range categories {
+			if cat.ID == uint(id) {
+				return cat, true
+			}
+		}
+	}
+
+	key := models.CategoryKey(value)
+	for _, cat := range categories {
+		if cat.Slug == value || models.CategoryKey(cat.Name) == key {
+			return cat, true
+		}
+	}
+	return models.Category{}, false
+}
+
+// applyCategoryFilter filtre les produits sur une catégorie et toutes ses sous-catégories
+func applyCategoryFilter(db *gorm.DB, query *gorm.DB, shopID uint, value string) (*gorm.DB, error) {
+	categories, err := loadShopCategories(db, shopID)
+	if err != nil {
+		return nil, err
+	}
+
+	category, ok := findCategory(categories, value)
+	if !ok {
+		// Catégorie inconnue: aucun produit
+		return query.Where("1 = 0"), nil
+	}
+	return query.Where("category_id IN ?", categoryDescendantIDs(categories, category.ID)), nil
+}
+
+// resolveProductCategory détermine la catégorie d'un produit à partir de category_id ou,
+// pour compatibilité, du nom libre "category" (créée à la racine si inconnue)
+func resolveProductCategory(db *gorm.DB, shopID uint, categoryID *uint, name string) (*models.Category, error) {
+	if categoryID != nil && *categoryID != 0 {
+		var category models.Category
+		if err := db.Where("id = ? AND shop_id = ?", *categoryID, shopID).First(&category).Error; err != nil {
+			return nil, fmt.Errorf("catégorie non trouvée")
+		}
+		return &category, nil
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, nil
+	}
+
+	categories, err := loadShopCategories(db, shopID)
+	if err != nil {
+		return nil, err
+	}
+	if category, ok := findCategory(categories, name); ok {
+		return &category, nil
+	}
+
+	category := models.Category{
+		Name:   name,
+		Slug:   models.Slugify(name),
+		ShopID: shopID,
+	}
+	if category.Slug == "" {
+		return nil, errors.New("nom de catégorie invalide")
+	}
+	if err := db.Create(&category).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
\ No newline at end of file
@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"electronic-shop-api/database"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"electronic-shop-api/promotions"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errCouponUsedUp - Limite d'utilisation atteinte entre la vérification et l'enregistrement de la vente
+var errCouponUsedUp = errors.New("Code promo déjà utilisé")
+
+// ========================================
+// STRUCTURE DE REQUÊTE
+// ========================================
+
+// CouponInput - Création et modification (PUT: seuls les champs envoyés sont modifiés, null pour retirer)
+type CouponInput struct {
+	Code               string       `json:"code" binding:"required"`
+	Description        string       `json:"description"`
+	Type               string       `json:"type" binding:"required,oneof=percent fixed"`
+	Value              models.Money `json:"value" binding:"gt=0"`
+	ProductID          *uint        `json:"product_id"`
+	CategoryID         *uint        `json:"category_id"`
+	MinBasket          models.Money `json:"min_basket" binding:"gte=0"`
+	MaxUses            int          `json:"max_uses" binding:"gte=0"`
+	MaxUsesPerCustomer int          `json:"max_uses_per_customer" binding:"gte=0"`
+	ExpiresAt          string       `json:"expires_at"` // RFC3339, "YYYY-MM-DD HH:MM" ou "YYYY-MM-DD" (fuseau du shop)
+	Active             *bool        `json:"active"`     // true par défaut
+}
+
+// CouponUsage - Utilisation d'un code promo sur une période (rapport)
+type CouponUsage struct {
+	CouponID      uint         `json:"coupon_id"`
+	Code          string       `json:"code"`
+	Uses          int64        `json:"uses"`
+	Customers     int64        `json:"customers"` // Clients distincts identifiés
+	TotalDiscount models.Money `json:"total_discount"`
+	TotalSales    models.Money `json:"total_sales"` // Montant encaissé des ventes concernées
+}
+
+// ========================================
+// GET COUPONS
+// ========================================
+
+func GetCoupons(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	var coupons []models.Coupon
+	if err := database.GetDB().Where("shop_id = ?", shopID).Order("created_at DESC").Find(&coupons).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des codes promo"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"coupons": coupons, "count": len(coupons)})
+}
+
+// ========================================
+// CREATE COUPON (SuperAdmin)
+// ========================================
+
+func CreateCoupon(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	var input CouponInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	coupon := models.Coupon{ShopID: shopID, Active: true}
+	if err := applyCouponInput(db, shopID, &coupon, input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Vérifier l'unicité du code
+	var existing int64
+	db.Model(&models.Coupon{}).Where("shop_id = ? AND code = ?", shopID, coupon.Code).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ce code promo existe déjà"})
+		return
+	}
+
+	if err := db.Create(&coupon).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création du code promo"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Code promo créé", "coupon": coupon})
+}
+
+// ========================================
+// UPDATE COUPON (SuperAdmin)
+// ========================================
+
+func UpdateCoupon(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	coupon, ok := findShopCoupon(c, shopID)
+	if !ok {
+		return
+	}
+
+	// Partir des valeurs actuelles: le JSON reçu ne remplace que les champs envoyés
+	input := couponInput(coupon)
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	if err := applyCouponInput(db, shopID, &coupon, input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var existing int64
+	db.Model(&models.Coupon{}).Where("shop_id = ? AND code = ? AND id != ?", shopID, coupon.Code, coupon.ID).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ce code promo existe déjà"})
+		return
+	}
+
+	// Select explicite: les compteurs et limites à zéro doivent aussi être enregistrés
+	if err := db.Model(&coupon).Select("*").Omit("uses", "created_at").Updates(&coupon).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Code promo mis à jour", "coupon": coupon})
+}
+
+// ========================================
+// DELETE COUPON (SuperAdmin)
+// ========================================
+
+// DeleteCoupon supprime un code promo (les utilisations passées restent dans les rapports)
+func DeleteCoupon(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	coupon, ok := findShopCoupon(c, shopID)
+	if !ok {
+		return
+	}
+
+	if err := database.GetDB().Delete(&coupon).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Code promo supprimé"})
+}
+
+// ========================================
+// REDEMPTIONS & RAPPORT
+// ========================================
+
+// GetCouponRedemptions liste les utilisations d'un code promo
+func GetCouponRedemptions(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	coupon, ok := findShopCoupon(c, shopID)
+	if !ok {
+		return
+	}
+
+	var redemptions []models.CouponRedemption
+	if err := database.GetDB().Where("coupon_id = ? AND shop_id = ?", coupon.ID, shopID).
+		Order("created_at DESC").Find(&redemptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des utilisations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"coupon": coupon, "redemptions": redemptions, "count": len(redemptions)})
+}
+
+// GetCouponReport - Utilisation des codes promo sur une période (from / to, optionnels)
+func GetCouponReport(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+
+	query := db.Table("coupon_redemptions r").
+		Select(`r.coupon_id, MAX(r.code) as code, COUNT(*) as uses,
+			COUNT(DISTINCT NULLIF(r.customer_ref, '')) as customers,
+			SUM(r.discount) as total_discount, SUM(t.amount) as total_sales`).
+		Joins("JOIN transactions t ON t.id = r.transaction_id").
+		Where("r.shop_id = ?", shopID)
+
+	query, err := applyPeriod(c, db, shopID, query, "r.created_at")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	usage := []CouponUsage{}
+	if err := query.Group("r.coupon_id").Order("total_discount DESC").Scan(&usage).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du calcul du rapport"})
+		return
+	}
+
+	var totalDiscount models.Money
+	var totalUses int64
+	for _, u := range usage {
+		totalDiscount += u.TotalDiscount
+		totalUses += u.Uses
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"coupons":        usage,
+		"total_uses":     totalUses,
+		"total_discount": totalDiscount,
+		"from":           c.Query("from"),
+		"to":             c.Query("to"),
+	})
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// checkCoupon vérifie qu'un code promo est utilisable pour la vente évaluée
+// et retourne le code trouvé. La limite par client n'est contrôlée que si customer est renseigné.
+func checkCoupon(db *gorm.DB, shopID uint, code, customer string, pricing promotions.Result, now time.Time) (models.Coupon, error) {
+	var coupon models.Coupon
+	if err := db.Where("shop_id = ? AND code = ?", shopID, normalizeCouponCode(code)).First(&coupon).Error; err != nil {
+		return coupon, errors.New("Code promo invalide")
+	}
+
+	if !coupon.Active {
+		return coupon, errors.New("Code promo désactivé")
+	}
+	if coupon.ExpiresAt != nil && !now.Before(*coupon.ExpiresAt) {
+		return coupon, errors.New("Code promo expiré")
+	}
+	if coupon.MaxUses > 0 && coupon.Uses >= coupon.MaxUses {
+		return coupon, errors.New("Code promo déjà utilisé")
+	}
+	if !pricing.Line.Covers(coupon.ProductID, coupon.CategoryID) {
+		return coupon, errors.New("Code promo non valable pour ce produit")
+	}
+	if pricing.Subtotal < coupon.MinBasket {
+		return coupon, fmt.Errorf("Code promo valable à partir de %s d'achat", coupon.MinBasket)
+	}
+
+	if coupon.MaxUsesPerCustomer > 0 && customer != "" {
+		var used int64
+		db.Model(&models.CouponRedemption{}).
+			Where("coupon_id = ? AND customer_ref = ?", coupon.ID, customer).
+			Count(&used)
+		if used >= int64(coupon.MaxUsesPerCustomer) {
+			return coupon, errors.New("Code promo déjà utilisé par ce client")
+		}
+	}
+	return coupon, nil
+}
+
+// redeemCoupon enregistre l'utilisation du code promo dans la transaction de vente.
+// Le compteur est incrémenté sous condition: deux ventes simultanées ne dépassent pas max_uses.
+func redeemCoupon(tx *gorm.DB, coupon models.Coupon, transaction models.Transaction, customer string, userID uint) error {
+	result := tx.Model(&models.Coupon{}).
+		Where("id = ? AND (max_uses = 0 OR uses < max_uses)", coupon.ID).
+		Update("uses", gorm.Expr("uses + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errCouponUsedUp
+	}
+
+	return tx.Create(&models.CouponRedemption{
+		CouponID:      coupon.ID,
+		Code:          coupon.Code,
+		TransactionID: transaction.ID,
+		CustomerRef:   customer,
+		Discount:      transaction.CouponDiscount,
+		UserID:        userID,
+		ShopID:        transaction.ShopID,
+	}).Error
+}
+
+// releaseCoupon annule l'utilisation du code promo d'une vente supprimée
+func releaseCoupon(tx *gorm.DB, transactionID uint) error {
+	var redemptions []models.CouponRedemption
+	if err := tx.Where("transaction_id = ?", transactionID).Find(&redemptions).Error; err != nil {
+		return err
+	}
+	for _, r := range redemptions {
+		if err := tx.Model(&models.Coupon{}).Where("id = ? AND uses > 0", r.CouponID).
+			Update("uses", gorm.Expr("uses - 1")).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Where("transaction_id = ?", transactionID).Delete(&models.CouponRedemption{}).Error
+}
+
+// applyCouponInput valide l'entrée et la reporte sur le code promo
+func applyCouponInput(db *gorm.DB, shopID uint, coupon *models.Coupon, input CouponInput) error {
+	code := normalizeCouponCode(input.Code)
+	if code == "" || strings.ContainsFunc(code, func(r rune) bool {
+		return !(r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_')
+	}) {
+		return errors.New("Code invalide (lettres, chiffres, - et _ uniquement)")
+	}
+	if models.PromotionType(input.Type) == models.PromotionPercent && input.Value.Float64() > 100 {
+		return errors.New("value doit être un pourcentage entre 0 et 100")
+	}
+	if err := checkDiscountScope(db, shopID, input.ProductID, input.CategoryID); err != nil {
+		return err
+	}
+	expiresAt, err := parseOptionalDate(db, shopID, input.ExpiresAt, "expires_at")
+	if err != nil {
+		return err
+	}
+
+	coupon.Code = code
+	coupon.Description = input.Description
+	coupon.Type = models.PromotionType(input.Type)
+	coupon.Value = input.Value
+	coupon.ProductID = input.ProductID
+	coupon.CategoryID = input.CategoryID
+	coupon.MinBasket = input.MinBasket
+	coupon.MaxUses = input.MaxUses
+	coupon.MaxUsesPerCustomer = input.MaxUsesPerCustomer
+	coupon.ExpiresAt = expiresAt
+	if input.Active != nil {
+		coupon.Active = *input.Active
+	}
+	return nil
+}
+
+// couponInput reconstruit l'entrée correspondant à un code promo existant
+func couponInput(coupon models.Coupon) CouponInput {
+	input := CouponInput{
+		Code:               coupon.Code,
+		Description:        coupon.Description,
+		Type:               string(coupon.Type),
+		Value:              coupon.Value,
+		ProductID:          coupon.ProductID,
+		CategoryID:         coupon.CategoryID,
+		MinBasket:          coupon.MinBasket,
+		MaxUses:            coupon.MaxUses,
+		MaxUsesPerCustomer: coupon.MaxUsesPerCustomer,
+		Active:             &coupon.Active,
+	}
+	if coupon.ExpiresAt != nil {
+		input.ExpiresAt = coupon.ExpiresAt.Format(time.RFC3339)
+	}
+	return input
+}
+
+// findShopCoupon charge le code promo :id du shop (répond 400/404 sinon)
+func findShopCoupon(c *gin.Context, shopID uint) (models.Coupon, bool) {
+	var coupon models.Coupon
+
+	couponID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de code promo invalide"})
+		return coupon, false
+	}
+
+	// MULTI-TENANT
+	if err := database.GetDB().Where("id = ? AND shop_id = ?", couponID, shopID).First(&coupon).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Code promo non trouvé"})
+		return coupon, false
+	}
+	return coupon, true
+}
+
+// normalizeCouponCode - Codes insensibles à la casse et aux espaces
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// normalizeCustomerRef - Référence client comparable (téléphone ou email saisi à la caisse)
+func normalizeCustomerRef(customer string) string {
+	return strings.ToLower(strings.Join(strings.Fields(customer), ""))
+}
\ No newline at end of file
@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"electronic-shop-api/database"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// STRUCTURES DE REQUÊTE
+// ========================================
+
+type ExchangeRateInput struct {
+	Currency string  `json:"currency" binding:"required"`
+	Rate     float64 `json:"rate" binding:"required,gt=0"` // Valeur d'une unité de currency dans la devise du shop
+	Date     string  `json:"date"`                         // YYYY-MM-DD (défaut: aujourd'hui)
+}
+
+// rateDateLayout - Format des dates de taux de change
+const rateDateLayout = "2006-01-02"
+
+// maxRateFileSize - Taille maximale d'un fichier de taux importé
+const maxRateFileSize = 2 << 20
+
+// exchangeRateFields - En-têtes reconnus à l'import d'un fichier de taux
+var exchangeRateFields = map[string][]string{
+	"currency": {"currency", "devise", "code"},
+	"date":     {"date", "jour"},
+	"rate":     {"rate", "taux", "cours"},
+}
+
+// errMissingRate - Aucun taux de change connu pour la devise à la date demandée
+var errMissingRate = errors.New("taux de change manquant")
+
+// ========================================
+// GET EXCHANGE RATES
+// ========================================
+
+// GetExchangeRates liste les taux du shop (filtres optionnels: currency, from, to)
+func GetExchangeRates(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	query := database.GetDB().Where("shop_id = ?", shopID)
+	if currency := c.Query("currency"); currency != "" {
+		query = query.Where("currency = ?", strings.ToUpper(currency))
+	}
+	if from := c.Query("from"); from != "" {
+		query = query.Where("date >= ?", from)
+	}
+	if to := c.Query("to"); to != "" {
+		query = query.Where("date <= ?", to)
+	}
+
+	var rates []models.ExchangeRate
+	if err := query.Order("currency ASC, date DESC").Find(&rates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des taux de change"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"base_currency":  shopCurrency(database.GetDB(), shopID),
+		"exchange_rates": rates,
+		"count":          len(rates),
+	})
+}
+
+// ========================================
+// SET EXCHANGE RATE (SuperAdmin)
+// ========================================
+
+// SetExchangeRate enregistre le taux d'une devise pour une date (remplace le taux existant du même jour)
+func SetExchangeRate(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	var input ExchangeRateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	rate, err := saveExchangeRate(db, shopID, input.Currency, input.Date, input.Rate, models.RateSourceManual)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Taux de change enregistré", "exchange_rate": rate})
+}
+
+// ========================================
+// IMPORT EXCHANGE RATES (SuperAdmin)
+// ========================================
+
+// ImportExchangeRates importe un fichier CSV ou XLSX de taux (colonnes devise, date, taux).
+// Les lignes valides sont enregistrées, les autres signalées par numéro de ligne.
+func ImportExchangeRates(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Fichier manquant (champ file)"})
+		return
+	}
+	data, err := readUpload(file, maxRateFileSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Lecture impossible du fichier (%d Mo maximum)", maxRateFileSize>>20)})
+		return
+	}
+	records, err := readSpreadsheet(file.Filename, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(records) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le fichier doit contenir une ligne d'en-tête et au moins une ligne de données"})
+		return
+	}
+
+	// Colonnes d'après l'en-tête
+	columns := map[string]int{}
+	for i, header := range records[0] {
+		slug := models.Slugify(header)
+		for field, names := range exchangeRateFields {
+			for _, name := range names {
+				if _, taken := columns[field]; !taken && slug == name {
+					columns[field] = i
+				}
+			}
+		}
+	}
+	for _, field := range []string{"currency", "date", "rate"} {
+		if _, ok := columns[field]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Colonnes attendues: devise, date, taux", "columns": records[0]})
+			return
+		}
+	}
+
+	db := database.GetDB()
+	imported := 0
+	rowErrors := []models.ImportRowError{}
+	for i, record := range records[1:] {
+		cell := func(field string) string {
+			if index := columns[field]; index < len(record) {
+				return strings.TrimSpace(record[index])
+			}
+			return ""
+		}
+		if cell("currency") == "" && cell("date") == "" && cell("rate") == "" {
+			continue
+		}
+
+		line := i + 2
+		value, err := parseDecimal(cell("rate"))
+		if err != nil || value <= 0 {
+			rowErrors = append(rowErrors, models.ImportRowError{Row: line, Column: "rate", Error: "taux invalide: " + cell("rate")})
+			continue
+		}
+		if _, err := saveExchangeRate(db, shopID, cell("currency"), cell("date"), value, models.RateSourceImport); err != nil {
+			rowErrors = append(rowErrors, models.ImportRowError{Row: line, Error: err.Error()})
+			continue
+		}
+		imported++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Import des taux terminé",
+		"imported": imported,
+		"failed":   len(rowErrors),
+		"errors":   rowErrors,
+	})
+}
+
+// ========================================
+// DELETE EXCHANGE RATE (SuperAdmin)
+// ========================================
+
+func DeleteExchangeRate(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	rateID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de taux invalide"})
+		return
+	}
+
+	db := database.GetDB()
+
+	// MULTI-TENANT
+	var rate models.ExchangeRate
+	if err := db.Where("id = ? AND shop_id = ?", rateID, shopID).First(&rate).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Taux de change non trouvé"})
+		return
+	}
+
+	// Garder au moins un taux pour une devise encore utilisée par des produits
+	var others, products int64
+	db.Model(&models.ExchangeRate{}).Where("shop_id = ? AND currency = ? AND id != ?", shopID, rate.Currency, rate.ID).Count(&others)
+	db.Model(&models.Product{}).Where("shop_id = ? AND purchase_currency = ?", shopID, rate.Currency).Count(&products)
+	if others == 0 && products > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Dernier taux d'une devise utilisée par des produits", "products": products})
+		return
+	}
+
+	if err := db.Delete(&rate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Taux de change supprimé"})
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// saveExchangeRate valide et enregistre (ou remplace) le taux d'une devise pour un jour
+func saveExchangeRate(db *gorm.DB, shopID uint, currency, date string, value float64, source string) (models.ExchangeRate, error) {
+	currency, err := models.NormalizeCurrency(currency)
+	if err != nil {
+		return models.ExchangeRate{}, err
+	}
+	if currency == shopCurrency(db, shopID) {
+		return models.ExchangeRate{}, fmt.Errorf("%s est la devise du shop", currency)
+	}
+	if value <= 0 {
+		return models.ExchangeRate{}, errors.New("le taux doit être supérieur à 0")
+	}
+
+	// Jour du taux dans le fuseau du shop
+	loc := shopLocation(db, shopID)
+	day := time.Now().In(loc).Format(rateDateLayout)
+	if date != "" {
+		parsed, err := parseRateDate(date, loc)
+		if err != nil {
+			return models.ExchangeRate{}, err
+		}
+		day = parsed
+	}
+
+	rate := models.ExchangeRate{Currency: currency, Date: day, ShopID: shopID}
+	err = db.Where("shop_id = ? AND currency = ? AND date = ?", shopID, currency, day).
+		Assign(models.ExchangeRate{Rate: value, Source: source}).
+		FirstOrCreate(&rate).Error
+	return rate, err
+}
+
+// parseRateDate accepte YYYY-MM-DD, JJ/MM/AAAA ou un horodatage RFC3339 (jour dans le fuseau loc)
+func parseRateDate(value string, loc *time.Location) (string, error) {
+	for _, layout := range []string{rateDateLayout, "02/01/2006", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t.In(loc).Format(rateDateLayout), nil
+		}
+	}
+	return "", fmt.Errorf("date invalide: %s (YYYY-MM-DD ou JJ/MM/AAAA)", value)
+}
+
+// shopCurrency retourne la devise de base du shop
+func shopCurrency(db *gorm.DB, shopID uint) string {
+	var shop models.Shop
+	if err := db.Select("currency").First(&shop, shopID).Error; err != nil || shop.Currency == "" {
+		return models.DefaultCurrency
+	}
+	return shop.Currency
+}
+
+// exchangeRateAt retourne le taux d'une devise applicable à une date: le plus récent à ce jour
+// (fuseau du shop). La devise du shop (ou une devise vide) vaut 1.
+func exchangeRateAt(db *gorm.DB, shopID uint, currency string, at time.Time) (float64, error) {
+	if currency == "" || currency == shopCurrency(db, shopID) {
+		return 1, nil
+	}
+
+	day := at.In(shopLocation(db, shopID)).Format(rateDateLayout)
+	var rate models.ExchangeRate
+	err := db.Where("shop_id = ? AND currency = ? AND date <= ?", shopID, currency, day).
+		Order("date DESC").First(&rate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, fmt.Errorf("%w: %s au %s", errMissingRate, currency, day)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return rate.Rate, nil
+}
+
+// toShopCurrency convertit un montant dans la devise du shop au taux de la date donnée
+func toShopCurrency(db *gorm.DB, shopID uint, amount models.Money, currency string, at time.Time) (models.Money, float64, error) {
+	rate, err := exchangeRateAt(db, shopID, currency, at)
+	if err != nil {
+		return 0, 0, err
+	}
+	return amount.Convert(rate), rate, nil
+}
+
+// productCost retourne le prix d'achat du produit dans la devise du shop à la date donnée
+func productCost(db *gorm.DB, shopID uint, product models.Product, at time.Time) (models.Money, error) {
+	cost, _, err := toShopCurrency(db, shopID, product.PurchasePrice, product.PurchaseCurrency, at)
+	return cost, err
+}
+
+// purchaseCurrency valide la devise d'achat d'un produit: vide si c'est la devise du shop,
+// sinon un taux doit exister pour pouvoir calculer les coûts
+func purchaseCurrency(db *gorm.DB, shopID uint, code string) (string, error) {
+	if code == "" {
+		return "", nil
+	}
+	currency, err := models.NormalizeCurrency(code)
+	if err != nil {
+		return "", err
+	}
+	if currency == shopCurrency(db, shopID) {
+		return "", nil
+	}
+	if _, err := exchangeRateAt(db, shopID, currency, time.Now()); err != nil {
+		return "", fmt.Errorf("aucun taux de change %s: l'enregistrer via /exchange-rates", currency)
+	}
+	return currency, nil
+}
\ No newline at end of file
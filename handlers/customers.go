@@ -0,0 +1,566 @@
+package handlers
+
+import (
+	"electronic-shop-api/database"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// STRUCTURES DE REQUÊTE / RÉPONSE
+// ========================================
+
+type CustomerInput struct {
+	Name         string   `json:"name" binding:"required"`
+	Phone        string   `json:"phone"`
+	Email        string   `json:"email" binding:"omitempty,email"`
+	Notes        string   `json:"notes"`
+	Tags         []string `json:"tags"`
+	AcceptsSMS   bool     `json:"accepts_sms"`
+	AcceptsEmail bool     `json:"accepts_email"`
+
+	CreditLimit models.Money `json:"credit_limit" binding:"gte=0"` // SuperAdmin uniquement
+}
+
+type UpdateCustomerInput struct {
+	Name         *string   `json:"name"`
+	Phone        *string   `json:"phone"`
+	Email        *string   `json:"email" binding:"omitempty,email"`
+	Notes        *string   `json:"notes"`
+	Tags         *[]string `json:"tags"`
+	AcceptsSMS   *bool     `json:"accepts_sms"`
+	AcceptsEmail *bool     `json:"accepts_email"`
+
+	CreditLimit *models.Money `json:"credit_limit" binding:"omitempty,gte=0"` // SuperAdmin uniquement
+}
+
+type MergeCustomerInput struct {
+	CustomerID uint `json:"customer_id" binding:"required"` // Doublon fusionné puis supprimé
+}
+
+// CustomerStats - Historique d'achat d'un client
+type CustomerStats struct {
+	Purchases       int64        `json:"purchases"`
+	UnitsBought     int64        `json:"units_bought"`
+	TotalSpent      models.Money `json:"total_spent"` // Net des remboursements
+	TotalRefunded   models.Money `json:"total_refunded"`
+	FirstPurchaseAt *time.Time   `json:"first_purchase_at"`
+	LastPurchaseAt  *time.Time   `json:"last_purchase_at"`
+	Balance         models.Money `json:"balance"` // Reste dû des ventes à crédit
+	LoyaltyPoints   int          `json:"loyalty_points"`
+	StoreCredit     models.Money `json:"store_credit"` // Solde des cartes cadeaux et avoirs valides
+}
+
+// defaultCustomerLimit - Clients retournés par défaut par la recherche (maximum: maxCustomerLimit)
+const (
+	defaultCustomerLimit = 50
+	maxCustomerLimit     = 200
+)
+
+// ========================================
+// GET CUSTOMERS
+// ========================================
+
+// GetCustomers liste les clients; q cherche dans le nom, le téléphone et l'email (recherche en caisse)
+func GetCustomers(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	// MULTI-TENANT
+	query := database.GetDB().Where("shop_id = ?", shopID)
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		like := "%" + strings.ToLower(q) + "%"
+		conditions := "LOWER(name) LIKE ? OR LOWER(email) LIKE ?"
+		args := []interface{}{like, like}
+		if digits := strings.TrimPrefix(models.NormalizePhone(q), "+"); len(digits) >= 3 {
+			conditions += " OR phone LIKE ?"
+			args = append(args, "%"+strings.TrimPrefix(digits, "0")+"%")
+		}
+		query = query.Where(conditions, args...)
+	}
+	if tag := strings.TrimSpace(c.Query("tag")); tag != "" {
+		query = query.Where("tags LIKE ?", `%"`+normalizeTag(tag)+`"%`)
+	}
+	if c.Query("accepts_sms") == "true" {
+		query = query.Where("accepts_sms = ?", true)
+	}
+	if c.Query("accepts_email") == "true" {
+		query = query.Where("accepts_email = ?", true)
+	}
+
+	limit := defaultCustomerLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxCustomerLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit invalide (1 à 200)"})
+			return
+		}
+		limit = parsed
+	}
+
+	var customers []models.Customer
+	if err := query.Order("name ASC").Limit(limit).Find(&customers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des clients"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"customers": customers, "count": len(customers)})
+}
+
+// GetCustomer retourne le client et ses statistiques d'achat
+func GetCustomer(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	customer, ok := findShopCustomer(c, shopID)
+	if !ok {
+		return
+	}
+
+	stats, err := customerStats(database.GetDB(), shopID, customer.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du calcul de l'historique"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"customer": customer, "stats": stats})
+}
+
+// GetCustomerTransactions - Historique d'achat: ventes et remboursements du client (période optionnelle)
+func GetCustomerTransactions(c *gin.Context) {
+	_, shopID, role := middleware.GetUserFromContext(c)
+
+	customer, ok := findShopCustomer(c, shopID)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	query := db.Where("shop_id = ? AND customer_id = ?", shopID, customer.ID)
+	query, err := applyPeriod(c, db, shopID, query, "created_at")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var transactions []models.Transaction
+	if err := query.Preload("Product").Preload("Payments").Order("created_at DESC").Find(&transactions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des transactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"customer": customer, "transactions": transactionsForRole(role, transactions), "count": len(transactions)})
+}
+
+// ========================================
+// CREATE / UPDATE CUSTOMER
+// ========================================
+
+// CreateCustomer crée un client; un numéro déjà connu renvoie le client existant (409)
+func CreateCustomer(c *gin.Context) {
+	_, shopID, role := middleware.GetUserFromContext(c)
+
+	var input CustomerInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+	if input.CreditLimit > 0 && role != models.RoleSuperAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": errCreditLimitRole.Error()})
+		return
+	}
+
+	customer := models.Customer{
+		Name:         strings.TrimSpace(input.Name),
+		Email:        strings.ToLower(strings.TrimSpace(input.Email)),
+		Notes:        strings.TrimSpace(input.Notes),
+		Tags:         normalizeTags(input.Tags),
+		AcceptsSMS:   input.AcceptsSMS,
+		AcceptsEmail: input.AcceptsEmail,
+		CreditLimit:  input.CreditLimit,
+		ShopID:       shopID,
+	}
+	if customer.AcceptsSMS || customer.AcceptsEmail {
+		now := time.Now()
+		customer.ConsentAt = &now
+	}
+
+	db := database.GetDB()
+	if err := setCustomerPhone(db, &customer, input.Phone); err != nil {
+		respondCustomerConflict(c, err)
+		return
+	}
+
+	if err := db.Create(&customer).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création du client"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Client créé", "customer": customer})
+}
+
+func UpdateCustomer(c *gin.Context) {
+	_, shopID, role := middleware.GetUserFromContext(c)
+
+	customer, ok := findShopCustomer(c, shopID)
+	if !ok {
+		return
+	}
+
+	var input UpdateCustomerInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	if input.Name != nil && strings.TrimSpace(*input.Name) != "" {
+		customer.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Phone != nil {
+		if err := setCustomerPhone(db, &customer, *input.Phone); err != nil {
+			respondCustomerConflict(c, err)
+			return
+		}
+	}
+	if input.Email != nil {
+		customer.Email = strings.ToLower(strings.TrimSpace(*input.Email))
+	}
+	if input.Notes != nil {
+		customer.Notes = strings.TrimSpace(*input.Notes)
+	}
+	if input.Tags != nil {
+		customer.Tags = normalizeTags(*input.Tags)
+	}
+	if input.CreditLimit != nil && *input.CreditLimit != customer.CreditLimit {
+		if role != models.RoleSuperAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": errCreditLimitRole.Error()})
+			return
+		}
+		customer.CreditLimit = *input.CreditLimit
+	}
+
+	// Consentements: date de la dernière modification conservée
+	consentChanged := (input.AcceptsSMS != nil && *input.AcceptsSMS != customer.AcceptsSMS) ||
+		(input.AcceptsEmail != nil && *input.AcceptsEmail != customer.AcceptsEmail)
+	if input.AcceptsSMS != nil {
+		customer.AcceptsSMS = *input.AcceptsSMS
+	}
+	if input.AcceptsEmail != nil {
+		customer.AcceptsEmail = *input.AcceptsEmail
+	}
+	if consentChanged {
+		now := time.Now()
+		customer.ConsentAt = &now
+	}
+
+	if err := db.Save(&customer).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Client mis à jour", "customer": customer})
+}
+
+// ========================================
+// MERGE / DELETE CUSTOMER (SuperAdmin)
+// ========================================
+
+// MergeCustomer fusionne un doublon dans le client :id: transactions rattachées,
+// étiquettes réunies, champs vides complétés, consentements les plus récents conservés
+func MergeCustomer(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	customer, ok := findShopCustomer(c, shopID)
+	if !ok {
+		return
+	}
+
+	var input MergeCustomerInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+	if input.CustomerID == customer.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Impossible de fusionner un client avec lui-même"})
+		return
+	}
+
+	db := database.GetDB()
+	var duplicate models.Customer
+	if err := db.Where("id = ? AND shop_id = ?", input.CustomerID, shopID).First(&duplicate).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client à fusionner non trouvé"})
+		return
+	}
+
+	if customer.Phone == "" {
+		customer.Phone, customer.PhoneKey = duplicate.Phone, duplicate.PhoneKey
+	}
+	if customer.Email == "" {
+		customer.Email = duplicate.Email
+	}
+	if duplicate.Notes != "" {
+		customer.Notes = strings.TrimSpace(customer.Notes + "\n" + duplicate.Notes)
+	}
+	customer.Tags = normalizeTags(append(customer.Tags, duplicate.Tags...))
+	if customer.CreditLimit == 0 {
+		customer.CreditLimit = duplicate.CreditLimit
+	}
+	if duplicate.ConsentAt != nil && (customer.ConsentAt == nil || duplicate.ConsentAt.After(*customer.ConsentAt)) {
+		customer.AcceptsSMS, customer.AcceptsEmail, customer.ConsentAt = duplicate.AcceptsSMS, duplicate.AcceptsEmail, duplicate.ConsentAt
+	}
+
+	var moved int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Doublon supprimé d'abord: son numéro est libéré pour le client conservé
+		if err := tx.Delete(&duplicate).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&models.Transaction{}).Where("shop_id = ? AND customer_id = ?", shopID, duplicate.ID).
+			Update("customer_id", customer.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		moved = result.RowsAffected
+		if err := tx.Model(&models.Layaway{}).Where("shop_id = ? AND customer_id = ?", shopID, duplicate.ID).
+			Update("customer_id", customer.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.LoyaltyEntry{}).Where("shop_id = ? AND customer_id = ?", shopID, duplicate.ID).
+			Update("customer_id", customer.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.GiftCard{}).Where("shop_id = ? AND customer_id = ?", shopID, duplicate.ID).
+			Update("customer_id", customer.ID).Error; err != nil {
+			return err
+		}
+		return tx.Save(&customer).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la fusion des clients"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Clients fusionnés", "customer": customer, "transactions_moved": moved})
+}
+
+// DeleteCustomer supprime le client; ses transactions et cartes cadeaux sont conservées sans
+// client, ses points fidélité sont perdus.
+// Un client avec un reste dû ou des ventes à tempérament ne peut pas être supprimé.
+func DeleteCustomer(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	customer, ok := findShopCustomer(c, shopID)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	if balance := customerBalance(db, shopID, customer.ID); balance > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ce client a des ventes à crédit non réglées", "balance": balance})
+		return
+	}
+	var layaways int64
+	db.Model(&models.Layaway{}).Where("shop_id = ? AND customer_id = ?", shopID, customer.ID).Count(&layaways)
+	if layaways > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ce client a des ventes à tempérament", "layaways": layaways})
+		return
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Transaction{}).Where("shop_id = ? AND customer_id = ?", shopID, customer.ID).
+			Update("customer_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("debit_id IN (SELECT id FROM loyalty_entries WHERE shop_id = ? AND customer_id = ?)", shopID, customer.ID).
+			Delete(&models.LoyaltyAllocation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("shop_id = ? AND customer_id = ?", shopID, customer.ID).Delete(&models.LoyaltyEntry{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.GiftCard{}).Where("shop_id = ? AND customer_id = ?", shopID, customer.ID).
+			Update("customer_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&customer).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Client supprimé"})
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// errCreditLimitRole - La limite de crédit engage la trésorerie du shop
+var errCreditLimitRole = errors.New("seul un SuperAdmin peut modifier la limite de crédit")
+
+// errDuplicateCustomer - Numéro de téléphone déjà attribué à un autre client
+type errDuplicateCustomer struct {
+	existing models.Customer
+}
+
+func (e errDuplicateCustomer) Error() string {
+	return "Un client existe déjà avec ce numéro de téléphone"
+}
+
+// setCustomerPhone renseigne le téléphone et sa clé de dédoublonnage; un numéro
+// déjà attribué à un autre client du shop renvoie errDuplicateCustomer
+func setCustomerPhone(db *gorm.DB, customer *models.Customer, phone string) error {
+	phone = strings.TrimSpace(phone)
+	key := models.PhoneKey(phone)
+	if phone != "" && key == "" {
+		return errors.New("numéro de téléphone invalide")
+	}
+
+	customer.Phone, customer.PhoneKey = models.NormalizePhone(phone), nil
+	if key == "" {
+		return nil
+	}
+
+	var existing models.Customer
+	if err := db.Where("shop_id = ? AND phone_key = ? AND id <> ?", customer.ShopID, key, customer.ID).First(&existing).Error; err == nil {
+		return errDuplicateCustomer{existing: existing}
+	}
+	customer.PhoneKey = &key
+	return nil
+}
+
+// respondCustomerConflict répond 409 (doublon, avec le client existant) ou 400
+func respondCustomerConflict(c *gin.Context, err error) {
+	var duplicate errDuplicateCustomer
+	if errors.As(err, &duplicate) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "customer": duplicate.existing})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// resolveCustomer retrouve le client d'une vente: par customer_id, sinon par la référence
+// saisie en caisse (téléphone ou email). Une référence inconnue ne rattache aucun client.
+func resolveCustomer(db *gorm.DB, shopID uint, customerID *uint, ref string) (*models.Customer, error) {
+	var customer models.Customer
+	if customerID != nil && *customerID != 0 {
+		if err := db.Where("id = ? AND shop_id = ?", *customerID, shopID).First(&customer).Error; err != nil {
+			return nil, errors.New("client non trouvé")
+		}
+		return &customer, nil
+	}
+
+	ref = strings.TrimSpace(ref)
+	switch {
+	case ref == "":
+		return nil, nil
+	case strings.Contains(ref, "@"):
+		if err := db.Where("shop_id = ? AND email = ?", shopID, strings.ToLower(ref)).First(&customer).Error; err != nil {
+			return nil, nil
+		}
+	default:
+		key := models.PhoneKey(ref)
+		if key == "" {
+			return nil, nil
+		}
+		if err := db.Where("shop_id = ? AND phone_key = ?", shopID, key).First(&customer).Error; err != nil {
+			return nil, nil
+		}
+	}
+	return &customer, nil
+}
+
+// customerRef - Référence client des codes promo (téléphone, sinon email)
+func customerRef(customer models.Customer) string {
+	if customer.Phone != "" {
+		return normalizeCustomerRef(customer.Phone)
+	}
+	return normalizeCustomerRef(customer.Email)
+}
+
+// customerStats calcule l'historique d'achat d'un client
+func customerStats(db *gorm.DB, shopID, customerID uint) (CustomerStats, error) {
+	var stats CustomerStats
+	var rows []struct {
+		Type     models.TransactionType
+		Count    int64
+		Quantity int64
+		Amount   models.Money
+	}
+	if err := db.Model(&models.Transaction{}).
+		Select("type, COUNT(*) as count, COALESCE(SUM(quantity), 0) as quantity, COALESCE(SUM(amount), 0) as amount").
+		Where("shop_id = ? AND customer_id = ? AND type IN ?", shopID, customerID, []models.TransactionType{models.TypeSale, models.TypeRefund}).
+		Group("type").
+		Scan(&rows).Error; err != nil {
+		return stats, err
+	}
+	var refundedUnits int64
+	for _, row := range rows {
+		if row.Type == models.TypeSale {
+			stats.Purchases, stats.UnitsBought, stats.TotalSpent = row.Count, row.Quantity, row.Amount
+		} else {
+			refundedUnits, stats.TotalRefunded = row.Quantity, row.Amount
+		}
+	}
+	stats.UnitsBought -= refundedUnits
+	stats.TotalSpent -= stats.TotalRefunded
+
+	var first, last models.Transaction
+	sales := db.Where("shop_id = ? AND customer_id = ? AND type = ?", shopID, customerID, models.TypeSale)
+	if sales.Session(&gorm.Session{}).Order("created_at ASC").First(&first).Error == nil {
+		stats.FirstPurchaseAt = &first.CreatedAt
+	}
+	if sales.Session(&gorm.Session{}).Order("created_at DESC").First(&last).Error == nil {
+		stats.LastPurchaseAt = &last.CreatedAt
+	}
+	stats.Balance = customerBalance(db, shopID, customerID)
+	stats.LoyaltyPoints = loyaltyBalance(db, shopID, customerID)
+	stats.StoreCredit = giftCardBalance(db, shopID, customerID)
+	return stats, nil
+}
+
+// normalizeTags - Étiquettes en minuscules, sans doublon, triées
+func normalizeTags(tags []string) []string {
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.Join(strings.Fields(tag), " "))
+}
+
+// findShopCustomer charge le client :id du shop ou répond 400/404
+func findShopCustomer(c *gin.Context, shopID uint) (models.Customer, bool) {
+	var customer models.Customer
+
+	customerID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de client invalide"})
+		return customer, false
+	}
+
+	// MULTI-TENANT
+	if err := database.GetDB().Where("id = ? AND shop_id = ?", customerID, shopID).First(&customer).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client non trouvé"})
+		return customer, false
+	}
+	return customer, true
+}
\ No newline at end of file
@@ -5,56 +5,220 @@ import (
 	"electronic-shop-api/middleware"
 	"electronic-shop-api/models"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // ========================================
 // GET DASHBOARD (SuperAdmin uniquement)
 // ========================================
 
+// GetDashboard - Indicateurs sur une période (period=today|week|month|year ou from / to, toutes dates par défaut).
+// compare=true ajoute la période précédente équivalente et la variation de chaque indicateur.
 func GetDashboard(c *gin.Context) {
 	_, shopID, _ := middleware.GetUserFromContext(c)
 	db := database.GetDB()
+	now := time.Now()
+
+	period, err := parsePeriod(c, db, shopID, now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dashboard := computeDashboard(db, shopID, period)
+	response := gin.H{"dashboard": dashboard, "period": period}
+
+	if c.Query("compare") == "true" {
+		previous, err := period.previous(now)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		previousDashboard := computeDashboard(db, shopID, previous)
+		response["comparison"] = DashboardComparison{
+			Period:    previous,
+			Dashboard: previousDashboard,
+			Changes:   dashboardChanges(dashboard, previousDashboard),
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DashboardComparison - Indicateurs de la période précédente et variation en % de la période demandée
+type DashboardComparison struct {
+	Period    reportPeriod        `json:"period"`
+	Dashboard Dashboard           `json:"dashboard"`
+	Changes   map[string]*float64 `json:"changes"` // null: indicateur nul sur la période précédente
+}
+
+// TopProduct - Produit le plus vendu du dashboard
+type TopProduct struct {
+	ProductID   uint         `json:"product_id"`
+	ProductName string       `json:"product_name"`
+	TotalSold   int          `json:"total_sold"`
+	TotalAmount models.Money `json:"total_amount"`
+}
+
+// TransactionCounts - Nombre de transactions par type
+type TransactionCounts struct {
+	Sales       int64 `json:"sales"`
+	Expenses    int64 `json:"expenses"`
+	Withdrawals int64 `json:"withdrawals"`
+	Refunds     int64 `json:"refunds"`
+	Total       int64 `json:"total"`
+}
+
+// PromotionUsage - Utilisation d'une promotion et total des remises accordées
+type PromotionUsage struct {
+	PromotionID   uint         `json:"promotion_id"`
+	Name          string       `json:"name"`
+	Uses          int64        `json:"uses"`
+	TotalDiscount models.Money `json:"total_discount"`
+}
+
+// Dashboard - Indicateurs du shop (réponse de /reports/dashboard et de son export)
+type Dashboard struct {
+	Currency         string            `json:"currency"`    // Devise des montants
+	TotalSales       models.Money      `json:"total_sales"` // Facturé, taxes comprises (ventes à crédit comprises)
+	NetSales         models.Money      `json:"net_sales"`   // Hors taxes
+	TotalDiscounts   models.Money      `json:"total_discounts"`
+	TotalRefunds     models.Money      `json:"total_refunds"`     // Remboursé, taxes comprises
+	RevenueInvoiced  models.Money      `json:"revenue_invoiced"`  // Ventes facturées nettes des remboursements (TTC)
+	CancellationFees models.Money      `json:"cancellation_fees"` // Frais d'annulation retenus sur les ventes à tempérament
+	CashReceived     models.Money      `json:"cash_received"`     // Règlements reçus sur la période (crédits compris), nets des remboursements
+	NonCashTenders   models.Money      `json:"non_cash_tenders"`  // Règlements en avoirs, cartes cadeaux et points fidélité, nets des remboursements
+	Receivables      models.Money      `json:"receivables"`       // Reste dû des ventes à crédit (à ce jour)
+	TaxCollected     models.Money      `json:"tax_collected"`
+	TaxDeductible    models.Money      `json:"tax_deductible"`
+	TotalExpenses    models.Money      `json:"total_expenses"`
+	TotalWithdrawals models.Money      `json:"total_withdrawals"`
+	CostOfGoodsSold  models.Money      `json:"cost_of_goods_sold"`
+	NetProfit        models.Money      `json:"net_profit"`
+	GrossMargin      models.Money      `json:"gross_margin"`
+	TotalProducts    int64             `json:"total_products"`
+	LowStockProducts int64             `json:"low_stock_products"`
+	StockValue       models.Money      `json:"stock_value"`
+	MissingRates     []string          `json:"missing_rates,omitempty"` // Devises sans taux: exclues de stock_value
+	Transactions     TransactionCounts `json:"transactions"`
+	TopProducts      []TopProduct      `json:"top_products"`
+	Promotions       []PromotionUsage  `json:"promotions"`
+}
+
+// computeDashboard calcule les indicateurs du shop. Les indicateurs issus des transactions
+// portent sur la période; ceux du stock (produits, valeur) et les créances sont ceux du moment.
+func computeDashboard(db *gorm.DB, shopID uint, period reportPeriod) Dashboard {
+	var shop models.Shop
+	db.Select("currency").First(&shop, shopID)
+
+	// Transactions d'un type sur la période
+	transactions := func(transactionType models.TransactionType) *gorm.DB {
+		return period.apply(db.Model(&models.Transaction{}).Where("shop_id = ? AND type = ?", shopID, transactionType), "created_at")
+	}
 
 	// 1. Total des ventes
-	var totalSales float64
-	db.Model(&models.Transaction{}).
-		Where("shop_id = ? AND type = ?", shopID, models.TypeSale).
+	var totalSales models.Money
+	transactions(models.TypeSale).
 		Select("COALESCE(SUM(amount), 0)").
 		Scan(&totalSales)
 
-	// 2. Total des dépenses
-	var totalExpenses float64
+	// 1b. Total des remises accordées (promotions)
+	var totalDiscounts models.Money
+	transactions(models.TypeSale).
+		Select("COALESCE(SUM(discount), 0)").
+		Scan(&totalDiscounts)
+
+	// 1c. Remboursements (taxe reversée et coût des articles retournés)
+	var refunds struct {
+		Amount    models.Money
+		TaxAmount models.Money
+		Cost      models.Money
+	}
+	transactions(models.TypeRefund).
+		Select("COALESCE(SUM(amount), 0) as amount, COALESCE(SUM(tax_amount), 0) as tax_amount, COALESCE(SUM(cost), 0) as cost").
+		Scan(&refunds)
+
+	// 1d. Taxes collectées (ventes, nettes des remboursements) et déductibles (dépenses)
+	var taxCollected, taxDeductible models.Money
+	transactions(models.TypeSale).
+		Select("COALESCE(SUM(tax_amount), 0)").
+		Scan(&taxCollected)
+	taxCollected -= refunds.TaxAmount
+	transactions(models.TypeExpense).
+		Select("COALESCE(SUM(tax_amount), 0)").
+		Scan(&taxDeductible)
+
+	// 1e. Encaissements: règlements des ventes reçus sur la période (une vente à crédit est
+	// encaissée au fil de ses règlements), moins les remboursements payés. Les avoirs et cartes
+	// cadeaux (encaissées à leur vente) et les points fidélité sont comptés à part.
+	var received []struct {
+		Type   models.TransactionType
+		Method models.PaymentKind
+		Amount models.Money
+	}
+	receivedQuery := shopPayments(db, shopID).
+		Where(paymentType+" IN ?", []models.TransactionType{models.TypeSale, models.TypeRefund})
+	period.apply(receivedQuery, "p.created_at").
+		Select(paymentType + " as type, p.method, COALESCE(SUM(ABS(p.amount)), 0) as amount").
+		Group(paymentType + ", p.method").
+		Scan(&received)
+	var cashReceived, nonCashTenders models.Money
+	for _, row := range received {
+		total := &cashReceived
+		if row.Method.NonCash() {
+			total = &nonCashTenders
+		}
+		if row.Type == models.TypeRefund {
+			*total -= row.Amount
+		} else {
+			*total += row.Amount
+		}
+	}
+
+	// 1f. Créances: reste dû des ventes à crédit (à ce jour)
+	var receivables models.Money
 	db.Model(&models.Transaction{}).
-		Where("shop_id = ? AND type = ?", shopID, models.TypeExpense).
+		Where("shop_id = ? AND type = ? AND amount_due > 0", shopID, models.TypeSale).
+		Select("COALESCE(SUM(amount_due), 0)").
+		Scan(&receivables)
+
+	// 2. Total des dépenses
+	var totalExpenses models.Money
+	transactions(models.TypeExpense).
 		Select("COALESCE(SUM(amount), 0)").
 		Scan(&totalExpenses)
 
 	// 3. Total des retraits
-	var totalWithdrawals float64
-	db.Model(&models.Transaction{}).
-		Where("shop_id = ? AND type = ?", shopID, models.TypeWithdrawal).
+	var totalWithdrawals models.Money
+	transactions(models.TypeWithdrawal).
 		Select("COALESCE(SUM(amount), 0)").
 		Scan(&totalWithdrawals)
 
-	// 4. Coût des produits vendus
-	var costOfGoodsSold float64
-	db.Raw(`
-		SELECT COALESCE(SUM(p.purchase_price * t.quantity), 0)
-		FROM transactions t
-		JOIN products p ON t.product_id = p.id
-		WHERE t.shop_id = ? AND t.type = ?
-	`, shopID, models.TypeSale).Scan(&costOfGoodsSold)
+	// 4. Coût des produits vendus (enregistré à la vente, converti au taux du jour de la vente),
+	// net des articles retournés
+	var costOfGoodsSold models.Money
+	transactions(models.TypeSale).
+		Select("COALESCE(SUM(cost), 0)").
+		Scan(&costOfGoodsSold)
+	costOfGoodsSold -= refunds.Cost
 
-	// 5. Profit net
-	netProfit := totalSales - costOfGoodsSold - totalExpenses
+	// 4b. Frais d'annulation retenus sur les ventes à tempérament (produit sans coût d'achat)
+	var cancellationFeesTotal models.Money
+	cancellationFees(db, shopID, period).
+		Select("COALESCE(SUM(l.cancellation_fee), 0)").
+		Scan(&cancellationFeesTotal)
+
+	// 5. Profit net (hors taxes et remboursements: la taxe collectée est reversée, la taxe déductible récupérée)
+	netSales := totalSales - refunds.Amount - taxCollected
+	grossMargin := netSales + cancellationFeesTotal - costOfGoodsSold
+	netProfit := grossMargin - (totalExpenses - taxDeductible)
 
 	// 6. Produits en stock faible (< 5)
 	var lowStockCount int64
-	db.Model(&models.Product{}).
-		Where("shop_id = ? AND stock < ?", shopID, 5).
-		Count(&lowStockCount)
+	lowStockProducts(db, shopID).Model(&models.Product{}).Count(&lowStockCount)
 
 	// 7. Total des produits
 	var totalProducts int64
@@ -62,61 +226,128 @@ func GetDashboard(c *gin.Context) {
 		Where("shop_id = ?", shopID).
 		Count(&totalProducts)
 
-	// 8. Valeur totale du stock
-	var stockValue float64
+	// 8. Valeur totale du stock (prix d'achat convertis au taux le plus récent)
+	var stockByCurrency []struct {
+		PurchaseCurrency string
+		Value            models.Money
+	}
 	db.Model(&models.Product{}).
 		Where("shop_id = ?", shopID).
-		Select("COALESCE(SUM(purchase_price * stock), 0)").
-		Scan(&stockValue)
+		Select("purchase_currency, COALESCE(SUM(purchase_price * stock), 0) as value").
+		Group("purchase_currency").
+		Scan(&stockByCurrency)
+
+	var stockValue models.Money
+	missingRates := []string{}
+	now := time.Now()
+	for _, s := range stockByCurrency {
+		value, _, err := toShopCurrency(db, shopID, s.Value, s.PurchaseCurrency, now)
+		if err != nil {
+			missingRates = append(missingRates, s.PurchaseCurrency)
+			continue
+		}
+		stockValue += value
+	}
 
 	// 9. Nombre de transactions par type
-	var salesCount, expensesCount, withdrawalsCount int64
-	db.Model(&models.Transaction{}).Where("shop_id = ? AND type = ?", shopID, models.TypeSale).Count(&salesCount)
-	db.Model(&models.Transaction{}).Where("shop_id = ? AND type = ?", shopID, models.TypeExpense).Count(&expensesCount)
-	db.Model(&models.Transaction{}).Where("shop_id = ? AND type = ?", shopID, models.TypeWithdrawal).Count(&withdrawalsCount)
+	var salesCount, expensesCount, withdrawalsCount, refundsCount int64
+	transactions(models.TypeSale).Count(&salesCount)
+	transactions(models.TypeExpense).Count(&expensesCount)
+	transactions(models.TypeWithdrawal).Count(&withdrawalsCount)
+	transactions(models.TypeRefund).Count(&refundsCount)
 
 	// 10. Top 5 produits vendus
-	type TopProduct struct {
-		ProductID   uint    `json:"product_id"`
-		ProductName string  `json:"product_name"`
-		TotalSold   int     `json:"total_sold"`
-		TotalAmount float64 `json:"total_amount"`
-	}
 	var topProducts []TopProduct
-	db.Raw(`
-		SELECT 
-			t.product_id,
-			p.name as product_name,
-			SUM(t.quantity) as total_sold,
-			SUM(t.amount) as total_amount
-		FROM transactions t
-		JOIN products p ON t.product_id = p.id
-		WHERE t.shop_id = ? AND t.type = ?
-		GROUP BY t.product_id, p.name
-		ORDER BY total_sold DESC
-		LIMIT 5
-	`, shopID, models.TypeSale).Scan(&topProducts)
+	topQuery := db.Table("transactions t").
+		Select("t.product_id, p.name as product_name, SUM(t.quantity) as total_sold, SUM(t.amount) as total_amount").
+		Joins("JOIN products p ON t.product_id = p.id").
+		Where("t.shop_id = ? AND t.type = ?", shopID, models.TypeSale)
+	period.apply(topQuery, "t.created_at").
+		Group("t.product_id, p.name").
+		Order("total_sold DESC").
+		Limit(5).
+		Scan(&topProducts)
 
-	c.JSON(http.StatusOK, gin.H{
-		"dashboard": gin.H{
-			"total_sales":        totalSales,
-			"total_expenses":     totalExpenses,
-			"total_withdrawals":  totalWithdrawals,
-			"cost_of_goods_sold": costOfGoodsSold,
-			"net_profit":         netProfit,
-			"gross_margin":       totalSales - costOfGoodsSold,
-			"total_products":     totalProducts,
-			"low_stock_products": lowStockCount,
-			"stock_value":        stockValue,
-			"transactions": gin.H{
-				"sales":       salesCount,
-				"expenses":    expensesCount,
-				"withdrawals": withdrawalsCount,
-				"total":       salesCount + expensesCount + withdrawalsCount,
-			},
-			"top_products": topProducts,
+	// 11. Remises par promotion
+	promotionUsage := []PromotionUsage{}
+	promotionQuery := db.Table("transaction_promotions tp").
+		Select("tp.promotion_id, MAX(tp.name) as name, COUNT(*) as uses, SUM(tp.discount) as total_discount").
+		Joins("JOIN transactions t ON tp.transaction_id = t.id").
+		Where("t.shop_id = ? AND t.type = ?", shopID, models.TypeSale)
+	period.apply(promotionQuery, "t.created_at").
+		Group("tp.promotion_id").
+		Order("total_discount DESC").
+		Scan(&promotionUsage)
+
+	return Dashboard{
+		Currency:         shop.Currency,
+		TotalSales:       totalSales,
+		NetSales:         netSales,
+		TotalDiscounts:   totalDiscounts,
+		TotalRefunds:     refunds.Amount,
+		RevenueInvoiced:  totalSales - refunds.Amount,
+		CancellationFees: cancellationFeesTotal,
+		CashReceived:     cashReceived,
+		NonCashTenders:   nonCashTenders,
+		Receivables:      receivables,
+		TaxCollected:     taxCollected,
+		TaxDeductible:    taxDeductible,
+		TotalExpenses:    totalExpenses,
+		TotalWithdrawals: totalWithdrawals,
+		CostOfGoodsSold:  costOfGoodsSold,
+		NetProfit:        netProfit,
+		GrossMargin:      grossMargin,
+		TotalProducts:    totalProducts,
+		LowStockProducts: lowStockCount,
+		StockValue:       stockValue,
+		MissingRates:     missingRates,
+		Transactions: TransactionCounts{
+			Sales:       salesCount,
+			Expenses:    expensesCount,
+			Withdrawals: withdrawalsCount,
+			Refunds:     refundsCount,
+			Total:       salesCount + expensesCount + withdrawalsCount + refundsCount,
 		},
-	})
+		TopProducts: topProducts,
+		Promotions:  promotionUsage,
+	}
+}
+
+// dashboardChanges calcule la variation des indicateurs de période
+func dashboardChanges(current, previous Dashboard) map[string]*float64 {
+	money := map[string][2]models.Money{
+		"total_sales":        {current.TotalSales, previous.TotalSales},
+		"net_sales":          {current.NetSales, previous.NetSales},
+		"total_discounts":    {current.TotalDiscounts, previous.TotalDiscounts},
+		"total_refunds":      {current.TotalRefunds, previous.TotalRefunds},
+		"revenue_invoiced":   {current.RevenueInvoiced, previous.RevenueInvoiced},
+		"cancellation_fees":  {current.CancellationFees, previous.CancellationFees},
+		"cash_received":      {current.CashReceived, previous.CashReceived},
+		"non_cash_tenders":   {current.NonCashTenders, previous.NonCashTenders},
+		"tax_collected":      {current.TaxCollected, previous.TaxCollected},
+		"tax_deductible":     {current.TaxDeductible, previous.TaxDeductible},
+		"total_expenses":     {current.TotalExpenses, previous.TotalExpenses},
+		"total_withdrawals":  {current.TotalWithdrawals, previous.TotalWithdrawals},
+		"cost_of_goods_sold": {current.CostOfGoodsSold, previous.CostOfGoodsSold},
+		"net_profit":         {current.NetProfit, previous.NetProfit},
+		"gross_margin":       {current.GrossMargin, previous.GrossMargin},
+	}
+	changes := make(map[string]*float64, len(money)+5)
+	for metric, values := range money {
+		changes[metric] = percentChange(values[0].Float64(), values[1].Float64())
+	}
+
+	counts := map[string][2]int64{
+		"transactions.sales":       {current.Transactions.Sales, previous.Transactions.Sales},
+		"transactions.expenses":    {current.Transactions.Expenses, previous.Transactions.Expenses},
+		"transactions.withdrawals": {current.Transactions.Withdrawals, previous.Transactions.Withdrawals},
+		"transactions.refunds":     {current.Transactions.Refunds, previous.Transactions.Refunds},
+		"transactions.total":       {current.Transactions.Total, previous.Transactions.Total},
+	}
+	for metric, values := range counts {
+		changes[metric] = percentChange(float64(values[0]), float64(values[1]))
+	}
+	return changes
 }
 
 // ========================================
@@ -128,7 +359,7 @@ func GetLowStockProducts(c *gin.Context) {
 	db := database.GetDB()
 
 	var products []models.Product
-	if err := db.Where("shop_id = ? AND stock < ?", shopID, 5).
+	if err := lowStockProducts(db, shopID).
 		Order("stock ASC").
 		Find(&products).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des produits"})
@@ -140,4 +371,9 @@ func GetLowStockProducts(c *gin.Context) {
 		"count":    len(products),
 		"message":  "Produits avec stock inférieur à 5 unités",
 	})
+}
+
+// lowStockProducts sélectionne les produits actifs du shop dont le stock est inférieur à 5
+func lowStockProducts(db *gorm.DB, shopID uint) *gorm.DB {
+	return db.Where("shop_id = ? AND stock < ? AND archived_at IS NULL", shopID, 5)
 }
\ No newline at end of file
@@ -0,0 +1,806 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"electronic-shop-api/config"
+	"electronic-shop-api/database"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"electronic-shop-api/storage"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// STRUCTURES DE REQUÊTE
+// ========================================
+
+type ExpenseCategoryInput struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type RecurringExpenseInput struct {
+	Name              string       `json:"name" binding:"required"`
+	Amount            models.Money `json:"amount" binding:"required,gt=0"`
+	TaxAmount         models.Money `json:"tax_amount" binding:"gte=0"`
+	ExpenseCategoryID *uint        `json:"expense_category_id"`
+	ExpenseCategory   string       `json:"expense_category"` // Nom (créée si inconnue), alternative à expense_category_id
+	Description       string       `json:"description"`
+	Vendor            string       `json:"vendor"`
+	DayOfMonth        int          `json:"day_of_month" binding:"required,min=1,max=31"`
+	StartsOn          string       `json:"starts_on"` // YYYY-MM-DD (défaut: aujourd'hui)
+	EndsOn            string       `json:"ends_on"`   // Dernier jour possible d'une échéance (optionnel)
+}
+
+type UpdateRecurringExpenseInput struct {
+	Name              *string       `json:"name"`
+	Amount            *models.Money `json:"amount" binding:"omitempty,gt=0"`
+	TaxAmount         *models.Money `json:"tax_amount" binding:"omitempty,gte=0"`
+	ExpenseCategoryID *uint         `json:"expense_category_id"`
+	ExpenseCategory   string        `json:"expense_category"`
+	Description       *string       `json:"description"`
+	Vendor            *string       `json:"vendor"`
+	DayOfMonth        *int          `json:"day_of_month" binding:"omitempty,min=1,max=31"`
+	EndsOn            *string       `json:"ends_on"` // "" = sans fin
+	Active            *bool         `json:"active"`
+}
+
+// ExpenseCategoryLine - Dépenses d'une catégorie sur la période
+type ExpenseCategoryLine struct {
+	ExpenseCategoryID *uint        `json:"expense_category_id"`
+	Category          string       `json:"category"`
+	Count             int64        `json:"count"`
+	Amount            models.Money `json:"amount"`     // Taxes comprises
+	TaxAmount         models.Money `json:"tax_amount"` // Taxe déductible
+	NetAmount         models.Money `json:"net_amount"` // Hors taxes
+	Share             float64      `json:"share"`      // Part du total (%)
+}
+
+// recurringExpenseInterval - Fréquence de vérification des dépenses récurrentes
+const recurringExpenseInterval = 15 * time.Minute
+
+// maxCatchUpPostings - Échéances rattrapées au plus par passage (serveur arrêté plusieurs mois)
+const maxCatchUpPostings = 24
+
+// maxAttachmentsPerExpense - Nombre maximal de justificatifs par dépense
+const maxAttachmentsPerExpense = 5
+
+// attachmentExtensions - Types de justificatifs acceptés (détectés sur le contenu)
+var attachmentExtensions = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+}
+
+// ========================================
+// EXPENSE CATEGORIES
+// ========================================
+
+func GetExpenseCategories(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	var categories []models.ExpenseCategory
+	if err := database.GetDB().Where("shop_id = ?", shopID).Order("name ASC").Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des catégories de dépenses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"expense_categories": categories, "count": len(categories)})
+}
+
+// CreateExpenseCategory (SuperAdmin)
+func CreateExpenseCategory(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	var input ExpenseCategoryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	if existing, ok := findExpenseCategory(db, shopID, input.Name); ok {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cette catégorie de dépenses existe déjà", "expense_category": existing})
+		return
+	}
+
+	category, err := database.FindOrCreateExpenseCategory(db, shopID, input.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Catégorie de dépenses créée", "expense_category": category})
+}
+
+// UpdateExpenseCategory renomme une catégorie (SuperAdmin); le nom dénormalisé des dépenses suit
+func UpdateExpenseCategory(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	category, ok := findShopExpenseCategory(c, shopID)
+	if !ok {
+		return
+	}
+
+	var input ExpenseCategoryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	name := strings.TrimSpace(input.Name)
+	slug := models.Slugify(name)
+	if slug == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nom de catégorie invalide"})
+		return
+	}
+
+	db := database.GetDB()
+	if existing, ok := findExpenseCategory(db, shopID, name); ok && existing.ID != category.ID {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cette catégorie de dépenses existe déjà", "expense_category": existing})
+		return
+	}
+
+	category.Name, category.Slug = name, slug
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&category).Error; err != nil {
+			return err
+		}
+		// Garder le nom dénormalisé des dépenses et des modèles récurrents à jour
+		if err := tx.Model(&models.Transaction{}).
+			Where("shop_id = ? AND expense_category_id = ?", shopID, category.ID).
+			Update("expense_category", category.Name).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.RecurringExpense{}).
+			Where("shop_id = ? AND expense_category_id = ?", shopID, category.ID).
+			Update("expense_category", category.Name).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Catégorie de dépenses mise à jour", "expense_category": category})
+}
+
+// DeleteExpenseCategory (SuperAdmin): refusée tant que des dépenses ou des modèles l'utilisent
+func DeleteExpenseCategory(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	category, ok := findShopExpenseCategory(c, shopID)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	var expenses, recurring int64
+	db.Model(&models.Transaction{}).Where("shop_id = ? AND expense_category_id = ?", shopID, category.ID).Count(&expenses)
+	db.Model(&models.RecurringExpense{}).Where("shop_id = ? AND expense_category_id = ?", shopID, category.ID).Count(&recurring)
+	if expenses > 0 || recurring > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":              "Catégorie utilisée: la renommer plutôt que la supprimer",
+			"expenses":           expenses,
+			"recurring_expenses": recurring,
+		})
+		return
+	}
+
+	if err := db.Delete(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Catégorie de dépenses supprimée"})
+}
+
+// ========================================
+// EXPENSE ATTACHMENTS (justificatifs)
+// ========================================
+
+// UploadExpenseAttachment ajoute un justificatif (PDF, JPEG, PNG ou WebP, champ file) à une dépense
+func UploadExpenseAttachment(c *gin.Context) {
+	userID, shopID, _ := middleware.GetUserFromContext(c)
+
+	expense, ok := findShopExpense(c, shopID)
+	if !ok {
+		return
+	}
+
+	maxSize := config.AppConfig.MaxUploadSize
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+(1<<20))
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Fichier manquant ou trop volumineux (champ file)"})
+		return
+	}
+	if file.Size > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("%s dépasse la taille maximale de %d Mo", file.Filename, maxSize>>20)})
+		return
+	}
+	data, err := readUpload(file, maxSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Lecture impossible de " + file.Filename})
+		return
+	}
+
+	contentType := http.DetectContentType(data)
+	extension, ok := attachmentExtensions[contentType]
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Justificatif non supporté (PDF, JPEG, PNG ou WebP)"})
+		return
+	}
+
+	db := database.GetDB()
+	var count int64
+	db.Model(&models.ExpenseAttachment{}).Where("transaction_id = ?", expense.ID).Count(&count)
+	if count >= maxAttachmentsPerExpense {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Maximum %d justificatifs par dépense", maxAttachmentsPerExpense)})
+		return
+	}
+
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement du justificatif"})
+		return
+	}
+	key := fmt.Sprintf("%sshop-%d/expense-%d/%s%s", storage.ReceiptsPrefix, shopID, expense.ID, hex.EncodeToString(token), extension)
+
+	store := storage.Get()
+	if err := store.Put(c.Request.Context(), key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement du justificatif"})
+		return
+	}
+
+	attachment := models.ExpenseAttachment{
+		TransactionID: expense.ID,
+		FileName:      filepath.Base(file.Filename),
+		ContentType:   contentType,
+		Size:          int64(len(data)),
+		StorageKey:    key,
+		UserID:        userID,
+		ShopID:        shopID,
+	}
+	if err := db.Create(&attachment).Error; err != nil {
+		deleteStoredKeys(store, []string{key})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement du justificatif"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Justificatif ajouté", "attachment": attachment})
+}
+
+// DownloadExpenseAttachment envoie le justificatif (réservé aux utilisateurs du shop)
+func DownloadExpenseAttachment(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	attachment, ok := findExpenseAttachment(c, shopID)
+	if !ok {
+		return
+	}
+
+	reader, err := storage.Get().Open(c.Request.Context(), attachment.StorageKey)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fichier du justificatif introuvable"})
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Cache-Control", "private, no-store")
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, reader, map[string]string{
+		"Content-Disposition": fmt.Sprintf(`inline; filename="%s"`, strings.ReplaceAll(attachment.FileName, `"`, "")),
+	})
+}
+
+func DeleteExpenseAttachment(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	attachment, ok := findExpenseAttachment(c, shopID)
+	if !ok {
+		return
+	}
+
+	if err := database.GetDB().Delete(&attachment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression"})
+		return
+	}
+	deleteStoredKeys(storage.Get(), []string{attachment.StorageKey})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Justificatif supprimé"})
+}
+
+// ========================================
+// RECURRING EXPENSES (SuperAdmin)
+// ========================================
+
+func GetRecurringExpenses(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	query := database.GetDB().Where("shop_id = ?", shopID)
+	if active := c.Query("active"); active != "" {
+		query = query.Where("active = ?", active == "true")
+	}
+
+	var recurring []models.RecurringExpense
+	if err := query.Order("next_run_at ASC").Find(&recurring).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des dépenses récurrentes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recurring_expenses": recurring, "count": len(recurring)})
+}
+
+// CreateRecurringExpense crée un modèle: une dépense est enregistrée le day_of_month de chaque mois
+func CreateRecurringExpense(c *gin.Context) {
+	userID, shopID, _ := middleware.GetUserFromContext(c)
+
+	var input RecurringExpenseInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+	if input.TaxAmount >= input.Amount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tax_amount doit être inférieur au montant"})
+		return
+	}
+
+	db := database.GetDB()
+	loc := shopLocation(db, shopID)
+
+	category, err := resolveExpenseCategory(db, shopID, input.ExpenseCategoryID, input.ExpenseCategory)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now().In(loc)
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if input.StartsOn != "" {
+		if start, err = parseDateIn(input.StartsOn, loc); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "starts_on invalide (YYYY-MM-DD)"})
+			return
+		}
+	}
+	endsAt, err := parseEndsOn(input.EndsOn, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recurring := models.RecurringExpense{
+		Name:        strings.TrimSpace(input.Name),
+		Amount:      input.Amount,
+		TaxAmount:   input.TaxAmount,
+		Description: strings.TrimSpace(input.Description),
+		Vendor:      strings.TrimSpace(input.Vendor),
+		DayOfMonth:  input.DayOfMonth,
+		NextRunAt:   nextMonthlyRun(start, input.DayOfMonth, loc),
+		EndsAt:      endsAt,
+		Active:      true,
+		UserID:      userID,
+		ShopID:      shopID,
+	}
+	if category != nil {
+		recurring.ExpenseCategoryID, recurring.ExpenseCategory = &category.ID, category.Name
+	}
+	if endsAt != nil && !recurring.NextRunAt.Before(*endsAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_on est antérieur à la première échéance", "next_run_at": recurring.NextRunAt})
+		return
+	}
+
+	if err := db.Create(&recurring).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création de la dépense récurrente"})
+		return
+	}
+
+	// Première échéance déjà passée (starts_on dans le passé): enregistrée sans attendre le planificateur
+	processRecurringExpenses(time.Now())
+	db.First(&recurring, recurring.ID)
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Dépense récurrente créée", "recurring_expense": recurring})
+}
+
+func UpdateRecurringExpense(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	recurring, ok := findRecurringExpense(c, shopID)
+	if !ok {
+		return
+	}
+
+	var input UpdateRecurringExpenseInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	loc := shopLocation(db, shopID)
+
+	if input.Name != nil && strings.TrimSpace(*input.Name) != "" {
+		recurring.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Amount != nil {
+		recurring.Amount = *input.Amount
+	}
+	if input.TaxAmount != nil {
+		recurring.TaxAmount = *input.TaxAmount
+	}
+	if recurring.TaxAmount >= recurring.Amount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tax_amount doit être inférieur au montant"})
+		return
+	}
+	if input.ExpenseCategoryID != nil || input.ExpenseCategory != "" {
+		category, err := resolveExpenseCategory(db, shopID, input.ExpenseCategoryID, input.ExpenseCategory)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		recurring.ExpenseCategoryID, recurring.ExpenseCategory = nil, ""
+		if category != nil {
+			recurring.ExpenseCategoryID, recurring.ExpenseCategory = &category.ID, category.Name
+		}
+	}
+	if input.Description != nil {
+		recurring.Description = strings.TrimSpace(*input.Description)
+	}
+	if input.Vendor != nil {
+		recurring.Vendor = strings.TrimSpace(*input.Vendor)
+	}
+	if input.EndsOn != nil {
+		endsAt, err := parseEndsOn(*input.EndsOn, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		recurring.EndsAt = endsAt
+	}
+	if input.Active != nil {
+		recurring.Active = *input.Active
+	}
+
+	// Nouveau jour ou réactivation: prochaine échéance à partir d'aujourd'hui,
+	// sans enregistrer deux fois le mois déjà passé
+	if input.DayOfMonth != nil || (input.Active != nil && *input.Active) {
+		if input.DayOfMonth != nil {
+			recurring.DayOfMonth = *input.DayOfMonth
+		}
+		now := time.Now().In(loc)
+		from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+		if recurring.LastPostedAt != nil {
+			posted := recurring.LastPostedAt.In(loc)
+			if posted.Year() == from.Year() && posted.Month() == from.Month() {
+				from = time.Date(from.Year(), from.Month()+1, 1, 0, 0, 0, 0, loc)
+			}
+		}
+		recurring.NextRunAt = nextMonthlyRun(from, recurring.DayOfMonth, loc)
+	}
+
+	if err := db.Save(&recurring).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Dépense récurrente mise à jour", "recurring_expense": recurring})
+}
+
+// DeleteRecurringExpense supprime le modèle; les dépenses déjà enregistrées sont conservées
+func DeleteRecurringExpense(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	recurring, ok := findRecurringExpense(c, shopID)
+	if !ok {
+		return
+	}
+
+	if err := database.GetDB().Delete(&recurring).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Dépense récurrente supprimée"})
+}
+
+// ========================================
+// GET EXPENSE REPORT (SuperAdmin)
+// ========================================
+
+// GetExpenseReport - Dépenses par catégorie sur une période (period ou from / to)
+func GetExpenseReport(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+
+	period, err := parsePeriod(c, db, shopID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	lines := []ExpenseCategoryLine{}
+	query := db.Model(&models.Transaction{}).
+		Select("expense_category_id, MAX(expense_category) as category, COUNT(*) as count, "+
+			"COALESCE(SUM(amount), 0) as amount, COALESCE(SUM(tax_amount), 0) as tax_amount").
+		Where("shop_id = ? AND type = ?", shopID, models.TypeExpense)
+	if err := period.apply(query, "created_at").
+		Group("expense_category_id").
+		Order("amount DESC").
+		Scan(&lines).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du calcul du rapport"})
+		return
+	}
+
+	var total, totalTax models.Money
+	for _, line := range lines {
+		total += line.Amount
+		totalTax += line.TaxAmount
+	}
+	for i := range lines {
+		if lines[i].ExpenseCategoryID == nil || lines[i].Category == "" {
+			lines[i].Category = uncategorizedExpenses
+		}
+		lines[i].NetAmount = lines[i].Amount - lines[i].TaxAmount
+		if total > 0 {
+			lines[i].Share = roundAmount(float64(lines[i].Amount) / float64(total) * 100)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"currency":   shopCurrency(db, shopID),
+		"period":     period,
+		"categories": lines,
+		"total":      total,
+		"total_tax":  totalTax,
+		"total_net":  total - totalTax,
+	})
+}
+
+// ========================================
+// PLANIFICATEUR
+// ========================================
+
+// StartRecurringExpenseScheduler enregistre les dépenses récurrentes arrivées à échéance en arrière-plan
+func StartRecurringExpenseScheduler() {
+	go func() {
+		ticker := time.NewTicker(recurringExpenseInterval)
+		defer ticker.Stop()
+
+		processRecurringExpenses(time.Now())
+		for now := range ticker.C {
+			processRecurringExpenses(now)
+		}
+	}()
+	log.Println("✅ Planificateur des dépenses récurrentes démarré")
+}
+
+// processRecurringExpenses enregistre les échéances passées des modèles actifs
+func processRecurringExpenses(now time.Time) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("❌ Dépenses récurrentes: %v", r)
+		}
+	}()
+
+	db := database.GetDB()
+
+	// next_run_at est enregistré avec le décalage du fuseau du shop: comparer des instants
+	var due []models.RecurringExpense
+	db.Where("active = ? AND julianday(next_run_at) <= julianday(?)", true, now.UTC()).Order("next_run_at ASC").Find(&due)
+	for _, recurring := range due {
+		if err := postRecurringExpense(db, recurring, now); err != nil {
+			log.Printf("❌ Dépense récurrente %d: %v", recurring.ID, err)
+		}
+	}
+}
+
+// postRecurringExpense enregistre chaque échéance passée (datée du jour de l'échéance)
+// puis avance le modèle au mois suivant
+func postRecurringExpense(db *gorm.DB, recurring models.RecurringExpense, now time.Time) error {
+	loc := shopLocation(db, recurring.ShopID)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Relire dans la transaction: une modification a pu passer entre-temps
+		if err := tx.Where("id = ? AND active = ?", recurring.ID, true).First(&recurring).Error; err != nil {
+			return nil
+		}
+
+		for posted := 0; !recurring.NextRunAt.After(now) && posted < maxCatchUpPostings; posted++ {
+			if recurring.EndsAt != nil && !recurring.NextRunAt.Before(*recurring.EndsAt) {
+				break
+			}
+
+			runAt := recurring.NextRunAt
+			expense := models.Transaction{
+				Type:               models.TypeExpense,
+				Amount:             recurring.Amount,
+				TaxAmount:          recurring.TaxAmount,
+				ExpenseCategoryID:  recurring.ExpenseCategoryID,
+				ExpenseCategory:    recurring.ExpenseCategory,
+				Description:        recurring.Description,
+				Vendor:             recurring.Vendor,
+				RecurringExpenseID: &recurring.ID,
+				UserID:             &recurring.UserID,
+				ShopID:             recurring.ShopID,
+				CreatedAt:          runAt,
+			}
+			if expense.Description == "" {
+				expense.Description = recurring.Name
+			}
+			if recurring.TaxAmount > 0 {
+				expense.TaxRate = roundAmount(float64(recurring.TaxAmount) / float64(recurring.Amount-recurring.TaxAmount) * 100)
+			}
+			if err := tx.Create(&expense).Error; err != nil {
+				return err
+			}
+
+			recurring.LastPostedAt = &runAt
+			local := runAt.In(loc)
+			recurring.NextRunAt = nextMonthlyRun(time.Date(local.Year(), local.Month()+1, 1, 0, 0, 0, 0, loc), recurring.DayOfMonth, loc)
+		}
+
+		if recurring.EndsAt != nil && !recurring.NextRunAt.Before(*recurring.EndsAt) {
+			recurring.Active = false
+		}
+		return tx.Save(&recurring).Error
+	})
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// nextMonthlyRun retourne la première échéance (minuit, fuseau du shop) à partir de from.
+// Un jour absent du mois (31 en avril) tombe le dernier jour du mois.
+func nextMonthlyRun(from time.Time, day int, loc *time.Location) time.Time {
+	from = from.In(loc)
+	occurrence := func(year int, month time.Month) time.Time {
+		last := time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day()
+		if day > last {
+			return time.Date(year, month, last, 0, 0, 0, 0, loc)
+		}
+		return time.Date(year, month, day, 0, 0, 0, 0, loc)
+	}
+
+	run := occurrence(from.Year(), from.Month())
+	if run.Before(from) {
+		next := time.Date(from.Year(), from.Month()+1, 1, 0, 0, 0, 0, loc)
+		run = occurrence(next.Year(), next.Month())
+	}
+	return run
+}
+
+// parseEndsOn lit la date de fin (incluse) d'un modèle récurrent; vide = sans fin
+func parseEndsOn(value string, loc *time.Location) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return nil, errors.New("ends_on invalide (YYYY-MM-DD)")
+	}
+	end := day.AddDate(0, 0, 1)
+	return &end, nil
+}
+
+// resolveExpenseCategory détermine la catégorie d'une dépense à partir de expense_category_id
+// ou du nom (créée si inconnue); 0 ou rien = sans catégorie
+func resolveExpenseCategory(db *gorm.DB, shopID uint, categoryID *uint, name string) (*models.ExpenseCategory, error) {
+	if categoryID != nil && *categoryID != 0 {
+		var category models.ExpenseCategory
+		if err := db.Where("id = ? AND shop_id = ?", *categoryID, shopID).First(&category).Error; err != nil {
+			return nil, errors.New("catégorie de dépenses non trouvée")
+		}
+		return &category, nil
+	}
+	if strings.TrimSpace(name) == "" {
+		return nil, nil
+	}
+	category, err := database.FindOrCreateExpenseCategory(db, shopID, name)
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// findExpenseCategory cherche une catégorie de dépenses par nom (même clé de rapprochement)
+func findExpenseCategory(db *gorm.DB, shopID uint, name string) (models.ExpenseCategory, bool) {
+	var categories []models.ExpenseCategory
+	db.Where("shop_id = ?", shopID).Find(&categories)
+	key := models.CategoryKey(name)
+	for _, category := range categories {
+		if models.CategoryKey(category.Name) == key || category.Slug == models.Slugify(name) {
+			return category, true
+		}
+	}
+	return models.ExpenseCategory{}, false
+}
+
+// findShopExpenseCategory charge la catégorie :id du shop ou répond 400/404
+func findShopExpenseCategory(c *gin.Context, shopID uint) (models.ExpenseCategory, bool) {
+	var category models.ExpenseCategory
+
+	categoryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de catégorie invalide"})
+		return category, false
+	}
+
+	// MULTI-TENANT
+	if err := database.GetDB().Where("id = ? AND shop_id = ?", categoryID, shopID).First(&category).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Catégorie de dépenses non trouvée"})
+		return category, false
+	}
+	return category, true
+}
+
+// findShopExpense charge la dépense :id du shop ou répond 400/404
+func findShopExpense(c *gin.Context, shopID uint) (models.Transaction, bool) {
+	var expense models.Transaction
+
+	transactionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de transaction invalide"})
+		return expense, false
+	}
+
+	// MULTI-TENANT
+	if err := database.GetDB().Where("id = ? AND shop_id = ? AND type = ?", transactionID, shopID, models.TypeExpense).
+		First(&expense).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dépense non trouvée"})
+		return expense, false
+	}
+	return expense, true
+}
+
+// findExpenseAttachment charge le justificatif :attachmentID de la dépense :id du shop
+func findExpenseAttachment(c *gin.Context, shopID uint) (models.ExpenseAttachment, bool) {
+	var attachment models.ExpenseAttachment
+
+	expense, ok := findShopExpense(c, shopID)
+	if !ok {
+		return attachment, false
+	}
+
+	attachmentID, err := strconv.ParseUint(c.Param("attachmentID"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de justificatif invalide"})
+		return attachment, false
+	}
+
+	if err := database.GetDB().Where("id = ? AND transaction_id = ? AND shop_id = ?", attachmentID, expense.ID, shopID).
+		First(&attachment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Justificatif non trouvé"})
+		return attachment, false
+	}
+	return attachment, true
+}
+
+// findRecurringExpense charge le modèle récurrent :id du shop ou répond 400/404
+func findRecurringExpense(c *gin.Context, shopID uint) (models.RecurringExpense, bool) {
+	var recurring models.RecurringExpense
+
+	recurringID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de dépense récurrente invalide"})
+		return recurring, false
+	}
+
+	// MULTI-TENANT
+	if err := database.GetDB().Where("id = ? AND shop_id = ?", recurringID, shopID).First(&recurring).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dépense récurrente non trouvée"})
+		return recurring, false
+	}
+	return recurring, true
+}
\ No newline at end of file
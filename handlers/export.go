@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"electronic-shop-api/database"
+	"electronic-shop-api/export"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize - Nombre de lignes lues en base à la fois
+const exportBatchSize = 500
+
+// ========================================
+// EXPORT PRODUCTS
+// ========================================
+
+// ExportProducts exporte les produits avec les mêmes filtres que GetProducts
+// (PurchasePrice masqué pour les Admin)
+func ExportProducts(c *gin.Context) {
+	_, shopID, role := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+
+	query, status, err := filterProducts(db, shopID, c.Request.URL.Query())
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	writeProductExport(c, db, shopID, role, query, "produits")
+}
+
+// ========================================
+// EXPORT TRANSACTIONS
+// ========================================
+
+// ExportTransactions exporte les transactions avec les mêmes filtres que GetTransactions
+func ExportTransactions(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+
+	columns := []string{"id", "created_at", "type", "product_id", "product_name", "sku", "quantity", "subtotal", "discount", "coupon_code", "tax_rate", "tax_amount", "amount", "currency", "original_amount", "expense_category", "description", "vendor", "refund_of_id", "register_session_id", "customer_id", "customer_name", "payments"}
+	w, ok := startExport(c, "transactions", columns)
+	if !ok {
+		return
+	}
+
+	// Produit limité au nom et au SKU: l'export ne charge jamais le prix d'achat (Admin)
+	productColumns := func(db *gorm.DB) *gorm.DB { return db.Select("id", "name", "sku") }
+
+	var batch []models.Transaction
+	result := filterTransactions(c, db, shopID).Preload("Product", productColumns).Preload("Payments").Preload("Customer").
+		FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+			for _, t := range batch {
+				var productName string
+				var sku *string
+				if t.Product != nil {
+					productName, sku = t.Product.Name, t.Product.SKU
+				}
+				var originalAmount interface{} // Vide pour les montants saisis dans la devise du shop
+				if t.Currency != "" {
+					originalAmount = t.OriginalAmount
+				}
+				var customerName string
+				if t.Customer != nil {
+					customerName = t.Customer.Name
+				}
+				payments := make([]string, len(t.Payments))
+				for i, payment := range t.Payments {
+					payments[i] = payment.MethodName + " " + payment.Amount.String()
+				}
+				if err := w.WriteRow(t.ID, t.CreatedAt, string(t.Type), t.ProductID, productName, sku, t.Quantity, t.Subtotal, t.Discount, t.CouponCode, t.TaxRate, t.TaxAmount, t.Amount, t.Currency, originalAmount, t.ExpenseCategory, t.Description, t.Vendor, t.RefundOfID, t.RegisterSessionID, t.CustomerID, customerName, strings.Join(payments, "; ")); err != nil {
+					return err
+				}
+			}
+			return w.Flush()
+		})
+	finishExport(w, "transactions", result.Error)
+}
+
+// ========================================
+// EXPORT REPORTS (SuperAdmin)
+// ========================================
+
+// ExportDashboard exporte les indicateurs du dashboard (une ligne par indicateur, mêmes paramètres de période)
+func ExportDashboard(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+
+	period, err := parsePeriod(c, db, shopID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	dashboard := computeDashboard(db, shopID, period)
+
+	w, ok := startExport(c, "dashboard", []string{"metric", "value"})
+	if !ok {
+		return
+	}
+
+	rows := [][2]interface{}{
+		{"period.from", period.From},
+		{"period.to", period.To},
+		{"currency", dashboard.Currency},
+		{"total_sales", dashboard.TotalSales},
+		{"net_sales", dashboard.NetSales},
+		{"total_discounts", dashboard.TotalDiscounts},
+		{"total_refunds", dashboard.TotalRefunds},
+		{"revenue_invoiced", dashboard.RevenueInvoiced},
+		{"cancellation_fees", dashboard.CancellationFees},
+		{"cash_received", dashboard.CashReceived},
+		{"non_cash_tenders", dashboard.NonCashTenders},
+		{"receivables", dashboard.Receivables},
+		{"tax_collected", dashboard.TaxCollected},
+		{"tax_deductible", dashboard.TaxDeductible},
+		{"total_expenses", dashboard.TotalExpenses},
+		{"total_withdrawals", dashboard.TotalWithdrawals},
+		{"cost_of_goods_sold", dashboard.CostOfGoodsSold},
+		{"net_profit", dashboard.NetProfit},
+		{"gross_margin", dashboard.GrossMargin},
+		{"total_products", dashboard.TotalProducts},
+		{"low_stock_products", dashboard.LowStockProducts},
+		{"stock_value", dashboard.StockValue},
+		{"transactions.sales", dashboard.Transactions.Sales},
+		{"transactions.expenses", dashboard.Transactions.Expenses},
+		{"transactions.withdrawals", dashboard.Transactions.Withdrawals},
+		{"transactions.refunds", dashboard.Transactions.Refunds},
+		{"transactions.total", dashboard.Transactions.Total},
+	}
+	for i, top := range dashboard.TopProducts {
+		prefix := fmt.Sprintf("top_products.%d.", i+1)
+		rows = append(rows,
+			[2]interface{}{prefix + "product_name", top.ProductName},
+			[2]interface{}{prefix + "total_sold", top.TotalSold},
+			[2]interface{}{prefix + "total_amount", top.TotalAmount},
+		)
+	}
+
+	for i, usage := range dashboard.Promotions {
+		prefix := fmt.Sprintf("promotions.%d.", i+1)
+		rows = append(rows,
+			[2]interface{}{prefix + "name", usage.Name},
+			[2]interface{}{prefix + "uses", usage.Uses},
+			[2]interface{}{prefix + "total_discount", usage.TotalDiscount},
+		)
+	}
+
+	for _, row := range rows {
+		if err = w.WriteRow(row[0], row[1]); err != nil {
+			break
+		}
+	}
+	finishExport(w, "dashboard", err)
+}
+
+// ExportLowStockProducts exporte les produits en stock faible
+func ExportLowStockProducts(c *gin.Context) {
+	_, shopID, role := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+
+	writeProductExport(c, db, shopID, role, lowStockProducts(db, shopID), "stock-faible")
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// writeProductExport écrit les produits sélectionnés par query, par lots.
+// Les colonnes reprennent les champs de l'import (ré-importable), fiche technique en attr.<nom>.
+func writeProductExport(c *gin.Context, db *gorm.DB, shopID uint, role models.Role, query *gorm.DB, name string) {
+	var attributeNames []string
+	if err := db.Model(&models.CategoryAttribute{}).Where("shop_id = ?", shopID).
+		Distinct("name").Order("name").Pluck("name", &attributeNames).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'export"})
+		return
+	}
+
+	// Même règle que GetProducts: PurchasePrice réservé au SuperAdmin
+	showCost := role != models.RoleAdmin
+
+	columns := []string{"id", "name", "description", "category", "sku", "barcode"}
+	if showCost {
+		columns = append(columns, "purchase_price", "purchase_currency")
+	}
+	columns = append(columns, "selling_price", "stock", "image_url", "created_at")
+	for _, attribute := range attributeNames {
+		columns = append(columns, importAttributePrefix+attribute)
+	}
+
+	w, ok := startExport(c, name, columns)
+	if !ok {
+		return
+	}
+
+	var batch []models.Product
+	result := query.Scopes(productDetails).FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+		for _, p := range batch {
+			row := []interface{}{p.ID, p.Name, p.Description, p.Category, p.SKU, p.Barcode}
+			if showCost {
+				row = append(row, p.PurchasePrice, p.PurchaseCurrency)
+			}
+			row = append(row, p.SellingPrice, p.Stock, p.ImageURL, p.CreatedAt)
+
+			values := make(map[string]string, len(p.Attributes))
+			for _, pa := range p.Attributes {
+				if pa.Attribute != nil {
+					values[pa.Attribute.Name] = pa.Value
+				}
+			}
+			for _, attribute := range attributeNames {
+				row = append(row, values[attribute])
+			}
+
+			if err := w.WriteRow(row...); err != nil {
+				return err
+			}
+		}
+		return w.Flush()
+	})
+	finishExport(w, name, result.Error)
+}
+
+// startExport lit les paramètres format (csv, xlsx, jsonl, pdf) et sep (, ; tab),
+// envoie les en-têtes de téléchargement et ouvre le writer
+func startExport(c *gin.Context, name string, columns []string) (export.Writer, bool) {
+	return startReportExport(c, name, "", columns)
+}
+
+// startReportExport - startExport avec le titre du document PDF
+func startReportExport(c *gin.Context, name, title string, columns []string) (export.Writer, bool) {
+	format, err := export.ParseFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	opts := export.Options{Sheet: name, Title: title}
+	switch c.Query("sep") {
+	case "", ",":
+	case ";", "semicolon":
+		opts.Comma = ';'
+	case "tab":
+		opts.Comma = '\t'
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sep invalide (, ; ou tab)"})
+		return nil, false
+	}
+
+	filename := fmt.Sprintf("%s-%s%s", name, time.Now().Format("20060102-150405"), format.Extension())
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	w, err := export.NewWriter(c.Writer, format, columns, opts)
+	if err != nil {
+		log.Printf("❌ Export %s: %v", name, err)
+		return nil, false
+	}
+	return w, true
+}
+
+// finishExport termine le fichier. Le statut HTTP est déjà envoyé:
+// une erreur en cours de route ne peut qu'interrompre le flux.
+func finishExport(w export.Writer, name string, err error) {
+	if err != nil {
+		log.Printf("❌ Export %s interrompu: %v", name, err)
+		w.Abort()
+		return
+	}
+	if err := w.Close(); err != nil {
+		log.Printf("❌ Export %s: %v", name, err)
+	}
+}
\ No newline at end of file
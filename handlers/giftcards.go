@@ -0,0 +1,722 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"electronic-shop-api/database"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// STRUCTURES DE REQUÊTE / RÉPONSE
+// ========================================
+
+// CreateGiftCardInput - Vente d'une carte cadeau: payments règle le montant chargé
+// (espèces: monnaie rendue), le code est généré s'il n'est pas saisi
+type CreateGiftCardInput struct {
+	Amount     models.Money   `json:"amount" binding:"required,gt=0"`
+	Code       string         `json:"code"`
+	CustomerID *uint          `json:"customer_id"`
+	Customer   string         `json:"customer"`   // Téléphone ou email d'un client connu
+	ExpiresOn  string         `json:"expires_on"` // YYYY-MM-DD (défaut: validité du shop)
+	Note       string         `json:"note"`
+	Payments   []PaymentInput `json:"payments" binding:"omitempty,dive"`
+
+	RegisterSessionID *uint `json:"register_session_id"`
+}
+
+type GiftCardAdjustInput struct {
+	Amount models.Money `json:"amount" binding:"required"` // Négatif: montant retiré
+	Note   string       `json:"note" binding:"required"`
+}
+
+// GiftCardLiability - Encours des cartes cadeaux et avoirs (montants dus aux porteurs)
+type GiftCardLiability struct {
+	Cards       int64        `json:"cards"`       // Cartes avec un solde
+	Outstanding models.Money `json:"outstanding"` // Soldes restant à utiliser
+	Expiring    models.Money `json:"expiring"`    // Dont expirant dans les 30 jours
+	Sold        models.Money `json:"sold"`        // Émis: cartes vendues
+	Refunds     models.Money `json:"refunds"`     // Émis: avoirs de remboursement
+	Redeemed    models.Money `json:"redeemed"`    // Utilisés en paiement
+	Expired     models.Money `json:"expired"`
+	Adjusted    models.Money `json:"adjusted"`
+}
+
+// GiftCardLine - Carte cadeau avec un solde dans le rapport d'encours
+type GiftCardLine struct {
+	Code          string                `json:"code"`
+	Source        models.GiftCardSource `json:"source"`
+	CustomerID    *uint                 `json:"customer_id,omitempty"`
+	CustomerName  string                `json:"customer_name,omitempty"`
+	InitialAmount models.Money          `json:"initial_amount"`
+	Balance       models.Money          `json:"balance"`
+	CreatedAt     time.Time             `json:"created_at"`
+	ExpiresAt     *time.Time            `json:"expires_at,omitempty"`
+}
+
+const (
+	// giftCardExpiryInterval - Fréquence d'expiration des cartes cadeaux
+	giftCardExpiryInterval = time.Hour
+	// giftCardExpiringDays - Soldes signalés comme expirant bientôt
+	giftCardExpiringDays = 30
+	// giftCardCodeAlphabet - Caractères des codes générés (sans 0/O, 1/I)
+	giftCardCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+var (
+	errGiftCardBalance  = errors.New("solde de la carte cadeau insuffisant")
+	errGiftCardUsed     = errors.New("carte cadeau déjà utilisée: l'opération ne peut pas être annulée")
+	errGiftCardSaleOnly = errors.New("les cartes cadeaux et avoirs ne peuvent régler que des ventes")
+)
+
+// ========================================
+// GIFT CARDS
+// ========================================
+
+// GetGiftCards - Cartes cadeaux et avoirs (filtres customer_id, source, status: active, empty, expired; q: début du code)
+func GetGiftCards(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+	now := time.Now()
+	expireGiftCards(db, now, &shopID)
+
+	// MULTI-TENANT
+	query := db.Where("shop_id = ?", shopID)
+	if customerID := c.Query("customer_id"); customerID != "" {
+		query = query.Where("customer_id = ?", customerID)
+	}
+	if source := c.Query("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+	if q := c.Query("q"); q != "" {
+		query = query.Where("code LIKE ?", normalizeGiftCardCode(q)+"%")
+	}
+	switch c.Query("status") {
+	case "":
+	case "active":
+		query = query.Where("balance > 0")
+	case "empty":
+		query = query.Where("balance = 0 AND (expires_at IS NULL OR julianday(expires_at) > julianday(?))", now.UTC())
+	case "expired":
+		query = query.Where("julianday(expires_at) <= julianday(?)", now.UTC())
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status invalide (active, empty, expired)"})
+		return
+	}
+
+	var cards []models.GiftCard
+	if err := query.Preload("Customer").Order("created_at DESC, id DESC").Find(&cards).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des cartes cadeaux"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"gift_cards": cards, "count": len(cards)})
+}
+
+// GetGiftCard - Carte cadeau par code, avec son solde et ses mouvements
+func GetGiftCard(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+
+	card, err := findGiftCard(db, shopID, c.Param("code"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	expireGiftCards(db, time.Now(), &shopID)
+
+	respondGiftCard(c, http.StatusOK, "", card.ID)
+}
+
+// CreateGiftCard vend une carte cadeau: le montant encaissé n'est pas du chiffre d'affaires,
+// il le devient quand la carte règle une vente
+func CreateGiftCard(c *gin.Context) {
+	userID, shopID, _ := middleware.GetUserFromContext(c)
+
+	var input CreateGiftCardInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	sessionID, err := registerSessionFor(db, shopID, userID, input.RegisterSessionID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client, err := resolveCustomer(db, shopID, input.CustomerID, input.Customer)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	code := normalizeGiftCardCode(input.Code)
+	if code == "" {
+		if code, err = generateGiftCardCode(db, shopID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la génération du code"})
+			return
+		}
+	} else if _, err := findGiftCard(db, shopID, code); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Code de carte cadeau déjà utilisé", "code": code})
+		return
+	}
+
+	now := time.Now()
+	expiresAt := giftCardExpiry(db, shopID, now)
+	if input.ExpiresOn != "" {
+		loc := shopLocation(db, shopID)
+		day, err := time.ParseInLocation("2006-01-02", input.ExpiresOn, loc)
+		if err != nil || !day.After(now) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_on invalide (YYYY-MM-DD, à partir de demain)"})
+			return
+		}
+		// Valable toute la journée (fuseau du shop)
+		end := day.AddDate(0, 0, 1)
+		expiresAt = &end
+	}
+
+	payments, change, err := buildPayments(db, shopID, sessionID, input.Amount, input.Payments, true)
+	if err == nil {
+		err = rejectSaleOnlyPayments(payments)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "amount": input.Amount})
+		return
+	}
+
+	card := models.GiftCard{
+		Code:          code,
+		Source:        models.GiftCardSold,
+		InitialAmount: input.Amount,
+		Balance:       input.Amount,
+		ExpiresAt:     expiresAt,
+		Note:          strings.TrimSpace(input.Note),
+		UserID:        &userID,
+		ShopID:        shopID,
+		Entries: []models.GiftCardEntry{{
+			Type:   models.GiftCardIssue,
+			Amount: input.Amount,
+			UserID: &userID,
+			ShopID: shopID,
+		}},
+	}
+	if client != nil {
+		card.CustomerID = &client.ID
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&card).Error; err != nil {
+			return err
+		}
+		for i := range payments {
+			payments[i].GiftCardSaleID = &card.ID
+		}
+		return tx.Create(&payments).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création de la carte cadeau"})
+		return
+	}
+
+	db.Preload("Customer").First(&card, card.ID)
+	c.JSON(http.StatusCreated, gin.H{"message": "Carte cadeau vendue", "gift_card": card, "payments": payments, "change": change})
+}
+
+// AdjustGiftCard corrige le solde d'une carte (SuperAdmin, motif obligatoire)
+func AdjustGiftCard(c *gin.Context) {
+	userID, shopID, _ := middleware.GetUserFromContext(c)
+
+	var input GiftCardAdjustInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	card, err := findGiftCard(db, shopID, c.Param("code"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if card.Balance+input.Amount < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errGiftCardBalance.Error(), "balance": card.Balance})
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return moveGiftCardBalance(tx, card, models.GiftCardEntry{
+			Type:   models.GiftCardAdjust,
+			Amount: input.Amount,
+			Note:   strings.TrimSpace(input.Note),
+			UserID: &userID,
+		})
+	})
+	if errors.Is(err, errGiftCardBalance) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'ajustement de la carte cadeau"})
+		return
+	}
+
+	respondGiftCard(c, http.StatusOK, "Solde ajusté", card.ID)
+}
+
+// ========================================
+// LIABILITY REPORT
+// ========================================
+
+// GetGiftCardLiability - Encours des cartes cadeaux et avoirs: soldes restant dus (expiration
+// la plus proche d'abord) et mouvements cumulés
+func GetGiftCardLiability(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+
+	lines, totals, err := computeGiftCardLiability(db, shopID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du calcul de l'encours des cartes cadeaux"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"currency":   shopCurrency(db, shopID),
+		"as_of":      time.Now().In(shopLocation(db, shopID)).Format("2006-01-02"),
+		"gift_cards": lines,
+		"totals":     totals,
+	})
+}
+
+// ExportGiftCardLiability exporte l'encours des cartes cadeaux (format=csv, xlsx ou pdf)
+func ExportGiftCardLiability(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+
+	lines, totals, err := computeGiftCardLiability(db, shopID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du calcul de l'encours des cartes cadeaux"})
+		return
+	}
+
+	loc := shopLocation(db, shopID)
+	columns := []string{"code", "source", "customer", "initial_amount", "balance", "created_at", "expires_at"}
+	w, ok := startReportExport(c, "cartes-cadeaux", "Encours des cartes cadeaux et avoirs ("+shopCurrency(db, shopID)+")", columns)
+	if !ok {
+		return
+	}
+	for _, line := range lines {
+		expires := ""
+		if line.ExpiresAt != nil {
+			expires = line.ExpiresAt.In(loc).Format("2006-01-02")
+		}
+		if err = w.WriteRow(line.Code, string(line.Source), line.CustomerName, line.InitialAmount, line.Balance,
+			line.CreatedAt.In(loc).Format("2006-01-02"), expires); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.WriteRow("Total", "", "", "", totals.Outstanding, "", "")
+	}
+	finishExport(w, "cartes-cadeaux", err)
+}
+
+// ========================================
+// EXPIRATION DES CARTES CADEAUX
+// ========================================
+
+// StartGiftCardScheduler expire régulièrement les cartes cadeaux arrivées à échéance
+func StartGiftCardScheduler() {
+	go func() {
+		ticker := time.NewTicker(giftCardExpiryInterval)
+		defer ticker.Stop()
+
+		expireGiftCards(database.GetDB(), time.Now(), nil)
+		for now := range ticker.C {
+			expireGiftCards(database.GetDB(), now, nil)
+		}
+	}()
+	log.Println("✅ Planificateur des cartes cadeaux démarré")
+}
+
+// expireGiftCards solde les cartes expirées (d'un shop, ou de tous)
+func expireGiftCards(db *gorm.DB, now time.Time, shopID *uint) {
+	// Dates enregistrées avec le décalage de leur fuseau: comparer des instants
+	query := db.Where("balance > 0 AND julianday(expires_at) <= julianday(?)", now.UTC())
+	if shopID != nil {
+		query = query.Where("shop_id = ?", *shopID)
+	}
+
+	var expired []models.GiftCard
+	if err := query.Find(&expired).Error; err != nil {
+		log.Printf("❌ Cartes cadeaux: %v", err)
+		return
+	}
+	for _, card := range expired {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return moveGiftCardBalance(tx, card, models.GiftCardEntry{
+				Type:      models.GiftCardExpire,
+				Amount:    -card.Balance,
+				CreatedAt: *card.ExpiresAt,
+			})
+		})
+		if err != nil {
+			log.Printf("❌ Carte cadeau %s: %v", card.Code, err)
+		}
+	}
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// normalizeGiftCardCode - Code en majuscules, sans espaces
+func normalizeGiftCardCode(code string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(code), ""))
+}
+
+// generateGiftCardCode génère un code XXXX-XXXX-XXXX inutilisé dans le shop
+func generateGiftCardCode(db *gorm.DB, shopID uint) (string, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		token := make([]byte, 12)
+		if _, err := rand.Read(token); err != nil {
+			return "", err
+		}
+		var code strings.Builder
+		for i, b := range token {
+			if i > 0 && i%4 == 0 {
+				code.WriteByte('-')
+			}
+			code.WriteByte(giftCardCodeAlphabet[int(b)%len(giftCardCodeAlphabet)])
+		}
+		if _, err := findGiftCard(db, shopID, code.String()); err != nil {
+			return code.String(), nil
+		}
+	}
+	return "", errors.New("aucun code de carte cadeau disponible")
+}
+
+// findGiftCard charge la carte cadeau du shop par son code
+func findGiftCard(db *gorm.DB, shopID uint, code string) (models.GiftCard, error) {
+	var card models.GiftCard
+	if err := db.Where("shop_id = ? AND code = ?", shopID, normalizeGiftCardCode(code)).First(&card).Error; err != nil {
+		return card, fmt.Errorf("carte cadeau %s inconnue", normalizeGiftCardCode(code))
+	}
+	return card, nil
+}
+
+// giftCardExpiry - Expiration d'une carte émise maintenant (validité du shop, nil: sans expiration)
+func giftCardExpiry(db *gorm.DB, shopID uint, now time.Time) *time.Time {
+	var shop models.Shop
+	if err := db.Select("gift_card_validity_months").First(&shop, shopID).Error; err != nil || shop.GiftCardValidityMonths == 0 {
+		return nil
+	}
+	expiresAt := now.AddDate(0, shop.GiftCardValidityMonths, 0)
+	return &expiresAt
+}
+
+// giftCardUsable vérifie qu'une carte n'a pas expiré
+func giftCardUsable(card models.GiftCard, now time.Time) error {
+	if card.ExpiresAt != nil && !now.Before(*card.ExpiresAt) {
+		return fmt.Errorf("carte cadeau %s expirée", card.Code)
+	}
+	return nil
+}
+
+// saleOnlyKindError - Erreur des moyens de paiement réservés aux ventes et remboursements
+func saleOnlyKindError(kind models.PaymentKind) error {
+	switch kind {
+	case models.PaymentLoyalty:
+		return errLoyaltySaleOnly
+	case models.PaymentStoreCredit:
+		return errGiftCardSaleOnly
+	}
+	return nil
+}
+
+// rejectSaleOnlyPayments refuse les points fidélité et cartes cadeaux hors ventes et remboursements
+func rejectSaleOnlyPayments(payments []models.Payment) error {
+	for _, payment := range payments {
+		if err := saleOnlyKindError(payment.Method); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkGiftCardRedemption vérifie les règlements par carte cadeau d'une vente: code saisi,
+// carte valide et solde suffisant
+func checkGiftCardRedemption(db *gorm.DB, payments []models.Payment, now time.Time) error {
+	needed := map[uint]models.Money{}
+	for _, payment := range payments {
+		if payment.Method != models.PaymentStoreCredit {
+			continue
+		}
+		if payment.GiftCardID == nil {
+			return errors.New("gift_card_code requis pour payer avec une carte cadeau ou un avoir")
+		}
+		needed[*payment.GiftCardID] += payment.Amount
+	}
+	for id, amount := range needed {
+		var card models.GiftCard
+		if err := db.First(&card, id).Error; err != nil {
+			return err
+		}
+		if err := giftCardUsable(card, now); err != nil {
+			return err
+		}
+		if card.Balance < amount {
+			return fmt.Errorf("%w (%s: solde %s)", errGiftCardBalance, card.Code, card.Balance)
+		}
+	}
+	return nil
+}
+
+// checkGiftCardCredit vérifie les cartes existantes créditées par un remboursement
+func checkGiftCardCredit(db *gorm.DB, payments []models.Payment, now time.Time) error {
+	for _, payment := range payments {
+		if payment.Method != models.PaymentStoreCredit || payment.GiftCardID == nil {
+			continue
+		}
+		var card models.GiftCard
+		if err := db.First(&card, *payment.GiftCardID).Error; err != nil {
+			return err
+		}
+		if err := giftCardUsable(card, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redeemGiftCards débite les cartes cadeaux ayant réglé la vente
+func redeemGiftCards(tx *gorm.DB, sale models.Transaction, userID uint) error {
+	for _, payment := range sale.Payments {
+		if payment.Method != models.PaymentStoreCredit || payment.GiftCardID == nil {
+			continue
+		}
+		var card models.GiftCard
+		if err := tx.First(&card, *payment.GiftCardID).Error; err != nil {
+			return err
+		}
+		if err := moveGiftCardBalance(tx, card, models.GiftCardEntry{
+			Type:          models.GiftCardRedeem,
+			Amount:        -payment.Amount,
+			TransactionID: &sale.ID,
+			UserID:        &userID,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// creditGiftCards crédite les règlements en avoir d'un remboursement: sur la carte saisie,
+// ou sur un nouvel avoir au nom du client de la vente. Retourne les cartes créditées.
+func creditGiftCards(tx *gorm.DB, refund models.Transaction, userID uint, now time.Time) ([]models.GiftCard, error) {
+	cards := []models.GiftCard{}
+	for _, payment := range refund.Payments {
+		if payment.Method != models.PaymentStoreCredit {
+			continue
+		}
+		entry := models.GiftCardEntry{
+			Type:          models.GiftCardCredit,
+			Amount:        payment.Amount,
+			TransactionID: &refund.ID,
+			UserID:        &userID,
+		}
+
+		var card models.GiftCard
+		if payment.GiftCardID != nil {
+			if err := tx.First(&card, *payment.GiftCardID).Error; err != nil {
+				return nil, err
+			}
+			if err := moveGiftCardBalance(tx, card, entry); err != nil {
+				return nil, err
+			}
+		} else {
+			code, err := generateGiftCardCode(tx, refund.ShopID)
+			if err != nil {
+				return nil, err
+			}
+			entry.Type, entry.ShopID = models.GiftCardIssue, refund.ShopID
+			card = models.GiftCard{
+				Code:          code,
+				Source:        models.GiftCardRefund,
+				InitialAmount: payment.Amount,
+				Balance:       payment.Amount,
+				ExpiresAt:     giftCardExpiry(tx, refund.ShopID, now),
+				CustomerID:    refund.CustomerID,
+				TransactionID: &refund.ID,
+				UserID:        &userID,
+				ShopID:        refund.ShopID,
+				Entries:       []models.GiftCardEntry{entry},
+			}
+			if err := tx.Create(&card).Error; err != nil {
+				return nil, err
+			}
+			if err := tx.Model(&models.Payment{}).Where("id = ?", payment.ID).
+				Updates(map[string]interface{}{"gift_card_id": card.ID, "reference": card.Code}).Error; err != nil {
+				return nil, err
+			}
+		}
+		tx.First(&card, card.ID)
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+// revertGiftCardEntries annule les mouvements de cartes cadeaux d'une transaction supprimée.
+// Un avoir émis par un remboursement est supprimé, s'il n'a pas été utilisé.
+func revertGiftCardEntries(tx *gorm.DB, shopID, transactionID uint) error {
+	var entries []models.GiftCardEntry
+	if err := tx.Where("shop_id = ? AND transaction_id = ?", shopID, transactionID).Find(&entries).Error; err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		result := tx.Model(&models.GiftCard{}).Where("id = ? AND balance >= ?", entry.GiftCardID, entry.Amount).
+			Update("balance", gorm.Expr("balance - ?", entry.Amount))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errGiftCardUsed
+		}
+	}
+	if err := tx.Where("shop_id = ? AND transaction_id = ?", shopID, transactionID).Delete(&models.GiftCardEntry{}).Error; err != nil {
+		return err
+	}
+
+	var issued []models.GiftCard
+	if err := tx.Where("shop_id = ? AND transaction_id = ? AND source = ?", shopID, transactionID, models.GiftCardRefund).
+		Find(&issued).Error; err != nil {
+		return err
+	}
+	for _, card := range issued {
+		var others int64
+		tx.Model(&models.GiftCardEntry{}).Where("gift_card_id = ?", card.ID).Count(&others)
+		if others > 0 {
+			return errGiftCardUsed
+		}
+		if err := tx.Delete(&card).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveGiftCardBalance enregistre un mouvement et met à jour le solde (jamais négatif)
+func moveGiftCardBalance(tx *gorm.DB, card models.GiftCard, entry models.GiftCardEntry) error {
+	result := tx.Model(&models.GiftCard{}).Where("id = ? AND balance + ? >= 0", card.ID, entry.Amount).
+		Update("balance", gorm.Expr("balance + ?", entry.Amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errGiftCardBalance
+	}
+	entry.GiftCardID, entry.ShopID = card.ID, card.ShopID
+	return tx.Create(&entry).Error
+}
+
+// giftCardBalance - Solde des cartes cadeaux non expirées d'un client
+func giftCardBalance(db *gorm.DB, shopID, customerID uint) models.Money {
+	var balance models.Money
+	db.Model(&models.GiftCard{}).Select("COALESCE(SUM(balance), 0)").
+		Where("shop_id = ? AND customer_id = ? AND (expires_at IS NULL OR julianday(expires_at) > julianday(?))", shopID, customerID, time.Now().UTC()).
+		Scan(&balance)
+	return balance
+}
+
+// computeGiftCardLiability calcule l'encours des cartes cadeaux (cartes avec un solde,
+// expiration la plus proche d'abord) et les mouvements cumulés par type
+func computeGiftCardLiability(db *gorm.DB, shopID uint) ([]GiftCardLine, GiftCardLiability, error) {
+	var totals GiftCardLiability
+	now := time.Now()
+	expireGiftCards(db, now, &shopID)
+
+	var cards []models.GiftCard
+	if err := db.Preload("Customer").Where("shop_id = ? AND balance > 0", shopID).
+		Order("CASE WHEN expires_at IS NULL THEN 1 ELSE 0 END, julianday(expires_at) ASC, id ASC").
+		Find(&cards).Error; err != nil {
+		return nil, totals, err
+	}
+
+	lines := make([]GiftCardLine, 0, len(cards))
+	soon := now.AddDate(0, 0, giftCardExpiringDays)
+	for _, card := range cards {
+		line := GiftCardLine{
+			Code:          card.Code,
+			Source:        card.Source,
+			CustomerID:    card.CustomerID,
+			InitialAmount: card.InitialAmount,
+			Balance:       card.Balance,
+			CreatedAt:     card.CreatedAt,
+			ExpiresAt:     card.ExpiresAt,
+		}
+		if card.Customer != nil {
+			line.CustomerName = card.Customer.Name
+		}
+		lines = append(lines, line)
+		totals.Cards++
+		totals.Outstanding += card.Balance
+		if card.ExpiresAt != nil && card.ExpiresAt.Before(soon) {
+			totals.Expiring += card.Balance
+		}
+	}
+
+	var movements []struct {
+		Type   models.GiftCardEntryType
+		Source models.GiftCardSource
+		Amount models.Money
+	}
+	if err := db.Table("gift_card_entries e").
+		Select("e.type, g.source, COALESCE(SUM(e.amount), 0) as amount").
+		Joins("JOIN gift_cards g ON g.id = e.gift_card_id").
+		Where("e.shop_id = ?", shopID).
+		Group("e.type, g.source").
+		Scan(&movements).Error; err != nil {
+		return nil, totals, err
+	}
+	for _, movement := range movements {
+		switch movement.Type {
+		case models.GiftCardIssue, models.GiftCardCredit:
+			if movement.Type == models.GiftCardIssue && movement.Source == models.GiftCardSold {
+				totals.Sold += movement.Amount
+			} else {
+				totals.Refunds += movement.Amount
+			}
+		case models.GiftCardRedeem:
+			totals.Redeemed -= movement.Amount
+		case models.GiftCardExpire:
+			totals.Expired -= movement.Amount
+		case models.GiftCardAdjust:
+			totals.Adjusted += movement.Amount
+		}
+	}
+	return lines, totals, nil
+}
+
+// respondGiftCard répond avec la carte cadeau, son client et ses mouvements
+func respondGiftCard(c *gin.Context, status int, message string, cardID uint) {
+	var card models.GiftCard
+	database.GetDB().Preload("Customer").
+		Preload("Entries", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC, id ASC") }).
+		First(&card, cardID)
+
+	response := gin.H{"gift_card": card, "expired": giftCardUsable(card, time.Now()) != nil}
+	if message != "" {
+		response["message"] = message
+	}
+	c.JSON(status, response)
+}
\ No newline at end of file
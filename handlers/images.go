@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"electronic-shop-api/config"
+	"electronic-shop-api/database"
+	"electronic-shop-api/images"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"electronic-shop-api/storage"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// STRUCTURE DE REQUÊTE
+// ========================================
+
+type ReorderImagesInput struct {
+	ImageIDs []uint `json:"image_ids" binding:"required,min=1"`
+}
+
+// maxImagesPerRequest limite le nombre de fichiers par envoi
+const maxImagesPerRequest = 10
+
+// ========================================
+// UPLOAD PRODUCT IMAGES
+// ========================================
+
+func UploadProductImages(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	product, ok := findShopProduct(c, shopID)
+	if !ok {
+		return
+	}
+
+	// Limiter la taille totale de la requête
+	maxSize := config.AppConfig.MaxUploadSize
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize*maxImagesPerRequest+(1<<20))
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Formulaire multipart invalide ou trop volumineux"})
+		return
+	}
+	files := append(form.File["images"], form.File["image"]...)
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Aucun fichier reçu (champ images)"})
+		return
+	}
+	if len(files) > maxImagesPerRequest {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Maximum %d images par envoi", maxImagesPerRequest)})
+		return
+	}
+
+	// Valider et traiter toutes les images avant d'enregistrer quoi que ce soit
+	processed := make([]*images.Processed, 0, len(files))
+	originals := make([][]byte, 0, len(files))
+	for _, file := range files {
+		if file.Size > maxSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": fmt.Sprintf("%s dépasse la taille maximale de %d Mo", file.Filename, maxSize>>20),
+			})
+			return
+		}
+
+		data, err := readUpload(file, maxSize)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Lecture impossible de " + file.Filename})
+			return
+		}
+
+		result, err := images.Process(data)
+		if errors.Is(err, images.ErrTooManyPixels) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": file.Filename + ": " + err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": file.Filename + ": " + err.Error()})
+			return
+		}
+		processed = append(processed, result)
+		originals = append(originals, data)
+	}
+
+	db := database.GetDB()
+	store := storage.Get()
+	ctx := c.Request.Context()
+
+	// Les nouvelles images se placent après les existantes
+	var position int
+	db.Model(&models.ProductImage{}).Where("product_id = ?", product.ID).
+		Select("COALESCE(MAX(position) + 1, 0)").Scan(&position)
+
+	created := make([]models.ProductImage, 0, len(processed))
+	var storedKeys []string
+	for i, result := range processed {
+		image, keys, err := storeProductImage(ctx, store, product, result, originals[i])
+		storedKeys = append(storedKeys, keys...)
+		if err != nil {
+			deleteStoredKeys(store, storedKeys)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement des images"})
+			return
+		}
+		image.Position = position + i
+		created = append(created, image)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&created).Error; err != nil {
+			return err
+		}
+		return syncProductCover(tx, product.ID)
+	})
+	if err != nil {
+		deleteStoredKeys(store, storedKeys)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement des images"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Images ajoutées",
+		"images":  created,
+		"count":   len(created),
+	})
+}
+
+// ========================================
+// GET PRODUCT IMAGES
+// ========================================
+
+func GetProductImages(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	product, ok := findShopProduct(c, shopID)
+	if !ok {
+		return
+	}
+
+	var productImages []models.ProductImage
+	if err := database.GetDB().Where("product_id = ?", product.ID).
+		Order("position ASC, id ASC").Find(&productImages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des images"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"images": productImages, "count": len(productImages)})
+}
+
+// ========================================
+// REORDER PRODUCT IMAGES
+// ========================================
+
+func ReorderProductImages(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	product, ok := findShopProduct(c, shopID)
+	if !ok {
+		return
+	}
+
+	var input ReorderImagesInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	var productImages []models.ProductImage
+	db.Where("product_id = ?", product.ID).Find(&productImages)
+
+	// La liste doit contenir exactement les images du produit
+	known := make(map[uint]bool, len(productImages))
+	for _, img := range productImages {
+		known[img.ID] = true
+	}
+	seen := map[uint]bool{}
+	for _, id := range input.ImageIDs {
+		if !known[id] || seen[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Image %d inconnue ou dupliquée", id)})
+			return
+		}
+		seen[id] = true
+	}
+	if len(seen) != len(productImages) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image_ids doit contenir toutes les images du produit"})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for position, id := range input.ImageIDs {
+			if err := tx.Model(&models.ProductImage{}).Where("id = ?", id).Update("position", position).Error; err != nil {
+				return err
+			}
+		}
+		return syncProductCover(tx, product.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour"})
+		return
+	}
+
+	db.Where("product_id = ?", product.ID).Order("position ASC, id ASC").Find(&productImages)
+	c.JSON(http.StatusOK, gin.H{"message": "Ordre des images mis à jour", "images": productImages})
+}
+
+// ========================================
+// DELETE PRODUCT IMAGE
+// ========================================
+
+func DeleteProductImage(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	product, ok := findShopProduct(c, shopID)
+	if !ok {
+		return
+	}
+
+	imageID, err := strconv.ParseUint(c.Param("imageID"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID d'image invalide"})
+		return
+	}
+
+	db := database.GetDB()
+	var image models.ProductImage
+	if err := db.Where("id = ? AND product_id = ?", imageID, product.ID).First(&image).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image non trouvée"})
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&image).Error; err != nil {
+			return err
+		}
+		return syncProductCover(tx, product.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression"})
+		return
+	}
+
+	deleteStoredKeys(storage.Get(), image.StorageKeys)
+	c.JSON(http.StatusOK, gin.H{"message": "Image supprimée"})
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// findShopProduct charge le produit :id du shop ou répond 400/404
+func findShopProduct(c *gin.Context, shopID uint) (models.Product, bool) {
+	var product models.Product
+
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de produit invalide"})
+		return product, false
+	}
+
+	// MULTI-TENANT
+	if err := database.GetDB().Where("id = ? AND shop_id = ?", productID, shopID).First(&product).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Produit non trouvé"})
+		return product, false
+	}
+	return product, true
+}
+
+// readUpload lit un fichier envoyé en refusant ce qui dépasse maxSize
+func readUpload(file *multipart.FileHeader, maxSize int64) ([]byte, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("fichier trop volumineux")
+	}
+	return data, nil
+}
+
+// storeProductImage enregistre l'original et les miniatures d'une image.
+// Retourne les clés écrites, même en cas d'erreur, pour permettre le nettoyage.
+func storeProductImage(ctx context.Context, store storage.Storage, product models.Product, result *images.Processed, original []byte) (models.ProductImage, []string, error) {
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		return models.ProductImage{}, nil, err
+	}
+	base := fmt.Sprintf("%sshop-%d/product-%d/%s", storage.PublicPrefix, product.ShopID, product.ID, hex.EncodeToString(token))
+
+	image := models.ProductImage{
+		ProductID:   product.ID,
+		ShopID:      product.ShopID,
+		ContentType: result.ContentType,
+		Width:       result.Width,
+		Height:      result.Height,
+		Size:        int64(len(original)),
+	}
+
+	var keys []string
+	originalKey := base + result.Extension
+	if err := store.Put(ctx, originalKey, bytes.NewReader(original), int64(len(original)), result.ContentType); err != nil {
+		return image, keys, err
+	}
+	keys = append(keys, originalKey)
+	image.URL = store.URL(originalKey)
+
+	urls := map[string]*string{
+		"thumbnail": &image.ThumbnailURL,
+		"medium":    &image.MediumURL,
+		"large":     &image.LargeURL,
+	}
+	for _, variant := range images.Variants {
+		encoded := result.Variants[variant.Name]
+		key := base + "_" + variant.Name + encoded.Extension
+		if err := store.Put(ctx, key, bytes.NewReader(encoded.Data), int64(len(encoded.Data)), encoded.ContentType); err != nil {
+			return image, keys, err
+		}
+		keys = append(keys, key)
+		*urls[variant.Name] = store.URL(key)
+	}
+
+	image.StorageKeys = keys
+	return image, keys, nil
+}
+
+// deleteStoredKeys supprime des fichiers du stockage (au mieux)
+func deleteStoredKeys(store storage.Storage, keys []string) {
+	for _, key := range keys {
+		store.Delete(context.Background(), key)
+	}
+}
+
+// syncProductCover garde Product.ImageURL sur la première image (compatibilité)
+func syncProductCover(tx *gorm.DB, productID uint) error {
+	var cover models.ProductImage
+	err := tx.Where("product_id = ?", productID).Order("position ASC, id ASC").First(&cover).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Model(&models.Product{}).Where("id = ?", productID).Update("image_url", cover.MediumURL).Error
+}
\ No newline at end of file
@@ -0,0 +1,816 @@
+package handlers
+
+import (
+	"bytes"
+	"electronic-shop-api/database"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding/charmap"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// CONFIGURATION DE L'IMPORT
+// ========================================
+
+const (
+	maxImportFileSize   = 10 << 20
+	maxImportRows       = 5000
+	importProgressEvery = 25 // Fréquence d'enregistrement de la progression (en lignes)
+)
+
+// importFields - Champs importables de CreateProductInput et en-têtes reconnus automatiquement
+var importFields = map[string][]string{
+	"name":              {"name", "nom", "designation", "produit", "libelle"},
+	"description":       {"description"},
+	"category":          {"category", "categorie", "category-id"},
+	"sku":               {"sku", "reference", "ref"},
+	"barcode":           {"barcode", "code-barres", "code-barre", "ean", "upc", "gtin"},
+	"purchase_price":    {"purchase-price", "prix-achat", "prix-d-achat", "cout"},
+	"purchase_currency": {"purchase-currency", "devise-achat", "devise-d-achat", "devise"},
+	"selling_price":     {"selling-price", "prix-vente", "prix-de-vente", "prix"},
+	"stock":             {"stock", "quantite", "qte"},
+	"image_url":         {"image-url", "image"},
+}
+
+// requiredImportFields - Colonnes sans lesquelles aucune ligne ne peut être valide
+var requiredImportFields = []string{"name", "purchase_price", "selling_price"}
+
+// importAttributePrefix - Préfixe des colonnes de fiche technique ("attr.ram")
+const importAttributePrefix = "attr."
+
+// errDryRun annule la transaction d'une simulation
+var errDryRun = errors.New("simulation")
+
+// importMapping associe chaque champ à l'index de sa colonne dans le fichier
+type importMapping struct {
+	Fields     map[string]int
+	Attributes map[string]int
+}
+
+// importRow - Ligne du fichier à importer (Line = numéro de ligne, en-tête = 1)
+type importRow struct {
+	Line       int
+	Values     map[string]string
+	Attributes map[string]string
+}
+
+// importFieldError - Erreur rattachée à une colonne du fichier
+type importFieldError struct {
+	Field   string
+	Message string
+}
+
+func (e importFieldError) Error() string {
+	return e.Message
+}
+
+// importReport - Résultat d'une simulation ou d'un import
+type importReport struct {
+	Total   int
+	Created int
+	Updated int
+	Failed  int
+	Errors  []models.ImportRowError
+}
+
+// ========================================
+// IMPORT PRODUCTS (CSV / XLSX)
+// ========================================
+
+// ImportProducts importe des produits depuis un fichier CSV ou XLSX.
+// mode=dry_run (défaut) valide toutes les lignes sans rien enregistrer,
+// mode=upsert lance l'import en arrière-plan (mise à jour par SKU/code-barres).
+func ImportProducts(c *gin.Context) {
+	userID, shopID, _ := middleware.GetUserFromContext(c)
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportFileSize+(1<<20))
+
+	mode := c.DefaultPostForm("mode", "dry_run")
+	if mode != "dry_run" && mode != "upsert" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode invalide (dry_run ou upsert)"})
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Fichier manquant (champ file)"})
+		return
+	}
+	if file.Size > maxImportFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Fichier trop volumineux (%d Mo maximum)", maxImportFileSize>>20)})
+		return
+	}
+
+	data, err := readUpload(file, maxImportFileSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Lecture impossible du fichier"})
+		return
+	}
+
+	records, err := readSpreadsheet(file.Filename, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(records) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le fichier doit contenir une ligne d'en-tête et au moins une ligne de données"})
+		return
+	}
+
+	// Correspondance colonnes -> champs (automatique, complétée par le paramètre mapping)
+	var custom map[string]string
+	if raw := c.PostForm("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &custom); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mapping invalide: objet JSON {champ: colonne} attendu"})
+			return
+		}
+	}
+	header := records[0]
+	mapping, err := resolveImportMapping(header, custom)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   err.Error(),
+			"columns": header,
+			"fields":  importFieldNames(),
+		})
+		return
+	}
+
+	rows := parseImportRows(records, mapping)
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Aucune ligne de données"})
+		return
+	}
+	if len(rows) > maxImportRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Maximum %d lignes par import", maxImportRows)})
+		return
+	}
+
+	db := database.GetDB()
+
+	if mode == "dry_run" {
+		report, err := dryRunImport(db, shopID, rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la validation"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"dry_run":   true,
+			"columns":   header,
+			"mapping":   mapping.describe(header),
+			"total":     report.Total,
+			"valid":     report.Created + report.Updated,
+			"to_create": report.Created,
+			"to_update": report.Updated,
+			"invalid":   report.Failed,
+			"errors":    report.Errors,
+		})
+		return
+	}
+
+	// Un seul import à la fois par shop
+	var running int64
+	db.Model(&models.ImportJob{}).
+		Where("shop_id = ? AND status IN ?", shopID, []models.ImportStatus{models.ImportPending, models.ImportRunning}).
+		Count(&running)
+	if running > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Un import est déjà en cours pour ce shop"})
+		return
+	}
+
+	job := models.ImportJob{
+		FileName: file.Filename,
+		Status:   models.ImportPending,
+		Total:    len(rows),
+		Errors:   []models.ImportRowError{},
+		UserID:   userID,
+		ShopID:   shopID,
+	}
+	if err := db.Create(&job).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création de l'import"})
+		return
+	}
+
+	go runImportJob(job, rows)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Import lancé",
+		"mapping": mapping.describe(header),
+		"job":     job,
+	})
+}
+
+// ========================================
+// IMPORT JOBS (progression)
+// ========================================
+
+func GetImportJobs(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	var jobs []models.ImportJob
+	if err := database.GetDB().Where("shop_id = ?", shopID).
+		Omit("errors").Order("created_at DESC").Limit(20).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des imports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs, "count": len(jobs)})
+}
+
+func GetImportJob(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	jobID, err := strconv.ParseUint(c.Param("jobID"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID d'import invalide"})
+		return
+	}
+
+	// MULTI-TENANT
+	var job models.ImportJob
+	if err := database.GetDB().Where("id = ? AND shop_id = ?", jobID, shopID).First(&job).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import non trouvé"})
+		return
+	}
+
+	progress := 100
+	if job.Total > 0 {
+		progress = job.Processed * 100 / job.Total
+	}
+	c.JSON(http.StatusOK, gin.H{"job": job, "progress": progress})
+}
+
+// ========================================
+// EXÉCUTION
+// ========================================
+
+// dryRunImport valide toutes les lignes dans une transaction annulée à la fin:
+// les contrôles (unicité, catégories créées, doublons dans le fichier) sont ceux de l'import réel
+func dryRunImport(db *gorm.DB, shopID uint, rows []importRow) (importReport, error) {
+	report := importReport{Total: len(rows), Errors: []models.ImportRowError{}}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, row := range rows {
+			var created bool
+			err := tx.Transaction(func(rowTx *gorm.DB) error {
+				var err error
+				created, err = importProduct(rowTx, shopID, 0, row)
+				return err
+			})
+			report.record(row, created, err)
+		}
+		return errDryRun
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return report, err
+	}
+	return report, nil
+}
+
+// runImportJob importe les lignes une par une (une transaction par ligne) en enregistrant la progression
+func runImportJob(job models.ImportJob, rows []importRow) {
+	db := database.GetDB()
+	report := importReport{Total: len(rows), Errors: []models.ImportRowError{}}
+
+	save := func(status models.ImportStatus, processed int, message string) {
+		job.Status, job.Processed, job.Message = status, processed, message
+		job.Created, job.Updated, job.Failed, job.Errors = report.Created, report.Updated, report.Failed, report.Errors
+		if status == models.ImportCompleted || status == models.ImportFailed {
+			now := time.Now()
+			job.FinishedAt = &now
+		}
+		// Select explicite: les compteurs à zéro doivent aussi être enregistrés
+		db.Model(&job).
+			Select("status", "processed", "created", "updated", "failed", "errors", "message", "finished_at").
+			Updates(&job)
+	}
+
+	processed := 0
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("❌ Import %d interrompu: %v", job.ID, r)
+			save(models.ImportFailed, processed, "Erreur interne pendant l'import")
+		}
+	}()
+
+	save(models.ImportRunning, 0, "")
+	for _, row := range rows {
+		var created bool
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			created, err = importProduct(tx, job.ShopID, job.UserID, row)
+			return err
+		})
+		report.record(row, created, err)
+
+		processed++
+		if processed%importProgressEvery == 0 {
+			save(models.ImportRunning, processed, "")
+		}
+	}
+
+	save(models.ImportCompleted, processed, fmt.Sprintf("%d créé(s), %d mis à jour, %d en erreur", report.Created, report.Updated, report.Failed))
+}
+
+// record comptabilise le résultat d'une ligne
+func (r *importReport) record(row importRow, created bool, err error) {
+	switch {
+	case err != nil:
+		r.Failed++
+		rowError := models.ImportRowError{Row: row.Line, Error: err.Error()}
+		var fieldErr importFieldError
+		if errors.As(err, &fieldErr) {
+			rowError.Column = fieldErr.Field
+		}
+		r.Errors = append(r.Errors, rowError)
+	case created:
+		r.Created++
+	default:
+		r.Updated++
+	}
+}
+
+// importProduct enregistre une ligne avec les règles de CreateProduct:
+// mise à jour du produit ayant le même SKU (ou code-barres), création sinon.
+// Retourne true si le produit a été créé.
+func importProduct(tx *gorm.DB, shopID, userID uint, row importRow) (bool, error) {
+	input, err := row.input()
+	if err != nil {
+		return false, err
+	}
+	if err := validateImportInput(input); err != nil {
+		return false, err
+	}
+
+	existing, err := findImportTarget(tx, shopID, input.SKU, input.Barcode)
+	if err != nil {
+		return false, err
+	}
+
+	// Colonnes absentes ou vides: conserver les valeurs du produit existant
+	if existing != nil {
+		if input.Description == "" {
+			input.Description = existing.Description
+		}
+		if input.ImageURL == "" {
+			input.ImageURL = existing.ImageURL
+		}
+		if row.Values["stock"] == "" {
+			input.Stock = existing.Stock
+		}
+		if input.Stock < existing.Reserved {
+			return false, importFieldError{Field: "stock", Message: fmt.Sprintf("stock inférieur aux unités réservées (%d)", existing.Reserved)}
+		}
+		if input.SKU == "" && existing.SKU != nil {
+			input.SKU = *existing.SKU
+		}
+		if input.Barcode == "" && existing.Barcode != nil {
+			input.Barcode = *existing.Barcode
+		}
+		if input.Category == "" {
+			input.CategoryID = existing.CategoryID
+		}
+		if input.PurchaseCurrency == "" {
+			input.PurchaseCurrency = existing.PurchaseCurrency
+		}
+	}
+
+	// La catégorie est résolue d'abord pour typer les valeurs de fiche technique
+	category, err := resolveProductCategory(tx, shopID, input.CategoryID, input.Category)
+	if err != nil {
+		return false, importFieldError{Field: "category", Message: err.Error()}
+	}
+	if category != nil {
+		input.CategoryID, input.Category = &category.ID, ""
+	}
+
+	input.Attributes = map[string]interface{}{}
+	if existing != nil {
+		if input.Attributes, err = keptAttributeValues(tx, shopID, existing.ID, input.CategoryID); err != nil {
+			return false, err
+		}
+	}
+	if err := convertImportAttributes(tx, shopID, input.CategoryID, row.Attributes, input.Attributes); err != nil {
+		return false, err
+	}
+
+	productID := uint(0)
+	if existing != nil {
+		productID = existing.ID
+	}
+	product, attributes, _, err := prepareProduct(tx, shopID, productID, input)
+	if err != nil {
+		return false, err
+	}
+
+	if existing == nil {
+		if err := tx.Create(&product).Error; err != nil {
+			return false, err
+		}
+		return true, replaceProductAttributes(tx, product.ID, attributes)
+	}
+
+	updates := map[string]interface{}{
+		"name":           product.Name,
+		"description":    product.Description,
+		"category":       product.Category,
+		"category_id":    product.CategoryID,
+		"sku":            product.SKU,
+		"barcode":        product.Barcode,
+		"purchase_price": product.PurchasePrice,
+		"selling_price":  product.SellingPrice,
+		"stock":          product.Stock,
+		"image_url":      product.ImageURL,
+
+		"purchase_currency": product.PurchaseCurrency,
+	}
+	if product.SellingPrice != existing.SellingPrice {
+		updates["price_updated_at"] = time.Now()
+	}
+	if err := recordPriceChanges(tx, *existing, updates, models.PriceHistory{Source: models.PriceSourceImport, UserID: userRef(userID)}); err != nil {
+		return false, err
+	}
+	if err := tx.Model(existing).Updates(updates).Error; err != nil {
+		return false, err
+	}
+	return false, replaceProductAttributes(tx, existing.ID, attributes)
+}
+
+// findImportTarget cherche le produit à mettre à jour: par SKU, puis par code-barres
+func findImportTarget(tx *gorm.DB, shopID uint, sku, barcode string) (*models.Product, error) {
+	var product models.Product
+	if sku = normalizeCode(sku); sku != "" {
+		err := tx.Where("shop_id = ? AND sku = ?", shopID, sku).First(&product).Error
+		if err == nil {
+			return &product, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	if barcode = normalizeCode(barcode); barcode != "" {
+		err := tx.Where("shop_id = ? AND barcode IN ?", shopID, codeVariants(barcode)).First(&product).Error
+		if err == nil {
+			return &product, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// validateImportInput applique les règles binding de CreateProductInput
+func validateImportInput(input CreateProductInput) error {
+	err := binding.Validator.ValidateStruct(&input)
+	var fieldErrors validator.ValidationErrors
+	if err == nil || !errors.As(err, &fieldErrors) {
+		return err
+	}
+
+	fe := fieldErrors[0]
+	field := fe.Field()
+	if structField, ok := reflect.TypeOf(input).FieldByName(fe.StructField()); ok {
+		field = strings.Split(structField.Tag.Get("json"), ",")[0]
+	}
+
+	message := fmt.Sprintf("%s invalide", field)
+	switch fe.Tag() {
+	case "required":
+		message = fmt.Sprintf("%s obligatoire", field)
+	case "gt":
+		message = fmt.Sprintf("%s doit être supérieur à %s", field, fe.Param())
+	case "gte":
+		message = fmt.Sprintf("%s doit être supérieur ou égal à %s", field, fe.Param())
+	}
+	return importFieldError{Field: field, Message: message}
+}
+
+// convertImportAttributes convertit les valeurs texte des colonnes attr.* selon le type de l'attribut
+func convertImportAttributes(db *gorm.DB, shopID uint, categoryID *uint, raw map[string]string, values map[string]interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	schema, err := categorySchema(db, shopID, categoryID)
+	if err != nil {
+		return err
+	}
+	types := make(map[string]models.AttributeType, len(schema))
+	for _, a := range schema {
+		types[a.Name] = a.Type
+	}
+
+	for name, text := range raw {
+		column := importAttributePrefix + name
+		switch types[name] {
+		case models.AttributeNumber:
+			number, err := parseDecimal(text)
+			if err != nil {
+				return importFieldError{Field: column, Message: fmt.Sprintf("l'attribut %s doit être un nombre", name)}
+			}
+			values[name] = number
+		case models.AttributeBoolean:
+			flag, err := parseBoolean(text)
+			if err != nil {
+				return importFieldError{Field: column, Message: fmt.Sprintf("l'attribut %s doit être oui ou non", name)}
+			}
+			values[name] = flag
+		default:
+			// Texte, liste ou attribut inconnu (rejeté par buildProductAttributes)
+			values[name] = text
+		}
+	}
+	return nil
+}
+
+// ========================================
+// LECTURE DU FICHIER
+// ========================================
+
+// readSpreadsheet lit un fichier CSV ou XLSX (première feuille) en lignes de cellules
+func readSpreadsheet(filename string, data []byte) ([][]string, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == ".xlsx" || (ext != ".csv" && bytes.HasPrefix(data, []byte("PK\x03\x04"))) {
+		return readXLSX(data)
+	}
+	if ext != ".csv" && ext != ".txt" && ext != "" {
+		return nil, errors.New("format non supporté (CSV ou XLSX attendu)")
+	}
+	return readCSV(data)
+}
+
+func readXLSX(data []byte) ([][]string, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("fichier XLSX illisible")
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, errors.New("le classeur ne contient aucune feuille")
+	}
+	rows, err := f.GetRows(sheets[0])
+	if err != nil {
+		return nil, errors.New("fichier XLSX illisible")
+	}
+	return rows, nil
+}
+
+// readCSV accepte les séparateurs , ; et tabulation ainsi que les exports Excel en Windows-1252
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		decoded, err := charmap.Windows1252.NewDecoder().Bytes(data)
+		if err != nil {
+			return nil, errors.New("encodage du fichier non reconnu (UTF-8 attendu)")
+		}
+		data = decoded
+	}
+
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	delimiter := ','
+	best := bytes.Count(firstLine, []byte(","))
+	for _, candidate := range []rune{';', '\t'} {
+		if n := bytes.Count(firstLine, []byte(string(candidate))); n > best {
+			delimiter, best = candidate, n
+		}
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	var records [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CSV invalide: %v", err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// resolveImportMapping associe les colonnes du fichier aux champs produit.
+// custom ({"selling_price": "Prix TTC", "attr.ram": "RAM"}) complète la détection automatique.
+func resolveImportMapping(header []string, custom map[string]string) (importMapping, error) {
+	mapping := importMapping{Fields: map[string]int{}, Attributes: map[string]int{}}
+
+	aliases := map[string]string{}
+	for field, names := range importFields {
+		for _, name := range names {
+			aliases[name] = field
+		}
+	}
+
+	// Détection automatique d'après l'en-tête
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+		if name, ok := strings.CutPrefix(column, importAttributePrefix); ok && name != "" {
+			mapping.Attributes[name] = i
+			continue
+		}
+		if field, ok := aliases[models.Slugify(column)]; ok {
+			if _, taken := mapping.Fields[field]; !taken {
+				mapping.Fields[field] = i
+			}
+		}
+	}
+
+	// Correspondances explicites
+	for field, column := range custom {
+		index := -1
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), strings.TrimSpace(column)) {
+				index = i
+				break
+			}
+		}
+
+		if name, ok := strings.CutPrefix(field, importAttributePrefix); ok && name != "" {
+			if index < 0 {
+				return mapping, fmt.Errorf("colonne introuvable pour %s: %s", field, column)
+			}
+			mapping.Attributes[name] = index
+			continue
+		}
+		if _, ok := importFields[field]; !ok {
+			return mapping, fmt.Errorf("champ inconnu dans mapping: %s", field)
+		}
+		if column == "" {
+			// Ignorer une colonne détectée automatiquement
+			delete(mapping.Fields, field)
+			continue
+		}
+		if index < 0 {
+			return mapping, fmt.Errorf("colonne introuvable pour %s: %s", field, column)
+		}
+		mapping.Fields[field] = index
+	}
+
+	var missing []string
+	for _, field := range requiredImportFields {
+		if _, ok := mapping.Fields[field]; !ok {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		return mapping, fmt.Errorf("colonnes obligatoires non trouvées: %s (préciser le paramètre mapping)", strings.Join(missing, ", "))
+	}
+	return mapping, nil
+}
+
+// describe retourne la correspondance champ -> en-tête de colonne
+func (m importMapping) describe(header []string) map[string]string {
+	result := make(map[string]string, len(m.Fields)+len(m.Attributes))
+	for field, i := range m.Fields {
+		result[field] = header[i]
+	}
+	for name, i := range m.Attributes {
+		result[importAttributePrefix+name] = header[i]
+	}
+	return result
+}
+
+// parseImportRows extrait les valeurs des lignes non vides
+func parseImportRows(records [][]string, mapping importMapping) []importRow {
+	cell := func(record []string, i int) string {
+		if i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var rows []importRow
+	for n, record := range records[1:] {
+		empty := true
+		for _, value := range record {
+			if strings.TrimSpace(value) != "" {
+				empty = false
+				break
+			}
+		}
+		if empty {
+			continue
+		}
+
+		row := importRow{Line: n + 2, Values: map[string]string{}, Attributes: map[string]string{}}
+		for field, i := range mapping.Fields {
+			row.Values[field] = cell(record, i)
+		}
+		for name, i := range mapping.Attributes {
+			if value := cell(record, i); value != "" {
+				row.Attributes[name] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// input convertit une ligne en CreateProductInput
+func (r importRow) input() (CreateProductInput, error) {
+	input := CreateProductInput{
+		Name:        r.Values["name"],
+		Description: r.Values["description"],
+		Category:    r.Values["category"],
+		SKU:         r.Values["sku"],
+		Barcode:     r.Values["barcode"],
+		ImageURL:    r.Values["image_url"],
+
+		PurchaseCurrency: r.Values["purchase_currency"],
+	}
+
+	prices := []struct {
+		field  string
+		target *models.Money
+	}{
+		{"purchase_price", &input.PurchasePrice},
+		{"selling_price", &input.SellingPrice},
+	}
+	for _, price := range prices {
+		field, target := price.field, price.target
+		if value := r.Values[field]; value != "" {
+			amount, err := parseAmount(value)
+			if err != nil {
+				return input, importFieldError{Field: field, Message: fmt.Sprintf("%s: nombre invalide (%s)", field, value)}
+			}
+			*target = amount
+		}
+	}
+
+	if value := r.Values["stock"]; value != "" {
+		number, err := parseDecimal(value)
+		if err != nil || number != float64(int(number)) {
+			return input, importFieldError{Field: "stock", Message: fmt.Sprintf("stock: nombre entier attendu (%s)", value)}
+		}
+		input.Stock = int(number)
+	}
+	return input, nil
+}
+
+// parseDecimal lit un nombre au format français ("1 299,90") ou anglais ("1,299.90")
+func parseDecimal(value string) (float64, error) {
+	return strconv.ParseFloat(normalizeDecimal(value), 64)
+}
+
+// parseAmount lit un montant sans passer par un flottant (centimes exacts)
+func parseAmount(value string) (models.Money, error) {
+	return models.ParseMoney(normalizeDecimal(value))
+}
+
+// normalizeDecimal retire les séparateurs de milliers et utilise le point décimal
+func normalizeDecimal(value string) string {
+	value = strings.NewReplacer(" ", "", "\u00a0", "", "\u202f", "").Replace(strings.TrimSpace(value))
+
+	// Le dernier séparateur rencontré est le séparateur décimal
+	if strings.LastIndex(value, ",") > strings.LastIndex(value, ".") {
+		value = strings.ReplaceAll(value, ".", "")
+		value = strings.ReplaceAll(value, ",", ".")
+	} else {
+		value = strings.ReplaceAll(value, ",", "")
+	}
+	return value
+}
+
+// parseBoolean accepte oui/non, yes/no, true/false, vrai/faux et 1/0
+func parseBoolean(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "oui", "yes", "true", "vrai", "1", "x":
+		return true, nil
+	case "non", "no", "false", "faux", "0":
+		return false, nil
+	}
+	return false, errors.New("booléen invalide")
+}
+
+// importFieldNames liste les champs disponibles pour le paramètre mapping
+func importFieldNames() []string {
+	return []string{"name", "description", "category", "sku", "barcode", "purchase_price", "purchase_currency", "selling_price", "stock", "image_url", "attr.<nom>"}
+}
\ No newline at end of file
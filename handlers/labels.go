@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"bytes"
+	"electronic-shop-api/database"
+	"electronic-shop-api/labels"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// STRUCTURE DE REQUÊTE
+// ========================================
+
+type PrintLabelsInput struct {
+	ProductIDs        []uint `json:"product_ids"`
+	PriceChangedSince string `json:"price_changed_since"` // YYYY-MM-DD ou RFC3339
+	Symbology         string `json:"symbology" binding:"omitempty,oneof=auto code128 ean13 qr"`
+}
+
+// ========================================
+// GET PRODUCT BARCODE IMAGE (PNG / SVG)
+// ========================================
+
+func GetProductBarcode(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de produit invalide"})
+		return
+	}
+
+	db := database.GetDB()
+	var product models.Product
+
+	// MULTI-TENANT
+	if err := db.Where("id = ? AND shop_id = ?", productID, shopID).First(&product).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Produit non trouvé"})
+		return
+	}
+
+	// Contenu: code du produit, ou lien WhatsApp pour un QR destiné aux clients
+	var content string
+	symbology := labels.Symbology(c.DefaultQuery("type", "auto"))
+	if c.Query("content") == "whatsapp" {
+		var shop models.Shop
+		if err := db.First(&shop, shopID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Shop non trouvé"})
+			return
+		}
+		content = models.GenerateWhatsAppLink(shop.WhatsAppNumber, product.Name)
+		symbology = labels.QR
+	} else {
+		content = productCode(product)
+		if content == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Ce produit n'a ni code-barres ni SKU. Utilisez POST /products/barcodes"})
+			return
+		}
+	}
+	if symbology == "auto" {
+		symbology = autoSymbology(content)
+	}
+
+	symbol, err := labels.Encode(symbology, content)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	moduleSize, _ := strconv.Atoi(c.DefaultQuery("module", "3"))
+	height, _ := strconv.Atoi(c.DefaultQuery("height", "80"))
+	if moduleSize < 1 || moduleSize > 20 || height < 10 || height > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "module (1-20) ou height (10-1000) invalide"})
+		return
+	}
+
+	var buf bytes.Buffer
+	contentType := "image/png"
+	if c.DefaultQuery("format", "png") == "svg" {
+		contentType = "image/svg+xml"
+		err = symbol.WriteSVG(&buf, moduleSize, height)
+	} else {
+		err = symbol.WritePNG(&buf, moduleSize, height)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la génération du code-barres"})
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// ========================================
+// PRINT SHELF LABELS (PDF)
+// ========================================
+
+func PrintLabels(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	var input PrintLabelsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	if len(input.ProductIDs) == 0 && input.PriceChangedSince == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "product_ids ou price_changed_since est requis"})
+		return
+	}
+
+	db := database.GetDB()
+
+	// MULTI-TENANT
+	query := db.Where("shop_id = ?", shopID).Order("name ASC")
+	if len(input.ProductIDs) > 0 {
+		query = query.Where("id IN ?", input.ProductIDs)
+	}
+	if input.PriceChangedSince != "" {
+		since, err := parseDate(db, shopID, input.PriceChangedSince)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "price_changed_since invalide (YYYY-MM-DD attendu)"})
+			return
+		}
+		query = reportPeriod{From: &since}.apply(query, "COALESCE(price_updated_at, created_at)")
+	}
+
+	var products []models.Product
+	if err := query.Find(&products).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des produits"})
+		return
+	}
+
+	// Tous les produits doivent avoir un code scannable
+	var missing []gin.H
+	sheet := make([]labels.Label, 0, len(products))
+	for _, p := range products {
+		code := productCode(p)
+		if code == "" {
+			missing = append(missing, gin.H{"id": p.ID, "name": p.Name})
+			continue
+		}
+
+		symbology := labels.Symbology(input.Symbology)
+		if symbology == "" || symbology == "auto" || (symbology == labels.EAN13 && !models.IsValidGTIN(code)) {
+			symbology = autoSymbology(code)
+		}
+		symbol, err := labels.Encode(symbology, code)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: %s", p.Name, err.Error())})
+			return
+		}
+
+		sheet = append(sheet, labels.Label{
+			Name:   p.Name,
+			Price:  formatPrice(p.SellingPrice),
+			Symbol: symbol,
+		})
+	}
+	if len(missing) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":    "Produits sans code-barres ni SKU. Utilisez POST /products/barcodes",
+			"products": missing,
+		})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := labels.WriteSheet(&buf, sheet); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la génération du PDF"})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="etiquettes.pdf"`)
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// productCode retourne le code imprimé sur l'étiquette: code-barres, sinon SKU
+func productCode(p models.Product) string {
+	if p.Barcode != nil && *p.Barcode != "" {
+		return *p.Barcode
+	}
+	if p.SKU != nil {
+		return *p.SKU
+	}
+	return ""
+}
+
+// autoSymbology choisit EAN-13 pour un GTIN et Code128 pour un SKU libre
+func autoSymbology(code string) labels.Symbology {
+	if models.IsValidGTIN(code) && len(code) != 14 {
+		return labels.EAN13
+	}
+	return labels.Code128
+}
+
+// formatPrice formate un prix pour l'affichage ("1 299,00")
+func formatPrice(price models.Money) string {
+	cents := int64(price)
+	whole := strconv.FormatInt(cents/100, 10)
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + " " + whole[i:]
+	}
+	return fmt.Sprintf("%s,%02d", whole, cents%100)
+}
+
+// parseDate accepte un horodatage RFC3339, une date et heure (YYYY-MM-DD HH:MM) ou une date
+// (YYYY-MM-DD, minuit) dans le fuseau du shop. SQLite stocke les dates en texte avec leur
+// décalage: les filtres comparent les instants avec julianday() (voir reportPeriod.apply).
+func parseDate(db *gorm.DB, shopID uint, value string) (time.Time, error) {
+	return parseDateIn(value, shopLocation(db, shopID))
+}
+
+// parseDateIn - parseDate avec un fuseau horaire donné pour les dates sans fuseau
+func parseDateIn(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.ParseInLocation("2006-01-02", value, loc)
+}
\ No newline at end of file
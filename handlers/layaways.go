@@ -0,0 +1,701 @@
+package handlers
+
+import (
+	"electronic-shop-api/database"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// STRUCTURES DE REQUÊTE / RÉPONSE
+// ========================================
+
+// CreateLayawayInput - Vente à tempérament: payments est l'acompte éventuel, le reste est
+// réparti en installments échéances égales espacées de interval_days
+type CreateLayawayInput struct {
+	CustomerID   *uint          `json:"customer_id"`
+	Customer     string         `json:"customer"` // Téléphone ou email d'un client connu
+	ProductID    *uint          `json:"product_id"`
+	Code         string         `json:"code"` // Code-barres ou SKU scanné
+	Quantity     int            `json:"quantity" binding:"required,gt=0"`
+	Installments int            `json:"installments" binding:"omitempty,gte=1,lte=52"`  // Défaut: 4
+	IntervalDays int            `json:"interval_days" binding:"omitempty,gte=1,lte=92"` // Défaut: 7 (hebdomadaire)
+	FirstDueOn   string         `json:"first_due_on"`                                   // YYYY-MM-DD (défaut: dans interval_days jours)
+	Payments     []PaymentInput `json:"payments" binding:"omitempty,dive"`
+	Notes        string         `json:"notes"`
+
+	RegisterSessionID *uint `json:"register_session_id"`
+}
+
+// LayawayPaymentInput - Versement sur une vente à tempérament (montants exacts)
+type LayawayPaymentInput struct {
+	Payments []PaymentInput `json:"payments" binding:"required,min=1,dive"`
+
+	RegisterSessionID *uint `json:"register_session_id"`
+}
+
+// CancelLayawayInput - Annulation: frais retenus (défaut: taux du shop, autre montant SuperAdmin
+// uniquement) et moyens de remboursement du reste des versements (défaut: espèces)
+type CancelLayawayInput struct {
+	Fee      *models.Money  `json:"fee" binding:"omitempty,gte=0"`
+	Payments []PaymentInput `json:"payments" binding:"omitempty,dive"`
+	Reason   string         `json:"reason"`
+
+	RegisterSessionID *uint `json:"register_session_id"`
+}
+
+// OverdueInstallment - Échéance en retard d'une vente à tempérament en cours
+type OverdueInstallment struct {
+	InstallmentID uint         `json:"installment_id"`
+	LayawayID     uint         `json:"layaway_id"`
+	DueDate       time.Time    `json:"due_date"`
+	Amount        models.Money `json:"amount"`
+	Paid          models.Money `json:"paid"`
+	Outstanding   models.Money `json:"outstanding"`
+	DaysOverdue   int          `json:"days_overdue"`
+	CustomerID    uint         `json:"customer_id"`
+	CustomerName  string       `json:"customer_name"`
+	CustomerPhone string       `json:"customer_phone,omitempty"`
+	ProductID     uint         `json:"product_id"`
+	ProductName   string       `json:"product_name"`
+}
+
+// Échéancier par défaut: 4 versements hebdomadaires
+const (
+	defaultLayawayInstallments = 4
+	defaultLayawayIntervalDays = 7
+)
+
+// ========================================
+// GET LAYAWAYS
+// ========================================
+
+// GetLayaways liste les ventes à tempérament (filtres status, customer_id)
+func GetLayaways(c *gin.Context) {
+	_, shopID, role := middleware.GetUserFromContext(c)
+
+	// MULTI-TENANT
+	query := database.GetDB().Where("shop_id = ?", shopID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if customerID := c.Query("customer_id"); customerID != "" {
+		query = query.Where("customer_id = ?", customerID)
+	}
+
+	var layaways []models.Layaway
+	if err := query.Preload("Customer").Preload("Product").Preload("Installments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("due_date ASC, id ASC")
+	}).Order("created_at DESC").Find(&layaways).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des ventes à tempérament"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"layaways": layawaysForRole(role, layaways), "count": len(layaways)})
+}
+
+func GetLayaway(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	layaway, ok := findShopLayaway(c, shopID)
+	if !ok {
+		return
+	}
+
+	respondLayaway(c, http.StatusOK, "", layaway.ID)
+}
+
+// GetOverdueInstallments - Échéances non soldées dont la date est passée (ventes en cours), les plus anciennes d'abord
+func GetOverdueInstallments(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+
+	now := time.Now().In(shopLocation(db, shopID))
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var installments []OverdueInstallment
+	if err := db.Table("layaway_installments i").
+		Select("i.id as installment_id, i.layaway_id, i.due_date, i.amount, i.paid, l.customer_id, "+
+			"c.name as customer_name, c.phone as customer_phone, l.product_id, p.name as product_name").
+		Joins("JOIN layaways l ON l.id = i.layaway_id").
+		Joins("LEFT JOIN customers c ON c.id = l.customer_id").
+		Joins("LEFT JOIN products p ON p.id = l.product_id").
+		Where("i.shop_id = ? AND l.shop_id = ? AND l.status = ? AND i.paid < i.amount AND julianday(i.due_date) < julianday(?)",
+			shopID, shopID, models.LayawayActive, today.UTC()).
+		Order("i.due_date ASC, i.id ASC").
+		Scan(&installments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des échéances"})
+		return
+	}
+
+	var outstanding models.Money
+	for i := range installments {
+		installments[i].Outstanding = installments[i].Amount - installments[i].Paid
+		installments[i].DaysOverdue = ageDays(installments[i].DueDate, now)
+		outstanding += installments[i].Outstanding
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"installments": installments,
+		"count":        len(installments),
+		"outstanding":  outstanding,
+		"currency":     shopCurrency(db, shopID),
+	})
+}
+
+// ========================================
+// CREATE LAYAWAY
+// ========================================
+
+// CreateLayaway réserve le stock et fige le prix (promotions et taxe du jour), enregistre
+// l'acompte éventuel et l'échéancier du reste
+func CreateLayaway(c *gin.Context) {
+	userID, shopID, _ := middleware.GetUserFromContext(c)
+
+	var input CreateLayawayInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	sessionID, err := registerSessionFor(db, shopID, userID, input.RegisterSessionID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client, err := resolveCustomer(db, shopID, input.CustomerID, input.Customer)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if client == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Client (customer_id ou customer) requis pour une vente à tempérament"})
+		return
+	}
+
+	// Produit par id ou par code scanné
+	var product models.Product
+	if input.ProductID != nil {
+		if err := db.Where("id = ? AND shop_id = ?", *input.ProductID, shopID).First(&product).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Produit non trouvé"})
+			return
+		}
+		if product.ArchivedAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": errProductArchived.Error()})
+			return
+		}
+	} else {
+		found, err := findProductByCode(db, shopID, normalizeCode(input.Code))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Aucun produit pour ce code", "code": input.Code})
+			return
+		}
+		product = found
+	}
+	if product.Stock-product.Reserved < input.Quantity {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "Stock insuffisant",
+			"stock_disponible":  product.Stock - product.Reserved,
+			"quantite_demandee": input.Quantity,
+		})
+		return
+	}
+
+	// Prix figé: promotions en cours et taxe, comme une vente
+	now := time.Now()
+	pricing, err := evaluateSale(db, shopID, product, input.Quantity, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du calcul des promotions"})
+		return
+	}
+	var shop models.Shop
+	if err := db.First(&shop, shopID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shop non trouvé"})
+		return
+	}
+	taxRate, err := productTaxRate(db, shopID, product)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du calcul de la taxe"})
+		return
+	}
+	taxAmount, totalAmount := models.TaxSplit(pricing.Total, taxRate, shop.PricesIncludeTax)
+
+	// Acompte (montant exact)
+	var deposit models.Money
+	for _, payment := range input.Payments {
+		deposit += payment.Amount
+	}
+	if deposit >= totalAmount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "L'acompte couvre le montant: enregistrer une vente", "amount": totalAmount})
+		return
+	}
+	var payments []models.Payment
+	if deposit > 0 {
+		if payments, _, err = buildPayments(db, shopID, sessionID, deposit, input.Payments, false); err == nil {
+			err = rejectSaleOnlyPayments(payments)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "amount": totalAmount})
+			return
+		}
+	}
+
+	// Échéancier du reste
+	loc := shopLocation(db, shopID)
+	count, interval := input.Installments, input.IntervalDays
+	if count == 0 {
+		count = defaultLayawayInstallments
+	}
+	if interval == 0 {
+		interval = defaultLayawayIntervalDays
+	}
+	local := now.In(loc)
+	firstDue := time.Date(local.Year(), local.Month(), local.Day()+interval, 0, 0, 0, 0, loc)
+	if input.FirstDueOn != "" {
+		firstDue, err = time.ParseInLocation("2006-01-02", input.FirstDueOn, loc)
+		if err != nil || firstDue.Before(local) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "first_due_on invalide (YYYY-MM-DD, à partir de demain)"})
+			return
+		}
+	}
+	installments := layawaySchedule(totalAmount-deposit, count, interval, firstDue, shopID)
+
+	layaway := models.Layaway{
+		Status:       models.LayawayActive,
+		CustomerID:   client.ID,
+		ProductID:    product.ID,
+		Quantity:     input.Quantity,
+		Subtotal:     pricing.Subtotal,
+		Discount:     pricing.Discount,
+		Amount:       totalAmount,
+		TaxRate:      taxRate,
+		TaxAmount:    taxAmount,
+		Paid:         deposit,
+		Notes:        strings.TrimSpace(input.Notes),
+		UserID:       &userID,
+		ShopID:       shopID,
+		Installments: installments,
+		Payments:     payments,
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Réservation: le stock reste en magasin mais n'est plus vendable
+		result := tx.Model(&models.Product{}).
+			Where("id = ? AND shop_id = ? AND stock - reserved >= ?", product.ID, shopID, input.Quantity).
+			Update("reserved", gorm.Expr("reserved + ?", input.Quantity))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errLayawayStock
+		}
+		return tx.Create(&layaway).Error
+	})
+	if errors.Is(err, errLayawayStock) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création de la vente à tempérament"})
+		return
+	}
+
+	respondLayaway(c, http.StatusCreated, "Vente à tempérament enregistrée", layaway.ID)
+}
+
+// ========================================
+// LAYAWAY PAYMENTS
+// ========================================
+
+// RecordLayawayPayment enregistre un versement: les échéances les plus anciennes sont soldées
+// d'abord. Le dernier versement enregistre la vente et retire les articles du stock.
+func RecordLayawayPayment(c *gin.Context) {
+	userID, shopID, _ := middleware.GetUserFromContext(c)
+
+	layaway, ok := findShopLayaway(c, shopID)
+	if !ok {
+		return
+	}
+	if layaway.Status != models.LayawayActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "Vente à tempérament déjà soldée ou annulée", "status": layaway.Status})
+		return
+	}
+
+	var input LayawayPaymentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	sessionID, err := registerSessionFor(db, shopID, userID, input.RegisterSessionID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var paid models.Money
+	for _, payment := range input.Payments {
+		paid += payment.Amount
+	}
+	remaining := layaway.Amount - layaway.Paid
+	if paid > remaining {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le versement dépasse le reste à payer", "remaining": remaining})
+		return
+	}
+	payments, _, err := buildPayments(db, shopID, sessionID, paid, input.Payments, false)
+	if err == nil {
+		err = rejectSaleOnlyPayments(payments)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Dernier versement: coût d'achat au taux de change du jour (COGS de la vente)
+	var unitCost models.Money
+	if paid == remaining {
+		var product models.Product
+		if err := db.Where("id = ? AND shop_id = ?", layaway.ProductID, shopID).First(&product).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Produit non trouvé"})
+			return
+		}
+		if unitCost, err = productCost(db, shopID, product, time.Now()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	now := time.Now()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Versement ajouté en base: deux versements simultanés ne peuvent pas dépasser le montant
+		// ni payer une vente à tempérament annulée entre-temps
+		result := tx.Model(&models.Layaway{}).
+			Where("id = ? AND shop_id = ? AND status = ? AND paid + ? <= amount", layaway.ID, shopID, models.LayawayActive, paid).
+			Update("paid", gorm.Expr("paid + ?", paid))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errLayawayChanged
+		}
+		if err := tx.First(&layaway, layaway.ID).Error; err != nil {
+			return err
+		}
+
+		for i := range payments {
+			payments[i].LayawayID = &layaway.ID
+		}
+		if err := tx.Create(&payments).Error; err != nil {
+			return err
+		}
+
+		// Échéances soldées dans l'ordre
+		var installments []models.LayawayInstallment
+		if err := tx.Where("layaway_id = ? AND shop_id = ? AND paid < amount", layaway.ID, shopID).
+			Order("due_date ASC, id ASC").Find(&installments).Error; err != nil {
+			return err
+		}
+		left := paid
+		for _, installment := range installments {
+			if left == 0 {
+				break
+			}
+			part := (installment.Amount - installment.Paid).Min(left)
+			updates := map[string]interface{}{"paid": installment.Paid + part}
+			if installment.Paid+part == installment.Amount {
+				updates["paid_at"] = now
+			}
+			if err := tx.Model(&installment).Updates(updates).Error; err != nil {
+				return err
+			}
+			left -= part
+		}
+
+		if layaway.Paid < layaway.Amount {
+			return nil
+		}
+		if paid != remaining {
+			// Soldée avec un versement enregistré entre-temps: coût calculé maintenant
+			var product models.Product
+			if err := tx.Where("id = ? AND shop_id = ?", layaway.ProductID, shopID).First(&product).Error; err != nil {
+				return err
+			}
+			if unitCost, err = productCost(tx, shopID, product, now); err != nil {
+				return err
+			}
+		}
+		return completeLayaway(tx, &layaway, unitCost, userID, sessionID, now)
+	})
+	if errors.Is(err, errLayawayChanged) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement du versement"})
+		return
+	}
+
+	message := "Versement enregistré"
+	if layaway.Status == models.LayawayCompleted {
+		message = "Vente à tempérament soldée: vente enregistrée"
+	}
+	respondLayaway(c, http.StatusCreated, message, layaway.ID)
+}
+
+// ========================================
+// CANCEL LAYAWAY
+// ========================================
+
+// CancelLayaway libère le stock réservé et rend les versements, moins les frais d'annulation
+func CancelLayaway(c *gin.Context) {
+	userID, shopID, role := middleware.GetUserFromContext(c)
+
+	layaway, ok := findShopLayaway(c, shopID)
+	if !ok {
+		return
+	}
+	if layaway.Status != models.LayawayActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "Vente à tempérament déjà soldée ou annulée", "status": layaway.Status})
+		return
+	}
+
+	var input CancelLayawayInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	sessionID, err := registerSessionFor(db, shopID, userID, input.RegisterSessionID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Frais: taux du shop, ou montant saisi par un SuperAdmin; jamais plus que les versements
+	var shop models.Shop
+	db.Select("layaway_cancellation_fee").First(&shop, shopID)
+	fee := layaway.Amount.Percent(shop.LayawayCancellationFee)
+	if input.Fee != nil && *input.Fee != fee {
+		if role != models.RoleSuperAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "seul un SuperAdmin peut modifier les frais d'annulation"})
+			return
+		}
+		fee = *input.Fee
+	}
+	fee = fee.Min(layaway.Paid)
+
+	// Versements rendus (montants négatifs rattachés à la vente à tempérament)
+	refund := layaway.Paid - fee
+	var payments []models.Payment
+	if refund > 0 {
+		if payments, _, err = buildPayments(db, shopID, sessionID, refund, input.Payments, false); err == nil {
+			err = rejectSaleOnlyPayments(payments)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "amount": refund})
+			return
+		}
+		for i := range payments {
+			payments[i].Amount = -payments[i].Amount
+			payments[i].LayawayID = &layaway.ID
+		}
+	}
+
+	now := time.Now()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Annulation sur les versements lus: pas de versement ni d'annulation entre-temps
+		updates := map[string]interface{}{
+			"status":           models.LayawayCancelled,
+			"cancellation_fee": fee,
+			"refunded":         refund,
+			"cancelled_at":     now,
+		}
+		if reason := strings.TrimSpace(input.Reason); reason != "" {
+			updates["notes"] = strings.TrimSpace(layaway.Notes + "\nAnnulation: " + reason)
+		}
+		result := tx.Model(&models.Layaway{}).
+			Where("id = ? AND shop_id = ? AND status = ? AND paid = ?", layaway.ID, shopID, models.LayawayActive, layaway.Paid).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errLayawayChanged
+		}
+
+		if err := tx.Model(&models.Product{}).Where("id = ? AND shop_id = ?", layaway.ProductID, shopID).
+			Update("reserved", gorm.Expr("reserved - ?", layaway.Quantity)).Error; err != nil {
+			return err
+		}
+		if len(payments) > 0 {
+			return tx.Create(&payments).Error
+		}
+		return nil
+	})
+	if errors.Is(err, errLayawayChanged) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'annulation"})
+		return
+	}
+
+	respondLayaway(c, http.StatusOK, "Vente à tempérament annulée", layaway.ID)
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+var (
+	// errLayawayStock - Stock vendu entre la vérification et la réservation
+	errLayawayStock = errors.New("stock insuffisant pour la réservation")
+	// errLayawayChanged - Versement ou annulation enregistré entre la lecture et la mise à jour
+	errLayawayChanged = errors.New("vente à tempérament modifiée entre-temps (versement ou annulation): réessayer")
+)
+
+// layawaySchedule répartit amount en count échéances égales (la dernière reprend l'arrondi)
+func layawaySchedule(amount models.Money, count, intervalDays int, firstDue time.Time, shopID uint) []models.LayawayInstallment {
+	installments := make([]models.LayawayInstallment, count)
+	share := amount / models.Money(count)
+	for i := range installments {
+		installments[i] = models.LayawayInstallment{
+			DueDate: firstDue.AddDate(0, 0, i*intervalDays),
+			Amount:  share,
+			ShopID:  shopID,
+		}
+	}
+	installments[count-1].Amount = amount - share*models.Money(count-1)
+	return installments
+}
+
+// completeLayaway enregistre la vente d'une vente à tempérament soldée: les articles réservés
+// sortent du stock et les versements deviennent les règlements de la vente
+func completeLayaway(tx *gorm.DB, layaway *models.Layaway, unitCost models.Money, userID uint, sessionID *uint, now time.Time) error {
+	if err := tx.Model(&models.Product{}).Where("id = ? AND shop_id = ?", layaway.ProductID, layaway.ShopID).Updates(map[string]interface{}{
+		"stock":    gorm.Expr("stock - ?", layaway.Quantity),
+		"reserved": gorm.Expr("reserved - ?", layaway.Quantity),
+	}).Error; err != nil {
+		return err
+	}
+
+	sale := models.Transaction{
+		Type:       models.TypeSale,
+		ProductID:  &layaway.ProductID,
+		Quantity:   layaway.Quantity,
+		Subtotal:   layaway.Subtotal,
+		Discount:   layaway.Discount,
+		Amount:     layaway.Amount,
+		TaxRate:    layaway.TaxRate,
+		TaxAmount:  layaway.TaxAmount,
+		Cost:       unitCost.Times(layaway.Quantity),
+		CustomerID: &layaway.CustomerID,
+		UserID:     &userID,
+		ShopID:     layaway.ShopID,
+
+		RegisterSessionID: sessionID,
+	}
+	if err := tx.Create(&sale).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Payment{}).Where("layaway_id = ? AND shop_id = ?", layaway.ID, layaway.ShopID).
+		Update("transaction_id", sale.ID).Error; err != nil {
+		return err
+	}
+	if _, err := earnLoyaltyPoints(tx, sale, sale.Amount, userID, now); err != nil {
+		return err
+	}
+
+	layaway.Status, layaway.SaleID, layaway.CompletedAt = models.LayawayCompleted, &sale.ID, &now
+	return tx.Model(layaway).Updates(map[string]interface{}{
+		"status":       layaway.Status,
+		"sale_id":      sale.ID,
+		"completed_at": now,
+	}).Error
+}
+
+// respondLayaway répond avec la vente à tempérament complète et le reste à payer
+func respondLayaway(c *gin.Context, status int, message string, layawayID uint) {
+	var layaway models.Layaway
+	database.GetDB().Preload("Customer").Preload("Product").
+		Preload("Installments", func(db *gorm.DB) *gorm.DB { return db.Order("due_date ASC, id ASC") }).
+		Preload("Payments", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC, id ASC") }).
+		First(&layaway, layawayID)
+
+	_, _, role := middleware.GetUserFromContext(c)
+	response := gin.H{"layaway": layawayForRole(role, layaway), "remaining": layaway.Amount - layaway.Paid}
+	if layaway.Status != models.LayawayActive {
+		response["remaining"] = models.Money(0)
+	}
+	if message != "" {
+		response["message"] = message
+	}
+	c.JSON(status, response)
+}
+
+// cancellationFees - Ventes à tempérament annulées avec frais retenus sur la période (date
+// d'annulation). Les frais sont un produit du shop, sans coût d'achat ni taxe.
+func cancellationFees(db *gorm.DB, shopID uint, period reportPeriod) *gorm.DB {
+	query := db.Table("layaways l").
+		Where("l.shop_id = ? AND l.status = ? AND l.cancellation_fee > 0", shopID, models.LayawayCancelled)
+	return period.apply(query, "l.cancelled_at")
+}
+
+// findShopLayaway charge la vente à tempérament :id du shop ou répond 400/404
+func findShopLayaway(c *gin.Context, shopID uint) (models.Layaway, bool) {
+	var layaway models.Layaway
+
+	layawayID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de vente à tempérament invalide"})
+		return layaway, false
+	}
+
+	// MULTI-TENANT
+	if err := database.GetDB().Where("id = ? AND shop_id = ?", layawayID, shopID).First(&layaway).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vente à tempérament non trouvée"})
+		return layaway, false
+	}
+	return layaway, true
+}
+
+// layawayView - Vente à tempérament vue par un Admin: produit sans prix d'achat
+type layawayView struct {
+	models.Layaway
+	Product gin.H `json:"product,omitempty"`
+}
+
+// layawaysForRole masque le prix d'achat des produits préchargés pour les Admin
+func layawaysForRole(role models.Role, layaways []models.Layaway) interface{} {
+	if role != models.RoleAdmin {
+		return layaways
+	}
+	views := make([]layawayView, len(layaways))
+	for i, l := range layaways {
+		views[i] = layawayView{Layaway: l}
+		if l.Product != nil {
+			views[i].Product = productWithoutPurchasePrice(*l.Product)
+		}
+	}
+	return views
+}
+
+func layawayForRole(role models.Role, layaway models.Layaway) interface{} {
+	if role != models.RoleAdmin {
+		return layaway
+	}
+	return layawaysForRole(role, []models.Layaway{layaway}).([]layawayView)[0]
+}
\ No newline at end of file
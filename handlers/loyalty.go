@@ -0,0 +1,553 @@
+package handlers
+
+import (
+	"electronic-shop-api/database"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// STRUCTURES DE REQUÊTE / RÉPONSE
+// ========================================
+
+type LoyaltyProgramInput struct {
+	Active          *bool         `json:"active"`
+	EarnRate        *float64      `json:"earn_rate" binding:"omitempty,gte=0"`
+	PointValue      *models.Money `json:"point_value" binding:"omitempty,gt=0"`
+	MinRedeemPoints *int          `json:"min_redeem_points" binding:"omitempty,gte=0"`
+	ExpiryMonths    *int          `json:"expiry_months" binding:"omitempty,gte=0,lte=120"`
+}
+
+type LoyaltyAdjustInput struct {
+	Points int    `json:"points" binding:"required"` // Négatif: points retirés
+	Note   string `json:"note" binding:"required"`
+}
+
+// LoyaltySummary - Points du client après une vente ou un remboursement
+type LoyaltySummary struct {
+	Earned   int `json:"earned,omitempty"`
+	Redeemed int `json:"redeemed,omitempty"`
+	Reversed int `json:"reversed,omitempty"` // Points gagnés retirés au remboursement
+	Restored int `json:"restored,omitempty"` // Points rendus au remboursement
+	Balance  int `json:"balance"`
+}
+
+// Programme proposé tant que le shop n'a rien configuré (inactif): 1 point par unité
+// de devise, 0,10 par point, points valables 12 mois
+var defaultLoyaltyProgram = models.LoyaltyProgram{EarnRate: 1, PointValue: 10, ExpiryMonths: 12}
+
+const (
+	// loyaltyExpiryInterval - Fréquence d'expiration des points
+	loyaltyExpiryInterval = time.Hour
+	// loyaltyExpiringDays - Points signalés comme expirant bientôt
+	loyaltyExpiringDays = 30
+	// defaultLoyaltyEntries - Mouvements retournés par défaut par le relevé de points
+	defaultLoyaltyEntries = 50
+)
+
+var (
+	// errLoyaltySaleOnly - Les points fidélité ne règlent que des ventes (et leurs remboursements)
+	errLoyaltySaleOnly = errors.New("les points fidélité ne peuvent régler que des ventes")
+	errLoyaltyUsed     = errors.New("points fidélité déjà utilisés: l'opération ne peut pas être annulée")
+)
+
+// ========================================
+// LOYALTY PROGRAM
+// ========================================
+
+func GetLoyaltyProgram(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	c.JSON(http.StatusOK, gin.H{"program": findLoyaltyProgram(database.GetDB(), shopID)})
+}
+
+// UpdateLoyaltyProgram configure le programme (SuperAdmin). L'activation crée le moyen
+// de paiement "Points fidélité" s'il n'existe pas.
+func UpdateLoyaltyProgram(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	var input LoyaltyProgramInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	program := findLoyaltyProgram(db, shopID)
+	if input.Active != nil {
+		program.Active = *input.Active
+	}
+	if input.EarnRate != nil {
+		program.EarnRate = *input.EarnRate
+	}
+	if input.PointValue != nil {
+		program.PointValue = *input.PointValue
+	}
+	if input.MinRedeemPoints != nil {
+		program.MinRedeemPoints = *input.MinRedeemPoints
+	}
+	if input.ExpiryMonths != nil {
+		program.ExpiryMonths = *input.ExpiryMonths
+	}
+	if program.Active && program.PointValue <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "point_value doit être supérieur à 0"})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&program).Error; err != nil {
+			return err
+		}
+		if !program.Active {
+			return nil
+		}
+		var count int64
+		tx.Model(&models.PaymentMethod{}).Where("shop_id = ? AND kind = ?", shopID, models.PaymentLoyalty).Count(&count)
+		if count > 0 {
+			return nil
+		}
+		return tx.Create(&models.PaymentMethod{Name: "Points fidélité", Kind: models.PaymentLoyalty, Active: true, ShopID: shopID}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour du programme de fidélité"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Programme de fidélité mis à jour", "program": program})
+}
+
+// ========================================
+// CUSTOMER POINTS
+// ========================================
+
+// GetCustomerLoyalty - Solde de points du client, points expirant bientôt et relevé des mouvements (limit)
+func GetCustomerLoyalty(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	customer, ok := findShopCustomer(c, shopID)
+	if !ok {
+		return
+	}
+
+	limit := defaultLoyaltyEntries
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit invalide (1 à 500)"})
+			return
+		}
+		limit = parsed
+	}
+
+	db := database.GetDB()
+	now := time.Now()
+	expireLoyaltyPoints(db, now, &customer.ID)
+
+	var entries []models.LoyaltyEntry
+	if err := db.Where("shop_id = ? AND customer_id = ?", shopID, customer.ID).
+		Order("created_at DESC, id DESC").Limit(limit).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des points"})
+		return
+	}
+
+	var expiring int
+	db.Model(&models.LoyaltyEntry{}).Select("COALESCE(SUM(remaining), 0)").
+		Where("shop_id = ? AND customer_id = ? AND remaining > 0 AND julianday(expires_at) <= julianday(?)", shopID, customer.ID, now.AddDate(0, 0, loyaltyExpiringDays).UTC()).
+		Scan(&expiring)
+
+	program := findLoyaltyProgram(db, shopID)
+	balance := loyaltyBalance(db, shopID, customer.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"customer_id":   customer.ID,
+		"balance":       balance,
+		"value":         program.PointValue.Times(balance),
+		"expiring_soon": expiring,
+		"entries":       entries,
+		"program":       program,
+	})
+}
+
+// AdjustCustomerLoyalty ajoute ou retire des points manuellement (SuperAdmin, motif obligatoire)
+func AdjustCustomerLoyalty(c *gin.Context) {
+	userID, shopID, _ := middleware.GetUserFromContext(c)
+
+	customer, ok := findShopCustomer(c, shopID)
+	if !ok {
+		return
+	}
+
+	var input LoyaltyAdjustInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	now := time.Now()
+	expireLoyaltyPoints(db, now, &customer.ID)
+	balance := loyaltyBalance(db, shopID, customer.ID)
+	if balance+input.Points < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Points insuffisants", "balance": balance})
+		return
+	}
+
+	entry := models.LoyaltyEntry{
+		CustomerID: customer.ID,
+		Type:       models.LoyaltyAdjust,
+		Points:     input.Points,
+		Note:       strings.TrimSpace(input.Note),
+		UserID:     &userID,
+		ShopID:     shopID,
+	}
+	program := findLoyaltyProgram(db, shopID)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if input.Points > 0 {
+			return creditPoints(tx, program, &entry, now)
+		}
+		return debitPoints(tx, &entry)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'ajustement des points"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Points ajustés", "entry": entry, "balance": balance + input.Points})
+}
+
+// ========================================
+// EXPIRATION DES POINTS
+// ========================================
+
+// StartLoyaltyScheduler expire régulièrement les points arrivés à échéance
+func StartLoyaltyScheduler() {
+	go func() {
+		ticker := time.NewTicker(loyaltyExpiryInterval)
+		defer ticker.Stop()
+
+		expireLoyaltyPoints(database.GetDB(), time.Now(), nil)
+		for now := range ticker.C {
+			expireLoyaltyPoints(database.GetDB(), now, nil)
+		}
+	}()
+	log.Println("✅ Planificateur des points fidélité démarré")
+}
+
+// expireLoyaltyPoints retire les points non utilisés arrivés à échéance (d'un client, ou de tous)
+func expireLoyaltyPoints(db *gorm.DB, now time.Time, customerID *uint) {
+	query := db.Where("remaining > 0 AND julianday(expires_at) <= julianday(?)", now.UTC())
+	if customerID != nil {
+		query = query.Where("customer_id = ?", *customerID)
+	}
+
+	var expired []models.LoyaltyEntry
+	if err := query.Order("expires_at ASC, id ASC").Find(&expired).Error; err != nil {
+		log.Printf("❌ Points fidélité: %v", err)
+		return
+	}
+	for _, credit := range expired {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Solde inchangé depuis la lecture: sinon un autre traitement l'a déjà consommé ou expiré
+			result := tx.Model(&models.LoyaltyEntry{}).Where("id = ? AND remaining = ?", credit.ID, credit.Remaining).
+				Update("remaining", 0)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			return tx.Create(&models.LoyaltyEntry{
+				CustomerID: credit.CustomerID,
+				Type:       models.LoyaltyExpire,
+				Points:     -credit.Remaining,
+				Note:       fmt.Sprintf("Points du %s expirés", credit.CreatedAt.Format("2006-01-02")),
+				ShopID:     credit.ShopID,
+				CreatedAt:  *credit.ExpiresAt,
+			}).Error
+		})
+		if err != nil {
+			log.Printf("❌ Points fidélité %d: %v", credit.ID, err)
+		}
+	}
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// findLoyaltyProgram charge le programme du shop (programme par défaut, inactif, s'il n'existe pas)
+func findLoyaltyProgram(db *gorm.DB, shopID uint) models.LoyaltyProgram {
+	var program models.LoyaltyProgram
+	if err := db.Where("shop_id = ?", shopID).First(&program).Error; err != nil {
+		program = defaultLoyaltyProgram
+		program.ShopID = shopID
+	}
+	return program
+}
+
+// loyaltyBalance - Solde de points du client
+func loyaltyBalance(db *gorm.DB, shopID, customerID uint) int {
+	var balance int
+	db.Model(&models.LoyaltyEntry{}).Select("COALESCE(SUM(points), 0)").
+		Where("shop_id = ? AND customer_id = ?", shopID, customerID).
+		Scan(&balance)
+	return balance
+}
+
+// loyaltyAmount - Part des règlements payée en points
+func loyaltyAmount(payments []models.Payment) models.Money {
+	var amount models.Money
+	for _, payment := range payments {
+		if payment.Method == models.PaymentLoyalty {
+			amount += payment.Amount
+		}
+	}
+	return amount
+}
+
+// pointsFor - Points correspondant à un montant (arrondi au point supérieur)
+func pointsFor(program models.LoyaltyProgram, amount models.Money) int {
+	return int(math.Ceil(float64(amount) / float64(program.PointValue)))
+}
+
+// loyaltyRedemption vérifie les règlements en points d'une vente: client, programme actif
+// et solde suffisant. Retourne les points à débiter (la référence du règlement les indique).
+func loyaltyRedemption(db *gorm.DB, shopID uint, client *models.Customer, payments []models.Payment) (int, error) {
+	if loyaltyAmount(payments) == 0 {
+		return 0, nil
+	}
+	if client == nil {
+		return 0, errors.New("client requis pour payer avec des points fidélité")
+	}
+	program := findLoyaltyProgram(db, shopID)
+	if !program.Active {
+		return 0, errors.New("programme de fidélité inactif")
+	}
+
+	points := 0
+	for i := range payments {
+		if payments[i].Method != models.PaymentLoyalty {
+			continue
+		}
+		needed := pointsFor(program, payments[i].Amount)
+		payments[i].Reference = fmt.Sprintf("%d points", needed)
+		points += needed
+	}
+
+	expireLoyaltyPoints(db, time.Now(), &client.ID)
+	balance := loyaltyBalance(db, shopID, client.ID)
+	if balance < program.MinRedeemPoints {
+		return 0, fmt.Errorf("%d points minimum pour payer avec des points (solde: %d)", program.MinRedeemPoints, balance)
+	}
+	if points > balance {
+		return 0, fmt.Errorf("points insuffisants: %d requis, solde %d", points, balance)
+	}
+	return points, nil
+}
+
+// applySaleLoyalty débite les points utilisés en paiement et crédite les points gagnés
+// sur la part de la vente non payée en points
+func applySaleLoyalty(tx *gorm.DB, sale models.Transaction, redeemed int, userID uint) (LoyaltySummary, error) {
+	summary := LoyaltySummary{Redeemed: redeemed}
+	if sale.CustomerID == nil {
+		return summary, nil
+	}
+	now := time.Now()
+	if redeemed > 0 {
+		if err := debitPoints(tx, &models.LoyaltyEntry{
+			CustomerID:    *sale.CustomerID,
+			Type:          models.LoyaltyRedeem,
+			Points:        -redeemed,
+			TransactionID: &sale.ID,
+			UserID:        &userID,
+			ShopID:        sale.ShopID,
+		}); err != nil {
+			return summary, err
+		}
+	}
+
+	earned, err := earnLoyaltyPoints(tx, sale, sale.Amount-loyaltyAmount(sale.Payments), userID, now)
+	if err != nil {
+		return summary, err
+	}
+	summary.Earned = earned
+	summary.Balance = loyaltyBalance(tx, sale.ShopID, *sale.CustomerID)
+	return summary, nil
+}
+
+// earnLoyaltyPoints crédite les points gagnés sur base (programme actif, vente avec client)
+func earnLoyaltyPoints(tx *gorm.DB, sale models.Transaction, base models.Money, userID uint, now time.Time) (int, error) {
+	program := findLoyaltyProgram(tx, sale.ShopID)
+	if !program.Active || sale.CustomerID == nil || base <= 0 {
+		return 0, nil
+	}
+	points := int(math.Floor(base.Float64() * program.EarnRate))
+	if points <= 0 {
+		return 0, nil
+	}
+	return points, creditPoints(tx, program, &models.LoyaltyEntry{
+		CustomerID:    *sale.CustomerID,
+		Type:          models.LoyaltyEarn,
+		Points:        points,
+		TransactionID: &sale.ID,
+		UserID:        &userID,
+		ShopID:        sale.ShopID,
+	}, now)
+}
+
+// applyRefundLoyalty retire les points gagnés au prorata du remboursement (dans la limite
+// du solde) et rend les points des règlements remboursés en points
+func applyRefundLoyalty(tx *gorm.DB, sale, refund models.Transaction, userID uint) (LoyaltySummary, error) {
+	var summary LoyaltySummary
+	if sale.CustomerID == nil {
+		return summary, nil
+	}
+	customerID := *sale.CustomerID
+	now := time.Now()
+
+	var earned int
+	tx.Model(&models.LoyaltyEntry{}).Select("COALESCE(SUM(points), 0)").
+		Where("shop_id = ? AND transaction_id = ? AND type = ?", sale.ShopID, sale.ID, models.LoyaltyEarn).
+		Scan(&earned)
+	if earned > 0 && sale.Amount > 0 {
+		reversed := int(math.Round(float64(earned) * float64(refund.Amount) / float64(sale.Amount)))
+		if balance := loyaltyBalance(tx, sale.ShopID, customerID); reversed > balance {
+			reversed = balance
+		}
+		if reversed > 0 {
+			if err := debitPoints(tx, &models.LoyaltyEntry{
+				CustomerID:    customerID,
+				Type:          models.LoyaltyReversal,
+				Points:        -reversed,
+				TransactionID: &refund.ID,
+				UserID:        &userID,
+				ShopID:        sale.ShopID,
+			}); err != nil {
+				return summary, err
+			}
+			summary.Reversed = reversed
+		}
+	}
+
+	if amount := loyaltyAmount(refund.Payments); amount > 0 {
+		program := findLoyaltyProgram(tx, sale.ShopID)
+		restored := pointsFor(program, amount)
+		if err := creditPoints(tx, program, &models.LoyaltyEntry{
+			CustomerID:    customerID,
+			Type:          models.LoyaltyRestore,
+			Points:        restored,
+			TransactionID: &refund.ID,
+			UserID:        &userID,
+			ShopID:        sale.ShopID,
+		}, now); err != nil {
+			return summary, err
+		}
+		summary.Restored = restored
+	}
+
+	summary.Balance = loyaltyBalance(tx, sale.ShopID, customerID)
+	return summary, nil
+}
+
+// creditPoints enregistre un crédit de points, valable ExpiryMonths mois
+func creditPoints(tx *gorm.DB, program models.LoyaltyProgram, entry *models.LoyaltyEntry, now time.Time) error {
+	entry.Remaining = entry.Points
+	if program.ExpiryMonths > 0 {
+		expiresAt := now.AddDate(0, program.ExpiryMonths, 0)
+		entry.ExpiresAt = &expiresAt
+	}
+	return tx.Create(entry).Error
+}
+
+// debitPoints enregistre un débit de points (Points négatif), consommés sur les crédits
+// qui expirent le plus tôt. Les crédits consommés sont notés pour pouvoir annuler le débit.
+func debitPoints(tx *gorm.DB, entry *models.LoyaltyEntry) error {
+	if err := tx.Create(entry).Error; err != nil {
+		return err
+	}
+
+	var credits []models.LoyaltyEntry
+	if err := tx.Where("shop_id = ? AND customer_id = ? AND remaining > 0", entry.ShopID, entry.CustomerID).
+		Order("CASE WHEN expires_at IS NULL THEN 1 ELSE 0 END, expires_at ASC, id ASC").
+		Find(&credits).Error; err != nil {
+		return err
+	}
+
+	left := -entry.Points
+	for _, credit := range credits {
+		if left == 0 {
+			break
+		}
+		used := credit.Remaining
+		if used > left {
+			used = left
+		}
+		result := tx.Model(&models.LoyaltyEntry{}).Where("id = ? AND remaining >= ?", credit.ID, used).
+			Update("remaining", gorm.Expr("remaining - ?", used))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue // Crédit consommé ou expiré entre-temps
+		}
+		if err := tx.Create(&models.LoyaltyAllocation{DebitID: entry.ID, CreditID: credit.ID, Points: used}).Error; err != nil {
+			return err
+		}
+		left -= used
+	}
+	return nil
+}
+
+// revertLoyaltyEntries annule les mouvements de points d'une transaction supprimée: les points
+// débités sont rendus aux crédits consommés, les points crédités ne doivent pas avoir servi
+func revertLoyaltyEntries(tx *gorm.DB, shopID, transactionID uint) error {
+	var entries []models.LoyaltyEntry
+	if err := tx.Where("shop_id = ? AND transaction_id = ?", shopID, transactionID).
+		Order("points ASC").Find(&entries).Error; err != nil {
+		return err
+	}
+
+	// Débits d'abord: ils ont pu consommer un crédit de la même transaction
+	for _, entry := range entries {
+		if entry.Points >= 0 {
+			continue
+		}
+		var allocations []models.LoyaltyAllocation
+		if err := tx.Where("debit_id = ?", entry.ID).Find(&allocations).Error; err != nil {
+			return err
+		}
+		for _, allocation := range allocations {
+			if err := tx.Model(&models.LoyaltyEntry{}).Where("id = ?", allocation.CreditID).
+				Update("remaining", gorm.Expr("remaining + ?", allocation.Points)).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("debit_id = ?", entry.ID).Delete(&models.LoyaltyAllocation{}).Error; err != nil {
+			return err
+		}
+	}
+	for _, entry := range entries {
+		if entry.Points <= 0 {
+			continue
+		}
+		var credit models.LoyaltyEntry
+		if err := tx.First(&credit, entry.ID).Error; err != nil {
+			return err
+		}
+		if credit.Remaining < credit.Points {
+			return errLoyaltyUsed
+		}
+	}
+
+	if len(entries) == 0 {
+		return nil
+	}
+	return tx.Where("shop_id = ? AND transaction_id = ?", shopID, transactionID).Delete(&models.LoyaltyEntry{}).Error
+}
\ No newline at end of file
@@ -0,0 +1,478 @@
+package handlers
+
+import (
+	"electronic-shop-api/database"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// STRUCTURES DE REQUÊTE / RÉPONSE
+// ========================================
+
+type PaymentMethodInput struct {
+	Name string             `json:"name" binding:"required"`
+	Kind models.PaymentKind `json:"kind" binding:"required"`
+}
+
+type UpdatePaymentMethodInput struct {
+	Name   string `json:"name"`
+	Active *bool  `json:"active"`
+}
+
+// PaymentInput - Règlement saisi avec une transaction (payment_method_id, ou method: type ou nom)
+type PaymentInput struct {
+	PaymentMethodID *uint        `json:"payment_method_id"`
+	Method          string       `json:"method"`
+	Amount          models.Money `json:"amount" binding:"required,gt=0"` // Espèces: montant remis (monnaie rendue calculée)
+	Reference       string       `json:"reference"`
+	GiftCardCode    string       `json:"gift_card_code"` // Carte cadeau / avoir (moyen store_credit)
+}
+
+// TakingsLine - Encaissements d'un moyen de paiement sur un jour (ou sur la période)
+type TakingsLine struct {
+	Date            string             `json:"date,omitempty"` // YYYY-MM-DD (fuseau du shop)
+	PaymentMethodID uint               `json:"payment_method_id"`
+	Method          models.PaymentKind `json:"method"`
+	Name            string             `json:"name"`
+	Count           int64              `json:"count"`    // Règlements de ventes
+	Received        models.Money       `json:"received"` // Ventes encaissées
+	Refunded        models.Money       `json:"refunded"` // Remboursements
+	Net             models.Money       `json:"net"`
+	NonCash         bool               `json:"non_cash"` // Avoirs, cartes cadeaux, points fidélité: hors total encaissé
+}
+
+// takingsRow - Agrégat SQL par créneau horaire, moyen de paiement et type de transaction
+type takingsRow struct {
+	Slot            string
+	PaymentMethodID uint
+	Method          models.PaymentKind
+	Name            string
+	Type            models.TransactionType
+	Count           int64
+	Amount          models.Money
+}
+
+// ========================================
+// PAYMENT METHODS
+// ========================================
+
+func GetPaymentMethods(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	query := database.GetDB().Where("shop_id = ?", shopID)
+	if active := c.Query("active"); active != "" {
+		query = query.Where("active = ?", active == "true")
+	}
+
+	var methods []models.PaymentMethod
+	if err := query.Order("id ASC").Find(&methods).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des moyens de paiement"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payment_methods": methods, "count": len(methods)})
+}
+
+// CreatePaymentMethod (SuperAdmin)
+func CreatePaymentMethod(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	var input PaymentMethodInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+	if !models.ValidPaymentKind(input.Kind) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind invalide", "kinds": models.PaymentKinds})
+		return
+	}
+
+	db := database.GetDB()
+	name := strings.TrimSpace(input.Name)
+	if _, err := findPaymentMethodByName(db, shopID, name); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ce moyen de paiement existe déjà"})
+		return
+	}
+
+	method := models.PaymentMethod{Name: name, Kind: input.Kind, Active: true, ShopID: shopID}
+	if err := db.Create(&method).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création du moyen de paiement"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Moyen de paiement créé", "payment_method": method})
+}
+
+// UpdatePaymentMethod renomme ou désactive un moyen de paiement (SuperAdmin); son type est fixe
+func UpdatePaymentMethod(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	method, ok := findShopPaymentMethod(c, shopID)
+	if !ok {
+		return
+	}
+
+	var input UpdatePaymentMethodInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	if name := strings.TrimSpace(input.Name); name != "" && name != method.Name {
+		if existing, err := findPaymentMethodByName(db, shopID, name); err == nil && existing.ID != method.ID {
+			c.JSON(http.StatusConflict, gin.H{"error": "Ce moyen de paiement existe déjà"})
+			return
+		}
+		method.Name = name
+	}
+	if input.Active != nil {
+		method.Active = *input.Active
+	}
+
+	if err := db.Save(&method).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Moyen de paiement mis à jour", "payment_method": method})
+}
+
+// DeletePaymentMethod (SuperAdmin): un moyen déjà utilisé se désactive (active: false)
+func DeletePaymentMethod(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	method, ok := findShopPaymentMethod(c, shopID)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	var used int64
+	db.Model(&models.Payment{}).Where("shop_id = ? AND payment_method_id = ?", shopID, method.ID).Count(&used)
+	if used > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Moyen de paiement utilisé: le désactiver plutôt que le supprimer", "payments": used})
+		return
+	}
+
+	if err := db.Delete(&method).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Moyen de paiement supprimé"})
+}
+
+// ========================================
+// GET TAKINGS REPORT (SuperAdmin)
+// ========================================
+
+// GetTakingsReport - Encaissements par moyen de paiement et par jour (rapprochement bancaire).
+// Période: period ou from / to, 30 derniers jours par défaut.
+func GetTakingsReport(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+
+	days, totals, period, err := computeTakings(c, db, shopID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Total encaissé; les avoirs, cartes cadeaux (encaissées à leur vente) et points à part
+	var total, nonCash models.Money
+	for _, line := range totals {
+		if line.NonCash {
+			nonCash += line.Net
+		} else {
+			total += line.Net
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"currency": shopCurrency(db, shopID),
+		"period":   period,
+		"days":     days,
+		"methods":  totals,
+		"total":    total,
+		"non_cash": nonCash,
+	})
+}
+
+// ExportTakingsReport exporte les encaissements par jour et moyen de paiement (format=csv, xlsx ou pdf)
+func ExportTakingsReport(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+
+	days, _, _, err := computeTakings(c, db, shopID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	columns := []string{"date", "payment_method_id", "method", "name", "count", "received", "refunded", "net"}
+	w, ok := startReportExport(c, "encaissements", "Encaissements par moyen de paiement ("+shopCurrency(db, shopID)+")", columns)
+	if !ok {
+		return
+	}
+	for _, line := range days {
+		if err = w.WriteRow(line.Date, line.PaymentMethodID, string(line.Method), line.Name, line.Count, line.Received, line.Refunded, line.Net); err != nil {
+			break
+		}
+	}
+	finishExport(w, "encaissements", err)
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// buildPayments valide les règlements d'une transaction de montant total. Sans règlement saisi,
+// la transaction est réglée en espèces. Seules les espèces peuvent dépasser le montant dû
+// (allowChange): l'excédent est la monnaie rendue. Les règlements sont rattachés à la session de caisse.
+func buildPayments(db *gorm.DB, shopID uint, sessionID *uint, total models.Money, inputs []PaymentInput, allowChange bool) ([]models.Payment, models.Money, error) {
+	if len(inputs) == 0 {
+		inputs = []PaymentInput{{Method: string(models.PaymentCash), Amount: total}}
+	}
+
+	payments := make([]models.Payment, 0, len(inputs))
+	var paid, cash models.Money
+	for _, input := range inputs {
+		if input.Amount <= 0 {
+			return nil, 0, errors.New("montant de paiement invalide")
+		}
+		method, err := resolvePaymentMethod(db, shopID, input)
+		if err != nil {
+			return nil, 0, err
+		}
+		payment := models.Payment{
+			PaymentMethodID: method.ID,
+			Method:          method.Kind,
+			MethodName:      method.Name,
+			Amount:          input.Amount,
+			Reference:       strings.TrimSpace(input.Reference),
+			ShopID:          shopID,
+
+			RegisterSessionID: sessionID,
+		}
+		if input.GiftCardCode != "" {
+			if method.Kind != models.PaymentStoreCredit {
+				return nil, 0, errors.New("gift_card_code ne s'utilise qu'avec le moyen store_credit")
+			}
+			card, err := findGiftCard(db, shopID, input.GiftCardCode)
+			if err != nil {
+				return nil, 0, err
+			}
+			payment.GiftCardID = &card.ID
+			payment.Reference = card.Code
+		}
+		payments = append(payments, payment)
+		paid += input.Amount
+		if method.Kind == models.PaymentCash {
+			cash += input.Amount
+		}
+	}
+
+	if paid < total {
+		return nil, 0, fmt.Errorf("paiement insuffisant: reste %s à régler", total-paid)
+	}
+	change := paid - total
+	if change == 0 {
+		return payments, 0, nil
+	}
+	if !allowChange {
+		return nil, 0, fmt.Errorf("le total des paiements (%s) doit être égal au montant (%s)", paid, total)
+	}
+	if change > cash {
+		return nil, 0, errors.New("seules les espèces peuvent dépasser le montant dû (monnaie rendue)")
+	}
+
+	// Monnaie rendue sur les règlements en espèces, en commençant par le dernier
+	remaining := change
+	for i := len(payments) - 1; i >= 0 && remaining > 0; i-- {
+		if payments[i].Method != models.PaymentCash {
+			continue
+		}
+		part := remaining
+		if part > payments[i].Amount {
+			part = payments[i].Amount
+		}
+		payments[i].Tendered = payments[i].Amount
+		payments[i].Change = part
+		payments[i].Amount -= part
+		remaining -= part
+	}
+
+	// Un règlement en espèces entièrement rendu n'est pas un règlement
+	kept := payments[:0]
+	for _, payment := range payments {
+		if payment.Amount > 0 {
+			kept = append(kept, payment)
+		}
+	}
+	return kept, change, nil
+}
+
+// resolvePaymentMethod retrouve le moyen de paiement actif d'un règlement: par id,
+// sinon par type (premier moyen de ce type) ou par nom
+func resolvePaymentMethod(db *gorm.DB, shopID uint, input PaymentInput) (models.PaymentMethod, error) {
+	var method models.PaymentMethod
+	switch {
+	case input.PaymentMethodID != nil:
+		if err := db.Where("id = ? AND shop_id = ?", *input.PaymentMethodID, shopID).First(&method).Error; err != nil {
+			return method, errors.New("moyen de paiement non trouvé")
+		}
+	case strings.TrimSpace(input.Method) != "":
+		kind := models.PaymentKind(strings.ToLower(strings.TrimSpace(input.Method)))
+		err := db.Where("shop_id = ? AND kind = ? AND active = ?", shopID, kind, true).Order("id").First(&method).Error
+		if err != nil {
+			if method, err = findPaymentMethodByName(db, shopID, input.Method); err != nil {
+				return method, fmt.Errorf("moyen de paiement inconnu: %s", input.Method)
+			}
+		}
+	default:
+		return method, errors.New("payment_method_id ou method est requis pour chaque paiement")
+	}
+	if !method.Active {
+		return method, fmt.Errorf("moyen de paiement désactivé: %s", method.Name)
+	}
+	return method, nil
+}
+
+// findPaymentMethodByName cherche un moyen de paiement par nom (sans tenir compte de la casse ni des accents)
+func findPaymentMethodByName(db *gorm.DB, shopID uint, name string) (models.PaymentMethod, error) {
+	var methods []models.PaymentMethod
+	if err := db.Where("shop_id = ?", shopID).Find(&methods).Error; err != nil {
+		return models.PaymentMethod{}, err
+	}
+	key := models.Slugify(name)
+	for _, method := range methods {
+		if models.Slugify(method.Name) == key {
+			return method, nil
+		}
+	}
+	return models.PaymentMethod{}, gorm.ErrRecordNotFound
+}
+
+// findShopPaymentMethod charge le moyen de paiement :id du shop ou répond 400/404
+func findShopPaymentMethod(c *gin.Context, shopID uint) (models.PaymentMethod, bool) {
+	var method models.PaymentMethod
+
+	methodID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de moyen de paiement invalide"})
+		return method, false
+	}
+
+	// MULTI-TENANT
+	if err := database.GetDB().Where("id = ? AND shop_id = ?", methodID, shopID).First(&method).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Moyen de paiement non trouvé"})
+		return method, false
+	}
+	return method, true
+}
+
+// paymentType - Type de transaction d'un règlement (payments p, transactions t): le versement
+// d'une vente à tempérament non soldée compte comme une vente, le versement rendu à
+// l'annulation (montant négatif) comme un remboursement
+const paymentType = "CASE WHEN t.id IS NOT NULL THEN t.type WHEN p.amount < 0 THEN '" + string(models.TypeRefund) +
+	"' ELSE '" + string(models.TypeSale) + "' END"
+
+// shopPayments sélectionne les règlements du shop avec leur transaction (payments p, transactions t)
+func shopPayments(db *gorm.DB, shopID uint) *gorm.DB {
+	return db.Table("payments p").
+		Joins("LEFT JOIN transactions t ON t.id = p.transaction_id AND t.shop_id = p.shop_id").
+		Where("p.shop_id = ?", shopID)
+}
+
+// computeTakings agrège les règlements des ventes et des remboursements par jour de
+// règlement (fuseau du shop: une vente à crédit est encaissée le jour de chaque règlement)
+// et par moyen de paiement, puis par moyen sur toute la période
+func computeTakings(c *gin.Context, db *gorm.DB, shopID uint) ([]TakingsLine, []TakingsLine, reportPeriod, error) {
+	now := time.Now()
+	period, err := parsePeriod(c, db, shopID, now)
+	if err != nil {
+		return nil, nil, period, err
+	}
+	loc := shopLocation(db, shopID)
+	period = boundedPeriod(period, now, loc)
+
+	buckets := timeBuckets(*period.From, *period.To, IntervalDay, loc)
+	if len(buckets) > maxTimeSeriesPoints {
+		return nil, nil, period, fmt.Errorf("période trop longue (%d jours maximum)", maxTimeSeriesPoints)
+	}
+
+	shift := slotShift(*period.From, loc)
+	var rows []takingsRow
+	query := shopPayments(db, shopID).
+		Where(paymentType+" IN ?", []models.TransactionType{models.TypeSale, models.TypeRefund})
+	if err := period.apply(query, "p.created_at").
+		Select(slotColumn("p.created_at", shift) + " as slot, p.payment_method_id, MAX(p.method) as method, " +
+			"MAX(p.method_name) as name, " + paymentType + " as type, COUNT(*) as count, COALESCE(SUM(ABS(p.amount)), 0) as amount").
+		Group("slot, p.payment_method_id, " + paymentType).
+		Scan(&rows).Error; err != nil {
+		return nil, nil, period, err
+	}
+
+	type key struct {
+		day    int
+		method uint
+	}
+	lines := map[key]*TakingsLine{}
+	totals := map[uint]*TakingsLine{}
+	for _, row := range rows {
+		index, err := slotBucket(row.Slot, shift, buckets)
+		if err != nil {
+			return nil, nil, period, err
+		}
+		if index < 0 {
+			continue
+		}
+		k := key{index, row.PaymentMethodID}
+		if lines[k] == nil {
+			lines[k] = &TakingsLine{Date: buckets[index].Format("2006-01-02"), PaymentMethodID: row.PaymentMethodID, Method: row.Method, Name: row.Name, NonCash: row.Method.NonCash()}
+		}
+		if totals[row.PaymentMethodID] == nil {
+			totals[row.PaymentMethodID] = &TakingsLine{PaymentMethodID: row.PaymentMethodID, Method: row.Method, Name: row.Name, NonCash: row.Method.NonCash()}
+		}
+		for _, line := range []*TakingsLine{lines[k], totals[row.PaymentMethodID]} {
+			if row.Type == models.TypeRefund {
+				line.Refunded += row.Amount
+			} else {
+				line.Count += row.Count
+				line.Received += row.Amount
+			}
+			line.Net = line.Received - line.Refunded
+		}
+	}
+
+	days := make([]TakingsLine, 0, len(lines))
+	for _, line := range lines {
+		days = append(days, *line)
+	}
+	sort.Slice(days, func(i, j int) bool {
+		if days[i].Date != days[j].Date {
+			return days[i].Date < days[j].Date
+		}
+		return days[i].PaymentMethodID < days[j].PaymentMethodID
+	})
+
+	methods := make([]TakingsLine, 0, len(totals))
+	for _, line := range totals {
+		methods = append(methods, *line)
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].PaymentMethodID < methods[j].PaymentMethodID })
+
+	return days, methods, period, nil
+}
\ No newline at end of file
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"electronic-shop-api/models"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// PÉRIODES DES RAPPORTS
+// ========================================
+
+// Périodes prédéfinies (paramètre period)
+const (
+	PeriodToday  = "today"
+	PeriodWeek   = "week" // Semaine en cours, du lundi au dimanche
+	PeriodMonth  = "month"
+	PeriodYear   = "year"
+	PeriodCustom = "custom" // from / to
+	PeriodAll    = "all"    // Sans borne
+)
+
+// reportPeriod - Intervalle [From, To[ d'un rapport; une borne nil n'est pas appliquée
+type reportPeriod struct {
+	Name     string     `json:"name"`
+	From     *time.Time `json:"from"`
+	To       *time.Time `json:"to"`
+	Timezone string     `json:"timezone"`
+}
+
+// parsePeriod lit period (today, week, month, year) ou from / to (YYYY-MM-DD, YYYY-MM-DD HH:MM ou RFC3339).
+// Les jours commencent à minuit dans le fuseau horaire du shop; une date seule en to inclut toute la journée.
+func parsePeriod(c *gin.Context, db *gorm.DB, shopID uint, now time.Time) (reportPeriod, error) {
+	loc := shopLocation(db, shopID)
+	now = now.In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	period := reportPeriod{Name: PeriodAll, Timezone: loc.String()}
+
+	var from, to time.Time
+	switch name := c.Query("period"); name {
+	case PeriodToday:
+		from, to = today, today.AddDate(0, 0, 1)
+	case PeriodWeek:
+		from = today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		to = from.AddDate(0, 0, 7)
+	case PeriodMonth:
+		from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+		to = from.AddDate(0, 1, 0)
+	case PeriodYear:
+		from = time.Date(now.Year(), 1, 1, 0, 0, 0, 0, loc)
+		to = from.AddDate(1, 0, 0)
+	case "", PeriodAll, PeriodCustom:
+		return customPeriod(c, period, loc)
+	default:
+		return period, errors.New("period invalide (today, week, month ou year)")
+	}
+
+	if c.Query("from") != "" || c.Query("to") != "" {
+		return period, errors.New("period ne peut pas être combiné avec from / to")
+	}
+	period.Name, period.From, period.To = c.Query("period"), &from, &to
+	return period, nil
+}
+
+// customPeriod lit les bornes from / to
+func customPeriod(c *gin.Context, period reportPeriod, loc *time.Location) (reportPeriod, error) {
+	if value := c.Query("from"); value != "" {
+		from, err := parseDateIn(value, loc)
+		if err != nil {
+			return period, errors.New("from invalide (RFC3339, YYYY-MM-DD HH:MM ou YYYY-MM-DD)")
+		}
+		period.From = &from
+	}
+	if value := c.Query("to"); value != "" {
+		to, err := parseDateIn(value, loc)
+		if err != nil {
+			return period, errors.New("to invalide (RFC3339, YYYY-MM-DD HH:MM ou YYYY-MM-DD)")
+		}
+		if len(value) == len("2006-01-02") {
+			to = to.AddDate(0, 0, 1)
+		}
+		period.To = &to
+	}
+	if period.From != nil && period.To != nil && !period.To.After(*period.From) {
+		return period, errors.New("to doit être postérieur à from")
+	}
+	if period.From != nil || period.To != nil {
+		period.Name = PeriodCustom
+	}
+	return period, nil
+}
+
+// applyPeriod filtre column sur la période demandée (period ou from / to, fuseau du shop)
+func applyPeriod(c *gin.Context, db *gorm.DB, shopID uint, query *gorm.DB, column string) (*gorm.DB, error) {
+	period, err := parsePeriod(c, db, shopID, time.Now())
+	if err != nil {
+		return query, err
+	}
+	return period.apply(query, column), nil
+}
+
+// apply filtre column sur la période. SQLite stocke les dates en texte avec le décalage
+// de leur fuseau: la comparaison passe par julianday() pour comparer des instants, quel
+// que soit le fuseau du serveur ou du shop.
+func (p reportPeriod) apply(query *gorm.DB, column string) *gorm.DB {
+	if p.From != nil {
+		query = query.Where("julianday("+column+") >= julianday(?)", p.From.UTC())
+	}
+	if p.To != nil {
+		query = query.Where("julianday("+column+") < julianday(?)", p.To.UTC())
+	}
+	return query
+}
+
+// previous retourne la période équivalente précédente: mois, semaine, jour ou année civile
+// précédente pour les périodes prédéfinies, même durée juste avant from sinon.
+func (p reportPeriod) previous(now time.Time) (reportPeriod, error) {
+	if p.From == nil {
+		return p, errors.New("la comparaison nécessite une période (period ou from)")
+	}
+	to := now
+	if p.To != nil {
+		to = *p.To
+	}
+
+	from := *p.From
+	prevFrom, prevTo := from.Add(-to.Sub(from)), from
+	switch p.Name {
+	case PeriodToday:
+		prevFrom = from.AddDate(0, 0, -1)
+	case PeriodWeek:
+		prevFrom = from.AddDate(0, 0, -7)
+	case PeriodMonth:
+		prevFrom = from.AddDate(0, -1, 0)
+	case PeriodYear:
+		prevFrom = from.AddDate(-1, 0, 0)
+	}
+	return reportPeriod{Name: p.Name, From: &prevFrom, To: &prevTo, Timezone: p.Timezone}, nil
+}
+
+// shopLocation retourne le fuseau horaire du shop (fuseau du serveur par défaut)
+func shopLocation(db *gorm.DB, shopID uint) *time.Location {
+	var shop models.Shop
+	if err := db.Select("timezone").First(&shop, shopID).Error; err != nil || shop.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(shop.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// percentChange - Variation en % par rapport à la période précédente (nil si elle est nulle)
+func percentChange(current, previous float64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := roundAmount((current - previous) / math.Abs(previous) * 100)
+	return &change
+}
\ No newline at end of file
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"electronic-shop-api/models"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// newTestDB ouvre une base SQLite en mémoire avec les tables données
+func newTestDB(t *testing.T, tables ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.SetMaxOpenConns(1) // Base en mémoire: une seule connexion
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// Shop à Lagos (UTC+1), serveur à New York: les bornes du jour du shop doivent
+// retrouver les ventes enregistrées avec le décalage du serveur
+func TestReportPeriodShopZoneDiffersFromServer(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("fuseaux horaires indisponibles:", err)
+	}
+	lagos, err := time.LoadLocation("Africa/Lagos")
+	if err != nil {
+		t.Skip("fuseaux horaires indisponibles:", err)
+	}
+	server := time.Local
+	time.Local = newYork
+	defer func() { time.Local = server }()
+
+	db := newTestDB(t, &models.Transaction{})
+
+	// Enregistrées comme par GORM (heure du serveur): la première tombe le 20 à Lagos
+	sales := []time.Time{
+		time.Date(2026, 10, 19, 23, 30, 0, 0, time.UTC), // 20/10 00:30 à Lagos
+		time.Date(2026, 10, 19, 22, 30, 0, 0, time.UTC), // 19/10 23:30 à Lagos
+		time.Date(2026, 10, 20, 22, 59, 0, 0, time.UTC), // 20/10 23:59 à Lagos
+		time.Date(2026, 10, 20, 23, 0, 0, 0, time.UTC),  // 21/10 00:00 à Lagos
+	}
+	for _, at := range sales {
+		if err := db.Create(&models.Transaction{Type: models.TypeSale, ShopID: 1, CreatedAt: at.In(time.Local)}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	from := time.Date(2026, 10, 20, 0, 0, 0, 0, lagos)
+	to := from.AddDate(0, 0, 1)
+	var count int64
+	reportPeriod{From: &from, To: &to}.apply(db.Model(&models.Transaction{}), "created_at").Count(&count)
+	if count != 2 {
+		t.Fatalf("ventes du 20/10 à Lagos: %d, attendu 2", count)
+	}
+
+	// Une date enregistrée avec un autre décalage est comparée sur l'instant
+	if err := db.Create(&models.Transaction{Type: models.TypeSale, ShopID: 1, CreatedAt: time.Date(2026, 10, 20, 12, 0, 0, 0, lagos)}).Error; err != nil {
+		t.Fatal(err)
+	}
+	reportPeriod{From: &from, To: &to}.apply(db.Model(&models.Transaction{}), "created_at").Count(&count)
+	if count != 3 {
+		t.Fatalf("ventes du 20/10 à Lagos: %d, attendu 3", count)
+	}
+}
+
+// Dates saisies sans fuseau: heure du shop (Lagos). Les lignes enregistrées en heure du
+// serveur (New York) sont comparées sur l'instant, pas sur le texte
+func TestParseDateUsesShopZone(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("fuseaux horaires indisponibles:", err)
+	}
+	server := time.Local
+	time.Local = newYork
+	defer func() { time.Local = server }()
+
+	db := newTestDB(t, &models.Shop{}, &models.Product{})
+	shop := models.Shop{Name: "Lagos", Timezone: "Africa/Lagos"}
+	if err := db.Create(&shop).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2026-10-20", time.Date(2026, 10, 19, 23, 0, 0, 0, time.UTC)},
+		{"2026-10-20 08:30", time.Date(2026, 10, 20, 7, 30, 0, 0, time.UTC)},
+		{"2026-10-20T08:30", time.Date(2026, 10, 20, 7, 30, 0, 0, time.UTC)},
+		{"2026-10-20T08:30:00+03:00", time.Date(2026, 10, 20, 5, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseDate(db, shop.ID, tt.value)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.value, err)
+		}
+		if !got.Equal(tt.want) {
+			t.Fatalf("%s: %s, attendu %s", tt.value, got, tt.want)
+		}
+	}
+	if _, err := parseDate(db, shop.ID, "20/10/2026"); err == nil {
+		t.Fatal("20/10/2026: date acceptée")
+	}
+
+	// Produit modifié le 20 à 00:30 à Lagos (19 au soir à New York): retenu depuis le 20,
+	// pas le produit modifié le 19 à 23:30 à Lagos
+	for _, at := range []time.Time{
+		time.Date(2026, 10, 19, 23, 30, 0, 0, time.UTC),
+		time.Date(2026, 10, 19, 22, 30, 0, 0, time.UTC),
+	} {
+		updated := at.In(time.Local)
+		if err := db.Create(&models.Product{Name: "Câble", ShopID: shop.ID, PriceUpdatedAt: &updated}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	since, _ := parseDate(db, shop.ID, "2026-10-20")
+	var count int64
+	reportPeriod{From: &since}.apply(db.Model(&models.Product{}), "COALESCE(price_updated_at, created_at)").Count(&count)
+	if count != 1 {
+		t.Fatalf("produits modifiés depuis le 20/10: %d, attendu 1", count)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"electronic-shop-api/database"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// priceSchedulerInterval - Fréquence de vérification des changements de prix programmés
+const priceSchedulerInterval = 30 * time.Second
+
+// ========================================
+// STRUCTURE DE REQUÊTE
+// ========================================
+
+type CreatePriceScheduleInput struct {
+	Label        string       `json:"label"`
+	SellingPrice models.Money `json:"selling_price" binding:"required,gt=0"`
+	StartsAt     string       `json:"starts_at" binding:"required"` // RFC3339, "YYYY-MM-DD HH:MM" ou "YYYY-MM-DD" (fuseau du shop)
+	EndsAt       string       `json:"ends_at"`                      // Vide: changement définitif
+}
+
+// ========================================
+// GET PRICE HISTORY
+// ========================================
+
+func GetPriceHistory(c *gin.Context) {
+	_, shopID, role := middleware.GetUserFromContext(c)
+
+	product, ok := findShopProduct(c, shopID)
+	if !ok {
+		return
+	}
+
+	query := database.GetDB().Where("product_id = ?", product.ID)
+
+	// Si Admin, masquer l'historique du PurchasePrice
+	if role == models.RoleAdmin {
+		query = query.Where("field = ?", "selling_price")
+	}
+
+	var history []models.PriceHistory
+	if err := query.Order("created_at DESC, id DESC").Find(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération de l'historique"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"product_id": product.ID, "history": history, "count": len(history)})
+}
+
+// ========================================
+// PRICE SCHEDULES
+// ========================================
+
+func GetPriceSchedules(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	product, ok := findShopProduct(c, shopID)
+	if !ok {
+		return
+	}
+
+	var schedules []models.PriceSchedule
+	if err := database.GetDB().Where("product_id = ?", product.ID).
+		Order("starts_at DESC").Find(&schedules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des changements programmés"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"schedules": schedules, "count": len(schedules)})
+}
+
+func CreatePriceSchedule(c *gin.Context) {
+	userID, shopID, _ := middleware.GetUserFromContext(c)
+
+	product, ok := findShopProduct(c, shopID)
+	if !ok {
+		return
+	}
+
+	var input CreatePriceScheduleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	startsAt, err := parseDate(db, shopID, input.StartsAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "starts_at invalide (RFC3339, YYYY-MM-DD HH:MM ou YYYY-MM-DD)"})
+		return
+	}
+	var endsAt *time.Time
+	if input.EndsAt != "" {
+		end, err := parseDate(db, shopID, input.EndsAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at invalide (RFC3339, YYYY-MM-DD HH:MM ou YYYY-MM-DD)"})
+			return
+		}
+		if !end.After(startsAt) || !end.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at doit être postérieur à starts_at et à maintenant"})
+			return
+		}
+		endsAt = &end
+	}
+
+	// Validation: prix de vente > prix d'achat (converti dans la devise du shop)
+	cost, err := productCost(db, shopID, product, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.SellingPrice < cost {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le prix de vente doit être supérieur au prix d'achat"})
+		return
+	}
+
+	// Un seul changement programmé à la fois sur une période donnée
+	overlap := db.Model(&models.PriceSchedule{}).
+		Where("product_id = ? AND status IN ?", product.ID, []models.PriceScheduleStatus{models.ScheduleScheduled, models.ScheduleActive}).
+		Where("ends_at IS NULL OR julianday(ends_at) > julianday(?)", startsAt.UTC())
+	if endsAt != nil {
+		overlap = overlap.Where("julianday(starts_at) < julianday(?)", endsAt.UTC())
+	}
+	var conflicts int64
+	overlap.Count(&conflicts)
+	if conflicts > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Un changement de prix est déjà programmé sur cette période"})
+		return
+	}
+
+	schedule := models.PriceSchedule{
+		ProductID:    product.ID,
+		Label:        input.Label,
+		SellingPrice: input.SellingPrice,
+		StartsAt:     startsAt,
+		EndsAt:       endsAt,
+		Status:       models.ScheduleScheduled,
+		CreatedBy:    userID,
+		ShopID:       shopID,
+	}
+	if err := db.Create(&schedule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la programmation"})
+		return
+	}
+
+	// Début immédiat: appliquer sans attendre le prochain passage du planificateur
+	if !startsAt.After(time.Now()) {
+		processPriceSchedules(time.Now())
+		db.First(&schedule, schedule.ID)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Changement de prix programmé", "schedule": schedule})
+}
+
+// CancelPriceSchedule annule un changement à venir ou interrompt un changement en cours
+// (le prix précédent est alors restauré)
+func CancelPriceSchedule(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	product, ok := findShopProduct(c, shopID)
+	if !ok {
+		return
+	}
+
+	scheduleID, err := strconv.ParseUint(c.Param("scheduleID"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de programmation invalide"})
+		return
+	}
+
+	db := database.GetDB()
+	var schedule models.PriceSchedule
+	if err := db.Where("id = ? AND product_id = ?", scheduleID, product.ID).First(&schedule).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Changement programmé non trouvé"})
+		return
+	}
+
+	switch schedule.Status {
+	case models.ScheduleScheduled:
+		err = db.Model(&schedule).Where("status = ?", models.ScheduleScheduled).
+			Update("status", models.ScheduleCancelled).Error
+	case models.ScheduleActive:
+		err = endPriceSchedule(db, schedule, time.Now(), models.ScheduleCancelled)
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": "Ce changement de prix est déjà terminé"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'annulation"})
+		return
+	}
+
+	db.First(&schedule, schedule.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "Changement de prix annulé", "schedule": schedule})
+}
+
+// ========================================
+// PLANIFICATEUR
+// ========================================
+
+// StartPriceScheduler applique et termine les changements de prix programmés en arrière-plan
+func StartPriceScheduler() {
+	go func() {
+		ticker := time.NewTicker(priceSchedulerInterval)
+		defer ticker.Stop()
+
+		processPriceSchedules(time.Now())
+		for now := range ticker.C {
+			processPriceSchedules(now)
+		}
+	}()
+	log.Println("✅ Planificateur de prix démarré")
+}
+
+// processPriceSchedules démarre les changements arrivés à échéance puis restaure les prix des périodes terminées
+func processPriceSchedules(now time.Time) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("❌ Planificateur de prix: %v", r)
+		}
+	}()
+
+	db := database.GetDB()
+
+	var due []models.PriceSchedule
+	db.Where("status = ? AND julianday(starts_at) <= julianday(?)", models.ScheduleScheduled, now.UTC()).Order("starts_at ASC").Find(&due)
+	for _, schedule := range due {
+		if err := startPriceSchedule(db, schedule, now); err != nil {
+			log.Printf("❌ Changement de prix %d: %v", schedule.ID, err)
+		}
+	}
+
+	var ending []models.PriceSchedule
+	db.Where("status = ? AND julianday(ends_at) <= julianday(?)", models.ScheduleActive, now.UTC()).Find(&ending)
+	for _, schedule := range ending {
+		if err := endPriceSchedule(db, schedule, now, models.ScheduleCompleted); err != nil {
+			log.Printf("❌ Fin du changement de prix %d: %v", schedule.ID, err)
+		}
+	}
+}
+
+// startPriceSchedule applique le prix programmé et mémorise le prix à restaurer
+func startPriceSchedule(db *gorm.DB, schedule models.PriceSchedule, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Relire dans la transaction: une annulation a pu passer entre-temps
+		if err := tx.Where("id = ? AND status = ?", schedule.ID, models.ScheduleScheduled).First(&schedule).Error; err != nil {
+			return nil
+		}
+
+		var product models.Product
+		if err := tx.Where("id = ? AND shop_id = ?", schedule.ProductID, schedule.ShopID).First(&product).Error; err != nil {
+			return finishSchedule(tx, &schedule, models.ScheduleFailed, "Produit introuvable")
+		}
+
+		// Serveur arrêté pendant toute la période: ne rien appliquer
+		if schedule.EndsAt != nil && !schedule.EndsAt.After(now) {
+			return finishSchedule(tx, &schedule, models.ScheduleCompleted, "Période écoulée avant application")
+		}
+		// Prix d'achat converti dans la devise du shop au taux du jour d'application
+		cost, err := productCost(tx, schedule.ShopID, product, now)
+		if err != nil {
+			return finishSchedule(tx, &schedule, models.ScheduleFailed, err.Error())
+		}
+		if schedule.SellingPrice < cost {
+			return finishSchedule(tx, &schedule, models.ScheduleFailed, "Le prix de vente doit être supérieur au prix d'achat")
+		}
+
+		previous := product.SellingPrice
+		updates := map[string]interface{}{"selling_price": schedule.SellingPrice}
+		if schedule.SellingPrice != product.SellingPrice {
+			updates["price_updated_at"] = now
+		}
+		if err := recordPriceChanges(tx, product, updates, models.PriceHistory{Source: models.PriceSourceSchedule, ScheduleID: &schedule.ID}); err != nil {
+			return err
+		}
+		if err := tx.Model(&product).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		schedule.PreviousPrice = &previous
+		schedule.AppliedAt = &now
+		if schedule.EndsAt == nil {
+			return finishSchedule(tx, &schedule, models.ScheduleCompleted, "")
+		}
+		schedule.Status = models.ScheduleActive
+		return tx.Save(&schedule).Error
+	})
+}
+
+// endPriceSchedule restaure le prix précédent, sauf si le prix a été modifié pendant la période
+func endPriceSchedule(db *gorm.DB, schedule models.PriceSchedule, now time.Time, status models.PriceScheduleStatus) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND status = ?", schedule.ID, models.ScheduleActive).First(&schedule).Error; err != nil {
+			return nil
+		}
+
+		var product models.Product
+		if err := tx.Where("id = ? AND shop_id = ?", schedule.ProductID, schedule.ShopID).First(&product).Error; err != nil {
+			return finishSchedule(tx, &schedule, status, "Produit introuvable")
+		}
+		if schedule.PreviousPrice == nil || product.SellingPrice != schedule.SellingPrice {
+			return finishSchedule(tx, &schedule, status, "Prix modifié pendant la période: prix actuel conservé")
+		}
+
+		updates := map[string]interface{}{"selling_price": *schedule.PreviousPrice, "price_updated_at": now}
+		if err := recordPriceChanges(tx, product, updates, models.PriceHistory{Source: models.PriceSourceSchedule, ScheduleID: &schedule.ID}); err != nil {
+			return err
+		}
+		if err := tx.Model(&product).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		schedule.RevertedAt = &now
+		return finishSchedule(tx, &schedule, status, "")
+	})
+}
+
+// finishSchedule enregistre l'état final d'un changement programmé
+func finishSchedule(tx *gorm.DB, schedule *models.PriceSchedule, status models.PriceScheduleStatus, message string) error {
+	schedule.Status = status
+	schedule.Message = message
+	return tx.Save(schedule).Error
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// recordPriceChanges historise les changements de prix contenus dans updates.
+// product est l'état avant modification; entry fournit la source, l'auteur et la programmation.
+func recordPriceChanges(tx *gorm.DB, product models.Product, updates map[string]interface{}, entry models.PriceHistory) error {
+	current := map[string]models.Money{
+		"selling_price":  product.SellingPrice,
+		"purchase_price": product.PurchasePrice,
+	}
+
+	var entries []models.PriceHistory
+	for _, field := range []string{"selling_price", "purchase_price"} {
+		price, ok := updates[field].(models.Money)
+		if !ok || price == current[field] {
+			continue
+		}
+		change := entry
+		change.ProductID = product.ID
+		change.ShopID = product.ShopID
+		change.Field = field
+		change.OldPrice = current[field]
+		change.NewPrice = price
+		entries = append(entries, change)
+	}
+
+	if len(entries) == 0 {
+		return nil
+	}
+	return tx.Create(&entries).Error
+}
+
+// userRef retourne un pointeur vers l'ID utilisateur (nil si inconnu)
+func userRef(userID uint) *uint {
+	if userID == 0 {
+		return nil
+	}
+	return &userID
+}
\ No newline at end of file
@@ -4,10 +4,15 @@ import (
 	"electronic-shop-api/database"
 	"electronic-shop-api/middleware"
 	"electronic-shop-api/models"
+	"electronic-shop-api/storage"
+	"errors"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // ========================================
@@ -15,23 +20,172 @@ import (
 // ========================================
 
 type CreateProductInput struct {
-	Name          string  `json:"name" binding:"required"`
-	Description   string  `json:"description"`
-	Category      string  `json:"category"`
-	PurchasePrice float64 `json:"purchase_price" binding:"required,gt=0"`
-	SellingPrice  float64 `json:"selling_price" binding:"required,gt=0"`
-	Stock         int     `json:"stock" binding:"gte=0"`
-	ImageURL      string  `json:"image_url"`
+	Name          string       `json:"name" binding:"required"`
+	Description   string       `json:"description"`
+	CategoryID    *uint        `json:"category_id"`
+	Category      string       `json:"category"` // Compatibilité: nom libre, rapproché d'une catégorie existante
+	SKU           string       `json:"sku"`
+	Barcode       string       `json:"barcode"`
+	PurchasePrice models.Money `json:"purchase_price" binding:"required,gt=0"`
+	SellingPrice  models.Money `json:"selling_price" binding:"required,gt=0"`
+	Stock         int          `json:"stock" binding:"gte=0"`
+	ImageURL      string       `json:"image_url"`
+	TaxRateID     *uint        `json:"tax_rate_id"` // Classe fiscale (défaut: taux par défaut du shop)
+
+	// Devise du prix d'achat (ex: USD), vide pour la devise du shop
+	PurchaseCurrency string `json:"purchase_currency"`
+
+	// Générer un EAN-13 interne si aucun code-barres n'est fourni
+	GenerateBarcode bool `json:"generate_barcode"`
+
+	// Fiche technique: {"ram": 16, "stockage": 512, "ecran": "OLED"}
+	Attributes map[string]interface{} `json:"attributes"`
 }
 
 type UpdateProductInput struct {
-	Name          string  `json:"name"`
-	Description   string  `json:"description"`
-	Category      string  `json:"category"`
-	PurchasePrice float64 `json:"purchase_price"`
-	SellingPrice  float64 `json:"selling_price"`
-	Stock         int     `json:"stock"`
-	ImageURL      string  `json:"image_url"`
+	Name          string       `json:"name"`
+	Description   string       `json:"description"`
+	CategoryID    *uint        `json:"category_id"`
+	Category      string       `json:"category"`
+	SKU           string       `json:"sku"`
+	Barcode       string       `json:"barcode"`
+	PurchasePrice models.Money `json:"purchase_price"`
+	SellingPrice  models.Money `json:"selling_price"`
+	Stock         *int         `json:"stock" binding:"omitempty,gte=0"` // Absent: stock inchangé
+	ImageURL      string       `json:"image_url"`
+	TaxRateID     *uint        `json:"tax_rate_id"` // 0 = revenir au taux par défaut du shop
+
+	PurchaseCurrency string `json:"purchase_currency"` // Devise du prix d'achat (devise du shop pour revenir à celle-ci)
+
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// productWithoutPurchasePrice - Vue produit pour les Admin (PurchasePrice masqué)
+func productWithoutPurchasePrice(p models.Product) gin.H {
+	return gin.H{
+		"id":            p.ID,
+		"name":          p.Name,
+		"description":   p.Description,
+		"category":      p.Category,
+		"category_id":   p.CategoryID,
+		"sku":           p.SKU,
+		"barcode":       p.Barcode,
+		"selling_price": p.SellingPrice,
+		"stock":         p.Stock,
+		"reserved":      p.Reserved,
+		"image_url":     p.ImageURL,
+		"tax_rate_id":   p.TaxRateID,
+		"archived_at":   p.ArchivedAt,
+		"images":        p.Images,
+		"attributes":    p.Attributes,
+		"shop_id":       p.ShopID,
+		"created_at":    p.CreatedAt,
+	}
+}
+
+// productDetails précharge la fiche technique et les images (triées) d'un produit
+func productDetails(db *gorm.DB) *gorm.DB {
+	return db.Preload("Attributes.Attribute").
+		Preload("Images", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC, id ASC") })
+}
+
+// filterProducts applique les filtres de la liste des produits (partagés avec l'export
+// et les opérations en masse). Retourne le code HTTP à renvoyer en cas d'erreur.
+func filterProducts(db *gorm.DB, shopID uint, params url.Values) (*gorm.DB, int, error) {
+	// MULTI-TENANT: Filtrer par ShopID du token
+	query := db.Where("shop_id = ?", shopID)
+
+	// Produits archivés masqués par défaut (archived=true: archivés seulement, archived=all: tous)
+	switch params.Get("archived") {
+	case "", "false":
+		query = query.Where("archived_at IS NULL")
+	case "true":
+		query = query.Where("archived_at IS NOT NULL")
+	case "all":
+	default:
+		return nil, http.StatusBadRequest, errors.New("archived invalide (true, false ou all)")
+	}
+
+	// Filtre par catégorie, sous-catégories incluses (optionnel)
+	if category := params.Get("category"); category != "" {
+		var err error
+		if query, err = applyCategoryFilter(db, query, shopID, category); err != nil {
+			return nil, http.StatusInternalServerError, errors.New("Erreur lors de la récupération des produits")
+		}
+	}
+
+	// Filtres par fiche technique (optionnel)
+	query, err := applyAttributeFilters(query, shopID, params)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	return query, http.StatusOK, nil
+}
+
+// prepareProduct applique les règles de validation d'un produit (prix, SKU/code-barres,
+// catégorie, fiche technique) et construit le produit à enregistrer.
+// productID exclut le produit lui-même des contrôles d'unicité (0 pour une création).
+func prepareProduct(db *gorm.DB, shopID, productID uint, input CreateProductInput) (models.Product, []models.ProductAttribute, int, error) {
+	// Devise d'achat (prix d'achat converti pour la comparaison)
+	currency, err := purchaseCurrency(db, shopID, input.PurchaseCurrency)
+	if err != nil {
+		return models.Product{}, nil, http.StatusBadRequest, err
+	}
+	cost, _, err := toShopCurrency(db, shopID, input.PurchasePrice, currency, time.Now())
+	if err != nil {
+		return models.Product{}, nil, http.StatusBadRequest, err
+	}
+
+	// Validation: prix de vente > prix d'achat
+	if input.SellingPrice < cost {
+		return models.Product{}, nil, http.StatusBadRequest, errors.New("Le prix de vente doit être supérieur au prix d'achat")
+	}
+
+	// SKU et code-barres uniques dans le shop
+	input.SKU, input.Barcode = normalizeCode(input.SKU), normalizeCode(input.Barcode)
+	if status, err := checkProductCodes(db, shopID, productID, input.SKU, input.Barcode); err != nil {
+		return models.Product{}, nil, status, err
+	}
+
+	product := models.Product{
+		Name:          input.Name,
+		Description:   input.Description,
+		SKU:           optionalCode(input.SKU),
+		Barcode:       optionalCode(input.Barcode),
+		PurchasePrice: input.PurchasePrice,
+		SellingPrice:  input.SellingPrice,
+		Stock:         input.Stock,
+		ImageURL:      input.ImageURL,
+		ShopID:        shopID, // Toujours prendre le ShopID du token !
+
+		PurchaseCurrency: currency,
+	}
+
+	// Rattacher à une catégorie du shop
+	category, err := resolveProductCategory(db, shopID, input.CategoryID, input.Category)
+	if err != nil {
+		return models.Product{}, nil, http.StatusBadRequest, err
+	}
+	if category != nil {
+		product.CategoryID = &category.ID
+		product.Category = category.Name
+	}
+
+	// Classe fiscale du shop
+	if input.TaxRateID != nil {
+		if _, ok := checkTaxRate(db, shopID, *input.TaxRateID); !ok {
+			return models.Product{}, nil, http.StatusBadRequest, errors.New("Taux de taxe non trouvé")
+		}
+		product.TaxRateID = input.TaxRateID
+	}
+
+	// Validation de la fiche technique selon la catégorie
+	attributes, err := buildProductAttributes(db, shopID, product.CategoryID, input.Attributes, true)
+	if err != nil {
+		return models.Product{}, nil, http.StatusBadRequest, err
+	}
+
+	return product, attributes, http.StatusOK, nil
 }
 
 // ========================================
@@ -44,8 +198,13 @@ func GetProducts(c *gin.Context) {
 
 	var products []models.Product
 
-	// MULTI-TENANT: Filtrer par ShopID du token
-	if err := db.Where("shop_id = ?", shopID).Find(&products).Error; err != nil {
+	query, status, err := filterProducts(db, shopID, c.Request.URL.Query())
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := query.Scopes(productDetails).Find(&products).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des produits"})
 		return
 	}
@@ -54,17 +213,7 @@ func GetProducts(c *gin.Context) {
 	if role == models.RoleAdmin {
 		var filteredProducts []gin.H
 		for _, p := range products {
-			filteredProducts = append(filteredProducts, gin.H{
-				"id":            p.ID,
-				"name":          p.Name,
-				"description":   p.Description,
-				"category":      p.Category,
-				"selling_price": p.SellingPrice,
-				"stock":         p.Stock,
-				"image_url":     p.ImageURL,
-				"shop_id":       p.ShopID,
-				"created_at":    p.CreatedAt,
-			})
+			filteredProducts = append(filteredProducts, productWithoutPurchasePrice(p))
 		}
 		c.JSON(http.StatusOK, gin.H{"products": filteredProducts, "count": len(filteredProducts)})
 		return
@@ -91,26 +240,15 @@ func GetProduct(c *gin.Context) {
 	var product models.Product
 
 	// MULTI-TENANT: Vérifier que le produit appartient au shop
-	if err := db.Where("id = ? AND shop_id = ?", productID, shopID).First(&product).Error; err != nil {
+	if err := db.Where("id = ? AND shop_id = ?", productID, shopID).
+		Scopes(productDetails).First(&product).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Produit non trouvé"})
 		return
 	}
 
 	// Si Admin, masquer PurchasePrice
 	if role == models.RoleAdmin {
-		c.JSON(http.StatusOK, gin.H{
-			"product": gin.H{
-				"id":            product.ID,
-				"name":          product.Name,
-				"description":   product.Description,
-				"category":      product.Category,
-				"selling_price": product.SellingPrice,
-				"stock":         product.Stock,
-				"image_url":     product.ImageURL,
-				"shop_id":       product.ShopID,
-				"created_at":    product.CreatedAt,
-			},
-		})
+		c.JSON(http.StatusOK, gin.H{"product": productWithoutPurchasePrice(product)})
 		return
 	}
 
@@ -130,29 +268,34 @@ func CreateProduct(c *gin.Context) {
 		return
 	}
 
-	// Validation: prix de vente > prix d'achat
-	if input.SellingPrice < input.PurchasePrice {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Le prix de vente doit être supérieur au prix d'achat"})
-		return
-	}
+	db := database.GetDB()
 
-	product := models.Product{
-		Name:          input.Name,
-		Description:   input.Description,
-		Category:      input.Category,
-		PurchasePrice: input.PurchasePrice,
-		SellingPrice:  input.SellingPrice,
-		Stock:         input.Stock,
-		ImageURL:      input.ImageURL,
-		ShopID:        shopID, // Toujours prendre le ShopID du token !
+	// Mêmes règles que l'import en masse (prix, codes, catégorie, fiche technique)
+	product, attributes, status, err := prepareProduct(db, shopID, 0, input)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
 	}
 
-	db := database.GetDB()
-	if err := db.Create(&product).Error; err != nil {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
+		if input.GenerateBarcode && product.Barcode == nil {
+			barcode := models.InternalBarcode(shopID, product.ID)
+			if err := tx.Model(&product).Update("barcode", barcode).Error; err != nil {
+				return err
+			}
+		}
+		return replaceProductAttributes(tx, product.ID, attributes)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création du produit"})
 		return
 	}
 
+	db.Scopes(productDetails).First(&product, product.ID)
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Produit créé avec succès",
 		"product": product,
@@ -164,7 +307,7 @@ func CreateProduct(c *gin.Context) {
 // ========================================
 
 func UpdateProduct(c *gin.Context) {
-	_, shopID, _ := middleware.GetUserFromContext(c)
+	userID, shopID, _ := middleware.GetUserFromContext(c)
 
 	productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -195,28 +338,111 @@ func UpdateProduct(c *gin.Context) {
 	if input.Description != "" {
 		updates["description"] = input.Description
 	}
-	if input.Category != "" {
-		updates["category"] = input.Category
+	if input.SKU != "" || input.Barcode != "" {
+		input.SKU, input.Barcode = normalizeCode(input.SKU), normalizeCode(input.Barcode)
+		if status, err := checkProductCodes(db, shopID, product.ID, input.SKU, input.Barcode); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		if input.SKU != "" {
+			updates["sku"] = input.SKU
+		}
+		if input.Barcode != "" {
+			updates["barcode"] = input.Barcode
+		}
+	}
+
+	// Changement de catégorie
+	categoryID := product.CategoryID
+	if input.CategoryID != nil || input.Category != "" {
+		category, err := resolveProductCategory(db, shopID, input.CategoryID, input.Category)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if category != nil {
+			categoryID = &category.ID
+			updates["category_id"] = category.ID
+			updates["category"] = category.Name
+		}
 	}
 	if input.PurchasePrice > 0 {
 		updates["purchase_price"] = input.PurchasePrice
 	}
+	if input.PurchaseCurrency != "" {
+		currency, err := purchaseCurrency(db, shopID, input.PurchaseCurrency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updates["purchase_currency"] = currency
+	}
 	if input.SellingPrice > 0 {
 		updates["selling_price"] = input.SellingPrice
+		if input.SellingPrice != product.SellingPrice {
+			updates["price_updated_at"] = time.Now()
+		}
 	}
-	if input.Stock >= 0 {
-		updates["stock"] = input.Stock
+	if input.Stock != nil {
+		if *input.Stock < product.Reserved {
+			c.JSON(http.StatusConflict, gin.H{"error": "Stock inférieur aux unités réservées par des ventes à tempérament", "reserved": product.Reserved})
+			return
+		}
+		updates["stock"] = *input.Stock
 	}
 	if input.ImageURL != "" {
 		updates["image_url"] = input.ImageURL
 	}
+	if input.TaxRateID != nil {
+		if *input.TaxRateID == 0 {
+			updates["tax_rate_id"] = nil
+		} else if _, ok := checkTaxRate(db, shopID, *input.TaxRateID); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Taux de taxe non trouvé"})
+			return
+		} else {
+			updates["tax_rate_id"] = *input.TaxRateID
+		}
+	}
 
-	if err := db.Model(&product).Updates(updates).Error; err != nil {
+	// Fiche technique: revalider si les valeurs ou la catégorie changent
+	categoryChanged := categoryID != nil && (product.CategoryID == nil || *categoryID != *product.CategoryID)
+	var attributes []models.ProductAttribute
+	if input.Attributes != nil || categoryChanged {
+		values := input.Attributes
+		if values == nil {
+			// Conserver les valeurs existantes encore valides dans la nouvelle catégorie
+			values, err = keptAttributeValues(db, shopID, product.ID, categoryID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour"})
+				return
+			}
+		}
+
+		attributes, err = buildProductAttributes(db, shopID, categoryID, values, input.Attributes != nil)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := recordPriceChanges(tx, product, updates, models.PriceHistory{Source: models.PriceSourceManual, UserID: &userID}); err != nil {
+			return err
+		}
+		if err := tx.Model(&product).Updates(updates).Error; err != nil {
+			return err
+		}
+		if input.Attributes != nil || categoryChanged {
+			return replaceProductAttributes(tx, product.ID, attributes)
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour"})
 		return
 	}
 
-	db.First(&product, productID)
+	db.Scopes(productDetails).First(&product, productID)
 	c.JSON(http.StatusOK, gin.H{"message": "Produit mis à jour", "product": product})
 }
 
@@ -241,12 +467,39 @@ func DeleteProduct(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Produit non trouvé"})
 		return
 	}
+	if product.Reserved > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Produit réservé par des ventes à tempérament", "reserved": product.Reserved})
+		return
+	}
 
-	if err := db.Delete(&product).Error; err != nil {
+	var productImages []models.ProductImage
+	db.Where("product_id = ?", product.ID).Find(&productImages)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", product.ID).Delete(&models.ProductAttribute{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("product_id = ?", product.ID).Delete(&models.ProductImage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("product_id = ?", product.ID).Delete(&models.PriceSchedule{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("product_id = ?", product.ID).Delete(&models.PriceHistory{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&product).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression"})
 		return
 	}
 
+	// Fichiers supprimés après le commit pour ne rien perdre en cas d'échec
+	for _, image := range productImages {
+		deleteStoredKeys(storage.Get(), image.StorageKeys)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Produit supprimé avec succès"})
 }
 
@@ -272,19 +525,29 @@ func GetPublicProducts(c *gin.Context) {
 
 	// Récupérer les produits
 	var products []models.Product
-	query := db.Where("shop_id = ?", shopID)
+	query := db.Where("shop_id = ? AND archived_at IS NULL", shopID)
 
-	// Filtre par catégorie (optionnel)
+	// Filtre par catégorie: ID, slug ou nom, sous-catégories incluses (optionnel)
 	if category := c.Query("category"); category != "" {
-		query = query.Where("category = ?", category)
+		if query, err = applyCategoryFilter(db, query, shop.ID, category); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des produits"})
+			return
+		}
 	}
 
-	// Filtre produits en stock (optionnel)
+	// Filtre produits en stock disponible, hors unités réservées (optionnel)
 	if inStock := c.Query("in_stock"); inStock == "true" {
-		query = query.Where("stock > 0")
+		query = query.Where("stock - reserved > 0")
+	}
+
+	// Filtres par fiche technique (optionnel)
+	query, err = applyAttributeFilters(query, uint(shopID), c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	if err := query.Find(&products).Error; err != nil {
+	if err := query.Scopes(productDetails).Find(&products).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des produits"})
 		return
 	}
@@ -297,8 +560,9 @@ func GetPublicProducts(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"shop": gin.H{
-			"id":   shop.ID,
-			"name": shop.Name,
+			"id":       shop.ID,
+			"name":     shop.Name,
+			"currency": shop.Currency,
 		},
 		"products": publicProducts,
 		"count":    len(publicProducts),
@@ -333,10 +597,32 @@ func GetPublicProduct(c *gin.Context) {
 
 	// Récupérer le produit
 	var product models.Product
-	if err := db.Where("id = ? AND shop_id = ?", productID, shopID).First(&product).Error; err != nil {
+	if err := db.Where("id = ? AND shop_id = ? AND archived_at IS NULL", productID, shopID).
+		Scopes(productDetails).First(&product).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Produit non trouvé"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"product": product.ToPublic(shop.WhatsAppNumber)})
+	public := product.ToPublic(shop.WhatsAppNumber)
+
+	// Code promo (optionnel): prix remisé et lien WhatsApp le mentionnant
+	if code := c.Query("coupon"); code != "" {
+		now := time.Now()
+		pricing, err := evaluateSale(db, shop.ID, product, 1, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du calcul du prix"})
+			return
+		}
+		coupon, err := checkCoupon(db, shop.ID, code, "", pricing, now)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{"product": public, "currency": shop.Currency, "coupon_error": err.Error()})
+			return
+		}
+		pricing.AddCoupon(coupon.Type, coupon.Value)
+
+		public.Coupon = &models.CouponPrice{Code: coupon.Code, Discount: pricing.Discount, DiscountedPrice: pricing.Total}
+		public.WhatsAppLink = models.GenerateWhatsAppCouponLink(shop.WhatsAppNumber, product.Name, coupon.Code, pricing.Total)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"product": public, "currency": shop.Currency})
 }
\ No newline at end of file
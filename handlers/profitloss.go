@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"electronic-shop-api/database"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// COMPTE DE RÉSULTAT
+// ========================================
+
+// maxProfitAndLossMonths - Nombre maximal de mois d'un compte de résultat
+const maxProfitAndLossMonths = 24
+
+// uncategorizedExpenses - Libellé des dépenses sans catégorie
+const uncategorizedExpenses = "Non catégorisé"
+
+// ProfitAndLoss - Compte de résultat mensuel (réponse de /reports/profit-and-loss)
+type ProfitAndLoss struct {
+	Currency          string       `json:"currency"`
+	Period            reportPeriod `json:"period"`
+	ExpenseCategories []string     `json:"expense_categories"` // Catégories de charges, par montant décroissant
+	Months            []PnLMonth   `json:"months"`
+	Total             PnLMonth     `json:"total"`
+}
+
+// PnLMonth - Compte de résultat d'un mois. Montants hors taxes: la taxe collectée
+// est reversée et la taxe déductible des dépenses récupérée.
+type PnLMonth struct {
+	Month           string                  `json:"month"`              // YYYY-MM (total: vide)
+	Revenue         models.Money            `json:"revenue"`            // Ventes brutes, avant remises
+	Discounts       models.Money            `json:"discounts"`          // Promotions et codes promo
+	Refunds         models.Money            `json:"refunds"`            // Ventes remboursées
+	Fees            models.Money            `json:"fees"`               // Frais d'annulation retenus (ventes à tempérament)
+	NetRevenue      models.Money            `json:"net_revenue"`        // Revenue - Discounts - Refunds + Fees
+	CostOfGoodsSold models.Money            `json:"cost_of_goods_sold"` // Net des articles retournés
+	GrossMargin     models.Money            `json:"gross_margin"`
+	Expenses        map[string]models.Money `json:"expenses"` // Par catégorie
+	TotalExpenses   models.Money            `json:"total_expenses"`
+	OperatingProfit models.Money            `json:"operating_profit"`
+	Withdrawals     models.Money            `json:"withdrawals"` // Prélèvements de l'exploitant: capitaux propres, hors résultat
+}
+
+// pnlRow - Agrégat SQL d'un créneau horaire par type (et catégorie de dépense)
+type pnlRow struct {
+	Slot     string
+	Type     models.TransactionType
+	Category string
+	Amount   models.Money
+	Tax      models.Money
+	Discount models.Money // Remises hors taxes
+	Cost     models.Money
+}
+
+// GetProfitAndLoss - Compte de résultat par mois (period ou from / to, année en cours par défaut)
+func GetProfitAndLoss(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+
+	report, err := computeProfitAndLoss(c, db, shopID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"profit_and_loss": report})
+}
+
+// ExportProfitAndLoss exporte le compte de résultat (format=pdf, xlsx ou csv): une ligne par poste,
+// une colonne par mois. Les postes déduits (remises, remboursements, coûts, charges) sont négatifs.
+func ExportProfitAndLoss(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+
+	report, err := computeProfitAndLoss(c, db, shopID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	columns := []string{"poste"}
+	for _, month := range report.Months {
+		columns = append(columns, month.Month)
+	}
+	columns = append(columns, "total")
+
+	title := fmt.Sprintf("Compte de résultat (%s)", report.Currency)
+	if len(report.Months) > 0 {
+		title += fmt.Sprintf(" - %s à %s", report.Months[0].Month, report.Months[len(report.Months)-1].Month)
+	}
+	w, ok := startReportExport(c, "compte-de-resultat", title, columns)
+	if !ok {
+		return
+	}
+
+	months := append(append([]PnLMonth{}, report.Months...), report.Total)
+	line := func(label string, value func(PnLMonth) models.Money) error {
+		row := []interface{}{label}
+		for _, month := range months {
+			row = append(row, value(month))
+		}
+		return w.WriteRow(row...)
+	}
+
+	lines := []struct {
+		label string
+		value func(PnLMonth) models.Money
+	}{
+		{"Chiffre d'affaires brut HT", func(m PnLMonth) models.Money { return m.Revenue }},
+		{"Remises", func(m PnLMonth) models.Money { return -m.Discounts }},
+		{"Remboursements", func(m PnLMonth) models.Money { return -m.Refunds }},
+		{"Frais d'annulation retenus", func(m PnLMonth) models.Money { return m.Fees }},
+		{"Chiffre d'affaires net HT", func(m PnLMonth) models.Money { return m.NetRevenue }},
+		{"Coût des produits vendus", func(m PnLMonth) models.Money { return -m.CostOfGoodsSold }},
+		{"Marge brute", func(m PnLMonth) models.Money { return m.GrossMargin }},
+	}
+	for _, category := range report.ExpenseCategories {
+		category := category
+		lines = append(lines, struct {
+			label string
+			value func(PnLMonth) models.Money
+		}{"Charges: " + category, func(m PnLMonth) models.Money { return -m.Expenses[category] }})
+	}
+	lines = append(lines, []struct {
+		label string
+		value func(PnLMonth) models.Money
+	}{
+		{"Total des charges HT", func(m PnLMonth) models.Money { return -m.TotalExpenses }},
+		{"Résultat d'exploitation", func(m PnLMonth) models.Money { return m.OperatingProfit }},
+		{"Prélèvements de l'exploitant (capitaux propres)", func(m PnLMonth) models.Money { return m.Withdrawals }},
+	}...)
+
+	for _, l := range lines {
+		if err = line(l.label, l.value); err != nil {
+			break
+		}
+	}
+	finishExport(w, "compte-de-resultat", err)
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// computeProfitAndLoss calcule le compte de résultat de chaque mois de la période
+func computeProfitAndLoss(c *gin.Context, db *gorm.DB, shopID uint, now time.Time) (ProfitAndLoss, error) {
+	period, err := parsePeriod(c, db, shopID, now)
+	if err != nil {
+		return ProfitAndLoss{}, err
+	}
+
+	// Bornes par défaut: l'année en cours, ou les 12 mois précédant to
+	loc := shopLocation(db, shopID)
+	if period.To == nil {
+		local := now.In(loc)
+		to := time.Date(local.Year(), local.Month()+1, 1, 0, 0, 0, 0, loc)
+		if period.From == nil {
+			to = time.Date(local.Year()+1, 1, 1, 0, 0, 0, 0, loc)
+		}
+		period.To = &to
+	}
+	if period.From == nil {
+		from := truncateTime(period.To.AddDate(-1, 0, 0), IntervalMonth, loc)
+		period.From = &from
+	}
+	if period.Name == PeriodAll {
+		period.Name = PeriodYear
+	}
+
+	buckets := timeBuckets(*period.From, *period.To, IntervalMonth, loc)
+	if len(buckets) > maxProfitAndLossMonths {
+		return ProfitAndLoss{}, fmt.Errorf("période trop longue (%d mois maximum)", maxProfitAndLossMonths)
+	}
+
+	// Une ligne par créneau horaire, type et catégorie de dépense; regroupement par mois dans le fuseau du shop
+	shift := slotShift(*period.From, loc)
+	discount := "CAST(CASE WHEN t.subtotal > t.discount " +
+		"THEN ROUND(t.discount * 1.0 * (t.amount - t.tax_amount) / (t.subtotal - t.discount)) " +
+		"ELSE t.discount END AS INTEGER)"
+	var rows []pnlRow
+	query := db.Table("transactions t").Where("t.shop_id = ?", shopID)
+	err = period.apply(query, "t.created_at").
+		Select(slotColumn("t.created_at", shift)+" as slot, t.type, "+
+			"CASE WHEN t.type = ? THEN COALESCE(t.expense_category, '') ELSE '' END as category, "+
+			"COALESCE(SUM(t.amount), 0) as amount, COALESCE(SUM(t.tax_amount), 0) as tax, "+
+			"COALESCE(SUM("+discount+"), 0) as discount, COALESCE(SUM(t.cost), 0) as cost", models.TypeExpense).
+		Group("slot, t.type, category").
+		Scan(&rows).Error
+	if err != nil {
+		return ProfitAndLoss{}, err
+	}
+	var fees []pnlRow
+	err = cancellationFees(db, shopID, period).
+		Select(slotColumn("l.cancelled_at", shift) + " as slot, COALESCE(SUM(l.cancellation_fee), 0) as amount").
+		Group("slot").
+		Scan(&fees).Error
+	if err != nil {
+		return ProfitAndLoss{}, err
+	}
+
+	months := make([]PnLMonth, len(buckets))
+	for i, bucket := range buckets {
+		months[i] = PnLMonth{Month: bucket.Format("2006-01"), Expenses: map[string]models.Money{}}
+	}
+
+	labels := map[string]string{} // Catégories sans tenir compte de la casse
+	for _, row := range rows {
+		index, err := slotBucket(row.Slot, shift, buckets)
+		if err != nil {
+			return ProfitAndLoss{}, err
+		}
+		if index < 0 {
+			continue
+		}
+		month := &months[index]
+		switch row.Type {
+		case models.TypeSale:
+			month.Revenue += row.Amount - row.Tax + row.Discount
+			month.Discounts += row.Discount
+			month.CostOfGoodsSold += row.Cost
+		case models.TypeRefund:
+			month.Refunds += row.Amount - row.Tax
+			month.CostOfGoodsSold -= row.Cost
+		case models.TypeExpense:
+			category := strings.TrimSpace(row.Category)
+			if category == "" {
+				category = uncategorizedExpenses
+			}
+			key := strings.ToLower(category)
+			if _, ok := labels[key]; !ok {
+				labels[key] = category
+			}
+			month.Expenses[labels[key]] += row.Amount - row.Tax
+		case models.TypeWithdrawal:
+			month.Withdrawals += row.Amount
+		}
+	}
+	for _, row := range fees {
+		index, err := slotBucket(row.Slot, shift, buckets)
+		if err != nil {
+			return ProfitAndLoss{}, err
+		}
+		if index >= 0 {
+			months[index].Fees += row.Amount
+		}
+	}
+
+	total := PnLMonth{Expenses: map[string]models.Money{}}
+	for i := range months {
+		month := &months[i]
+		month.NetRevenue = month.Revenue - month.Discounts - month.Refunds + month.Fees
+		month.GrossMargin = month.NetRevenue - month.CostOfGoodsSold
+		for category, amount := range month.Expenses {
+			month.TotalExpenses += amount
+			total.Expenses[category] += amount
+		}
+		month.OperatingProfit = month.GrossMargin - month.TotalExpenses
+
+		total.Revenue += month.Revenue
+		total.Discounts += month.Discounts
+		total.Refunds += month.Refunds
+		total.Fees += month.Fees
+		total.NetRevenue += month.NetRevenue
+		total.CostOfGoodsSold += month.CostOfGoodsSold
+		total.GrossMargin += month.GrossMargin
+		total.TotalExpenses += month.TotalExpenses
+		total.OperatingProfit += month.OperatingProfit
+		total.Withdrawals += month.Withdrawals
+	}
+
+	categories := make([]string, 0, len(total.Expenses))
+	for category := range total.Expenses {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if total.Expenses[categories[i]] != total.Expenses[categories[j]] {
+			return total.Expenses[categories[i]] > total.Expenses[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+
+	return ProfitAndLoss{
+		Currency:          shopCurrency(db, shopID),
+		Period:            period,
+		ExpenseCategories: categories,
+		Months:            months,
+		Total:             total,
+	}, nil
+}
\ No newline at end of file
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"electronic-shop-api/database"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"electronic-shop-api/promotions"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// STRUCTURE DE REQUÊTE
+// ========================================
+
+// PromotionInput - Création et modification (PUT: seuls les champs envoyés sont modifiés, null pour retirer)
+type PromotionInput struct {
+	Name           string       `json:"name" binding:"required"`
+	Type           string       `json:"type" binding:"required,oneof=percent fixed buy_x_get_y bundle"`
+	Value          models.Money `json:"value" binding:"gte=0"`
+	BuyQuantity    int          `json:"buy_quantity"`
+	FreeQuantity   int          `json:"free_quantity"`
+	BundleQuantity int          `json:"bundle_quantity"`
+	ProductID      *uint        `json:"product_id"`
+	CategoryID     *uint        `json:"category_id"`
+	MinBasket      models.Money `json:"min_basket" binding:"gte=0"`
+	StartsAt       string       `json:"starts_at"` // RFC3339, "YYYY-MM-DD HH:MM" ou "YYYY-MM-DD" (fuseau du shop)
+	EndsAt         string       `json:"ends_at"`
+	Stackable      bool         `json:"stackable"`
+	Active         *bool        `json:"active"` // true par défaut
+}
+
+// ========================================
+// GET PROMOTIONS
+// ========================================
+
+func GetPromotions(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	var list []models.Promotion
+	if err := database.GetDB().Where("shop_id = ?", shopID).Order("created_at DESC").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des promotions"})
+		return
+	}
+
+	// Promotions applicables maintenant uniquement (optionnel)
+	if c.Query("current") == "true" {
+		now := time.Now()
+		current := []models.Promotion{}
+		for _, promotion := range list {
+			if promotions.Running(promotion, now) {
+				current = append(current, promotion)
+			}
+		}
+		list = current
+	}
+
+	c.JSON(http.StatusOK, gin.H{"promotions": list, "count": len(list)})
+}
+
+// ========================================
+// CREATE PROMOTION (SuperAdmin)
+// ========================================
+
+func CreatePromotion(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	var input PromotionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	promotion := models.Promotion{ShopID: shopID, Active: true}
+	if err := applyPromotionInput(db, shopID, &promotion, input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Create(&promotion).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création de la promotion"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Promotion créée", "promotion": promotion})
+}
+
+// ========================================
+// UPDATE PROMOTION (SuperAdmin)
+// ========================================
+
+func UpdatePromotion(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	promotion, ok := findShopPromotion(c, shopID)
+	if !ok {
+		return
+	}
+
+	// Partir des valeurs actuelles: le JSON reçu ne remplace que les champs envoyés
+	input := promotionInput(promotion)
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	if err := applyPromotionInput(db, shopID, &promotion, input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Save(&promotion).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Promotion mise à jour", "promotion": promotion})
+}
+
+// ========================================
+// DELETE PROMOTION (SuperAdmin)
+// ========================================
+
+// DeletePromotion supprime une promotion (les ventes passées conservent la remise et son nom)
+func DeletePromotion(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	promotion, ok := findShopPromotion(c, shopID)
+	if !ok {
+		return
+	}
+
+	if err := database.GetDB().Delete(&promotion).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Promotion supprimée"})
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// evaluateSale applique les promotions du shop à la vente de quantity unités du produit
+func evaluateSale(db *gorm.DB, shopID uint, product models.Product, quantity int, now time.Time) (promotions.Result, error) {
+	line := promotions.Line{
+		ProductID: product.ID,
+		UnitPrice: product.SellingPrice,
+		Quantity:  quantity,
+	}
+
+	if product.CategoryID != nil {
+		categories, err := loadShopCategories(db, shopID)
+		if err != nil {
+			return promotions.Result{}, err
+		}
+		line.CategoryIDs = categoryAncestorIDs(categories, *product.CategoryID)
+	}
+
+	var list []models.Promotion
+	if err := db.Where("shop_id = ? AND active = ?", shopID, true).Order("id").Find(&list).Error; err != nil {
+		return promotions.Result{}, err
+	}
+
+	return promotions.Evaluate(list, line, now), nil
+}
+
+// appliedPromotions convertit les remises retenues en lignes de transaction
+func appliedPromotions(result promotions.Result) []models.TransactionPromotion {
+	var applied []models.TransactionPromotion
+	for _, a := range result.Applied {
+		applied = append(applied, models.TransactionPromotion{
+			PromotionID: a.Promotion.ID,
+			Name:        a.Promotion.Name,
+			Type:        a.Promotion.Type,
+			Discount:    a.Discount,
+		})
+	}
+	return applied
+}
+
+// applyPromotionInput valide l'entrée et la reporte sur la promotion
+func applyPromotionInput(db *gorm.DB, shopID uint, promotion *models.Promotion, input PromotionInput) error {
+	switch models.PromotionType(input.Type) {
+	case models.PromotionPercent:
+		if input.Value <= 0 || input.Value.Float64() > 100 {
+			return errors.New("value doit être un pourcentage entre 0 et 100")
+		}
+	case models.PromotionFixed:
+		if input.Value <= 0 {
+			return errors.New("value (montant de la remise) doit être supérieur à 0")
+		}
+	case models.PromotionBuyXGetY:
+		if input.BuyQuantity < 1 || input.FreeQuantity < 1 {
+			return errors.New("buy_quantity et free_quantity doivent être supérieurs à 0")
+		}
+	case models.PromotionBundle:
+		if input.BundleQuantity < 2 || input.Value <= 0 {
+			return errors.New("bundle_quantity (2 minimum) et value (prix du lot) sont requis")
+		}
+	}
+
+	if err := checkDiscountScope(db, shopID, input.ProductID, input.CategoryID); err != nil {
+		return err
+	}
+
+	// Période
+	startsAt, err := parseOptionalDate(db, shopID, input.StartsAt, "starts_at")
+	if err != nil {
+		return err
+	}
+	endsAt, err := parseOptionalDate(db, shopID, input.EndsAt, "ends_at")
+	if err != nil {
+		return err
+	}
+	if startsAt != nil && endsAt != nil && !endsAt.After(*startsAt) {
+		return errors.New("ends_at doit être postérieur à starts_at")
+	}
+
+	promotion.Name = input.Name
+	promotion.Type = models.PromotionType(input.Type)
+	promotion.Value = input.Value
+	promotion.BuyQuantity = input.BuyQuantity
+	promotion.FreeQuantity = input.FreeQuantity
+	promotion.BundleQuantity = input.BundleQuantity
+	promotion.ProductID = input.ProductID
+	promotion.CategoryID = input.CategoryID
+	promotion.MinBasket = input.MinBasket
+	promotion.StartsAt = startsAt
+	promotion.EndsAt = endsAt
+	promotion.Stackable = input.Stackable
+	if input.Active != nil {
+		promotion.Active = *input.Active
+	}
+	return nil
+}
+
+// checkDiscountScope vérifie que le produit et la catégorie ciblés appartiennent au shop (MULTI-TENANT)
+func checkDiscountScope(db *gorm.DB, shopID uint, productID, categoryID *uint) error {
+	if productID != nil {
+		var count int64
+		db.Model(&models.Product{}).Where("id = ? AND shop_id = ?", *productID, shopID).Count(&count)
+		if count == 0 {
+			return errors.New("Produit non trouvé")
+		}
+	}
+	if categoryID != nil {
+		var count int64
+		db.Model(&models.Category{}).Where("id = ? AND shop_id = ?", *categoryID, shopID).Count(&count)
+		if count == 0 {
+			return errors.New("Catégorie non trouvée")
+		}
+	}
+	return nil
+}
+
+// promotionInput reconstruit l'entrée correspondant à une promotion existante
+func promotionInput(promotion models.Promotion) PromotionInput {
+	input := PromotionInput{
+		Name:           promotion.Name,
+		Type:           string(promotion.Type),
+		Value:          promotion.Value,
+		BuyQuantity:    promotion.BuyQuantity,
+		FreeQuantity:   promotion.FreeQuantity,
+		BundleQuantity: promotion.BundleQuantity,
+		ProductID:      promotion.ProductID,
+		CategoryID:     promotion.CategoryID,
+		MinBasket:      promotion.MinBasket,
+		Stackable:      promotion.Stackable,
+		Active:         &promotion.Active,
+	}
+	if promotion.StartsAt != nil {
+		input.StartsAt = promotion.StartsAt.Format(time.RFC3339)
+	}
+	if promotion.EndsAt != nil {
+		input.EndsAt = promotion.EndsAt.Format(time.RFC3339)
+	}
+	return input
+}
+
+// findShopPromotion charge la promotion :id du shop (répond 400/404 sinon)
+func findShopPromotion(c *gin.Context, shopID uint) (models.Promotion, bool) {
+	var promotion models.Promotion
+
+	promotionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de promotion invalide"})
+		return promotion, false
+	}
+
+	// MULTI-TENANT
+	if err := database.GetDB().Where("id = ? AND shop_id = ?", promotionID, shopID).First(&promotion).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Promotion non trouvée"})
+		return promotion, false
+	}
+	return promotion, true
+}
+
+// parseOptionalDate lit une date facultative (vide = nil)
+func parseOptionalDate(db *gorm.DB, shopID uint, value, field string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := parseDate(db, shopID, value)
+	if err != nil {
+		return nil, errors.New(field + " invalide (RFC3339, YYYY-MM-DD HH:MM ou YYYY-MM-DD)")
+	}
+	return &t, nil
+}
\ No newline at end of file
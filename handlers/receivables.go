@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"electronic-shop-api/database"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// STRUCTURES DE REQUÊTE / RÉPONSE
+// ========================================
+
+// CustomerPaymentInput - Règlement d'un client sur ses ventes à crédit (sale_id: une vente
+// précise, sinon les plus anciennes d'abord)
+type CustomerPaymentInput struct {
+	SaleID   *uint          `json:"sale_id"`
+	Payments []PaymentInput `json:"payments" binding:"required,min=1,dive"`
+
+	// Session de caisse qui reçoit le règlement (défaut: celle de l'utilisateur)
+	RegisterSessionID *uint `json:"register_session_id"`
+}
+
+// OpenInvoice - Vente à crédit restant due
+type OpenInvoice struct {
+	SaleID    uint         `json:"sale_id"`
+	CreatedAt time.Time    `json:"created_at"`
+	Amount    models.Money `json:"amount"`
+	AmountDue models.Money `json:"amount_due"`
+	AgeDays   int          `json:"age_days"`
+}
+
+// AgingBuckets - Reste dû par ancienneté (jours depuis la vente, fuseau du shop)
+type AgingBuckets struct {
+	Days0To30  models.Money `json:"days_0_30"`
+	Days31To60 models.Money `json:"days_31_60"`
+	Days61To90 models.Money `json:"days_61_90"`
+	Over90     models.Money `json:"days_over_90"`
+	Total      models.Money `json:"total"`
+}
+
+// AgingLine - Balance âgée d'un client
+type AgingLine struct {
+	CustomerID  uint         `json:"customer_id"`
+	Name        string       `json:"name"`
+	Phone       string       `json:"phone,omitempty"`
+	CreditLimit models.Money `json:"credit_limit"`
+	Invoices    int          `json:"invoices"`
+	OldestDays  int          `json:"oldest_days"`
+	AgingBuckets
+}
+
+// add impute un reste dû à sa tranche d'ancienneté
+func (b *AgingBuckets) add(amount models.Money, ageDays int) {
+	switch {
+	case ageDays <= 30:
+		b.Days0To30 += amount
+	case ageDays <= 60:
+		b.Days31To60 += amount
+	case ageDays <= 90:
+		b.Days61To90 += amount
+	default:
+		b.Over90 += amount
+	}
+	b.Total += amount
+}
+
+// ========================================
+// CUSTOMER RECEIVABLES
+// ========================================
+
+// GetCustomerReceivables - Relevé du client: ventes à crédit non réglées, encours et crédit disponible
+func GetCustomerReceivables(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	customer, ok := findShopCustomer(c, shopID)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	sales, err := openSales(db, shopID, customer.ID, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des ventes à crédit"})
+		return
+	}
+
+	now := time.Now().In(shopLocation(db, shopID))
+	invoices := make([]OpenInvoice, len(sales))
+	var aging AgingBuckets
+	for i, sale := range sales {
+		age := ageDays(sale.CreatedAt, now)
+		invoices[i] = OpenInvoice{SaleID: sale.ID, CreatedAt: sale.CreatedAt, Amount: sale.Amount, AmountDue: sale.AmountDue, AgeDays: age}
+		aging.add(sale.AmountDue, age)
+	}
+
+	available := customer.CreditLimit - aging.Total
+	if available < 0 {
+		available = 0
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"customer":         customer,
+		"currency":         shopCurrency(db, shopID),
+		"invoices":         invoices,
+		"aging":            aging,
+		"balance":          aging.Total,
+		"credit_limit":     customer.CreditLimit,
+		"available_credit": available,
+	})
+}
+
+// RecordCustomerPayment enregistre un règlement du client sur ses ventes à crédit: chaque
+// règlement est réparti sur les ventes les plus anciennes (ou sur sale_id) et encaissé
+// par la session de caisse ouverte.
+func RecordCustomerPayment(c *gin.Context) {
+	userID, shopID, _ := middleware.GetUserFromContext(c)
+
+	customer, ok := findShopCustomer(c, shopID)
+	if !ok {
+		return
+	}
+
+	var input CustomerPaymentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	sessionID, err := registerSessionFor(db, shopID, userID, input.RegisterSessionID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sales, err := openSales(db, shopID, customer.ID, input.SaleID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des ventes à crédit"})
+		return
+	}
+	if len(sales) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Aucune vente à crédit à régler pour ce client"})
+		return
+	}
+
+	var due, paid models.Money
+	for _, sale := range sales {
+		due += sale.AmountDue
+	}
+	methods := make([]models.PaymentMethod, len(input.Payments))
+	for i, payment := range input.Payments {
+		method, err := resolvePaymentMethod(db, shopID, payment)
+		if err == nil {
+			err = saleOnlyKindError(method.Kind)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		methods[i] = method
+		paid += payment.Amount
+	}
+	if paid > due {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le règlement dépasse le reste dû", "amount_due": due})
+		return
+	}
+
+	var payments []models.Payment
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Reste dû relu dans la transaction: un autre règlement a pu être enregistré entre-temps
+		sales, err := openSales(tx, shopID, customer.ID, input.SaleID)
+		if err != nil {
+			return err
+		}
+		var allocated map[uint]models.Money
+		if payments, allocated, err = allocateCustomerPayment(sales, input.Payments, methods, shopID, sessionID); err != nil {
+			return err
+		}
+
+		if err := tx.Create(&payments).Error; err != nil {
+			return err
+		}
+		for saleID, amount := range allocated {
+			result := tx.Model(&models.Transaction{}).Where("id = ? AND shop_id = ? AND amount_due >= ?", saleID, shopID, amount).
+				Update("amount_due", gorm.Expr("amount_due - ?", amount))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errReceivableChanged
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errReceivableChanged) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "balance": customerBalance(db, shopID, customer.ID)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement du règlement"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Règlement enregistré",
+		"payments": payments,
+		"paid":     paid,
+		"balance":  customerBalance(db, shopID, customer.ID),
+	})
+}
+
+// ========================================
+// RECEIVABLES AGING REPORT (SuperAdmin)
+// ========================================
+
+// GetReceivablesReport - Balance âgée des créances clients (0-30, 31-60, 61-90, +90 jours)
+func GetReceivablesReport(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+
+	lines, totals, err := computeAging(db, shopID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du calcul des créances"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"currency":  shopCurrency(db, shopID),
+		"as_of":     time.Now().In(shopLocation(db, shopID)).Format("2006-01-02"),
+		"customers": lines,
+		"totals":    totals,
+	})
+}
+
+// ExportReceivablesReport exporte la balance âgée (format=csv, xlsx ou pdf)
+func ExportReceivablesReport(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+
+	lines, totals, err := computeAging(db, shopID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du calcul des créances"})
+		return
+	}
+
+	columns := []string{"customer_id", "name", "phone", "credit_limit", "invoices", "oldest_days", "days_0_30", "days_31_60", "days_61_90", "days_over_90", "total"}
+	w, ok := startReportExport(c, "creances", "Balance âgée des créances clients ("+shopCurrency(db, shopID)+")", columns)
+	if !ok {
+		return
+	}
+	for _, line := range lines {
+		if err = w.WriteRow(line.CustomerID, line.Name, line.Phone, line.CreditLimit, line.Invoices, line.OldestDays,
+			line.Days0To30, line.Days31To60, line.Days61To90, line.Over90, line.Total); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.WriteRow("", "Total", "", "", "", "", totals.Days0To30, totals.Days31To60, totals.Days61To90, totals.Over90, totals.Total)
+	}
+	finishExport(w, "creances", err)
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// customerBalance - Reste dû des ventes à crédit du client
+func customerBalance(db *gorm.DB, shopID, customerID uint) models.Money {
+	var balance models.Money
+	db.Model(&models.Transaction{}).Select("COALESCE(SUM(amount_due), 0)").
+		Where("shop_id = ? AND customer_id = ? AND type = ? AND amount_due > 0", shopID, customerID, models.TypeSale).
+		Scan(&balance)
+	return balance
+}
+
+// errReceivableChanged - Reste dû réglé entre la lecture et l'enregistrement du règlement
+var errReceivableChanged = errors.New("reste dû modifié entre-temps par un autre règlement: réessayer")
+
+// allocateCustomerPayment répartit les règlements sur les ventes, les plus anciennes d'abord
+func allocateCustomerPayment(sales []models.Transaction, inputs []PaymentInput, methods []models.PaymentMethod, shopID uint, sessionID *uint) ([]models.Payment, map[uint]models.Money, error) {
+	var payments []models.Payment
+	allocated := map[uint]models.Money{}
+	next := 0
+	for i, input := range inputs {
+		left := input.Amount
+		for left > 0 {
+			if next == len(sales) {
+				return nil, nil, errReceivableChanged
+			}
+			sale := &sales[next]
+			part := left
+			if part > sale.AmountDue {
+				part = sale.AmountDue
+			}
+			payments = append(payments, models.Payment{
+				TransactionID:   sale.ID,
+				PaymentMethodID: methods[i].ID,
+				Method:          methods[i].Kind,
+				MethodName:      methods[i].Name,
+				Amount:          part,
+				Reference:       input.Reference,
+				ShopID:          shopID,
+
+				RegisterSessionID: sessionID,
+			})
+			allocated[sale.ID] += part
+			sale.AmountDue -= part
+			left -= part
+			if sale.AmountDue == 0 {
+				next++
+			}
+		}
+	}
+	return payments, allocated, nil
+}
+
+// openSales charge les ventes à crédit non réglées du client (ou la vente saleID), les plus anciennes d'abord
+func openSales(db *gorm.DB, shopID, customerID uint, saleID *uint) ([]models.Transaction, error) {
+	query := db.Where("shop_id = ? AND customer_id = ? AND type = ? AND amount_due > 0", shopID, customerID, models.TypeSale)
+	if saleID != nil {
+		query = query.Where("id = ?", *saleID)
+	}
+	var sales []models.Transaction
+	err := query.Order("created_at ASC, id ASC").Find(&sales).Error
+	return sales, err
+}
+
+// ageDays - Jours calendaires écoulés depuis date (fuseau de now)
+func ageDays(date, now time.Time) int {
+	date = date.In(now.Location())
+	from := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from).Hours() / 24)
+}
+
+// computeAging calcule la balance âgée par client (reste dû décroissant) et le total
+func computeAging(db *gorm.DB, shopID uint) ([]AgingLine, AgingBuckets, error) {
+	var totals AgingBuckets
+	var sales []models.Transaction
+	if err := db.Select("id, customer_id, amount_due, created_at").
+		Where("shop_id = ? AND type = ? AND amount_due > 0 AND customer_id IS NOT NULL", shopID, models.TypeSale).
+		Find(&sales).Error; err != nil {
+		return nil, totals, err
+	}
+
+	now := time.Now().In(shopLocation(db, shopID))
+	lines := map[uint]*AgingLine{}
+	ids := []uint{}
+	for _, sale := range sales {
+		line := lines[*sale.CustomerID]
+		if line == nil {
+			line = &AgingLine{CustomerID: *sale.CustomerID}
+			lines[*sale.CustomerID] = line
+			ids = append(ids, *sale.CustomerID)
+		}
+		age := ageDays(sale.CreatedAt, now)
+		line.add(sale.AmountDue, age)
+		totals.add(sale.AmountDue, age)
+		line.Invoices++
+		if age > line.OldestDays {
+			line.OldestDays = age
+		}
+	}
+
+	var customers []models.Customer
+	if len(ids) > 0 {
+		if err := db.Where("shop_id = ? AND id IN ?", shopID, ids).Find(&customers).Error; err != nil {
+			return nil, totals, err
+		}
+	}
+	for _, customer := range customers {
+		line := lines[customer.ID]
+		line.Name, line.Phone, line.CreditLimit = customer.Name, customer.Phone, customer.CreditLimit
+	}
+
+	result := make([]AgingLine, 0, len(lines))
+	for _, line := range lines {
+		result = append(result, *line)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Total != result[j].Total {
+			return result[i].Total > result[j].Total
+		}
+		return result[i].CustomerID < result[j].CustomerID
+	})
+	return result, totals, nil
+}
\ No newline at end of file
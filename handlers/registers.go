@@ -0,0 +1,590 @@
+package handlers
+
+import (
+	"electronic-shop-api/database"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// STRUCTURES DE REQUÊTE / RÉPONSE
+// ========================================
+
+type OpenRegisterSessionInput struct {
+	Register     string       `json:"register"` // Nom de la caisse (défaut: Caisse principale)
+	OpeningFloat models.Money `json:"opening_float" binding:"gte=0"`
+	Note         string       `json:"note"`
+}
+
+type CloseRegisterSessionInput struct {
+	CountedCash *models.Money `json:"counted_cash" binding:"required,gte=0"`
+	Note        string        `json:"note"`
+}
+
+// ZReport - Rapport Z d'une session de caisse ou d'une journée (toutes les sessions ouvertes ce jour)
+type ZReport struct {
+	Currency         string                   `json:"currency"`
+	Date             string                   `json:"date,omitempty"`     // Rapport du jour: YYYY-MM-DD (fuseau du shop)
+	Session          *models.RegisterSession  `json:"session,omitempty"`  // Rapport d'une session
+	Sessions         []models.RegisterSession `json:"sessions,omitempty"` // Rapport du jour
+	OpenSessions     int                      `json:"open_sessions"`      // Sessions pas encore clôturées
+	SalesCount       int64                    `json:"sales_count"`
+	UnitsSold        int64                    `json:"units_sold"`
+	GrossSales       models.Money             `json:"gross_sales"` // Avant remises
+	Discounts        models.Money             `json:"discounts"`
+	NetSales         models.Money             `json:"net_sales"` // Facturé (TTC), ventes à crédit comprises
+	RefundsCount     int64                    `json:"refunds_count"`
+	Refunds          models.Money             `json:"refunds"`
+	TaxCollected     models.Money             `json:"tax_collected"` // Net des remboursements
+	Taxes            []TaxLine                `json:"taxes"`         // Par taux, net des remboursements
+	Payments         []TakingsLine            `json:"payments"`      // Encaissements par moyen de paiement (règlements de crédits compris)
+	ExpensesCount    int64                    `json:"expenses_count"`
+	Expenses         models.Money             `json:"expenses"`
+	WithdrawalsCount int64                    `json:"withdrawals_count"`
+	Withdrawals      models.Money             `json:"withdrawals"`
+	OpeningFloat     models.Money             `json:"opening_float"`
+	ExpectedCash     models.Money             `json:"expected_cash"`
+	CountedCash      *models.Money            `json:"counted_cash"` // Sessions clôturées uniquement
+	Variance         models.Money             `json:"variance"`     // Sessions clôturées uniquement
+}
+
+// zRow - Agrégat des transactions des sessions par type
+type zRow struct {
+	Type      models.TransactionType
+	Count     int64
+	Quantity  int64
+	Subtotal  models.Money
+	Discount  models.Money
+	Amount    models.Money
+	TaxAmount models.Money
+}
+
+// ========================================
+// GET REGISTER SESSIONS
+// ========================================
+
+// GetRegisterSessions liste les sessions (filtres status, register, from / to sur l'ouverture)
+func GetRegisterSessions(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+
+	// MULTI-TENANT
+	query := db.Where("shop_id = ?", shopID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if register := c.Query("register"); register != "" {
+		query = query.Where("register = ?", register)
+	}
+	query, err := applyPeriod(c, db, shopID, query, "opened_at")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var sessions []models.RegisterSession
+	if err := query.Order("opened_at DESC").Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des sessions de caisse"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"register_sessions": sessions, "count": len(sessions)})
+}
+
+// GetCurrentRegisterSession retourne la session ouverte par l'utilisateur connecté
+func GetCurrentRegisterSession(c *gin.Context) {
+	userID, shopID, _ := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+
+	var session models.RegisterSession
+	if err := db.Where("shop_id = ? AND opened_by_id = ? AND status = ?", shopID, userID, models.SessionOpen).
+		First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Aucune session de caisse ouverte"})
+		return
+	}
+
+	report, err := computeZReport(db, shopID, []models.RegisterSession{session})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du calcul de la session"})
+		return
+	}
+	session.ExpectedCash = report.ExpectedCash
+
+	c.JSON(http.StatusOK, gin.H{"register_session": session, "summary": report})
+}
+
+// ========================================
+// OPEN / CLOSE REGISTER SESSION
+// ========================================
+
+// OpenRegisterSession ouvre une caisse avec le fond de caisse compté.
+// Une caisse n'a qu'une session ouverte, un utilisateur aussi.
+func OpenRegisterSession(c *gin.Context) {
+	userID, shopID, _ := middleware.GetUserFromContext(c)
+
+	var input OpenRegisterSessionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	register := strings.TrimSpace(input.Register)
+	if register == "" {
+		register = models.DefaultRegister
+	}
+
+	db := database.GetDB()
+	var open models.RegisterSession
+	if err := db.Where("shop_id = ? AND status = ? AND (register = ? OR opened_by_id = ?)", shopID, models.SessionOpen, register, userID).
+		First(&open).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Une session est déjà ouverte sur cette caisse ou par cet utilisateur", "register_session": open})
+		return
+	}
+
+	session := models.RegisterSession{
+		Register:     register,
+		Status:       models.SessionOpen,
+		OpeningFloat: input.OpeningFloat,
+		ExpectedCash: input.OpeningFloat,
+		OpeningNote:  strings.TrimSpace(input.Note),
+		OpenedByID:   userID,
+		OpenedAt:     time.Now(),
+		ShopID:       shopID,
+	}
+	if err := db.Create(&session).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'ouverture de la caisse"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Caisse ouverte", "register_session": session})
+}
+
+// CloseRegisterSession clôture la session: espèces attendues figées, écart avec le comptage
+func CloseRegisterSession(c *gin.Context) {
+	userID, shopID, _ := middleware.GetUserFromContext(c)
+
+	session, ok := findRegisterSession(c, shopID)
+	if !ok {
+		return
+	}
+	if session.Status != models.SessionOpen {
+		c.JSON(http.StatusConflict, gin.H{"error": "Session déjà clôturée", "register_session": session})
+		return
+	}
+
+	var input CloseRegisterSessionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	now := time.Now()
+
+	// Statut changé en premier: plus aucune transaction rattachée après la clôture
+	result := db.Model(&models.RegisterSession{}).Where("id = ? AND status = ?", session.ID, models.SessionOpen).
+		Updates(map[string]interface{}{"status": models.SessionClosed, "closed_by_id": userID, "closed_at": now})
+	if result.Error != nil || result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Session déjà clôturée"})
+		return
+	}
+
+	report, err := computeZReport(db, shopID, []models.RegisterSession{session})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du calcul des espèces attendues"})
+		return
+	}
+
+	session.Status, session.ClosedByID, session.ClosedAt = models.SessionClosed, &userID, &now
+	session.ExpectedCash = report.ExpectedCash
+	session.CountedCash = input.CountedCash
+	session.Variance = *input.CountedCash - report.ExpectedCash
+	session.ClosingNote = strings.TrimSpace(input.Note)
+	if err := db.Save(&session).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la clôture de la caisse"})
+		return
+	}
+
+	report, _ = computeZReport(db, shopID, []models.RegisterSession{session})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Caisse clôturée", "register_session": session, "z_report": report})
+}
+
+// ========================================
+// Z-REPORTS
+// ========================================
+
+// GetRegisterSessionZReport - Rapport Z d'une session
+func GetRegisterSessionZReport(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	report, ok := sessionZReport(c, shopID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"z_report": report})
+}
+
+// ExportRegisterSessionZReport - Rapport Z d'une session à imprimer (format=pdf, xlsx ou csv)
+func ExportRegisterSessionZReport(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	report, ok := sessionZReport(c, shopID)
+	if !ok {
+		return
+	}
+
+	title := fmt.Sprintf("Rapport Z - %s - session %d", report.Session.Register, report.Session.ID)
+	writeZReport(c, shopID, fmt.Sprintf("rapport-z-session-%d", report.Session.ID), title, report)
+}
+
+// GetDailyZReport - Rapport Z du jour (date, défaut aujourd'hui; register pour une seule caisse)
+func GetDailyZReport(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	report, ok := dailyZReport(c, shopID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"z_report": report})
+}
+
+// ExportDailyZReport - Rapport Z du jour à imprimer (format=pdf, xlsx ou csv)
+func ExportDailyZReport(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	report, ok := dailyZReport(c, shopID)
+	if !ok {
+		return
+	}
+
+	writeZReport(c, shopID, "rapport-z-"+report.Date, "Rapport Z du "+report.Date, report)
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// errNoOpenSession - Vente ou remboursement sans session de caisse ouverte
+var errNoOpenSession = errors.New("aucune session de caisse ouverte")
+
+// registerSessionFor détermine la session de caisse d'une nouvelle transaction: celle demandée
+// (ouverte), sinon celle de l'utilisateur, sinon la seule session ouverte du shop.
+// Sans session ouverte la transaction n'est rattachée à aucune caisse (nil).
+func registerSessionFor(db *gorm.DB, shopID, userID uint, requested *uint) (*uint, error) {
+	if requested != nil && *requested != 0 {
+		var session models.RegisterSession
+		if err := db.Where("id = ? AND shop_id = ?", *requested, shopID).First(&session).Error; err != nil {
+			return nil, errors.New("session de caisse non trouvée")
+		}
+		if session.Status != models.SessionOpen {
+			return nil, errors.New("session de caisse clôturée")
+		}
+		return &session.ID, nil
+	}
+
+	var open []models.RegisterSession
+	if err := db.Where("shop_id = ? AND status = ?", shopID, models.SessionOpen).Find(&open).Error; err != nil {
+		return nil, err
+	}
+	for _, session := range open {
+		if session.OpenedByID == userID {
+			return &session.ID, nil
+		}
+	}
+	switch len(open) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &open[0].ID, nil
+	}
+	return nil, errors.New("plusieurs caisses ouvertes: préciser register_session_id")
+}
+
+// checkSessionOpen refuse de modifier une transaction d'une session clôturée, ou dont un
+// règlement (crédit réglé plus tard) a été reçu dans une session clôturée (rapport Z figé)
+func checkSessionOpen(db *gorm.DB, transaction models.Transaction) error {
+	if transaction.RegisterSessionID != nil {
+		var session models.RegisterSession
+		if err := db.Select("status").First(&session, *transaction.RegisterSessionID).Error; err == nil && session.Status == models.SessionClosed {
+			return errors.New("transaction d'une session de caisse clôturée")
+		}
+	}
+
+	var closed int64
+	db.Model(&models.Payment{}).
+		Joins("JOIN register_sessions s ON s.id = payments.register_session_id").
+		Where("payments.transaction_id = ? AND s.status = ?", transaction.ID, models.SessionClosed).
+		Count(&closed)
+	if closed > 0 {
+		return errors.New("règlement reçu dans une session de caisse clôturée")
+	}
+	return nil
+}
+
+// computeZReport totalise les transactions des sessions. Espèces attendues: fond de caisse
+// + ventes réglées en espèces - remboursements, dépenses et retraits réglés en espèces.
+func computeZReport(db *gorm.DB, shopID uint, sessions []models.RegisterSession) (ZReport, error) {
+	report := ZReport{Currency: shopCurrency(db, shopID), Taxes: []TaxLine{}, Payments: []TakingsLine{}}
+
+	ids := make([]uint, len(sessions))
+	var counted models.Money
+	closed := 0
+	for i, session := range sessions {
+		ids[i] = session.ID
+		report.OpeningFloat += session.OpeningFloat
+		if session.Status == models.SessionOpen {
+			report.OpenSessions++
+			continue
+		}
+		if session.CountedCash != nil {
+			counted += *session.CountedCash
+			report.Variance += session.Variance
+			closed++
+		}
+	}
+	if closed > 0 {
+		report.CountedCash = &counted
+	}
+	if len(ids) == 0 {
+		return report, nil
+	}
+
+	var rows []zRow
+	if err := db.Model(&models.Transaction{}).
+		Select("type, COUNT(*) as count, COALESCE(SUM(quantity), 0) as quantity, COALESCE(SUM(subtotal), 0) as subtotal, "+
+			"COALESCE(SUM(discount), 0) as discount, COALESCE(SUM(amount), 0) as amount, COALESCE(SUM(tax_amount), 0) as tax_amount").
+		Where("shop_id = ? AND register_session_id IN ?", shopID, ids).
+		Group("type").
+		Scan(&rows).Error; err != nil {
+		return report, err
+	}
+
+	var refundedTax models.Money
+	for _, row := range rows {
+		switch row.Type {
+		case models.TypeSale:
+			report.SalesCount, report.UnitsSold = row.Count, row.Quantity
+			report.GrossSales, report.Discounts, report.NetSales = row.Subtotal, row.Discount, row.Amount
+			report.TaxCollected += row.TaxAmount
+		case models.TypeRefund:
+			report.RefundsCount, report.Refunds = row.Count, row.Amount
+			refundedTax = row.TaxAmount
+		case models.TypeExpense:
+			report.ExpensesCount, report.Expenses = row.Count, row.Amount
+		case models.TypeWithdrawal:
+			report.WithdrawalsCount, report.Withdrawals = row.Count, row.Amount
+		}
+	}
+	report.TaxCollected -= refundedTax
+
+	// Règlements reçus dans les sessions (un crédit est encaissé par la caisse qui reçoit le règlement)
+	var payments []takingsRow
+	if err := shopPayments(db, shopID).
+		Select("p.payment_method_id, MAX(p.method) as method, MAX(p.method_name) as name, "+paymentType+" as type, "+
+			"COUNT(*) as count, COALESCE(SUM(ABS(p.amount)), 0) as amount").
+		Where("p.register_session_id IN ?", ids).
+		Group("p.payment_method_id, " + paymentType).
+		Order("p.payment_method_id").
+		Scan(&payments).Error; err != nil {
+		return report, err
+	}
+
+	// Espèces attendues: seuls les règlements en espèces passent par le tiroir
+	report.ExpectedCash = report.OpeningFloat
+	lines := map[uint]int{} // Index dans report.Payments
+	for _, row := range payments {
+		if row.Method == models.PaymentCash {
+			switch row.Type {
+			case models.TypeSale:
+				report.ExpectedCash += row.Amount
+			default:
+				report.ExpectedCash -= row.Amount
+			}
+		}
+		if row.Type != models.TypeSale && row.Type != models.TypeRefund {
+			continue
+		}
+		index, ok := lines[row.PaymentMethodID]
+		if !ok {
+			report.Payments = append(report.Payments, TakingsLine{PaymentMethodID: row.PaymentMethodID, Method: row.Method, Name: row.Name, NonCash: row.Method.NonCash()})
+			index = len(report.Payments) - 1
+			lines[row.PaymentMethodID] = index
+		}
+		line := &report.Payments[index]
+		if row.Type == models.TypeRefund {
+			line.Refunded += row.Amount
+		} else {
+			line.Count += row.Count
+			line.Received += row.Amount
+		}
+		line.Net = line.Received - line.Refunded
+	}
+
+	// Taxe par taux: ventes moins remboursements
+	if err := db.Model(&models.Transaction{}).
+		Select("tax_rate, COUNT(*) as count, "+
+			"COALESCE(SUM(CASE WHEN type = ? THEN amount - tax_amount ELSE tax_amount - amount END), 0) as base, "+
+			"COALESCE(SUM(CASE WHEN type = ? THEN tax_amount ELSE -tax_amount END), 0) as tax", models.TypeSale, models.TypeSale).
+		Where("shop_id = ? AND register_session_id IN ? AND type IN ?", shopID, ids, []models.TransactionType{models.TypeSale, models.TypeRefund}).
+		Group("tax_rate").
+		Order("tax_rate DESC").
+		Scan(&report.Taxes).Error; err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// sessionZReport calcule le rapport Z de la session :id
+func sessionZReport(c *gin.Context, shopID uint) (ZReport, bool) {
+	session, ok := findRegisterSession(c, shopID)
+	if !ok {
+		return ZReport{}, false
+	}
+
+	report, err := computeZReport(database.GetDB(), shopID, []models.RegisterSession{session})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du calcul du rapport Z"})
+		return ZReport{}, false
+	}
+	if session.Status == models.SessionOpen {
+		session.ExpectedCash = report.ExpectedCash
+	}
+	report.Session = &session
+	return report, true
+}
+
+// dailyZReport calcule le rapport Z des sessions ouvertes le jour date (fuseau du shop)
+func dailyZReport(c *gin.Context, shopID uint) (ZReport, bool) {
+	db := database.GetDB()
+	loc := shopLocation(db, shopID)
+
+	now := time.Now().In(loc)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if value := c.Query("date"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date invalide (YYYY-MM-DD)"})
+			return ZReport{}, false
+		}
+		day = parsed
+	}
+
+	// MULTI-TENANT
+	next := day.AddDate(0, 0, 1)
+	query := reportPeriod{From: &day, To: &next}.apply(db.Where("shop_id = ?", shopID), "opened_at")
+	if register := c.Query("register"); register != "" {
+		query = query.Where("register = ?", register)
+	}
+	var sessions []models.RegisterSession
+	if err := query.Order("opened_at ASC").Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des sessions de caisse"})
+		return ZReport{}, false
+	}
+
+	report, err := computeZReport(db, shopID, sessions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du calcul du rapport Z"})
+		return ZReport{}, false
+	}
+	report.Date = day.Format("2006-01-02")
+	report.Sessions = sessions
+	if report.Sessions == nil {
+		report.Sessions = []models.RegisterSession{}
+	}
+	return report, true
+}
+
+// writeZReport exporte le rapport Z: une ligne par poste, puis le détail des taxes et des sessions
+func writeZReport(c *gin.Context, shopID uint, name, title string, report ZReport) {
+	w, ok := startReportExport(c, name, title+" ("+report.Currency+")", []string{"poste", "valeur"})
+	if !ok {
+		return
+	}
+
+	var counted interface{} = ""
+	if report.CountedCash != nil {
+		counted = *report.CountedCash
+	}
+	rows := [][2]interface{}{
+		{"Ventes", report.SalesCount},
+		{"Articles vendus", report.UnitsSold},
+		{"Ventes brutes", report.GrossSales},
+		{"Remises", report.Discounts},
+		{"Ventes facturées", report.NetSales},
+		{"Remboursements", report.RefundsCount},
+		{"Montant remboursé", report.Refunds},
+		{"Taxe collectée", report.TaxCollected},
+		{"Dépenses", report.ExpensesCount},
+		{"Montant des dépenses", report.Expenses},
+		{"Retraits", report.WithdrawalsCount},
+		{"Montant des retraits", report.Withdrawals},
+		{"Fond de caisse", report.OpeningFloat},
+		{"Espèces attendues", report.ExpectedCash},
+		{"Espèces comptées", counted},
+		{"Écart", report.Variance},
+	}
+	for _, payment := range report.Payments {
+		rows = append(rows, [2]interface{}{"Encaissé " + payment.Name, payment.Net})
+	}
+	for _, tax := range report.Taxes {
+		rows = append(rows,
+			[2]interface{}{fmt.Sprintf("Base HT %s%%", strconv.FormatFloat(tax.TaxRate, 'f', -1, 64)), tax.Base},
+			[2]interface{}{fmt.Sprintf("Taxe %s%%", strconv.FormatFloat(tax.TaxRate, 'f', -1, 64)), tax.Tax},
+		)
+	}
+
+	sessions := report.Sessions
+	if report.Session != nil {
+		sessions = []models.RegisterSession{*report.Session}
+	}
+	loc := shopLocation(database.GetDB(), shopID)
+	for _, session := range sessions {
+		state := "ouverte"
+		if session.ClosedAt != nil {
+			state = "clôturée à " + session.ClosedAt.In(loc).Format("15:04")
+		}
+		rows = append(rows, [2]interface{}{
+			fmt.Sprintf("Session %d - %s", session.ID, session.Register),
+			fmt.Sprintf("ouverte à %s, %s", session.OpenedAt.In(loc).Format("15:04"), state),
+		})
+	}
+
+	var err error
+	for _, row := range rows {
+		if err = w.WriteRow(row[0], row[1]); err != nil {
+			break
+		}
+	}
+	finishExport(w, name, err)
+}
+
+// findRegisterSession charge la session :id du shop ou répond 400/404
+func findRegisterSession(c *gin.Context, shopID uint) (models.RegisterSession, bool) {
+	var session models.RegisterSession
+
+	sessionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de session invalide"})
+		return session, false
+	}
+
+	// MULTI-TENANT
+	if err := database.GetDB().Where("id = ? AND shop_id = ?", sessionID, shopID).First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session de caisse non trouvée"})
+		return session, false
+	}
+	return session, true
+}
\ No newline at end of file
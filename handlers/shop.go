@@ -6,6 +6,8 @@ import (
 	"electronic-shop-api/models"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
@@ -36,6 +38,13 @@ type UpdateShopInput struct {
 	Name           string `json:"name"`
 	WhatsAppNumber string `json:"whatsapp_number"`
 	Active         *bool  `json:"active"`
+
+	PricesIncludeTax *bool   `json:"prices_include_tax"`
+	Currency         string  `json:"currency" binding:"omitempty,len=3,alpha"` // Code ISO 4217 (MAD, EUR, USD...)
+	Timezone         *string `json:"timezone"`                                 // Fuseau IANA (Africa/Casablanca...), "" pour le fuseau du serveur
+
+	LayawayCancellationFee *float64 `json:"layaway_cancellation_fee" binding:"omitempty,gte=0,lte=100"`  // % du montant retenu à l'annulation
+	GiftCardValidityMonths *int     `json:"gift_card_validity_months" binding:"omitempty,gte=0,lte=120"` // 0: sans expiration
 }
 
 func UpdateShop(c *gin.Context) {
@@ -65,6 +74,38 @@ func UpdateShop(c *gin.Context) {
 	if input.Active != nil {
 		updates["active"] = *input.Active
 	}
+	if input.PricesIncludeTax != nil {
+		updates["prices_include_tax"] = *input.PricesIncludeTax
+	}
+	if input.LayawayCancellationFee != nil {
+		updates["layaway_cancellation_fee"] = *input.LayawayCancellationFee
+	}
+	if input.GiftCardValidityMonths != nil {
+		updates["gift_card_validity_months"] = *input.GiftCardValidityMonths
+	}
+	if input.Timezone != nil {
+		if _, err := time.LoadLocation(*input.Timezone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Fuseau horaire invalide (ex: Africa/Casablanca)"})
+			return
+		}
+		updates["timezone"] = *input.Timezone
+	}
+	if input.Currency != "" && !strings.EqualFold(input.Currency, shop.Currency) {
+		// Les montants enregistrés sont exprimés dans la devise actuelle
+		var transactions, rates int64
+		db.Model(&models.Transaction{}).Where("shop_id = ?", shopID).Count(&transactions)
+		db.Model(&models.ExchangeRate{}).Where("shop_id = ?", shopID).Count(&rates)
+		if transactions > 0 || rates > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "La devise ne peut plus être modifiée après l'enregistrement de transactions ou de taux de change"})
+			return
+		}
+		currency, err := models.NormalizeCurrency(input.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updates["currency"] = currency
+	}
 
 	if err := db.Model(&shop).Updates(updates).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour"})
@@ -285,8 +326,9 @@ func GetPublicShops(c *gin.Context) {
 	var publicShops []gin.H
 	for _, s := range shops {
 		publicShops = append(publicShops, gin.H{
-			"id":   s.ID,
-			"name": s.Name,
+			"id":       s.ID,
+			"name":     s.Name,
+			"currency": s.Currency,
 		})
 	}
 
@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"electronic-shop-api/database"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// STRUCTURES DE REQUÊTE / RÉPONSE
+// ========================================
+
+type CreateTaxRateInput struct {
+	Name      string   `json:"name" binding:"required"`
+	Rate      *float64 `json:"rate" binding:"required,gte=0,lte=100"`
+	IsDefault bool     `json:"is_default"`
+}
+
+type UpdateTaxRateInput struct {
+	Name      string   `json:"name"`
+	Rate      *float64 `json:"rate" binding:"omitempty,gte=0,lte=100"`
+	IsDefault *bool    `json:"is_default"`
+}
+
+// TaxLine - Base et taxe par taux sur la période
+type TaxLine struct {
+	TaxRate float64      `json:"tax_rate"`
+	Count   int64        `json:"count"`
+	Base    models.Money `json:"base"` // Montant hors taxe
+	Tax     models.Money `json:"tax"`
+}
+
+// ========================================
+// GET TAX RATES
+// ========================================
+
+func GetTaxRates(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	var rates []models.TaxRate
+	if err := database.GetDB().Where("shop_id = ?", shopID).Order("rate DESC").Find(&rates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des taux de taxe"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tax_rates": rates, "count": len(rates)})
+}
+
+// ========================================
+// CREATE TAX RATE (SuperAdmin)
+// ========================================
+
+func CreateTaxRate(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	var input CreateTaxRateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	rate := models.TaxRate{
+		Name:      input.Name,
+		Rate:      *input.Rate,
+		IsDefault: input.IsDefault,
+		ShopID:    shopID,
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&rate).Error; err != nil {
+			return err
+		}
+		return keepSingleDefaultTaxRate(tx, rate)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création du taux de taxe"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Taux de taxe créé", "tax_rate": rate})
+}
+
+// ========================================
+// UPDATE TAX RATE (SuperAdmin)
+// ========================================
+
+// UpdateTaxRate modifie un taux: les ventes passées conservent le taux appliqué
+func UpdateTaxRate(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	rate, ok := findShopTaxRate(c, shopID)
+	if !ok {
+		return
+	}
+
+	var input UpdateTaxRateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données invalides: " + err.Error()})
+		return
+	}
+
+	if input.Name != "" {
+		rate.Name = input.Name
+	}
+	if input.Rate != nil {
+		rate.Rate = *input.Rate
+	}
+	if input.IsDefault != nil {
+		rate.IsDefault = *input.IsDefault
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&rate).Error; err != nil {
+			return err
+		}
+		return keepSingleDefaultTaxRate(tx, rate)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Taux de taxe mis à jour", "tax_rate": rate})
+}
+
+// ========================================
+// DELETE TAX RATE (SuperAdmin)
+// ========================================
+
+func DeleteTaxRate(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+
+	rate, ok := findShopTaxRate(c, shopID)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+
+	// Refuser si des produits utilisent ce taux
+	var productCount int64
+	db.Model(&models.Product{}).Where("shop_id = ? AND tax_rate_id = ?", shopID, rate.ID).Count(&productCount)
+	if productCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ce taux est utilisé par des produits", "products": productCount})
+		return
+	}
+
+	if err := db.Delete(&rate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Taux de taxe supprimé"})
+}
+
+// ========================================
+// GET TAX REPORT (SuperAdmin)
+// ========================================
+
+// GetTaxReport - Taxe collectée sur les ventes et taxe déductible des dépenses sur une période (from / to)
+func GetTaxReport(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+
+	collected, err := taxLines(c, db, shopID, models.TypeSale)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	refunded, err := taxLines(c, db, shopID, models.TypeRefund)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	deductible, err := taxLines(c, db, shopID, models.TypeExpense)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Taxe collectée nette des remboursements
+	totalCollected, totalDeductible := sumTax(collected)-sumTax(refunded), sumTax(deductible)
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":             c.Query("from"),
+		"to":               c.Query("to"),
+		"collected":        collected,
+		"refunded":         refunded,
+		"deductible":       deductible,
+		"total_collected":  totalCollected,
+		"total_deductible": totalDeductible,
+		"balance":          totalCollected - totalDeductible, // Positif: taxe à reverser
+	})
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// productTaxRate retourne le taux applicable au produit: sa classe fiscale, sinon le taux par défaut du shop
+func productTaxRate(db *gorm.DB, shopID uint, product models.Product) (float64, error) {
+	var rates []models.TaxRate
+	query := db.Where("shop_id = ?", shopID)
+	if product.TaxRateID != nil {
+		query = query.Where("id = ? OR is_default = ?", *product.TaxRateID, true)
+	} else {
+		query = query.Where("is_default = ?", true)
+	}
+	if err := query.Find(&rates).Error; err != nil {
+		return 0, err
+	}
+
+	var rate float64
+	for _, r := range rates {
+		if product.TaxRateID != nil && r.ID == *product.TaxRateID {
+			return r.Rate, nil
+		}
+		rate = r.Rate
+	}
+	return rate, nil
+}
+
+// checkTaxRate vérifie que le taux appartient au shop (MULTI-TENANT)
+func checkTaxRate(db *gorm.DB, shopID, taxRateID uint) (models.TaxRate, bool) {
+	var rate models.TaxRate
+	err := db.Where("id = ? AND shop_id = ?", taxRateID, shopID).First(&rate).Error
+	return rate, err == nil
+}
+
+// keepSingleDefaultTaxRate retire le statut par défaut des autres taux du shop
+func keepSingleDefaultTaxRate(tx *gorm.DB, rate models.TaxRate) error {
+	if !rate.IsDefault {
+		return nil
+	}
+	return tx.Model(&models.TaxRate{}).
+		Where("shop_id = ? AND id != ? AND is_default = ?", rate.ShopID, rate.ID, true).
+		Update("is_default", false).Error
+}
+
+// taxLines regroupe par taux les transactions du type donné sur la période demandée
+func taxLines(c *gin.Context, db *gorm.DB, shopID uint, transactionType models.TransactionType) ([]TaxLine, error) {
+	query := db.Model(&models.Transaction{}).
+		Select("tax_rate, COUNT(*) as count, SUM(amount - tax_amount) as base, SUM(tax_amount) as tax").
+		Where("shop_id = ? AND type = ?", shopID, transactionType)
+
+	// Dépenses: seules celles avec un justificatif de taxe sont déductibles
+	if transactionType == models.TypeExpense {
+		query = query.Where("tax_amount > 0")
+	}
+
+	query, err := applyPeriod(c, db, shopID, query, "created_at")
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []TaxLine{}
+	if err := query.Group("tax_rate").Order("tax_rate DESC").Scan(&lines).Error; err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+func sumTax(lines []TaxLine) models.Money {
+	var total models.Money
+	for _, line := range lines {
+		total += line.Tax
+	}
+	return total
+}
+
+// roundAmount arrondit au centime
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// findShopTaxRate charge le taux :id du shop (répond 400/404 sinon)
+func findShopTaxRate(c *gin.Context, shopID uint) (models.TaxRate, bool) {
+	taxRateID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de taux invalide"})
+		return models.TaxRate{}, false
+	}
+
+	rate, ok := checkTaxRate(database.GetDB(), shopID, uint(taxRateID))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Taux de taxe non trouvé"})
+	}
+	return rate, ok
+}
\ No newline at end of file
@@ -0,0 +1,453 @@
+package handlers
+
+import (
+	"electronic-shop-api/database"
+	"electronic-shop-api/middleware"
+	"electronic-shop-api/models"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ========================================
+// SÉRIES TEMPORELLES
+// ========================================
+
+// Indicateurs disponibles (paramètre metric)
+const (
+	MetricRevenue  = "revenue"  // Ventes facturées (ventes à crédit comprises), taxes comprises, nettes des remboursements, et frais d'annulation retenus
+	MetricProfit   = "profit"   // Ventes HT - coût d'achat + frais d'annulation - dépenses HT (remboursements déduits)
+	MetricUnits    = "units"    // Unités vendues, nettes des retours
+	MetricExpenses = "expenses" // Dépenses, taxes comprises
+)
+
+// Intervalles des points de la série (paramètre interval)
+const (
+	IntervalHour  = "hour"
+	IntervalDay   = "day"
+	IntervalWeek  = "week" // Du lundi au dimanche
+	IntervalMonth = "month"
+)
+
+// Découpages des séries (paramètre group_by)
+const (
+	GroupByCategory = "category"
+	GroupByProduct  = "product"
+	GroupByEmployee = "employee"
+)
+
+// maxTimeSeriesPoints - Nombre maximal de points d'une série
+const maxTimeSeriesPoints = 1000
+
+// defaultTimeSeriesDays - Période par défaut sans period ni from: les 30 derniers jours
+const defaultTimeSeriesDays = 30
+
+// defaultSeriesLimit - Nombre de séries par défaut avec group_by (les suivantes sont regroupées dans "Autres")
+const defaultSeriesLimit = 10
+
+// TimeSeries - Réponse de /reports/timeseries: une valeur par intervalle pour chaque série
+type TimeSeries struct {
+	Metric   string       `json:"metric"`
+	Interval string       `json:"interval"`
+	GroupBy  string       `json:"group_by,omitempty"`
+	Currency string       `json:"currency,omitempty"` // Indicateurs en montant
+	Period   reportPeriod `json:"period"`
+	Buckets  []time.Time  `json:"buckets"` // Début de chaque intervalle (fuseau du shop)
+	Series   []Series     `json:"series"`
+	Totals   []float64    `json:"totals"` // Somme des séries par intervalle
+	Total    float64      `json:"total"`
+}
+
+// Series - Valeurs d'une série, alignées sur Buckets
+type Series struct {
+	Key    *uint     `json:"key"` // ID de la catégorie, du produit ou de l'employé (null: sans, autres ou dépenses)
+	Label  string    `json:"label"`
+	Values []float64 `json:"values"`
+	Total  float64   `json:"total"`
+}
+
+// timeSeriesRow - Agrégat SQL d'un créneau horaire
+type timeSeriesRow struct {
+	Slot     string
+	GroupKey *uint
+	Label    *string
+	Value    int64
+	Apart    bool `gorm:"-"` // Série à part, jamais regroupée dans "Autres" (dépenses, frais d'annulation)
+}
+
+// GetTimeSeries - Série temporelle d'un indicateur pour les graphiques.
+// metric=revenue|profit|units|expenses, interval=hour|day|week|month (day par défaut),
+// group_by=category|product|employee (optionnel), période: period ou from / to (30 derniers jours par défaut).
+func GetTimeSeries(c *gin.Context) {
+	_, shopID, _ := middleware.GetUserFromContext(c)
+	db := database.GetDB()
+	now := time.Now()
+
+	metric := c.DefaultQuery("metric", MetricRevenue)
+	switch metric {
+	case MetricRevenue, MetricProfit, MetricUnits, MetricExpenses:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "metric invalide (revenue, profit, units ou expenses)"})
+		return
+	}
+
+	interval := c.DefaultQuery("interval", IntervalDay)
+	switch interval {
+	case IntervalHour, IntervalDay, IntervalWeek, IntervalMonth:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval invalide (hour, day, week ou month)"})
+		return
+	}
+
+	groupBy := c.Query("group_by")
+	switch groupBy {
+	case "", GroupByEmployee:
+	case GroupByCategory, GroupByProduct:
+		if metric == MetricExpenses {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Les dépenses ne sont rattachées à aucun produit: group_by=employee uniquement"})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by invalide (category, product ou employee)"})
+		return
+	}
+
+	limit := defaultSeriesLimit
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 50 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit invalide (1 à 50)"})
+			return
+		}
+		limit = n
+	}
+
+	period, err := parsePeriod(c, db, shopID, now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	loc := shopLocation(db, shopID)
+	period = boundedPeriod(period, now, loc)
+	shift := slotShift(*period.From, loc)
+
+	buckets := timeBuckets(*period.From, *period.To, interval, loc)
+	if len(buckets) > maxTimeSeriesPoints {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Trop de points (%d maximum): choisir un intervalle plus large ou une période plus courte", maxTimeSeriesPoints)})
+		return
+	}
+
+	rows, err := timeSeriesRows(db, shopID, period, metric, groupBy, shift)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du calcul de la série"})
+		return
+	}
+
+	series, err := buildSeries(rows, buckets, shift, metric, groupBy, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du calcul de la série"})
+		return
+	}
+
+	result := TimeSeries{
+		Metric:   metric,
+		Interval: interval,
+		GroupBy:  groupBy,
+		Period:   period,
+		Buckets:  buckets,
+		Series:   series,
+		Totals:   make([]float64, len(buckets)),
+	}
+	if metric != MetricUnits {
+		result.Currency = shopCurrency(db, shopID)
+	}
+	for _, s := range series {
+		for i, value := range s.Values {
+			result.Totals[i] = roundAmount(result.Totals[i] + value)
+		}
+		result.Total = roundAmount(result.Total + s.Total)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// boundedPeriod complète les bornes manquantes: 30 jours avant to, ou jusqu'à la fin de la journée
+func boundedPeriod(period reportPeriod, now time.Time, loc *time.Location) reportPeriod {
+	if period.To == nil {
+		local := now.In(loc)
+		to := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, loc)
+		period.To = &to
+	}
+	if period.From == nil {
+		from := period.To.AddDate(0, 0, -defaultTimeSeriesDays)
+		period.From = &from
+	}
+	if period.Name == PeriodAll {
+		period.Name = PeriodCustom
+	}
+	return period
+}
+
+// truncateTime retourne le début de l'intervalle contenant t
+func truncateTime(t time.Time, interval string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch interval {
+	case IntervalHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+	case IntervalWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case IntervalMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+}
+
+// timeBuckets liste le début des intervalles couvrant [from, to[ dans le fuseau du shop
+func timeBuckets(from, to time.Time, interval string, loc *time.Location) []time.Time {
+	buckets := []time.Time{}
+	for t := truncateTime(from, interval, loc); t.Before(to); {
+		buckets = append(buckets, t)
+		if len(buckets) > maxTimeSeriesPoints {
+			break
+		}
+		switch interval {
+		case IntervalHour:
+			t = truncateTime(t.Add(time.Hour), interval, loc)
+		case IntervalWeek:
+			t = t.AddDate(0, 0, 7)
+		case IntervalMonth:
+			t = t.AddDate(0, 1, 0)
+		default:
+			t = t.AddDate(0, 0, 1)
+		}
+	}
+	return buckets
+}
+
+// slotShift - Décalage (en minutes, 0 à 59) du fuseau par rapport à l'heure pleine UTC
+// (30 pour UTC+05:30): les créneaux SQL commencent alors à une heure pleine locale.
+func slotShift(at time.Time, loc *time.Location) int {
+	_, offset := at.In(loc).Zone()
+	return ((offset/60)%60 + 60) % 60
+}
+
+// slotColumn - Expression SQL du créneau d'une heure contenant column (UTC décalé de shift minutes)
+func slotColumn(column string, shift int) string {
+	return fmt.Sprintf("strftime('%%Y-%%m-%%d %%H:00:00', %s, '%+d minutes')", column, shift)
+}
+
+// slotBucket retourne l'index de l'intervalle contenant le créneau (-1 avant le premier)
+func slotBucket(slot string, shift int, buckets []time.Time) (int, error) {
+	start, err := time.ParseInLocation("2006-01-02 15:04:05", slot, time.UTC)
+	if err != nil {
+		return 0, err
+	}
+	start = start.Add(-time.Duration(shift) * time.Minute)
+	return sort.Search(len(buckets), func(i int) bool { return buckets[i].After(start) }) - 1, nil
+}
+
+// netOfRefunds - Expression SQL comptant value en négatif pour les remboursements
+func netOfRefunds(value string) string {
+	return fmt.Sprintf("CASE WHEN t.type = '%s' THEN -(%s) ELSE %s END", models.TypeRefund, value, value)
+}
+
+// timeSeriesRows agrège les transactions par créneau d'une heure (et par groupe).
+// Le regroupement en jours, semaines ou mois se fait ensuite dans le fuseau du shop,
+// ce qui reste exact lors des changements d'heure.
+func timeSeriesRows(db *gorm.DB, shopID uint, period reportPeriod, metric, groupBy string, shift int) ([]timeSeriesRow, error) {
+	slot := slotColumn("t.created_at", shift)
+	sales := []models.TransactionType{models.TypeSale, models.TypeRefund}
+	expenses := []models.TransactionType{models.TypeExpense}
+
+	aggregate := func(transactionTypes []models.TransactionType, value string, grouped bool) ([]timeSeriesRow, error) {
+		query := db.Table("transactions t").Where("t.shop_id = ? AND t.type IN ?", shopID, transactionTypes)
+		selectGroup := "NULL as group_key, NULL as label"
+		if grouped {
+			switch groupBy {
+			case GroupByCategory:
+				query = query.Joins("LEFT JOIN products p ON p.id = t.product_id")
+				selectGroup = "p.category_id as group_key, MAX(p.category) as label"
+			case GroupByProduct:
+				query = query.Joins("LEFT JOIN products p ON p.id = t.product_id")
+				selectGroup = "t.product_id as group_key, MAX(p.name) as label"
+			case GroupByEmployee:
+				query = query.Joins("LEFT JOIN users u ON u.id = t.user_id")
+				selectGroup = "t.user_id as group_key, MAX(u.name) as label"
+			}
+		}
+
+		var rows []timeSeriesRow
+		err := period.apply(query, "t.created_at").
+			Select(slot + " as slot, " + selectGroup + ", COALESCE(SUM(" + value + "), 0) as value").
+			Group("slot, group_key").
+			Scan(&rows).Error
+		return rows, err
+	}
+
+	// Frais d'annulation des ventes à tempérament: série à part avec group_by
+	withFees := func(rows []timeSeriesRow) ([]timeSeriesRow, error) {
+		var fees []timeSeriesRow
+		err := cancellationFees(db, shopID, period).
+			Select(slotColumn("l.cancelled_at", shift) + " as slot, COALESCE(SUM(l.cancellation_fee), 0) as value").
+			Group("slot").
+			Scan(&fees).Error
+		if err != nil {
+			return nil, err
+		}
+		label := "Frais d'annulation"
+		for _, row := range fees {
+			if groupBy != "" {
+				row.Label, row.Apart = &label, true
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+	}
+
+	grouped := groupBy != ""
+	switch metric {
+	case MetricUnits:
+		return aggregate(sales, netOfRefunds("t.quantity"), grouped)
+	case MetricExpenses:
+		return aggregate(expenses, "t.amount", grouped)
+	case MetricProfit:
+		// Marge HT des ventes par groupe; les dépenses HT (non rattachées à un produit)
+		// forment une série à part, de valeur négative
+		margins, err := aggregate(sales, netOfRefunds("t.amount - t.tax_amount - t.cost"), grouped)
+		if err == nil {
+			margins, err = withFees(margins)
+		}
+		if err != nil {
+			return nil, err
+		}
+		costs, err := aggregate(expenses, "t.amount - t.tax_amount", false)
+		if err != nil {
+			return nil, err
+		}
+		label := "Dépenses"
+		for _, row := range costs {
+			row.Value = -row.Value
+			if grouped {
+				row.Label, row.Apart = &label, true
+			}
+			margins = append(margins, row)
+		}
+		return margins, nil
+	default:
+		rows, err := aggregate(sales, netOfRefunds("t.amount"), grouped)
+		if err != nil {
+			return nil, err
+		}
+		return withFees(rows)
+	}
+}
+
+// buildSeries répartit les créneaux dans les intervalles et ne garde que les limit séries
+// les plus importantes (les autres sont cumulées dans "Autres")
+func buildSeries(rows []timeSeriesRow, buckets []time.Time, shift int, metric, groupBy string, limit int) ([]Series, error) {
+	type seriesKey struct {
+		id    uint
+		label string
+		apart bool
+	}
+	values := map[seriesKey][]int64{}
+	keys := map[seriesKey]*uint{}
+	order := []seriesKey{}
+
+	for _, row := range rows {
+		index, err := slotBucket(row.Slot, shift, buckets)
+		if err != nil {
+			return nil, err
+		}
+		if index < 0 {
+			continue
+		}
+
+		key := seriesKey{label: seriesLabel(row, groupBy), apart: row.Apart}
+		if row.GroupKey != nil {
+			key.id = *row.GroupKey
+		}
+		if _, ok := values[key]; !ok {
+			values[key] = make([]int64, len(buckets))
+			keys[key] = row.GroupKey
+			order = append(order, key)
+		}
+		values[key][index] += row.Value
+	}
+
+	totals := map[seriesKey]int64{}
+	for key, points := range values {
+		for _, value := range points {
+			totals[key] += value
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return totals[order[i]] > totals[order[j]] })
+
+	toFloat := func(value int64) float64 {
+		if metric == MetricUnits {
+			return float64(value)
+		}
+		return models.Money(value).Float64()
+	}
+	newSeries := func(key *uint, label string, points []int64) Series {
+		s := Series{Key: key, Label: label, Values: make([]float64, len(points))}
+		var total int64
+		for i, value := range points {
+			s.Values[i] = toFloat(value)
+			total += value
+		}
+		s.Total = toFloat(total)
+		return s
+	}
+
+	series := []Series{}
+	others := make([]int64, len(buckets))
+	hasOthers := false
+	for i, key := range order {
+		if groupBy != "" && i >= limit && !key.apart {
+			for j, value := range values[key] {
+				others[j] += value
+			}
+			hasOthers = true
+			continue
+		}
+		series = append(series, newSeries(keys[key], key.label, values[key]))
+	}
+	if hasOthers {
+		series = append(series, newSeries(nil, "Autres", others))
+	}
+	if len(series) == 0 && groupBy == "" {
+		series = append(series, newSeries(nil, "Total", make([]int64, len(buckets))))
+	}
+	return series, nil
+}
+
+// seriesLabel retourne le libellé d'une série (groupe sans nom: sans catégorie, produit supprimé...)
+func seriesLabel(row timeSeriesRow, groupBy string) string {
+	if row.Label != nil && *row.Label != "" {
+		return *row.Label
+	}
+	switch {
+	case groupBy == "":
+		return "Total"
+	case row.GroupKey == nil && groupBy == GroupByCategory:
+		return "Sans catégorie"
+	case row.GroupKey == nil && groupBy == GroupByEmployee:
+		return "Inconnu"
+	case groupBy == GroupByProduct:
+		return "Produit supprimé"
+	default:
+		return "Inconnu"
+	}
+}
\ No newline at end of file
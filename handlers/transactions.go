@@ -4,10 +4,16 @@ import (
 	"electronic-shop-api/database"
 	"electronic-shop-api/middleware"
 	"electronic-shop-api/models"
+	"electronic-shop-api/storage"
+	"errors"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // ========================================
@@ -15,10 +21,45 @@ import (
 // ========================================
 
 type CreateTransactionInput struct {
-	Type      string  `json:"type" binding:"required,oneof=Sale Expense Withdrawal"`
-	ProductID *uint   `json:"product_id"`
-	Quantity  int     `json:"quantity"`
-	Amount    float64 `json:"amount" binding:"required,gt=0"`
+	Type      string       `json:"type" binding:"required,oneof=Sale Expense Withdrawal Refund"`
+	ProductID *uint        `json:"product_id"`
+	Code      string       `json:"code"` // Code-barres ou SKU scanné (alternative à product_id)
+	Quantity  int          `json:"quantity"`
+	Amount    models.Money `json:"amount" binding:"omitempty,gt=0"` // Requis sauf pour un remboursement (calculé)
+
+	// Vente: code promo et client (customer_id, ou référence: téléphone ou email d'un client
+	// connu, sinon seulement utilisée pour les limites par client des codes promo)
+	CouponCode string `json:"coupon_code"`
+	CustomerID *uint  `json:"customer_id"`
+	Customer   string `json:"customer"`
+
+	// Vente à crédit (client requis, dans la limite de son encours): payments est l'acompte
+	// éventuel, le reste est dû et réglé plus tard (POST /customers/:id/payments)
+	OnAccount bool `json:"on_account"`
+
+	// Dépense: taxe déductible du justificatif (montant, ou taux appliqué au montant TTC)
+	TaxAmount *models.Money `json:"tax_amount" binding:"omitempty,gte=0"`
+	TaxRateID *uint         `json:"tax_rate_id"`
+
+	// Dépense ou retrait en devise étrangère (amount et tax_amount dans cette devise)
+	Currency string `json:"currency"`
+
+	// Dépense: catégorie (loyer, électricité, salaires... par id ou par nom, créée si inconnue),
+	// description et fournisseur
+	ExpenseCategoryID *uint  `json:"expense_category_id"`
+	ExpenseCategory   string `json:"expense_category"`
+	Description       string `json:"description"`
+	Vendor            string `json:"vendor"`
+
+	// Remboursement: vente remboursée (quantity: articles retournés, toute la vente par défaut)
+	SaleID *uint `json:"sale_id"`
+
+	// Règlements (défaut: espèces pour le montant total). Vente: les espèces peuvent dépasser
+	// le montant dû, l'excédent est la monnaie rendue.
+	Payments []PaymentInput `json:"payments" binding:"omitempty,dive"`
+
+	// Session de caisse (défaut: celle ouverte par l'utilisateur, ou la seule ouverte du shop)
+	RegisterSessionID *uint `json:"register_session_id"`
 }
 
 // ========================================
@@ -26,24 +67,19 @@ type CreateTransactionInput struct {
 // ========================================
 
 func GetTransactions(c *gin.Context) {
-	_, shopID, _ := middleware.GetUserFromContext(c)
+	_, shopID, role := middleware.GetUserFromContext(c)
 	db := database.GetDB()
 
 	var transactions []models.Transaction
 
-	query := db.Where("shop_id = ?", shopID).Order("created_at DESC")
+	query := filterTransactions(c, db, shopID).Order("created_at DESC")
 
-	// Filtre par type (optionnel)
-	if transType := c.Query("type"); transType != "" {
-		query = query.Where("type = ?", transType)
-	}
-
-	if err := query.Preload("Product").Find(&transactions).Error; err != nil {
+	if err := query.Preload("Product").Preload("Promotions").Preload("Attachments").Preload("Payments").Find(&transactions).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la récupération des transactions"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"transactions": transactions, "count": len(transactions)})
+	c.JSON(http.StatusOK, gin.H{"transactions": transactionsForRole(role, transactions), "count": len(transactions)})
 }
 
 // ========================================
@@ -51,7 +87,7 @@ func GetTransactions(c *gin.Context) {
 // ========================================
 
 func GetTransaction(c *gin.Context) {
-	_, shopID, _ := middleware.GetUserFromContext(c)
+	_, shopID, role := middleware.GetUserFromContext(c)
 
 	transactionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -63,12 +99,12 @@ func GetTransaction(c *gin.Context) {
 	var transaction models.Transaction
 
 	if err := db.Where("id = ? AND shop_id = ?", transactionID, shopID).
-		Preload("Product").First(&transaction).Error; err != nil {
+		Preload("Product").Preload("Promotions").Preload("Attachments").Preload("Payments").First(&transaction).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction non trouvée"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"transaction": transaction})
+	c.JSON(http.StatusOK, gin.H{"transaction": transactionForRole(role, transaction)})
 }
 
 // ========================================
@@ -76,7 +112,7 @@ func GetTransaction(c *gin.Context) {
 // ========================================
 
 func CreateTransaction(c *gin.Context) {
-	_, shopID, _ := middleware.GetUserFromContext(c)
+	userID, shopID, role := middleware.GetUserFromContext(c)
 
 	var input CreateTransactionInput
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -86,55 +122,225 @@ func CreateTransaction(c *gin.Context) {
 
 	db := database.GetDB()
 
+	// Rattacher la transaction à la caisse ouverte
+	sessionID, err := registerSessionFor(db, shopID, userID, input.RegisterSessionID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Ventes et remboursements passent par une caisse ouverte (rapport Z)
+	if sessionID == nil && (input.Type == string(models.TypeSale) || input.Type == string(models.TypeRefund)) {
+		c.JSON(http.StatusConflict, gin.H{"error": errNoOpenSession.Error()})
+		return
+	}
+
 	// ========================================
 	// CAS 1: VENTE (Sale)
 	// ========================================
 	if input.Type == "Sale" {
 		// Validation
-		if input.ProductID == nil || input.Quantity <= 0 {
+		if (input.ProductID == nil && normalizeCode(input.Code) == "") || input.Quantity <= 0 {
 			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "product_id et quantity sont requis pour une vente",
+				"error": "product_id (ou code) et quantity sont requis pour une vente",
 			})
 			return
 		}
+		if input.Currency != "" && !strings.EqualFold(input.Currency, shopCurrency(db, shopID)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Les ventes sont enregistrées dans la devise du shop"})
+			return
+		}
 
 		// Vérifier que le produit existe et appartient au shop
 		var product models.Product
-		if err := db.Where("id = ? AND shop_id = ?", *input.ProductID, shopID).First(&product).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Produit non trouvé"})
-			return
+		if input.ProductID != nil {
+			if err := db.Where("id = ? AND shop_id = ?", *input.ProductID, shopID).First(&product).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Produit non trouvé"})
+				return
+			}
+			if product.ArchivedAt != nil {
+				c.JSON(http.StatusConflict, gin.H{"error": errProductArchived.Error()})
+				return
+			}
+		} else {
+			// Vente par scan du code-barres (produits archivés exclus)
+			found, err := findProductByCode(db, shopID, normalizeCode(input.Code))
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Aucun produit pour ce code", "code": input.Code})
+				return
+			}
+			product = found
+			input.ProductID = &product.ID
 		}
 
-		// Vérifier le stock
-		if product.Stock < input.Quantity {
+		// Vérifier le stock (hors unités réservées par des ventes à tempérament)
+		if product.Stock-product.Reserved < input.Quantity {
 			c.JSON(http.StatusBadRequest, gin.H{
-				"error":              "Stock insuffisant",
-				"stock_disponible":   product.Stock,
-				"quantite_demandee":  input.Quantity,
+				"error":             "Stock insuffisant",
+				"stock_disponible":  product.Stock - product.Reserved,
+				"quantite_demandee": input.Quantity,
 			})
 			return
 		}
 
-		// Calculer le montant total
-		totalAmount := product.SellingPrice * float64(input.Quantity)
+		// Calculer le montant total (promotions en cours déduites)
+		pricing, err := evaluateSale(db, shopID, product, input.Quantity, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du calcul des promotions"})
+			return
+		}
+
+		// Client (optionnel)
+		client, err := resolveCustomer(db, shopID, input.CustomerID, input.Customer)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Code promo (optionnel), appliqué après les promotions
+		customer := normalizeCustomerRef(input.Customer)
+		if customer == "" && client != nil {
+			customer = customerRef(*client)
+		}
+		var coupon *models.Coupon
+		var couponDiscount models.Money
+		if input.CouponCode != "" {
+			found, err := checkCoupon(db, shopID, input.CouponCode, customer, pricing, time.Now())
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "coupon_code": input.CouponCode})
+				return
+			}
+			if found.MaxUsesPerCustomer > 0 && customer == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Référence client (customer) requise pour ce code promo"})
+				return
+			}
+			coupon = &found
+			couponDiscount = pricing.AddCoupon(found.Type, found.Value)
+		}
+
+		// Taxe calculée sur le montant après remises (ajoutée si les prix sont HT)
+		var shop models.Shop
+		if err := db.First(&shop, shopID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Shop non trouvé"})
+			return
+		}
+		taxRate, err := productTaxRate(db, shopID, product)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du calcul de la taxe"})
+			return
+		}
+		taxAmount, totalAmount := models.TaxSplit(pricing.Total, taxRate, shop.PricesIncludeTax)
+
+		// Règlements (plusieurs moyens possibles) et monnaie rendue
+		var payments []models.Payment
+		var change, amountDue models.Money
+		if input.OnAccount {
+			// Vente à crédit: acompte éventuel (montant exact), le reste est dû
+			if client == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Client (customer_id ou customer) requis pour une vente à crédit"})
+				return
+			}
+			var deposit models.Money
+			for _, payment := range input.Payments {
+				deposit += payment.Amount
+			}
+			if deposit > totalAmount {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "L'acompte dépasse le montant de la vente", "amount": totalAmount})
+				return
+			}
+			if deposit > 0 {
+				if payments, _, err = buildPayments(db, shopID, sessionID, deposit, input.Payments, false); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "amount": totalAmount})
+					return
+				}
+			}
+			amountDue = totalAmount - deposit
+			balance := customerBalance(db, shopID, client.ID)
+			if balance+amountDue > client.CreditLimit {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":        "Limite de crédit du client dépassée",
+					"credit_limit": client.CreditLimit,
+					"balance":      balance,
+					"amount_due":   amountDue,
+				})
+				return
+			}
+		} else {
+			if payments, change, err = buildPayments(db, shopID, sessionID, totalAmount, input.Payments, true); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "amount": totalAmount})
+				return
+			}
+		}
+
+		// Règlements en points fidélité: client et solde suffisant
+		redeemed, err := loyaltyRedemption(db, shopID, client, payments)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Règlements par carte cadeau ou avoir: code, validité et solde
+		if err := checkGiftCardRedemption(db, payments, time.Now()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Coût d'achat au taux de change du jour (COGS)
+		unitCost, err := productCost(db, shopID, product, time.Now())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
 		// Transaction DB atomique
 		tx := db.Begin()
 
-		// 1. Décrémenter le stock
-		if err := tx.Model(&product).Update("stock", product.Stock-input.Quantity).Error; err != nil {
+		// 1. Décrémenter le stock (revérifié: ventes et réservations concurrentes)
+		result := tx.Model(&models.Product{}).
+			Where("id = ? AND shop_id = ? AND stock - reserved >= ?", product.ID, shopID, input.Quantity).
+			Update("stock", gorm.Expr("stock - ?", input.Quantity))
+		if result.Error != nil {
 			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour du stock"})
 			return
 		}
+		if result.RowsAffected == 0 {
+			tx.Rollback()
+			db.Select("stock", "reserved").First(&product, product.ID)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "Stock insuffisant",
+				"stock_disponible":  product.Stock - product.Reserved,
+				"quantite_demandee": input.Quantity,
+			})
+			return
+		}
 
 		// 2. Créer la transaction
 		transaction := models.Transaction{
-			Type:      models.TypeSale,
-			ProductID: input.ProductID,
-			Quantity:  input.Quantity,
-			Amount:    totalAmount,
-			ShopID:    shopID,
+			Type:       models.TypeSale,
+			ProductID:  input.ProductID,
+			Quantity:   input.Quantity,
+			Subtotal:   pricing.Subtotal,
+			Discount:   pricing.Discount,
+			Amount:     totalAmount,
+			TaxRate:    taxRate,
+			TaxAmount:  taxAmount,
+			Cost:       unitCost.Times(input.Quantity),
+			UserID:     &userID,
+			ShopID:     shopID,
+			Promotions: appliedPromotions(pricing),
+			Payments:   payments,
+			OnAccount:  input.OnAccount,
+			AmountDue:  amountDue,
+
+			RegisterSessionID: sessionID,
+		}
+		if client != nil {
+			transaction.CustomerID = &client.ID
+		}
+		if coupon != nil {
+			transaction.CouponCode = coupon.Code
+			transaction.CouponDiscount = couponDiscount
 		}
 
 		if err := tx.Create(&transaction).Error; err != nil {
@@ -143,28 +349,162 @@ func CreateTransaction(c *gin.Context) {
 			return
 		}
 
-		tx.Commit()
+		// 3. Enregistrer l'utilisation du code promo
+		if coupon != nil {
+			if err := redeemCoupon(tx, *coupon, transaction, customer, userID); err != nil {
+				tx.Rollback()
+				if errors.Is(err, errCouponUsedUp) {
+					c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "coupon_code": input.CouponCode})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement du code promo"})
+				return
+			}
+		}
+
+		// 4. Cartes cadeaux débitées (solde revérifié: paiements concurrents)
+		if err := redeemGiftCards(tx, transaction, userID); err != nil {
+			tx.Rollback()
+			if errors.Is(err, errGiftCardBalance) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors du débit de la carte cadeau"})
+			return
+		}
+
+		// 5. Points fidélité utilisés et gagnés
+		loyalty, err := applySaleLoyalty(tx, transaction, redeemed, userID)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement des points fidélité"})
+			return
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement de la vente"})
+			return
+		}
 
 		// Charger le produit pour la réponse
-		db.Preload("Product").First(&transaction, transaction.ID)
+		db.Preload("Product").Preload("Promotions").Preload("Payments").Preload("Customer").First(&transaction, transaction.ID)
 
-		c.JSON(http.StatusCreated, gin.H{
+		response := gin.H{
 			"message":     "Vente enregistrée",
-			"transaction": transaction,
-			"new_stock":   product.Stock - input.Quantity,
-		})
+			"transaction": transactionForRole(role, transaction),
+			"change":      change,
+		}
+		if transaction.Product != nil {
+			response["new_stock"] = transaction.Product.Stock
+		}
+		if client != nil {
+			response["loyalty"] = loyalty
+		}
+		c.JSON(http.StatusCreated, response)
+		return
+	}
+
+	// ========================================
+	// CAS 2: REMBOURSEMENT (Refund)
+	// ========================================
+	if input.Type == string(models.TypeRefund) {
+		createRefund(c, db, input, userID, shopID, role, sessionID)
 		return
 	}
 
 	// ========================================
-	// CAS 2: DÉPENSE ou RETRAIT
+	// CAS 3: DÉPENSE ou RETRAIT
 	// ========================================
+	if input.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount est requis pour une dépense ou un retrait"})
+		return
+	}
 	transaction := models.Transaction{
-		Type:   models.TransactionType(input.Type),
-		Amount: input.Amount,
-		ShopID: shopID,
+		Type:              models.TransactionType(input.Type),
+		Amount:            input.Amount,
+		RegisterSessionID: sessionID,
+		UserID:            &userID,
+		ShopID:            shopID,
+	}
+	if transaction.Type == models.TypeExpense {
+		category, err := resolveExpenseCategory(db, shopID, input.ExpenseCategoryID, input.ExpenseCategory)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if category != nil {
+			transaction.ExpenseCategoryID, transaction.ExpenseCategory = &category.ID, category.Name
+		}
+		transaction.Description = strings.TrimSpace(input.Description)
+		transaction.Vendor = strings.TrimSpace(input.Vendor)
 	}
 
+	// Devise étrangère: montants convertis au taux du jour, montant saisi conservé
+	if input.Currency != "" {
+		currency, err := models.NormalizeCurrency(input.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if currency != shopCurrency(db, shopID) {
+			amount, rate, err := toShopCurrency(db, shopID, input.Amount, currency, time.Now())
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			transaction.Currency, transaction.OriginalAmount, transaction.ExchangeRate = currency, input.Amount, rate
+			transaction.Amount, input.Amount = amount, amount
+			if input.TaxAmount != nil {
+				tax := input.TaxAmount.Convert(rate)
+				input.TaxAmount = &tax
+			}
+			// Règlements saisis dans la même devise, convertis un à un. S'ils soldent le montant
+			// saisi, le dernier reprend le seul écart d'arrondi; sinon buildPayments refuse l'écart.
+			var original, converted models.Money
+			for i := range input.Payments {
+				original += input.Payments[i].Amount
+				input.Payments[i].Amount = input.Payments[i].Amount.Convert(rate)
+				converted += input.Payments[i].Amount
+			}
+			if n := len(input.Payments); n > 0 && original == transaction.OriginalAmount {
+				input.Payments[n-1].Amount += amount - converted
+			}
+		}
+	}
+
+	// Taxe déductible d'une dépense (montant TTC)
+	if transaction.Type == models.TypeExpense {
+		if input.TaxRateID != nil {
+			rate, ok := checkTaxRate(db, shopID, *input.TaxRateID)
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Taux de taxe non trouvé"})
+				return
+			}
+			transaction.TaxRate = rate.Rate
+			transaction.TaxAmount, _ = models.TaxSplit(input.Amount, rate.Rate, true)
+		}
+		if input.TaxAmount != nil {
+			if *input.TaxAmount >= input.Amount {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "tax_amount doit être inférieur au montant"})
+				return
+			}
+			transaction.TaxAmount = *input.TaxAmount
+			if input.TaxRateID == nil && *input.TaxAmount > 0 {
+				transaction.TaxRate = roundAmount(float64(*input.TaxAmount) / float64(input.Amount-*input.TaxAmount) * 100)
+			}
+		}
+	}
+
+	payments, _, err := buildPayments(db, shopID, sessionID, transaction.Amount, input.Payments, false)
+	if err == nil {
+		err = rejectSaleOnlyPayments(payments)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "amount": transaction.Amount})
+		return
+	}
+	transaction.Payments = payments
+
 	if err := db.Create(&transaction).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création de la transaction"})
 		return
@@ -176,10 +516,179 @@ func CreateTransaction(c *gin.Context) {
 	})
 }
 
+// ========================================
+// CREATE REFUND
+// ========================================
+
+// createRefund rembourse tout ou partie d'une vente: les montants (taxe, coût, remises)
+// sont répartis au prorata des articles retournés, qui reviennent en stock.
+func createRefund(c *gin.Context, db *gorm.DB, input CreateTransactionInput, userID, shopID uint, role models.Role, sessionID *uint) {
+	if input.SaleID == nil || input.Quantity < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sale_id est requis pour un remboursement"})
+		return
+	}
+
+	// MULTI-TENANT
+	var sale models.Transaction
+	if err := db.Where("id = ? AND shop_id = ? AND type = ?", *input.SaleID, shopID, models.TypeSale).First(&sale).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vente non trouvée"})
+		return
+	}
+
+	// Déjà remboursé sur cette vente
+	var refunded struct {
+		Quantity  int
+		Subtotal  models.Money
+		Discount  models.Money
+		Amount    models.Money
+		TaxAmount models.Money
+		Cost      models.Money
+	}
+	db.Model(&models.Transaction{}).
+		Select("COALESCE(SUM(quantity), 0) as quantity, COALESCE(SUM(subtotal), 0) as subtotal, COALESCE(SUM(discount), 0) as discount, "+
+			"COALESCE(SUM(amount), 0) as amount, COALESCE(SUM(tax_amount), 0) as tax_amount, COALESCE(SUM(cost), 0) as cost").
+		Where("shop_id = ? AND type = ? AND refund_of_id = ?", shopID, models.TypeRefund, sale.ID).
+		Scan(&refunded)
+
+	remaining := sale.Quantity - refunded.Quantity
+	quantity := input.Quantity
+	if quantity == 0 {
+		quantity = remaining
+	}
+	if remaining <= 0 || quantity > remaining {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantité supérieure aux articles non remboursés", "remaining": remaining})
+		return
+	}
+
+	// Part d'un montant de la vente; le dernier remboursement reprend le reliquat (arrondis)
+	share := func(total, already models.Money) models.Money {
+		if quantity == remaining {
+			return total - already
+		}
+		return models.Money(math.Round(float64(total) * float64(quantity) / float64(sale.Quantity)))
+	}
+
+	refund := models.Transaction{
+		Type:       models.TypeRefund,
+		ProductID:  sale.ProductID,
+		Quantity:   quantity,
+		Subtotal:   share(sale.Subtotal, refunded.Subtotal),
+		Discount:   share(sale.Discount, refunded.Discount),
+		Amount:     share(sale.Amount, refunded.Amount),
+		TaxRate:    sale.TaxRate,
+		TaxAmount:  share(sale.TaxAmount, refunded.TaxAmount),
+		Cost:       share(sale.Cost, refunded.Cost),
+		RefundOfID: &sale.ID,
+		CustomerID: sale.CustomerID,
+		UserID:     &userID,
+		ShopID:     shopID,
+
+		RegisterSessionID: sessionID,
+	}
+
+	// Vente à crédit: le remboursement réduit d'abord le reste dû
+	if sale.AmountDue > 0 {
+		refund.AmountDue = refund.Amount
+		if refund.AmountDue > sale.AmountDue {
+			refund.AmountDue = sale.AmountDue
+		}
+	}
+
+	// Reste remboursé en espèces par défaut, ou selon les règlements saisis (montant exact)
+	if toPay := refund.Amount - refund.AmountDue; toPay > 0 || len(input.Payments) > 0 {
+		payments, _, err := buildPayments(db, shopID, sessionID, toPay, input.Payments, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "amount": toPay})
+			return
+		}
+		refund.Payments = payments
+	}
+
+	// Remboursement en points fidélité: les points sont rendus au client de la vente
+	if loyaltyAmount(refund.Payments) > 0 {
+		if sale.CustomerID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Vente sans client: remboursement en points impossible"})
+			return
+		}
+		if program := findLoyaltyProgram(db, shopID); !program.Active {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "programme de fidélité inactif"})
+			return
+		}
+	}
+
+	// Remboursement en avoir: carte saisie (gift_card_code) encore valide, sinon nouvel avoir
+	now := time.Now()
+	if err := checkGiftCardCredit(db, refund.Payments, now); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx := db.Begin()
+
+	// Articles retournés en stock (si le produit existe encore)
+	if sale.ProductID != nil {
+		if err := tx.Model(&models.Product{}).Where("id = ? AND shop_id = ?", *sale.ProductID, shopID).
+			Update("stock", gorm.Expr("stock + ?", quantity)).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour du stock"})
+			return
+		}
+	}
+
+	if refund.AmountDue > 0 {
+		if err := tx.Model(&models.Transaction{}).Where("id = ? AND shop_id = ?", sale.ID, shopID).
+			Update("amount_due", gorm.Expr("amount_due - ?", refund.AmountDue)).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la mise à jour du reste dû"})
+			return
+		}
+	}
+
+	if err := tx.Create(&refund).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la création du remboursement"})
+		return
+	}
+
+	// Points gagnés sur la vente retirés au prorata, points remboursés rendus
+	loyalty, err := applyRefundLoyalty(tx, sale, refund, userID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'enregistrement des points fidélité"})
+		return
+	}
+
+	// Montants remboursés en avoir crédités sur les cartes cadeaux
+	giftCards, err := creditGiftCards(tx, refund, userID, now)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de l'émission de l'avoir"})
+		return
+	}
+	tx.Commit()
+
+	db.Preload("Product").Preload("Payments").First(&refund, refund.ID)
+
+	response := gin.H{
+		"message":     "Remboursement enregistré",
+		"transaction": transactionForRole(role, refund),
+		"remaining":   remaining - quantity,
+	}
+	if sale.CustomerID != nil {
+		response["loyalty"] = loyalty
+	}
+	if len(giftCards) > 0 {
+		response["gift_cards"] = giftCards
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
 // ========================================
 // DELETE TRANSACTION
 // ========================================
 
+var errRefundStock = errors.New("Stock insuffisant pour annuler le remboursement")
+
 func DeleteTransaction(c *gin.Context) {
 	_, shopID, _ := middleware.GetUserFromContext(c)
 
@@ -197,18 +706,177 @@ func DeleteTransaction(c *gin.Context) {
 		return
 	}
 
-	// Si c'est une vente, restaurer le stock
-	if transaction.Type == models.TypeSale && transaction.ProductID != nil {
-		var product models.Product
-		if err := db.First(&product, *transaction.ProductID).Error; err == nil {
-			db.Model(&product).Update("stock", product.Stock+transaction.Quantity)
+	// Le rapport Z d'une caisse clôturée est figé
+	if err := checkSessionOpen(db, transaction); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "register_session_id": transaction.RegisterSessionID})
+		return
+	}
+
+	// Une vente remboursée ne peut plus être supprimée (supprimer d'abord les remboursements),
+	// ni la vente d'une vente à tempérament soldée (ses règlements sont les versements)
+	if transaction.Type == models.TypeSale {
+		var layaways int64
+		db.Model(&models.Layaway{}).Where("shop_id = ? AND sale_id = ?", shopID, transaction.ID).Count(&layaways)
+		if layaways > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Vente d'une vente à tempérament: elle ne peut pas être supprimée"})
+			return
+		}
+
+		var refunds int64
+		db.Model(&models.Transaction{}).Where("shop_id = ? AND refund_of_id = ?", shopID, transaction.ID).Count(&refunds)
+		if refunds > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cette vente a des remboursements: les supprimer d'abord", "refunds": refunds})
+			return
 		}
 	}
 
-	if err := db.Delete(&transaction).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression"})
+	// Justificatifs d'une dépense: fichiers stockés supprimés après la transaction
+	var attachments []models.ExpenseAttachment
+	db.Where("transaction_id = ? AND shop_id = ?", transaction.ID, shopID).Find(&attachments)
+
+	// Annulation complète ou rien: stock, reste dû, cartes cadeaux, points fidélité,
+	// promotions, paiements, code promo et justificatifs dans une seule transaction
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		// Une vente annulée remet les articles en stock, un remboursement annulé les retire
+		if transaction.ProductID != nil && (transaction.Type == models.TypeSale || transaction.Type == models.TypeRefund) {
+			query := tx.Model(&models.Product{}).Where("id = ? AND shop_id = ?", *transaction.ProductID, shopID)
+			delta := transaction.Quantity
+			if transaction.Type == models.TypeRefund {
+				query = query.Where("stock >= ?", transaction.Quantity)
+				delta = -transaction.Quantity
+			}
+			result := query.Update("stock", gorm.Expr("stock + ?", delta))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 && transaction.Type == models.TypeRefund {
+				var products int64
+				tx.Model(&models.Product{}).Where("id = ? AND shop_id = ?", *transaction.ProductID, shopID).Count(&products)
+				if products > 0 {
+					return errRefundStock
+				}
+			}
+		}
+
+		// Remboursement d'une vente à crédit: la part imputée redevient due
+		if transaction.Type == models.TypeRefund && transaction.RefundOfID != nil && transaction.AmountDue > 0 {
+			if err := tx.Model(&models.Transaction{}).Where("id = ? AND shop_id = ?", *transaction.RefundOfID, shopID).
+				Update("amount_due", gorm.Expr("amount_due + ?", transaction.AmountDue)).Error; err != nil {
+				return err
+			}
+		}
+
+		// Cartes cadeaux et points fidélité débités ou crédités: soldes rétablis
+		// (409 si l'avoir émis ou les points gagnés ont été utilisés)
+		if err := revertGiftCardEntries(tx, shopID, transaction.ID); err != nil {
+			return err
+		}
+		if err := revertLoyaltyEntries(tx, shopID, transaction.ID); err != nil {
+			return err
+		}
+
+		if err := tx.Where("transaction_id = ?", transaction.ID).Delete(&models.TransactionPromotion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("transaction_id = ? AND shop_id = ?", transaction.ID, shopID).Delete(&models.Payment{}).Error; err != nil {
+			return err
+		}
+		if err := releaseCoupon(tx, transaction.ID); err != nil {
+			return err
+		}
+		if err := tx.Where("transaction_id = ? AND shop_id = ?", transaction.ID, shopID).Delete(&models.ExpenseAttachment{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&transaction).Error
+	}); err != nil {
+		switch {
+		case errors.Is(err, errRefundStock):
+			var product models.Product
+			db.Select("stock").First(&product, *transaction.ProductID)
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "stock_disponible": product.Stock})
+		case errors.Is(err, errGiftCardUsed) || errors.Is(err, errLoyaltyUsed):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erreur lors de la suppression"})
+		}
 		return
 	}
 
+	if len(attachments) > 0 {
+		keys := make([]string, len(attachments))
+		for i, attachment := range attachments {
+			keys[i] = attachment.StorageKey
+		}
+		db.Where("transaction_id = ? AND shop_id = ?", transaction.ID, shopID).Delete(&models.ExpenseAttachment{})
+		deleteStoredKeys(storage.Get(), keys)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Transaction supprimée"})
+}
+
+// ========================================
+// HELPERS
+// ========================================
+
+// filterTransactions applique les filtres de la liste des transactions (partagés avec l'export)
+func filterTransactions(c *gin.Context, db *gorm.DB, shopID uint) *gorm.DB {
+	// MULTI-TENANT
+	query := db.Where("shop_id = ?", shopID)
+
+	// Filtre par type (optionnel)
+	if transType := c.Query("type"); transType != "" {
+		query = query.Where("type = ?", transType)
+	}
+
+	// Filtre par client (optionnel)
+	if customerID := c.Query("customer_id"); customerID != "" {
+		query = query.Where("customer_id = ?", customerID)
+	}
+
+	// Ventes à crédit (optionnel): on_account=true, ou unpaid=true pour celles restant dues
+	if c.Query("on_account") == "true" {
+		query = query.Where("on_account = ?", true)
+	}
+	if c.Query("unpaid") == "true" {
+		query = query.Where("amount_due > 0 AND type = ?", models.TypeSale)
+	}
+
+	// Filtre par session de caisse (optionnel)
+	if sessionID := c.Query("register_session_id"); sessionID != "" {
+		query = query.Where("register_session_id = ?", sessionID)
+	}
+
+	// Filtre par catégorie de dépense (optionnel)
+	if categoryID := c.Query("expense_category_id"); categoryID != "" {
+		query = query.Where("expense_category_id = ?", categoryID)
+	}
+	return query
+}
+
+// transactionView - Transaction vue par un Admin: produit sans prix d'achat
+type transactionView struct {
+	models.Transaction
+	Product gin.H `json:"product,omitempty"`
+}
+
+// transactionsForRole masque le prix d'achat des produits préchargés pour les Admin (même règle que GetProducts)
+func transactionsForRole(role models.Role, transactions []models.Transaction) interface{} {
+	if role != models.RoleAdmin {
+		return transactions
+	}
+	views := make([]transactionView, len(transactions))
+	for i, t := range transactions {
+		views[i] = transactionView{Transaction: t}
+		if t.Product != nil {
+			views[i].Product = productWithoutPurchasePrice(*t.Product)
+		}
+	}
+	return views
+}
+
+func transactionForRole(role models.Role, transaction models.Transaction) interface{} {
+	if role != models.RoleAdmin {
+		return transaction
+	}
+	return transactionsForRole(role, []models.Transaction{transaction}).([]transactionView)[0]
 }
\ No newline at end of file
@@ -0,0 +1,152 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	_ "image/gif" // Décodeurs enregistrés pour image.Decode
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Variant - Taille générée automatiquement à l'upload (plus grand côté, en pixels)
+type Variant struct {
+	Name    string
+	MaxSize int
+}
+
+// Variants - Miniatures générées pour chaque image produit
+var Variants = []Variant{
+	{Name: "thumbnail", MaxSize: 150},
+	{Name: "medium", MaxSize: 600},
+	{Name: "large", MaxSize: 1200},
+}
+
+// AllowedTypes - Types MIME acceptés et leur extension
+var AllowedTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// MaxPixels - Dimensions maximales d'une image envoyée (largeur × hauteur). Un fichier de
+// quelques Ko peut déclarer des dimensions énormes: elles sont vérifiées avant le décodage.
+const MaxPixels = 40_000_000
+
+var (
+	// ErrUnsupportedType - Le contenu n'est pas une image acceptée
+	ErrUnsupportedType = errors.New("type de fichier non supporté (JPEG, PNG, GIF ou WebP attendu)")
+	// ErrTooManyPixels - L'image dépasse MaxPixels
+	ErrTooManyPixels = fmt.Errorf("dimensions de l'image trop grandes (%d mégapixels maximum)", MaxPixels/1_000_000)
+)
+
+// Encoded - Image encodée prête à être stockée
+type Encoded struct {
+	Data        []byte
+	ContentType string
+	Extension   string
+}
+
+// Processed - Résultat du traitement d'une image envoyée
+type Processed struct {
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+	Variants    map[string]Encoded
+}
+
+// DetectType retourne le type MIME réel (d'après le contenu, pas l'extension)
+func DetectType(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	if _, ok := AllowedTypes[contentType]; !ok {
+		return contentType, ErrUnsupportedType
+	}
+	return contentType, nil
+}
+
+// Process valide l'image et génère ses miniatures
+func Process(data []byte) (*Processed, error) {
+	contentType, err := DetectType(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Dimensions lues dans l'en-tête: l'image n'est décodée (et allouée) que si elle est raisonnable
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("image illisible ou corrompue")
+	}
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("image illisible ou corrompue")
+	}
+
+	bounds := src.Bounds()
+	result := &Processed{
+		ContentType: contentType,
+		Extension:   AllowedTypes[contentType],
+		Width:       bounds.Dx(),
+		Height:      bounds.Dy(),
+		Variants:    make(map[string]Encoded, len(Variants)),
+	}
+
+	for _, variant := range Variants {
+		encoded, err := encode(resize(src, variant.MaxSize))
+		if err != nil {
+			return nil, err
+		}
+		result.Variants[variant.Name] = encoded
+	}
+
+	return result, nil
+}
+
+// resize réduit l'image pour que son plus grand côté fasse maxSize (sans agrandir)
+func resize(src image.Image, maxSize int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSize && height <= maxSize {
+		return src
+	}
+
+	if width >= height {
+		height = max(1, height*maxSize/width)
+		width = maxSize
+	} else {
+		width = max(1, width*maxSize/height)
+		height = maxSize
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+	return dst
+}
+
+// encode produit un JPEG pour les images opaques et un PNG si la transparence doit être conservée
+func encode(img image.Image) (Encoded, error) {
+	var buf bytes.Buffer
+
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && !opaque.Opaque() {
+		if err := png.Encode(&buf, img); err != nil {
+			return Encoded{}, err
+		}
+		return Encoded{Data: buf.Bytes(), ContentType: "image/png", Extension: ".png"}, nil
+	}
+
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+		return Encoded{}, err
+	}
+	return Encoded{Data: buf.Bytes(), ContentType: "image/jpeg", Extension: ".jpg"}, nil
+}
\ No newline at end of file
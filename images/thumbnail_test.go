@@ -0,0 +1,101 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// pngImage encode une image PNG unie de la taille donnée
+func pngImage(t *testing.T, width, height int, fill color.Color) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, fill)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessGeneratesVariants(t *testing.T) {
+	result, err := Process(pngImage(t, 2000, 1000, color.NRGBA{R: 200, A: 255}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ContentType != "image/png" || result.Width != 2000 || result.Height != 1000 {
+		t.Fatalf("original: %s %dx%d", result.ContentType, result.Width, result.Height)
+	}
+
+	// Image opaque: variantes JPEG, plus grand côté ramené à la taille de la variante
+	for _, variant := range Variants {
+		encoded, ok := result.Variants[variant.Name]
+		if !ok {
+			t.Fatalf("variante %s absente", variant.Name)
+		}
+		if encoded.ContentType != "image/jpeg" || encoded.Extension != ".jpg" {
+			t.Fatalf("variante %s: %s", variant.Name, encoded.ContentType)
+		}
+		config, _, err := image.DecodeConfig(bytes.NewReader(encoded.Data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Width != variant.MaxSize || config.Height != variant.MaxSize/2 {
+			t.Fatalf("variante %s: %dx%d", variant.Name, config.Width, config.Height)
+		}
+	}
+}
+
+func TestProcessKeepsTransparencyAndSmallImages(t *testing.T) {
+	result, err := Process(pngImage(t, 100, 40, color.NRGBA{B: 255, A: 100}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, variant := range Variants {
+		encoded := result.Variants[variant.Name]
+		if encoded.ContentType != "image/png" {
+			t.Fatalf("variante %s: %s, attendu image/png (transparence)", variant.Name, encoded.ContentType)
+		}
+		config, err := png.DecodeConfig(bytes.NewReader(encoded.Data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Width != 100 || config.Height != 40 {
+			t.Fatalf("variante %s agrandie: %dx%d", variant.Name, config.Width, config.Height)
+		}
+	}
+}
+
+func TestProcessRejectsUnsupportedContent(t *testing.T) {
+	if _, err := Process([]byte("<html><body>pas une image</body></html>")); !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("erreur %v, attendu ErrUnsupportedType", err)
+	}
+
+	// En-tête PNG valide, données tronquées
+	data := pngImage(t, 10, 10, color.White)
+	if _, err := Process(data[:40]); err == nil {
+		t.Fatal("image tronquée acceptée")
+	}
+}
+
+func TestProcessRejectsTooManyPixelsBeforeDecoding(t *testing.T) {
+	// Petit fichier dont l'en-tête IHDR annonce 100000 x 100000 pixels (CRC recalculé):
+	// le décodage allouerait des dizaines de Go
+	data := pngImage(t, 1, 1, color.White)
+	binary.BigEndian.PutUint32(data[16:20], 100000)
+	binary.BigEndian.PutUint32(data[20:24], 100000)
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+
+	if _, err := Process(data); !errors.Is(err, ErrTooManyPixels) {
+		t.Fatalf("erreur %v, attendu ErrTooManyPixels", err)
+	}
+}
\ No newline at end of file
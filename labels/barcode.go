@@ -0,0 +1,182 @@
+package labels
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/ean"
+	"github.com/boombuler/barcode/qr"
+)
+
+// Symbology - Type de code-barres
+type Symbology string
+
+const (
+	Code128 Symbology = "code128"
+	EAN13   Symbology = "ean13"
+	QR      Symbology = "qr"
+)
+
+// Zones de silence (en modules) exigées par les lecteurs
+const (
+	linearQuietZone = 10
+	qrQuietZone     = 4
+)
+
+// Symbol - Code-barres encodé, indépendant du format de sortie
+type Symbol struct {
+	Symbology Symbology
+	Content   string
+	code      barcode.Barcode
+}
+
+// Encode encode un contenu dans la symbologie demandée
+func Encode(symbology Symbology, content string) (*Symbol, error) {
+	if content == "" {
+		return nil, errors.New("contenu vide")
+	}
+
+	var (
+		code barcode.Barcode
+		err  error
+	)
+	switch symbology {
+	case Code128:
+		code, err = code128.Encode(content)
+	case EAN13:
+		// ean.Encode accepte aussi les EAN-8; un UPC-A est complété en EAN-13
+		if len(content) == 12 {
+			content = "0" + content
+		}
+		code, err = ean.Encode(content)
+	case QR:
+		code, err = qr.Encode(content, qr.M, qr.Auto)
+	default:
+		return nil, fmt.Errorf("symbologie inconnue: %s", symbology)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encodage %s impossible: %w", symbology, err)
+	}
+
+	return &Symbol{Symbology: symbology, Content: content, code: code}, nil
+}
+
+// is2D indique un code matriciel (QR) plutôt que linéaire
+func (s *Symbol) is2D() bool {
+	return s.code.Metadata().Dimensions == 2
+}
+
+// modules retourne la taille du symbole en modules, zone de silence comprise
+func (s *Symbol) modules() (width, height, quiet int) {
+	bounds := s.code.Bounds()
+	if s.is2D() {
+		return bounds.Dx() + 2*qrQuietZone, bounds.Dy() + 2*qrQuietZone, qrQuietZone
+	}
+	return bounds.Dx() + 2*linearQuietZone, 1, linearQuietZone
+}
+
+// dark indique si le module (x, y) du symbole (hors zone de silence) est noir
+func (s *Symbol) dark(x, y int) bool {
+	bounds := s.code.Bounds()
+	r, _, _, _ := s.code.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+	return r < 0x8000
+}
+
+// Image dessine le symbole: moduleSize pixels par module, height pixels de haut pour un code linéaire
+func (s *Symbol) Image(moduleSize, height int) *image.Gray {
+	if moduleSize < 1 {
+		moduleSize = 1
+	}
+	width, rows, quiet := s.modules()
+
+	pixelHeight := height
+	if s.is2D() {
+		pixelHeight = rows * moduleSize
+	}
+	img := image.NewGray(image.Rect(0, 0, width*moduleSize, pixelHeight))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+
+	bounds := s.code.Bounds()
+	for y := 0; y < pixelHeight; y++ {
+		my := 0
+		if s.is2D() {
+			my = y/moduleSize - quiet
+			if my < 0 || my >= bounds.Dy() {
+				continue
+			}
+		}
+		for mx := 0; mx < bounds.Dx(); mx++ {
+			if !s.dark(mx, my) {
+				continue
+			}
+			for px := 0; px < moduleSize; px++ {
+				img.SetGray((mx+quiet)*moduleSize+px, y, color.Gray{Y: 0})
+			}
+		}
+	}
+	return img
+}
+
+// WritePNG écrit le symbole au format PNG
+func (s *Symbol) WritePNG(w io.Writer, moduleSize, height int) error {
+	return png.Encode(w, s.Image(moduleSize, height))
+}
+
+// WriteSVG écrit le symbole au format SVG (un rectangle par suite de modules noirs)
+func (s *Symbol) WriteSVG(w io.Writer, moduleSize, height int) error {
+	if moduleSize < 1 {
+		moduleSize = 1
+	}
+	width, rows, quiet := s.modules()
+
+	// Unité du viewBox = 1 module; un code linéaire est étiré à la hauteur demandée
+	viewHeight := rows
+	pixelHeight := rows * moduleSize
+	if !s.is2D() {
+		viewHeight, pixelHeight = height, height
+	}
+
+	if _, err := fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" preserveAspectRatio="none" shape-rendering="crispEdges">`+
+		`<rect width="100%%" height="100%%" fill="#fff"/><g fill="#000">`,
+		width*moduleSize, pixelHeight, width, viewHeight); err != nil {
+		return err
+	}
+
+	bounds := s.code.Bounds()
+	symbolRows := 1
+	if s.is2D() {
+		symbolRows = bounds.Dy()
+	}
+	for my := 0; my < symbolRows; my++ {
+		for mx := 0; mx < bounds.Dx(); {
+			if !s.dark(mx, my) {
+				mx++
+				continue
+			}
+			run := 1
+			for mx+run < bounds.Dx() && s.dark(mx+run, my) {
+				run++
+			}
+
+			y, h := my+quiet, 1
+			if !s.is2D() {
+				y, h = 0, viewHeight
+			}
+			if _, err := fmt.Fprintf(w, `<rect x="%d" y="%d" width="%d" height="%d"/>`, mx+quiet, y, run, h); err != nil {
+				return err
+			}
+			mx += run
+		}
+	}
+
+	_, err := io.WriteString(w, `</g></svg>`)
+	return err
+}
\ No newline at end of file
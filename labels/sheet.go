@@ -0,0 +1,107 @@
+package labels
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// Label - Étiquette de rayon: nom, prix et code-barres
+type Label struct {
+	Name   string
+	Price  string
+	Symbol *Symbol
+}
+
+// Planche A4 de 3 x 8 étiquettes (format 63,5 x 33,9 mm courant)
+const (
+	sheetColumns = 3
+	sheetRows    = 8
+	labelWidth   = 63.5
+	labelHeight  = 33.9
+	marginLeft   = 7.2
+	marginTop    = 12.9
+	labelPadding = 2.0
+)
+
+// WriteSheet génère une planche PDF d'étiquettes de rayon
+func WriteSheet(w io.Writer, labels []Label) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetMargins(0, 0, 0)
+	tr := pdf.UnicodeTranslatorFromDescriptor("") // Polices standard en cp1252 (accents)
+
+	perPage := sheetColumns * sheetRows
+	for i, label := range labels {
+		if i%perPage == 0 {
+			pdf.AddPage()
+		}
+		slot := i % perPage
+		x := marginLeft + float64(slot%sheetColumns)*labelWidth
+		y := marginTop + float64(slot/sheetColumns)*labelHeight
+
+		// Cadre de découpe
+		pdf.SetDrawColor(200, 200, 200)
+		pdf.Rect(x, y, labelWidth, labelHeight, "D")
+
+		inner := labelWidth - 2*labelPadding
+
+		// Nom du produit
+		pdf.SetFont("Helvetica", "B", 8)
+		pdf.SetXY(x+labelPadding, y+labelPadding)
+		pdf.CellFormat(inner, 4, tr(truncate(pdf, tr, label.Name, inner)), "", 0, "L", false, 0, "")
+
+		// Prix
+		pdf.SetFont("Helvetica", "B", 14)
+		pdf.SetXY(x+labelPadding, y+labelPadding+4.5)
+		pdf.CellFormat(inner, 7, tr(label.Price), "", 0, "L", false, 0, "")
+
+		// Code-barres
+		if label.Symbol != nil {
+			if err := placeSymbol(pdf, fmt.Sprintf("label-%d", i), label.Symbol, x+labelPadding, y+13, inner, labelHeight-13-labelPadding-3); err != nil {
+				return err
+			}
+			pdf.SetFont("Helvetica", "", 6)
+			pdf.SetXY(x+labelPadding, y+labelHeight-labelPadding-2.5)
+			pdf.CellFormat(inner, 2.5, label.Symbol.Content, "", 0, "C", false, 0, "")
+		}
+	}
+
+	if len(labels) == 0 {
+		pdf.AddPage()
+	}
+	return pdf.Output(w)
+}
+
+// placeSymbol insère le symbole centré dans la zone, en conservant le ratio des codes QR
+func placeSymbol(pdf *fpdf.Fpdf, name string, symbol *Symbol, x, y, maxWidth, maxHeight float64) error {
+	var buf bytes.Buffer
+	if err := symbol.WritePNG(&buf, 4, 120); err != nil {
+		return err
+	}
+	pdf.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: "PNG"}, &buf)
+	if err := pdf.Error(); err != nil {
+		return err
+	}
+
+	width, height := maxWidth, maxHeight
+	if symbol.is2D() {
+		width = maxHeight
+	}
+	pdf.ImageOptions(name, x+(maxWidth-width)/2, y, width, height, false, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+	return nil
+}
+
+// truncate raccourcit un texte pour tenir dans la largeur donnée
+func truncate(pdf *fpdf.Fpdf, tr func(string) string, text string, width float64) string {
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(tr(string(runes))) > width {
+		runes = runes[:len(runes)-1]
+	}
+	if len(runes) < len([]rune(text)) && len(runes) > 1 {
+		return string(runes[:len(runes)-1]) + "…"
+	}
+	return string(runes)
+}
\ No newline at end of file
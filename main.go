@@ -3,7 +3,9 @@ package main
 import (
 	"electronic-shop-api/config"
 	"electronic-shop-api/database"
+	"electronic-shop-api/handlers"
 	"electronic-shop-api/routes"
+	"electronic-shop-api/storage"
 	"log"
 
 	"github.com/gin-gonic/gin"
@@ -20,6 +22,21 @@ func main() {
 	database.Connect()
 	log.Println("✅ Base de données connectée")
 
+	// Stockage des fichiers (images produits, justificatifs de dépenses)
+	storage.Init()
+
+	// Démarrer le planificateur des changements de prix programmés
+	handlers.StartPriceScheduler()
+
+	// Démarrer le planificateur des dépenses récurrentes
+	handlers.StartRecurringExpenseScheduler()
+
+	// Démarrer l'expiration des points fidélité
+	handlers.StartLoyaltyScheduler()
+
+	// Démarrer l'expiration des cartes cadeaux et avoirs
+	handlers.StartGiftCardScheduler()
+
 	// Créer le routeur Gin
 	router := gin.Default()
 
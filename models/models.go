@@ -1,8 +1,14 @@
 package models
 
 import (
+	"fmt"
+	"math"
 	"net/url"
+	"strings"
 	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 // ========================================
@@ -14,6 +20,13 @@ type Shop struct {
 	Active         bool      `gorm:"default:true" json:"active"`
 	WhatsAppNumber string    `gorm:"not null" json:"whatsapp_number"`
 	CreatedAt      time.Time `json:"created_at"`
+
+	PricesIncludeTax bool   `gorm:"default:true" json:"prices_include_tax"` // Prix de vente TTC (sinon HT, taxe ajoutée à la vente)
+	Currency         string `gorm:"default:MAD" json:"currency"`            // Code ISO 4217 des montants du shop
+	Timezone         string `json:"timezone"`                               // Fuseau IANA des rapports (vide: fuseau du serveur)
+
+	LayawayCancellationFee float64 `json:"layaway_cancellation_fee"`                             // Frais d'annulation d'une vente à tempérament (% du montant, retenus sur les versements)
+	GiftCardValidityMonths int     `gorm:"not null;default:12" json:"gift_card_validity_months"` // Validité des cartes cadeaux et avoirs (0: sans expiration)
 }
 
 // ========================================
@@ -43,43 +56,222 @@ type Product struct {
 	ID            uint      `gorm:"primaryKey" json:"id"`
 	Name          string    `gorm:"not null" json:"name"`
 	Description   string    `json:"description"`
-	Category      string    `json:"category"`
-	PurchasePrice float64   `gorm:"not null" json:"purchase_price,omitempty"`
-	SellingPrice  float64   `gorm:"not null" json:"selling_price"`
+	Category      string    `json:"category"` // Nom de la catégorie (dénormalisé depuis CategoryID)
+	CategoryID    *uint     `gorm:"index" json:"category_id"`
+	SKU           *string   `gorm:"uniqueIndex:idx_shop_sku" json:"sku"`         // Référence interne, unique par shop
+	Barcode       *string   `gorm:"uniqueIndex:idx_shop_barcode" json:"barcode"` // GTIN/EAN/UPC, unique par shop
+	PurchasePrice Money     `gorm:"not null" json:"purchase_price,omitempty"`
+	SellingPrice  Money     `gorm:"not null" json:"selling_price"`
 	Stock         int       `gorm:"default:0" json:"stock"`
+	Reserved      int       `gorm:"default:0" json:"reserved"` // Unités réservées par des ventes à tempérament (non vendables)
 	ImageURL      string    `json:"image_url"`
-	ShopID        uint      `gorm:"not null" json:"shop_id"`
+	ShopID        uint      `gorm:"not null;uniqueIndex:idx_shop_sku;uniqueIndex:idx_shop_barcode" json:"shop_id"`
 	CreatedAt     time.Time `json:"created_at"`
+
+	PriceUpdatedAt   *time.Time `json:"price_updated_at,omitempty"`         // Dernier changement de SellingPrice
+	ArchivedAt       *time.Time `gorm:"index" json:"archived_at,omitempty"` // Produit archivé: masqué du public et des listes
+	TaxRateID        *uint      `gorm:"index" json:"tax_rate_id"`           // Nil: taux par défaut du shop
+	PurchaseCurrency string     `json:"purchase_currency,omitempty"`        // Devise de PurchasePrice (vide: devise du shop)
+
+	Attributes []ProductAttribute `gorm:"foreignKey:ProductID" json:"attributes,omitempty"`
+	Images     []ProductImage     `gorm:"foreignKey:ProductID" json:"images,omitempty"`
 }
 
 // ProductPublic - Version publique sans PurchasePrice
 type ProductPublic struct {
-	ID           uint    `json:"id"`
-	Name         string  `json:"name"`
-	Description  string  `json:"description"`
-	Category     string  `json:"category"`
-	SellingPrice float64 `json:"selling_price"`
-	Stock        int     `json:"stock"`
-	ImageURL     string  `json:"image_url"`
-	InStock      bool    `json:"in_stock"`
-	WhatsAppLink string  `json:"whatsapp_link"`
+	ID           uint          `json:"id"`
+	Name         string        `json:"name"`
+	Description  string        `json:"description"`
+	Category     string        `json:"category"`
+	CategoryID   *uint         `json:"category_id"`
+	SellingPrice Money         `json:"selling_price"`
+	Stock        int           `json:"stock"`
+	ImageURL     string        `json:"image_url"`
+	InStock      bool          `json:"in_stock"`
+	WhatsAppLink string        `json:"whatsapp_link"`
+	Specs        []ProductSpec `json:"specs"`
+	Images       []ImageURLs   `json:"images"`
+	Coupon       *CouponPrice  `json:"coupon,omitempty"` // Code promo validé (?coupon=)
+}
+
+// CouponPrice - Prix remisé d'un produit avec un code promo (catalogue public)
+type CouponPrice struct {
+	Code            string `json:"code"`
+	Discount        Money  `json:"discount"`
+	DiscountedPrice Money  `json:"discounted_price"`
 }
 
 // ToPublic convertit un Product en ProductPublic
 func (p *Product) ToPublic(whatsappNumber string) ProductPublic {
+	imageURL := p.ImageURL
+	images := make([]ImageURLs, 0, len(p.Images))
+	for _, img := range p.Images {
+		images = append(images, img.URLs())
+	}
+	if len(images) > 0 {
+		imageURL = images[0].Medium
+	}
+	available := p.Stock - p.Reserved // Unités réservées par des ventes à tempérament non vendables
+
 	return ProductPublic{
 		ID:           p.ID,
 		Name:         p.Name,
 		Description:  p.Description,
 		Category:     p.Category,
+		CategoryID:   p.CategoryID,
 		SellingPrice: p.SellingPrice,
-		Stock:        p.Stock,
-		ImageURL:     p.ImageURL,
-		InStock:      p.Stock > 0,
+		Stock:        available,
+		ImageURL:     imageURL,
+		InStock:      available > 0,
 		WhatsAppLink: GenerateWhatsAppLink(whatsappNumber, p.Name),
+		Specs:        p.Specs(),
+		Images:       images,
 	}
 }
 
+// Specs retourne la fiche technique du produit (attributs préchargés)
+func (p *Product) Specs() []ProductSpec {
+	specs := make([]ProductSpec, 0, len(p.Attributes))
+	for _, a := range p.Attributes {
+		if a.Attribute == nil {
+			continue
+		}
+		specs = append(specs, ProductSpec{
+			Name:  a.Attribute.Name,
+			Value: a.Value,
+			Unit:  a.Attribute.Unit,
+		})
+	}
+	return specs
+}
+
+// ========================================
+// 🖼️ PRODUCT IMAGE - Images envoyées et miniatures
+// ========================================
+type ProductImage struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	ProductID    uint      `gorm:"not null;index" json:"product_id"`
+	ShopID       uint      `gorm:"not null" json:"-"`
+	Position     int       `gorm:"default:0" json:"position"`
+	StorageKeys  []string  `gorm:"serializer:json" json:"-"` // Original et miniatures dans le stockage
+	ContentType  string    `json:"content_type"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Size         int64     `json:"size"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	MediumURL    string    `json:"medium_url"`
+	LargeURL     string    `json:"large_url"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ImageURLs - URLs d'une image dans les différentes tailles
+type ImageURLs struct {
+	Thumbnail string `json:"thumbnail"`
+	Medium    string `json:"medium"`
+	Large     string `json:"large"`
+	Original  string `json:"original"`
+}
+
+// URLs retourne les URLs de l'image par taille
+func (i ProductImage) URLs() ImageURLs {
+	return ImageURLs{
+		Thumbnail: i.ThumbnailURL,
+		Medium:    i.MediumURL,
+		Large:     i.LargeURL,
+		Original:  i.URL,
+	}
+}
+
+// ========================================
+// 🏷️ CODES-BARRES (GTIN / EAN / UPC)
+// ========================================
+
+// InternalBarcodePrefix - Préfixe GS1 "2" réservé aux codes internes au magasin
+const InternalBarcodePrefix = "2"
+
+// GTINCheckDigit calcule le chiffre de contrôle GS1 d'un code sans sa clé
+func GTINCheckDigit(digits string) byte {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		// Depuis la droite, les positions impaires sont pondérées par 3
+		if (len(digits)-1-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// IsValidGTIN vérifie un GTIN-8, UPC-A (12), EAN-13 ou GTIN-14 avec sa clé de contrôle
+func IsValidGTIN(code string) bool {
+	switch len(code) {
+	case 8, 12, 13, 14:
+	default:
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return GTINCheckDigit(code[:len(code)-1]) == code[len(code)-1]
+}
+
+// InternalBarcode génère un EAN-13 interne unique pour un produit: 2 + shop (4) + produit (7) + clé
+func InternalBarcode(shopID, productID uint) string {
+	digits := fmt.Sprintf("%s%04d%07d", InternalBarcodePrefix, shopID%10000, productID%10000000)
+	return digits + string(GTINCheckDigit(digits))
+}
+
+// ========================================
+// 🗂️ CATEGORY - Arborescence des catégories
+// ========================================
+type Category struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"not null" json:"name"`
+	Slug      string    `gorm:"not null;uniqueIndex:idx_shop_category_slug" json:"slug"`
+	ParentID  *uint     `gorm:"index" json:"parent_id"`
+	Position  int       `gorm:"default:0" json:"position"` // Ordre d'affichage parmi les sœurs
+	ImageURL  string    `json:"image_url"`
+	ShopID    uint      `gorm:"not null;uniqueIndex:idx_shop_category_slug" json:"shop_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Slugify transforme un nom en identifiant d'URL ("Téléphones & Tablettes" -> "telephones-tablettes")
+func Slugify(name string) string {
+	decomposed := norm.NFD.String(strings.ToLower(strings.TrimSpace(name)))
+
+	var b strings.Builder
+	dash := false
+	for _, r := range decomposed {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Accents supprimés
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+			dash = false
+		case !dash && b.Len() > 0:
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// CategoryKey retourne la clé de rapprochement d'un nom de catégorie:
+// "Phones", "phone" et " PHONES " donnent la même clé
+func CategoryKey(name string) string {
+	parts := strings.Split(Slugify(name), "-")
+	for i, part := range parts {
+		if len(part) > 3 && strings.HasSuffix(part, "s") && !strings.HasSuffix(part, "ss") {
+			parts[i] = strings.TrimSuffix(part, "s")
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
 // ========================================
 // 💰 TRANSACTION
 // ========================================
@@ -89,17 +281,43 @@ const (
 	TypeSale       TransactionType = "Sale"
 	TypeExpense    TransactionType = "Expense"
 	TypeWithdrawal TransactionType = "Withdrawal"
+	TypeRefund     TransactionType = "Refund" // Remboursement (retour) d'une vente
 )
 
 type Transaction struct {
-	ID        uint            `gorm:"primaryKey" json:"id"`
-	Type      TransactionType `gorm:"not null" json:"type"`
-	ProductID *uint           `json:"product_id,omitempty"`
-	Quantity  int             `json:"quantity"`
-	Amount    float64         `gorm:"not null" json:"amount"`
-	ShopID    uint            `gorm:"not null" json:"shop_id"`
-	CreatedAt time.Time       `json:"created_at"`
-	Product   *Product        `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	ID                 uint                   `gorm:"primaryKey" json:"id"`
+	Type               TransactionType        `gorm:"not null" json:"type"`
+	ProductID          *uint                  `json:"product_id,omitempty"`
+	Quantity           int                    `json:"quantity"`
+	Subtotal           Money                  `json:"subtotal,omitempty"` // Vente: SellingPrice * Quantity avant remises
+	Discount           Money                  `json:"discount,omitempty"` // Vente: total des remises appliquées
+	Amount             Money                  `gorm:"not null" json:"amount"`
+	CouponCode         string                 `json:"coupon_code,omitempty"`               // Code promo saisi à la vente
+	CouponDiscount     Money                  `json:"coupon_discount,omitempty"`           // Part de Discount due au code promo
+	TaxRate            float64                `json:"tax_rate,omitempty"`                  // Taux appliqué (%)
+	TaxAmount          Money                  `json:"tax_amount,omitempty"`                // Vente: taxe collectée, dépense: taxe déductible, remboursement: taxe reversée au client
+	Cost               Money                  `json:"-"`                                   // Vente: coût d'achat en devise du shop (au jour de la vente), remboursement: coût des articles retournés
+	Currency           string                 `json:"currency,omitempty"`                  // Dépense en devise étrangère: devise saisie
+	OriginalAmount     Money                  `json:"original_amount,omitempty"`           // Montant saisi dans Currency (Amount: converti)
+	ExchangeRate       float64                `json:"exchange_rate,omitempty"`             // Taux appliqué à la date de la transaction
+	RefundOfID         *uint                  `gorm:"index" json:"refund_of_id,omitempty"` // Remboursement: vente remboursée
+	ExpenseCategory    string                 `json:"expense_category,omitempty"`          // Dépense: nom de la catégorie (dénormalisé)
+	ExpenseCategoryID  *uint                  `gorm:"index" json:"expense_category_id,omitempty"`
+	Description        string                 `json:"description,omitempty"`                              // Dépense: libellé
+	Vendor             string                 `json:"vendor,omitempty"`                                   // Dépense: fournisseur
+	RecurringExpenseID *uint                  `json:"recurring_expense_id,omitempty"`                     // Dépense enregistrée par un modèle récurrent
+	RegisterSessionID  *uint                  `gorm:"index" json:"register_session_id,omitempty"`         // Session de caisse ouverte lors de l'enregistrement
+	CustomerID         *uint                  `gorm:"index" json:"customer_id,omitempty"`                 // Vente ou remboursement: client
+	OnAccount          bool                   `gorm:"not null;default:false" json:"on_account,omitempty"` // Vente à crédit (réglée plus tard)
+	AmountDue          Money                  `json:"amount_due,omitempty"`                               // Vente à crédit: reste dû; remboursement: part imputée sur le reste dû de la vente
+	UserID             *uint                  `json:"user_id,omitempty"`                                  // Employé ayant enregistré la transaction
+	ShopID             uint                   `gorm:"not null;index:idx_transactions_shop_date,priority:1" json:"shop_id"`
+	CreatedAt          time.Time              `gorm:"index:idx_transactions_shop_date,priority:2" json:"created_at"`
+	Product            *Product               `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	Promotions         []TransactionPromotion `gorm:"foreignKey:TransactionID" json:"promotions,omitempty"`
+	Attachments        []ExpenseAttachment    `gorm:"foreignKey:TransactionID" json:"attachments,omitempty"`
+	Payments           []Payment              `gorm:"foreignKey:TransactionID" json:"payments,omitempty"`
+	Customer           *Customer              `gorm:"foreignKey:CustomerID" json:"customer,omitempty"`
 }
 
 // ========================================
@@ -109,4 +327,651 @@ func GenerateWhatsAppLink(whatsappNumber string, productName string) string {
 	message := "Bonjour je veux plus d'information sur " + productName
 	encodedMessage := url.QueryEscape(message)
 	return "https://wa.me/" + whatsappNumber + "?text=" + encodedMessage
+}
+
+// GenerateWhatsAppCouponLink - Lien WhatsApp mentionnant le code promo et le prix remisé
+func GenerateWhatsAppCouponLink(whatsappNumber string, productName string, code string, price Money) string {
+	message := fmt.Sprintf("Bonjour je veux plus d'information sur %s avec le code %s (prix remisé: %s)", productName, code, price)
+	encodedMessage := url.QueryEscape(message)
+	return "https://wa.me/" + whatsappNumber + "?text=" + encodedMessage
+}
+
+// ========================================
+// 🔧 FICHE TECHNIQUE - Attributs par catégorie
+// ========================================
+type AttributeType string
+
+const (
+	AttributeNumber  AttributeType = "number"
+	AttributeText    AttributeType = "text"
+	AttributeBoolean AttributeType = "boolean"
+	AttributeEnum    AttributeType = "enum"
+)
+
+// CategoryAttribute définit un attribut technique (RAM, stockage, écran...) pour une catégorie.
+// Les sous-catégories héritent des attributs de leurs catégories parentes.
+type CategoryAttribute struct {
+	ID            uint          `gorm:"primaryKey" json:"id"`
+	CategoryID    uint          `gorm:"not null;default:0;uniqueIndex:idx_category_attribute_name" json:"category_id"`
+	Name          string        `gorm:"not null;uniqueIndex:idx_category_attribute_name" json:"name"`
+	Type          AttributeType `gorm:"not null" json:"type"`
+	Unit          string        `json:"unit"`
+	AllowedValues []string      `gorm:"serializer:json" json:"allowed_values,omitempty"`
+	Required      bool          `gorm:"default:false" json:"required"`
+	ShopID        uint          `gorm:"not null;index" json:"shop_id"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+// ProductAttribute stocke la valeur d'un attribut pour un produit
+type ProductAttribute struct {
+	ID          uint               `gorm:"primaryKey" json:"-"`
+	ProductID   uint               `gorm:"not null;uniqueIndex:idx_product_attribute" json:"-"`
+	AttributeID uint               `gorm:"not null;uniqueIndex:idx_product_attribute" json:"attribute_id"`
+	Value       string             `gorm:"not null" json:"value"`
+	NumberValue *float64           `json:"-"` // Renseigné pour les attributs "number" (filtres min/max)
+	Attribute   *CategoryAttribute `gorm:"foreignKey:AttributeID" json:"attribute,omitempty"`
+}
+
+// ProductSpec - Ligne de fiche technique exposée au public
+type ProductSpec struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Unit  string `json:"unit,omitempty"`
+}
+
+// ========================================
+// 📥 IMPORT - Import de produits en arrière-plan
+// ========================================
+type ImportStatus string
+
+const (
+	ImportPending   ImportStatus = "pending"
+	ImportRunning   ImportStatus = "running"
+	ImportCompleted ImportStatus = "completed"
+	ImportFailed    ImportStatus = "failed"
+)
+
+// ImportJob suit la progression d'un import CSV/XLSX (consulté par polling)
+type ImportJob struct {
+	ID         uint             `gorm:"primaryKey" json:"id"`
+	FileName   string           `json:"file_name"`
+	Status     ImportStatus     `gorm:"not null;index" json:"status"`
+	Total      int              `json:"total"`
+	Processed  int              `json:"processed"`
+	Created    int              `json:"created"`
+	Updated    int              `json:"updated"`
+	Failed     int              `json:"failed"`
+	Errors     []ImportRowError `gorm:"serializer:json" json:"errors"`
+	Message    string           `json:"message,omitempty"`
+	UserID     uint             `json:"user_id"`
+	ShopID     uint             `gorm:"not null;index" json:"shop_id"`
+	CreatedAt  time.Time        `json:"created_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+}
+
+// ImportRowError - Erreur de validation d'une ligne (numéro de ligne du fichier, en-tête = 1)
+type ImportRowError struct {
+	Row    int    `json:"row"`
+	Column string `json:"column,omitempty"`
+	Error  string `json:"error"`
+}
+
+// ========================================
+// 📈 PRIX - Historique et changements programmés
+// ========================================
+type PriceSource string
+
+const (
+	PriceSourceManual   PriceSource = "manual"   // PUT /products/:id
+	PriceSourceImport   PriceSource = "import"   // Import CSV/XLSX
+	PriceSourceBulk     PriceSource = "bulk"     // Opération en masse
+	PriceSourceSchedule PriceSource = "schedule" // Changement programmé (application ou retour)
+)
+
+// PriceHistory conserve chaque changement de prix d'un produit
+type PriceHistory struct {
+	ID         uint        `gorm:"primaryKey" json:"id"`
+	ProductID  uint        `gorm:"not null;index" json:"product_id"`
+	Field      string      `gorm:"not null" json:"field"` // selling_price ou purchase_price
+	OldPrice   Money       `json:"old_price"`
+	NewPrice   Money       `json:"new_price"`
+	Source     PriceSource `gorm:"not null" json:"source"`
+	ScheduleID *uint       `json:"schedule_id,omitempty"`
+	UserID     *uint       `json:"user_id,omitempty"`
+	ShopID     uint        `gorm:"not null;index" json:"shop_id"`
+	CreatedAt  time.Time   `gorm:"index" json:"created_at"`
+}
+
+type PriceScheduleStatus string
+
+const (
+	ScheduleScheduled PriceScheduleStatus = "scheduled" // En attente de StartsAt
+	ScheduleActive    PriceScheduleStatus = "active"    // Prix appliqué, retour prévu à EndsAt
+	ScheduleCompleted PriceScheduleStatus = "completed"
+	ScheduleCancelled PriceScheduleStatus = "cancelled"
+	ScheduleFailed    PriceScheduleStatus = "failed"
+)
+
+// PriceSchedule - Prix de vente appliqué automatiquement entre StartsAt et EndsAt
+// (sans EndsAt, le changement est définitif)
+type PriceSchedule struct {
+	ID            uint                `gorm:"primaryKey" json:"id"`
+	ProductID     uint                `gorm:"not null;index" json:"product_id"`
+	Label         string              `json:"label"` // Ex: "Black Friday"
+	SellingPrice  Money               `gorm:"not null" json:"selling_price"`
+	StartsAt      time.Time           `gorm:"not null;index" json:"starts_at"`
+	EndsAt        *time.Time          `gorm:"index" json:"ends_at,omitempty"`
+	Status        PriceScheduleStatus `gorm:"not null;index" json:"status"`
+	PreviousPrice *Money              `json:"previous_price,omitempty"` // Prix restauré à EndsAt
+	AppliedAt     *time.Time          `json:"applied_at,omitempty"`
+	RevertedAt    *time.Time          `json:"reverted_at,omitempty"`
+	Message       string              `json:"message,omitempty"`
+	CreatedBy     uint                `json:"created_by"`
+	ShopID        uint                `gorm:"not null;index" json:"shop_id"`
+	CreatedAt     time.Time           `json:"created_at"`
+}
+
+// ========================================
+// 🎁 PROMOTIONS - Remises appliquées à la vente
+// ========================================
+type PromotionType string
+
+const (
+	PromotionPercent  PromotionType = "percent"     // Value % de remise
+	PromotionFixed    PromotionType = "fixed"       // Value de remise sur la vente
+	PromotionBuyXGetY PromotionType = "buy_x_get_y" // BuyQuantity achetés, FreeQuantity offerts
+	PromotionBundle   PromotionType = "bundle"      // BundleQuantity unités pour Value
+)
+
+// Promotion - Remise du shop, limitée à un produit ou une catégorie (sous-catégories incluses)
+// et éventuellement à une période et un montant minimum
+type Promotion struct {
+	ID             uint          `gorm:"primaryKey" json:"id"`
+	Name           string        `gorm:"not null" json:"name"`
+	Type           PromotionType `gorm:"not null" json:"type"`
+	Value          Money         `json:"value"` // percent: pourcentage (au centième), fixed: remise, bundle: prix du lot
+	BuyQuantity    int           `json:"buy_quantity,omitempty"`
+	FreeQuantity   int           `json:"free_quantity,omitempty"`
+	BundleQuantity int           `json:"bundle_quantity,omitempty"`
+	ProductID      *uint         `gorm:"index" json:"product_id,omitempty"`  // Nil: tous les produits
+	CategoryID     *uint         `gorm:"index" json:"category_id,omitempty"` // Nil: toutes les catégories
+	MinBasket      Money         `json:"min_basket"`                         // Montant minimum de la vente avant remise
+	StartsAt       *time.Time    `json:"starts_at,omitempty"`
+	EndsAt         *time.Time    `json:"ends_at,omitempty"`
+	Stackable      bool          `json:"stackable"` // Cumulable avec les autres promotions
+	Active         bool          `json:"active"`
+	ShopID         uint          `gorm:"not null;index" json:"shop_id"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// TransactionPromotion - Promotion appliquée à une vente (nom conservé si la promotion est supprimée)
+type TransactionPromotion struct {
+	ID            uint          `gorm:"primaryKey" json:"-"`
+	TransactionID uint          `gorm:"not null;index" json:"-"`
+	PromotionID   uint          `gorm:"index" json:"promotion_id"`
+	Name          string        `json:"name"`
+	Type          PromotionType `json:"type"`
+	Discount      Money         `json:"discount"`
+}
+
+// ========================================
+// 🎟️ CODES PROMO - Saisis à la vente
+// ========================================
+
+// Coupon - Code promo (pourcentage ou montant fixe) avec limites d'utilisation
+type Coupon struct {
+	ID                 uint          `gorm:"primaryKey" json:"id"`
+	Code               string        `gorm:"not null;uniqueIndex:idx_coupon_shop_code" json:"code"` // Majuscules
+	Description        string        `json:"description"`
+	Type               PromotionType `gorm:"not null" json:"type"`  // percent ou fixed
+	Value              Money         `gorm:"not null" json:"value"` // Pourcentage (au centième) ou montant de la remise
+	ProductID          *uint         `json:"product_id,omitempty"`
+	CategoryID         *uint         `json:"category_id,omitempty"`
+	MinBasket          Money         `json:"min_basket"`
+	MaxUses            int           `json:"max_uses"`              // 0: illimité, 1: usage unique
+	MaxUsesPerCustomer int           `json:"max_uses_per_customer"` // 0: illimité
+	Uses               int           `json:"uses"`
+	ExpiresAt          *time.Time    `json:"expires_at,omitempty"`
+	Active             bool          `json:"active"`
+	ShopID             uint          `gorm:"not null;uniqueIndex:idx_coupon_shop_code" json:"shop_id"`
+	CreatedAt          time.Time     `json:"created_at"`
+	UpdatedAt          time.Time     `json:"updated_at"`
+}
+
+// CouponRedemption - Utilisation d'un code promo lors d'une vente
+type CouponRedemption struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	CouponID      uint      `gorm:"not null;index" json:"coupon_id"`
+	Code          string    `json:"code"`
+	TransactionID uint      `gorm:"not null;index" json:"transaction_id"`
+	CustomerRef   string    `gorm:"index" json:"customer_ref,omitempty"` // Téléphone ou email normalisé
+	Discount      Money     `json:"discount"`
+	UserID        uint      `json:"user_id"`
+	ShopID        uint      `gorm:"not null;index" json:"shop_id"`
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`
+}
+
+// ========================================
+// 🧾 TAXES - Taux de TVA du shop
+// ========================================
+
+// TaxRate - Taux de taxe (classe fiscale) attribué aux produits
+type TaxRate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"not null" json:"name"` // Ex: "TVA 20%", "TVA réduite"
+	Rate      float64   `json:"rate"`                 // Pourcentage
+	IsDefault bool      `json:"is_default"`           // Taux des produits sans classe fiscale
+	ShopID    uint      `gorm:"not null;index" json:"shop_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TaxSplit calcule la taxe d'un montant selon que celui-ci inclut déjà la taxe ou non.
+// Retourne la taxe et le montant TTC.
+func TaxSplit(amount Money, rate float64, inclusive bool) (tax, total Money) {
+	if rate <= 0 {
+		return 0, amount
+	}
+	if inclusive {
+		tax = Money(math.Round(float64(amount) * rate / (100 + rate)))
+		return tax, amount
+	}
+	tax = amount.Percent(rate)
+	return tax, amount + tax
+}
+
+// ========================================
+// 💱 TAUX DE CHANGE - Devises étrangères du shop
+// ========================================
+
+// ExchangeRate - Valeur d'une unité de Currency dans la devise du shop, applicable à partir de Date
+type ExchangeRate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Currency  string    `gorm:"not null;uniqueIndex:idx_shop_currency_date" json:"currency"`
+	Date      string    `gorm:"size:10;not null;uniqueIndex:idx_shop_currency_date" json:"date"` // YYYY-MM-DD
+	Rate      float64   `gorm:"not null" json:"rate"`
+	Source    string    `json:"source"` // manual ou import
+	ShopID    uint      `gorm:"not null;uniqueIndex:idx_shop_currency_date" json:"shop_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const (
+	RateSourceManual = "manual"
+	RateSourceImport = "import"
+)
+
+// ========================================
+// 💸 DÉPENSES - Catégories, justificatifs et dépenses récurrentes
+// ========================================
+
+// DefaultExpenseCategories - Catégories de dépenses créées pour chaque shop
+var DefaultExpenseCategories = []string{
+	"Loyer", "Électricité", "Eau", "Salaires", "Transport",
+	"Internet & téléphone", "Fournitures", "Entretien", "Marketing", "Divers",
+}
+
+// ExpenseCategory - Catégorie de dépenses du shop
+type ExpenseCategory struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"not null" json:"name"`
+	Slug      string    `gorm:"not null;uniqueIndex:idx_shop_expense_category_slug" json:"slug"`
+	ShopID    uint      `gorm:"not null;uniqueIndex:idx_shop_expense_category_slug" json:"shop_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExpenseAttachment - Justificatif d'une dépense (reçu, facture). Fichier privé:
+// téléchargé via l'API après authentification, jamais par une URL publique.
+type ExpenseAttachment struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	TransactionID uint      `gorm:"not null;index" json:"transaction_id"`
+	FileName      string    `json:"file_name"`
+	ContentType   string    `json:"content_type"`
+	Size          int64     `json:"size"`
+	StorageKey    string    `gorm:"not null" json:"-"`
+	UserID        uint      `json:"user_id"`
+	ShopID        uint      `gorm:"not null;index" json:"shop_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// RecurringExpense - Modèle de dépense enregistrée automatiquement chaque mois
+type RecurringExpense struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	Name              string     `gorm:"not null" json:"name"`
+	Amount            Money      `gorm:"not null" json:"amount"`
+	TaxAmount         Money      `json:"tax_amount"` // Taxe déductible de chaque échéance
+	ExpenseCategoryID *uint      `json:"expense_category_id"`
+	ExpenseCategory   string     `json:"expense_category"` // Nom dénormalisé
+	Description       string     `json:"description"`
+	Vendor            string     `json:"vendor"`
+	DayOfMonth        int        `gorm:"not null" json:"day_of_month"` // 1 à 31 (dernier jour des mois plus courts)
+	NextRunAt         time.Time  `gorm:"index" json:"next_run_at"`
+	EndsAt            *time.Time `json:"ends_at,omitempty"`
+	Active            bool       `gorm:"not null" json:"active"`
+	LastPostedAt      *time.Time `json:"last_posted_at,omitempty"`
+	UserID            uint       `json:"user_id"`
+	ShopID            uint       `gorm:"not null;index" json:"shop_id"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// ========================================
+// 🧾 CAISSE - Sessions de caisse (ouverture, clôture, rapport Z)
+// ========================================
+
+type RegisterSessionStatus string
+
+const (
+	SessionOpen   RegisterSessionStatus = "open"
+	SessionClosed RegisterSessionStatus = "closed"
+)
+
+// DefaultRegister - Nom de la caisse quand le shop n'en précise pas
+const DefaultRegister = "Caisse principale"
+
+// RegisterSession - Session d'une caisse, de l'ouverture (fond de caisse compté)
+// à la clôture (espèces comptées, écart avec les espèces attendues)
+type RegisterSession struct {
+	ID           uint                  `gorm:"primaryKey" json:"id"`
+	Register     string                `gorm:"not null" json:"register"` // Nom de la caisse
+	Status       RegisterSessionStatus `gorm:"not null;index" json:"status"`
+	OpeningFloat Money                 `gorm:"not null" json:"opening_float"` // Fond de caisse compté à l'ouverture
+	ExpectedCash Money                 `json:"expected_cash"`                 // Fond + encaissements - décaissements (figé à la clôture)
+	CountedCash  *Money                `json:"counted_cash,omitempty"`        // Espèces comptées à la clôture
+	Variance     Money                 `json:"variance"`                      // Compté - attendu (négatif: manquant)
+	OpeningNote  string                `json:"opening_note,omitempty"`
+	ClosingNote  string                `json:"closing_note,omitempty"`
+	OpenedByID   uint                  `gorm:"not null" json:"opened_by_id"`
+	ClosedByID   *uint                 `json:"closed_by_id,omitempty"`
+	OpenedAt     time.Time             `gorm:"not null;index" json:"opened_at"`
+	ClosedAt     *time.Time            `json:"closed_at,omitempty"`
+	ShopID       uint                  `gorm:"not null;index" json:"shop_id"`
+}
+
+// ========================================
+// 💳 PAIEMENTS - Moyens de paiement et règlements
+// ========================================
+
+type PaymentKind string
+
+const (
+	PaymentCash         PaymentKind = "cash"
+	PaymentCard         PaymentKind = "card"
+	PaymentBankTransfer PaymentKind = "bank_transfer"
+	PaymentMobileMoney  PaymentKind = "mobile_money"
+	PaymentStoreCredit  PaymentKind = "store_credit"
+	PaymentLoyalty      PaymentKind = "loyalty_points" // Points fidélité du client (ventes uniquement)
+)
+
+// PaymentKinds - Types de moyens de paiement acceptés
+var PaymentKinds = []PaymentKind{PaymentCash, PaymentCard, PaymentBankTransfer, PaymentMobileMoney, PaymentStoreCredit, PaymentLoyalty}
+
+// NonCash indique un règlement qui n'est pas un encaissement: avoir ou carte cadeau
+// (encaissée à la vente de la carte) et points fidélité
+func (k PaymentKind) NonCash() bool {
+	return k == PaymentStoreCredit || k == PaymentLoyalty
+}
+
+// DefaultPaymentMethods - Moyens de paiement créés pour chaque shop
+var DefaultPaymentMethods = []PaymentMethod{
+	{Name: "Espèces", Kind: PaymentCash},
+	{Name: "Carte bancaire", Kind: PaymentCard},
+	{Name: "Virement", Kind: PaymentBankTransfer},
+	{Name: "Mobile money", Kind: PaymentMobileMoney},
+	{Name: "Avoir client", Kind: PaymentStoreCredit},
+}
+
+// PaymentMethod - Moyen de paiement du shop ("Orange Money" de type mobile_money, "TPE Visa" de type card...)
+type PaymentMethod struct {
+	ID        uint        `gorm:"primaryKey" json:"id"`
+	Name      string      `gorm:"not null" json:"name"`
+	Kind      PaymentKind `gorm:"not null" json:"kind"`
+	Active    bool        `gorm:"not null" json:"active"`
+	ShopID    uint        `gorm:"not null;index" json:"shop_id"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// Payment - Règlement d'une transaction par un moyen de paiement (plusieurs par vente),
+// ou versement d'une vente à tempérament pas encore soldée (TransactionID 0, LayawayID)
+type Payment struct {
+	ID                uint        `gorm:"primaryKey" json:"id"`
+	TransactionID     uint        `gorm:"not null;index" json:"transaction_id,omitempty"`
+	PaymentMethodID   uint        `gorm:"index" json:"payment_method_id"`
+	Method            PaymentKind `gorm:"not null" json:"method"`                     // Type du moyen de paiement (dénormalisé)
+	MethodName        string      `json:"method_name"`                                // Nom du moyen de paiement (dénormalisé)
+	Amount            Money       `gorm:"not null" json:"amount"`                     // Montant affecté à la transaction
+	Tendered          Money       `json:"tendered,omitempty"`                         // Espèces remises par le client
+	Change            Money       `json:"change,omitempty"`                           // Monnaie rendue (Tendered - Amount)
+	Reference         string      `json:"reference,omitempty"`                        // N° d'autorisation, de virement...
+	RegisterSessionID *uint       `gorm:"index" json:"register_session_id,omitempty"` // Session de caisse ayant reçu le règlement
+	LayawayID         *uint       `gorm:"index" json:"layaway_id,omitempty"`          // Versement d'une vente à tempérament (négatif: acompte rendu à l'annulation)
+	GiftCardID        *uint       `gorm:"index" json:"gift_card_id,omitempty"`        // Carte cadeau / avoir débité (vente) ou crédité (remboursement)
+	GiftCardSaleID    *uint       `gorm:"index" json:"gift_card_sale_id,omitempty"`   // Règlement de l'achat d'une carte cadeau
+	ShopID            uint        `gorm:"not null;index" json:"shop_id"`
+	CreatedAt         time.Time   `json:"created_at"`
+}
+
+// ValidPaymentKind vérifie un type de moyen de paiement
+func ValidPaymentKind(kind PaymentKind) bool {
+	for _, k := range PaymentKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// ========================================
+// 👤 CLIENTS
+// ========================================
+
+// phoneKeyDigits - Chiffres comparés pour reconnaître un même numéro
+// ("0612345678", "+212 6 12 34 56 78" et "00212612345678" sont le même client)
+const phoneKeyDigits = 9
+
+// Customer - Client du shop. Le téléphone identifie le client: un seul client par numéro.
+type Customer struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	Name         string     `gorm:"not null" json:"name"`
+	Phone        string     `json:"phone,omitempty"`
+	PhoneKey     *string    `gorm:"uniqueIndex:idx_shop_customer_phone" json:"-"` // Derniers chiffres du téléphone (dédoublonnage)
+	Email        string     `gorm:"index" json:"email,omitempty"`
+	Notes        string     `json:"notes,omitempty"`
+	Tags         []string   `gorm:"serializer:json" json:"tags"`
+	AcceptsSMS   bool       `gorm:"not null" json:"accepts_sms"`   // Consentement: messages SMS / WhatsApp
+	AcceptsEmail bool       `gorm:"not null" json:"accepts_email"` // Consentement: emails marketing
+	CreditLimit  Money      `json:"credit_limit"`                  // Encours maximal des ventes à crédit (0: pas de crédit)
+	ConsentAt    *time.Time `json:"consent_at,omitempty"`          // Dernière modification des consentements
+	ShopID       uint       `gorm:"not null;index;uniqueIndex:idx_shop_customer_phone" json:"shop_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// NormalizePhone nettoie un numéro saisi: chiffres uniquement, "+" initial conservé ("00" devient "+")
+func NormalizePhone(phone string) string {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(phone) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		}
+	}
+	normalized := b.String()
+	if strings.HasPrefix(normalized, "00") {
+		normalized = "+" + normalized[2:]
+	}
+	return normalized
+}
+
+// PhoneKey retourne la clé de dédoublonnage d'un numéro (vide si le numéro est trop court)
+func PhoneKey(phone string) string {
+	digits := strings.TrimPrefix(NormalizePhone(phone), "+")
+	if len(digits) < 6 {
+		return ""
+	}
+	if len(digits) > phoneKeyDigits {
+		digits = digits[len(digits)-phoneKeyDigits:]
+	}
+	return digits
+}
+
+// ========================================
+// 📆 VENTES À TEMPÉRAMENT
+// ========================================
+
+type LayawayStatus string
+
+const (
+	LayawayActive    LayawayStatus = "active"
+	LayawayCompleted LayawayStatus = "completed" // Soldée: convertie en vente (SaleID)
+	LayawayCancelled LayawayStatus = "cancelled"
+)
+
+// Layaway - Vente à tempérament: le stock est réservé et le prix figé à la création,
+// le client paie par échéances, la vente est enregistrée une fois le total versé.
+// Tant qu'elle n'est pas soldée, les versements sont des règlements sans transaction (LayawayID).
+type Layaway struct {
+	ID              uint                 `gorm:"primaryKey" json:"id"`
+	Status          LayawayStatus        `gorm:"not null;index" json:"status"`
+	CustomerID      uint                 `gorm:"not null;index" json:"customer_id"`
+	ProductID       uint                 `gorm:"not null;index" json:"product_id"`
+	Quantity        int                  `gorm:"not null" json:"quantity"`
+	Subtotal        Money                `json:"subtotal"`
+	Discount        Money                `json:"discount,omitempty"`
+	Amount          Money                `gorm:"not null" json:"amount"` // Prix figé, taxes comprises
+	TaxRate         float64              `json:"tax_rate,omitempty"`
+	TaxAmount       Money                `json:"tax_amount,omitempty"`
+	Paid            Money                `json:"paid"`                       // Versements reçus
+	CancellationFee Money                `json:"cancellation_fee,omitempty"` // Annulation: frais retenus sur les versements
+	Refunded        Money                `json:"refunded,omitempty"`         // Annulation: versements rendus au client
+	SaleID          *uint                `json:"sale_id,omitempty"`          // Vente enregistrée une fois soldée
+	Notes           string               `json:"notes,omitempty"`
+	UserID          *uint                `json:"user_id,omitempty"`
+	ShopID          uint                 `gorm:"not null;index" json:"shop_id"`
+	CreatedAt       time.Time            `json:"created_at"`
+	UpdatedAt       time.Time            `json:"updated_at"`
+	CompletedAt     *time.Time           `json:"completed_at,omitempty"`
+	CancelledAt     *time.Time           `json:"cancelled_at,omitempty"`
+	Customer        *Customer            `gorm:"foreignKey:CustomerID" json:"customer,omitempty"`
+	Product         *Product             `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	Installments    []LayawayInstallment `gorm:"foreignKey:LayawayID" json:"installments,omitempty"`
+	Payments        []Payment            `gorm:"foreignKey:LayawayID" json:"payments,omitempty"`
+}
+
+// LayawayInstallment - Échéance d'une vente à tempérament (les versements soldent les plus anciennes d'abord)
+type LayawayInstallment struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	LayawayID uint       `gorm:"not null;index" json:"layaway_id"`
+	DueDate   time.Time  `gorm:"not null;index" json:"due_date"`
+	Amount    Money      `gorm:"not null" json:"amount"`
+	Paid      Money      `json:"paid"`
+	PaidAt    *time.Time `json:"paid_at,omitempty"` // Échéance soldée
+	ShopID    uint       `gorm:"not null;index" json:"shop_id"`
+}
+
+// ========================================
+// ⭐ FIDÉLITÉ
+// ========================================
+
+// LoyaltyProgram - Programme de fidélité du shop (un par shop)
+type LoyaltyProgram struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	Active          bool      `gorm:"not null" json:"active"`
+	EarnRate        float64   `json:"earn_rate"`         // Points gagnés par unité de devise dépensée
+	PointValue      Money     `json:"point_value"`       // Valeur d'un point utilisé en paiement
+	MinRedeemPoints int       `json:"min_redeem_points"` // Solde minimum pour payer avec des points
+	ExpiryMonths    int       `json:"expiry_months"`     // Validité des points gagnés (0: sans expiration)
+	ShopID          uint      `gorm:"not null;uniqueIndex" json:"shop_id"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type LoyaltyEntryType string
+
+const (
+	LoyaltyEarn     LoyaltyEntryType = "earn"     // Points gagnés sur une vente
+	LoyaltyRedeem   LoyaltyEntryType = "redeem"   // Points utilisés en paiement
+	LoyaltyReversal LoyaltyEntryType = "reversal" // Points retirés au remboursement d'une vente
+	LoyaltyRestore  LoyaltyEntryType = "restore"  // Points rendus au remboursement d'un paiement en points
+	LoyaltyExpire   LoyaltyEntryType = "expire"
+	LoyaltyAdjust   LoyaltyEntryType = "adjust" // Ajustement manuel
+)
+
+// LoyaltyEntry - Mouvement de points d'un client. Le solde est la somme des mouvements;
+// les points crédités sont consommés dans l'ordre d'expiration (Remaining: points restants).
+type LoyaltyEntry struct {
+	ID            uint             `gorm:"primaryKey" json:"id"`
+	CustomerID    uint             `gorm:"not null;index" json:"customer_id"`
+	Type          LoyaltyEntryType `gorm:"not null" json:"type"`
+	Points        int              `gorm:"not null" json:"points"` // Négatif: points retirés
+	Remaining     int              `json:"remaining,omitempty"`    // Points crédités non encore utilisés ni expirés
+	ExpiresAt     *time.Time       `gorm:"index" json:"expires_at,omitempty"`
+	TransactionID *uint            `gorm:"index" json:"transaction_id,omitempty"`
+	Note          string           `json:"note,omitempty"`
+	UserID        *uint            `json:"user_id,omitempty"`
+	ShopID        uint             `gorm:"not null;index" json:"shop_id"`
+	CreatedAt     time.Time        `json:"created_at"`
+}
+
+// LoyaltyAllocation - Points d'un crédit consommés par un débit (rendus si le débit est annulé)
+type LoyaltyAllocation struct {
+	ID       uint `gorm:"primaryKey" json:"id"`
+	DebitID  uint `gorm:"not null;index" json:"debit_id"`
+	CreditID uint `gorm:"not null;index" json:"credit_id"`
+	Points   int  `gorm:"not null" json:"points"`
+}
+
+// ========================================
+// 🎁 CARTES CADEAUX & AVOIRS
+// ========================================
+
+type GiftCardSource string
+
+const (
+	GiftCardSold   GiftCardSource = "sold"   // Carte cadeau vendue
+	GiftCardRefund GiftCardSource = "refund" // Avoir émis au remboursement d'une vente
+)
+
+// GiftCard - Carte cadeau ou avoir client, identifié par un code unique et utilisable
+// comme moyen de paiement (store_credit) jusqu'à épuisement du solde ou expiration
+type GiftCard struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	Code          string         `gorm:"not null;uniqueIndex:idx_gift_card_shop_code" json:"code"` // Majuscules
+	Source        GiftCardSource `gorm:"not null" json:"source"`
+	InitialAmount Money          `gorm:"not null" json:"initial_amount"`
+	Balance       Money          `gorm:"not null" json:"balance"` // Somme des mouvements
+	ExpiresAt     *time.Time     `gorm:"index" json:"expires_at,omitempty"`
+	CustomerID    *uint          `gorm:"index" json:"customer_id,omitempty"`
+	TransactionID *uint          `gorm:"index" json:"transaction_id,omitempty"` // Remboursement ayant émis l'avoir
+	Note          string         `json:"note,omitempty"`
+	UserID        *uint          `json:"user_id,omitempty"`
+	ShopID        uint           `gorm:"not null;uniqueIndex:idx_gift_card_shop_code;index" json:"shop_id"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+
+	Customer *Customer       `gorm:"foreignKey:CustomerID" json:"customer,omitempty"`
+	Entries  []GiftCardEntry `gorm:"foreignKey:GiftCardID" json:"entries,omitempty"`
+}
+
+type GiftCardEntryType string
+
+const (
+	GiftCardIssue  GiftCardEntryType = "issue"  // Émission (vente de la carte ou remboursement)
+	GiftCardRedeem GiftCardEntryType = "redeem" // Utilisation en paiement d'une vente
+	GiftCardCredit GiftCardEntryType = "credit" // Remboursement crédité sur une carte existante
+	GiftCardExpire GiftCardEntryType = "expire"
+	GiftCardAdjust GiftCardEntryType = "adjust" // Ajustement manuel
+)
+
+// GiftCardEntry - Mouvement du solde d'une carte cadeau (négatif: débit)
+type GiftCardEntry struct {
+	ID            uint              `gorm:"primaryKey" json:"id"`
+	GiftCardID    uint              `gorm:"not null;index" json:"gift_card_id"`
+	Type          GiftCardEntryType `gorm:"not null" json:"type"`
+	Amount        Money             `gorm:"not null" json:"amount"`
+	TransactionID *uint             `gorm:"index" json:"transaction_id,omitempty"`
+	Note          string            `json:"note,omitempty"`
+	UserID        *uint             `json:"user_id,omitempty"`
+	ShopID        uint              `gorm:"not null;index" json:"shop_id"`
+	CreatedAt     time.Time         `json:"created_at"`
 }
\ No newline at end of file
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ========================================
+// 💰 MONEY - Montants exacts en centimes
+// ========================================
+
+// Money - Montant en centièmes d'unité de la devise du shop (devises à deux décimales:
+// voir NormalizeCurrency). Stocké en entier pour des sommes exactes; sérialisé en JSON
+// comme un nombre décimal (120.5).
+type Money int64
+
+// DefaultCurrency - Devise des shops créés sans devise explicite (code ISO 4217)
+const DefaultCurrency = "MAD"
+
+// moneyScale - Nombre de centièmes par unité (même échelle pour tous les shops)
+const moneyScale = 100
+
+// currencyExponents - Devises ISO 4217 dont le nombre de décimales n'est pas 2
+// (les autres codes ont deux décimales)
+var currencyExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+	"RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// ErrInvalidAmount - Montant illisible
+var ErrInvalidAmount = errors.New("montant invalide")
+
+// MoneyFromFloat convertit un montant décimal (arrondi au centime le plus proche)
+func MoneyFromFloat(amount float64) Money {
+	return Money(math.Round(amount * moneyScale))
+}
+
+// ParseMoney lit un montant décimal exact ("120", "120.5", "-3.99").
+// Au-delà de deux décimales, le montant est arrondi au centime.
+func ParseMoney(value string) (Money, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, ErrInvalidAmount
+	}
+
+	// Notation scientifique: passer par un flottant
+	if strings.ContainsAny(value, "eE") {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(f) || math.Abs(f) >= math.MaxInt64/moneyScale {
+			return 0, ErrInvalidAmount
+		}
+		return MoneyFromFloat(f), nil
+	}
+
+	// Un seul signe
+	negative := strings.HasPrefix(value, "-")
+	if negative || strings.HasPrefix(value, "+") {
+		value = value[1:]
+	}
+
+	units, fraction, _ := strings.Cut(value, ".")
+	if units == "" && fraction == "" {
+		return 0, ErrInvalidAmount
+	}
+	if units == "" {
+		units = "0"
+	}
+	for _, part := range []string{units, fraction} {
+		if strings.Trim(part, "0123456789") != "" {
+			return 0, ErrInvalidAmount
+		}
+	}
+
+	whole, err := strconv.ParseInt(units, 10, 64)
+	if err != nil || whole > math.MaxInt64/moneyScale-1 {
+		return 0, ErrInvalidAmount
+	}
+
+	// Deux premières décimales, arrondi sur la troisième
+	digits := (fraction + "000")[:3]
+	cents, _ := strconv.ParseInt(digits[:2], 10, 64)
+	if digits[2] >= '5' {
+		cents++
+	}
+
+	amount := Money(whole*moneyScale + cents)
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// Float64 retourne le montant en unités (pour les calculs de pourcentage et l'affichage)
+func (m Money) Float64() float64 {
+	return float64(m) / moneyScale
+}
+
+// String - Montant avec deux décimales ("120.50")
+func (m Money) String() string {
+	sign := ""
+	abs := int64(m)
+	if abs < 0 {
+		sign, abs = "-", -abs
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, abs/moneyScale, abs%moneyScale)
+}
+
+// Times multiplie par une quantité
+func (m Money) Times(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// Percent retourne percent % du montant, arrondi au centime
+func (m Money) Percent(percent float64) Money {
+	return Money(math.Round(float64(m) * percent / 100))
+}
+
+// Convert applique un taux de change, arrondi au centime
+func (m Money) Convert(rate float64) Money {
+	return Money(math.Round(float64(m) * rate))
+}
+
+// NormalizeCurrency vérifie un code devise ISO 4217 ("usd" -> "USD"). Les montants sont
+// enregistrés au centième: les devises sans décimales (JPY, XOF) ou à trois décimales
+// (TND, KWD) sont refusées.
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 || strings.Trim(code, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return "", fmt.Errorf("devise invalide: %q (code ISO 4217 attendu, ex: USD)", code)
+	}
+	if exponent := CurrencyExponent(code); exponent != 2 {
+		return "", fmt.Errorf("devise non prise en charge: %s (%d décimales, seules les devises à 2 décimales sont acceptées)", code, exponent)
+	}
+	return code, nil
+}
+
+// CurrencyExponent retourne le nombre de décimales d'une devise ISO 4217
+func CurrencyExponent(code string) int {
+	if exponent, ok := currencyExponents[code]; ok {
+		return exponent
+	}
+	return 2
+}
+
+// Min retourne le plus petit des deux montants
+func (m Money) Min(other Money) Money {
+	if other < m {
+		return other
+	}
+	return m
+}
+
+// MarshalJSON - Nombre décimal sans zéros inutiles (compatible avec les anciens float64)
+func (m Money) MarshalJSON() ([]byte, error) {
+	text := m.String()
+	text = strings.TrimRight(text, "0")
+	text = strings.TrimSuffix(text, ".")
+	if text == "" || text == "-" {
+		text = "0"
+	}
+	return []byte(text), nil
+}
+
+// UnmarshalJSON accepte un nombre (120.5) ou une chaîne ("120.50")
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil
+	}
+	text := strings.Trim(string(data), `"`)
+	amount, err := ParseMoney(text)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidAmount, text)
+	}
+	*m = amount
+	return nil
+}
\ No newline at end of file
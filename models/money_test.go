@@ -0,0 +1,166 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Money
+		err   bool
+	}{
+		{value: "120", want: 12000},
+		{value: "120.5", want: 12050},
+		{value: "120.50", want: 12050},
+		{value: " 3.99 ", want: 399},
+		{value: "-3.99", want: -399},
+		{value: "+1", want: 100},
+		{value: ".5", want: 50},
+		{value: "1.", want: 100},
+		{value: "0.004", want: 0},
+		{value: "0.005", want: 1},
+		{value: "-0.005", want: -1},
+		{value: "0.995", want: 100},
+		{value: "1e3", want: 100000},
+		{value: "1.5E-1", want: 15},
+		{value: "-2e2", want: -20000},
+		{value: "92233720368547757.99", want: math.MaxInt64 - 8},
+		{value: "92233720368547758", err: true},
+		{value: "99999999999999999999999", err: true},
+		{value: "1e300", err: true},
+		{value: "-1e300", err: true},
+		{value: "NaN", err: true},
+		{value: "", err: true},
+		{value: "-", err: true},
+		{value: ".", err: true},
+		{value: "--1", err: true},
+		{value: "+-1", err: true},
+		{value: "1.2.3", err: true},
+		{value: "1,5", err: true},
+		{value: "1 000", err: true},
+		{value: "abc", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.value)
+		if tt.err {
+			if !errors.Is(err, ErrInvalidAmount) {
+				t.Errorf("ParseMoney(%q) = %d, %v; attendu ErrInvalidAmount", tt.value, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, %v; attendu %d", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		amount Money
+		want   string
+		json   string
+	}{
+		{0, "0.00", "0"},
+		{5, "0.05", "0.05"},
+		{-5, "-0.05", "-0.05"},
+		{100, "1.00", "1"},
+		{-100, "-1.00", "-1"},
+		{12050, "120.50", "120.5"},
+		{-12050, "-120.50", "-120.5"},
+		{123456789, "1234567.89", "1234567.89"},
+	}
+	for _, tt := range tests {
+		if got := tt.amount.String(); got != tt.want {
+			t.Errorf("Money(%d).String() = %q, attendu %q", tt.amount, got, tt.want)
+		}
+		data, err := json.Marshal(tt.amount)
+		if err != nil || string(data) != tt.json {
+			t.Errorf("json.Marshal(Money(%d)) = %s, %v; attendu %s", tt.amount, data, err, tt.json)
+		}
+	}
+}
+
+func TestMoneyJSONRoundTrip(t *testing.T) {
+	for _, amount := range []Money{0, 1, -1, 10, -10, 99, -99, 12050, -12050, -100001, math.MaxInt64 - 8, -(math.MaxInt64 - 8)} {
+		data, err := json.Marshal(amount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Money
+		if err := json.Unmarshal(data, &got); err != nil || got != amount {
+			t.Errorf("aller-retour de %d via %s: %d, %v", amount, data, got, err)
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data string
+		want Money
+		err  bool
+	}{
+		{data: `120.5`, want: 12050},
+		{data: `"120.50"`, want: 12050},
+		{data: `"-0.005"`, want: -1},
+		{data: `1e3`, want: 100000},
+		{data: `-3`, want: -300},
+		{data: `"abc"`, err: true},
+		{data: `""`, err: true},
+		{data: `true`, err: true},
+	}
+	for _, tt := range tests {
+		var got Money
+		err := json.Unmarshal([]byte(tt.data), &got)
+		if tt.err {
+			if !errors.Is(err, ErrInvalidAmount) {
+				t.Errorf("Unmarshal(%s) = %d, %v; attendu ErrInvalidAmount", tt.data, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Unmarshal(%s) = %d, %v; attendu %d", tt.data, got, err, tt.want)
+		}
+	}
+
+	// null laisse le montant inchangé (champ optionnel)
+	var input struct {
+		Amount *Money `json:"amount"`
+		Fee    Money  `json:"fee"`
+	}
+	input.Fee = 42
+	if err := json.Unmarshal([]byte(`{"amount": null, "fee": null}`), &input); err != nil {
+		t.Fatal(err)
+	}
+	if input.Amount != nil || input.Fee != 42 {
+		t.Errorf("null: amount %v, fee %d", input.Amount, input.Fee)
+	}
+}
+
+func TestNormalizeCurrency(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+		err  bool
+	}{
+		{code: "mad", want: "MAD"},
+		{code: " usd ", want: "USD"},
+		{code: "EUR", want: "EUR"},
+		{code: "JPY", err: true},
+		{code: "xof", err: true},
+		{code: "TND", err: true},
+		{code: "KWD", err: true},
+		{code: "CLF", err: true},
+		{code: "US", err: true},
+		{code: "U5D", err: true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeCurrency(tt.code)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("NormalizeCurrency(%q) = %q, %v", tt.code, got, err)
+		}
+	}
+}
\ No newline at end of file
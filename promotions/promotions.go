@@ -0,0 +1,172 @@
+package promotions
+
+import (
+	"electronic-shop-api/models"
+	"math"
+	"sort"
+	"time"
+)
+
+// Line - Ligne de vente évaluée
+type Line struct {
+	ProductID   uint
+	CategoryIDs []uint // Catégorie du produit et ses ancêtres
+	UnitPrice   models.Money
+	Quantity    int
+}
+
+// Subtotal - Montant de la ligne avant remise
+func (l Line) Subtotal() models.Money {
+	return l.UnitPrice.Times(l.Quantity)
+}
+
+// Applied - Promotion retenue et montant de sa remise
+type Applied struct {
+	Promotion models.Promotion
+	Discount  models.Money
+}
+
+// Result - Résultat de l'évaluation d'une ligne
+type Result struct {
+	Line     Line
+	Subtotal models.Money
+	Discount models.Money
+	Total    models.Money
+	Applied  []Applied
+}
+
+// Evaluate calcule les remises d'une ligne de vente à la date now.
+// La meilleure promotion non cumulable est retenue, puis toutes les promotions cumulables
+// s'y ajoutent; les remises sont calculées sur le montant avant remise et plafonnées à ce montant.
+func Evaluate(promotions []models.Promotion, line Line, now time.Time) Result {
+	result := Result{Line: line, Subtotal: line.Subtotal()}
+
+	var best *Applied
+	var stackable []Applied
+	for _, promotion := range promotions {
+		if !Eligible(promotion, line, now) {
+			continue
+		}
+		discount := Discount(promotion, line)
+		if discount <= 0 {
+			continue
+		}
+		applied := Applied{Promotion: promotion, Discount: discount}
+		if promotion.Stackable {
+			stackable = append(stackable, applied)
+		} else if best == nil || discount > best.Discount {
+			best = &applied
+		}
+	}
+
+	if best != nil {
+		result.Applied = append(result.Applied, *best)
+	}
+	sort.SliceStable(stackable, func(i, j int) bool { return stackable[i].Discount > stackable[j].Discount })
+	result.Applied = append(result.Applied, stackable...)
+
+	// Plafonner: la vente ne peut pas devenir négative
+	remaining := result.Subtotal
+	kept := result.Applied[:0]
+	for _, applied := range result.Applied {
+		if remaining <= 0 {
+			break
+		}
+		applied.Discount = applied.Discount.Min(remaining)
+		remaining -= applied.Discount
+		result.Discount += applied.Discount
+		kept = append(kept, applied)
+	}
+	result.Applied = kept
+	result.Total = result.Subtotal - result.Discount
+	return result
+}
+
+// Eligible indique si la promotion s'applique à la ligne (activation, période, produit, catégorie, minimum)
+func Eligible(promotion models.Promotion, line Line, now time.Time) bool {
+	if !Running(promotion, now) {
+		return false
+	}
+	if !line.Covers(promotion.ProductID, promotion.CategoryID) {
+		return false
+	}
+	return line.Subtotal() >= promotion.MinBasket
+}
+
+// Running indique si la promotion est activée et dans sa période
+func Running(promotion models.Promotion, now time.Time) bool {
+	if !promotion.Active {
+		return false
+	}
+	if promotion.StartsAt != nil && now.Before(*promotion.StartsAt) {
+		return false
+	}
+	return promotion.EndsAt == nil || now.Before(*promotion.EndsAt)
+}
+
+// Discount calcule la remise d'une promotion sur la ligne, sans contrôle d'éligibilité
+func Discount(promotion models.Promotion, line Line) models.Money {
+	subtotal := line.Subtotal()
+
+	switch promotion.Type {
+	case models.PromotionPercent:
+		return subtotal.Percent(math.Min(promotion.Value.Float64(), 100))
+
+	case models.PromotionFixed:
+		return promotion.Value.Min(subtotal)
+
+	case models.PromotionBuyXGetY:
+		// Par groupe de BuyQuantity + FreeQuantity unités, FreeQuantity sont offertes
+		group := promotion.BuyQuantity + promotion.FreeQuantity
+		if promotion.BuyQuantity <= 0 || promotion.FreeQuantity <= 0 {
+			return 0
+		}
+		free := line.Quantity / group * promotion.FreeQuantity
+		return line.UnitPrice.Times(free)
+
+	case models.PromotionBundle:
+		// Chaque lot de BundleQuantity unités est vendu Value au lieu du prix unitaire
+		if promotion.BundleQuantity <= 0 {
+			return 0
+		}
+		bundles := line.Quantity / promotion.BundleQuantity
+		saving := line.UnitPrice.Times(promotion.BundleQuantity) - promotion.Value
+		if saving <= 0 {
+			return 0
+		}
+		return saving.Times(bundles)
+	}
+	return 0
+}
+
+// AddCoupon ajoute la remise d'un code promo (pourcentage ou montant fixe), calculée sur le montant
+// restant après promotions et plafonnée à ce montant. Retourne la remise accordée.
+func (r *Result) AddCoupon(couponType models.PromotionType, value models.Money) models.Money {
+	var discount models.Money
+	switch couponType {
+	case models.PromotionPercent:
+		discount = r.Total.Percent(math.Min(value.Float64(), 100))
+	case models.PromotionFixed:
+		discount = value.Min(r.Total)
+	}
+	r.Discount += discount
+	r.Total = r.Subtotal - r.Discount
+	return discount
+}
+
+// Covers indique si la ligne entre dans la portée produit / catégorie
+func (l Line) Covers(productID, categoryID *uint) bool {
+	if productID != nil && *productID != l.ProductID {
+		return false
+	}
+	return categoryID == nil || containsID(l.CategoryIDs, *categoryID)
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
\ No newline at end of file
@@ -0,0 +1,250 @@
+package promotions
+
+import (
+	"electronic-shop-api/models"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+func uintPtr(v uint) *uint { return &v }
+
+func timePtr(t time.Time) *time.Time { return &t }
+
+// promotion - Promotion active, non cumulable, sur tous les produits
+func promotion(id uint, promotionType models.PromotionType, value models.Money) models.Promotion {
+	return models.Promotion{ID: id, Name: string(promotionType), Type: promotionType, Value: value, Active: true}
+}
+
+func stackable(p models.Promotion) models.Promotion {
+	p.Stackable = true
+	return p
+}
+
+// line - 10,00 l'unité
+func line(quantity int) Line {
+	return Line{ProductID: 1, CategoryIDs: []uint{3, 2}, UnitPrice: 1000, Quantity: quantity}
+}
+
+func TestEvaluate(t *testing.T) {
+	type applied struct {
+		id       uint
+		discount models.Money
+	}
+	tests := []struct {
+		name       string
+		promotions []models.Promotion
+		line       Line
+		want       []applied
+		total      models.Money
+	}{
+		{
+			name:  "sans promotion",
+			line:  line(3),
+			total: 3000,
+		},
+		{
+			name: "meilleure promotion non cumulable",
+			promotions: []models.Promotion{
+				promotion(1, models.PromotionPercent, 1000), // 10 %: 10,00
+				promotion(2, models.PromotionFixed, 1500),   // 15,00
+				promotion(3, models.PromotionPercent, 500),  // 5 %: 5,00
+			},
+			line:  line(10),
+			want:  []applied{{2, 1500}},
+			total: 8500,
+		},
+		{
+			name: "égalité: la première est retenue",
+			promotions: []models.Promotion{
+				promotion(1, models.PromotionFixed, 1000),
+				promotion(2, models.PromotionPercent, 1000),
+			},
+			line:  line(10),
+			want:  []applied{{1, 1000}},
+			total: 9000,
+		},
+		{
+			name: "cumulables après la non cumulable, par remise décroissante",
+			promotions: []models.Promotion{
+				stackable(promotion(1, models.PromotionFixed, 200)),
+				promotion(2, models.PromotionPercent, 1000),
+				stackable(promotion(3, models.PromotionPercent, 500)),
+				promotion(4, models.PromotionFixed, 300),
+			},
+			line:  line(10),
+			want:  []applied{{2, 1000}, {3, 500}, {1, 200}},
+			total: 8300,
+		},
+		{
+			name: "remises calculées sur le montant avant remise",
+			promotions: []models.Promotion{
+				stackable(promotion(1, models.PromotionPercent, 5000)),
+				stackable(promotion(2, models.PromotionPercent, 2000)),
+			},
+			line:  line(10),
+			want:  []applied{{1, 5000}, {2, 2000}},
+			total: 3000,
+		},
+		{
+			name: "plafonnées au montant de la ligne",
+			promotions: []models.Promotion{
+				promotion(1, models.PromotionFixed, 8000),
+				stackable(promotion(2, models.PromotionPercent, 5000)), // 50,00 ramené à 20,00
+				stackable(promotion(3, models.PromotionFixed, 100)),    // plus rien à remiser
+			},
+			line:  line(10),
+			want:  []applied{{1, 8000}, {2, 2000}},
+			total: 0,
+		},
+		{
+			name:       "montant fixe supérieur à la ligne",
+			promotions: []models.Promotion{promotion(1, models.PromotionFixed, 5000)},
+			line:       line(2),
+			want:       []applied{{1, 2000}},
+			total:      0,
+		},
+		{
+			name: "promotions non éligibles ignorées",
+			promotions: []models.Promotion{
+				{ID: 1, Type: models.PromotionFixed, Value: 100},                                                       // inactive
+				{ID: 2, Type: models.PromotionFixed, Value: 200, Active: true, StartsAt: timePtr(now.Add(time.Hour))},  // pas commencée
+				{ID: 3, Type: models.PromotionFixed, Value: 300, Active: true, EndsAt: timePtr(now)},                   // terminée
+				{ID: 4, Type: models.PromotionFixed, Value: 400, Active: true, ProductID: uintPtr(2)},                  // autre produit
+				{ID: 5, Type: models.PromotionFixed, Value: 500, Active: true, CategoryID: uintPtr(9)},                 // autre catégorie
+				{ID: 6, Type: models.PromotionFixed, Value: 600, Active: true, MinBasket: 3001},                        // minimum non atteint
+				{ID: 7, Type: models.PromotionFixed, Value: 50, Active: true, CategoryID: uintPtr(2), MinBasket: 3000}, // catégorie parente
+			},
+			line:  line(3),
+			want:  []applied{{7, 50}},
+			total: 2950,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Evaluate(tt.promotions, tt.line, now)
+			if len(result.Applied) != len(tt.want) {
+				t.Fatalf("%d promotions appliquées, attendu %d: %+v", len(result.Applied), len(tt.want), result.Applied)
+			}
+			var discount models.Money
+			for i, a := range result.Applied {
+				if a.Promotion.ID != tt.want[i].id || a.Discount != tt.want[i].discount {
+					t.Errorf("promotion %d: #%d remise %d, attendu #%d remise %d", i, a.Promotion.ID, a.Discount, tt.want[i].id, tt.want[i].discount)
+				}
+				discount += a.Discount
+			}
+			if result.Subtotal != tt.line.Subtotal() || result.Discount != discount || result.Total != tt.total {
+				t.Errorf("sous-total %d, remise %d, total %d; attendu %d, %d, %d",
+					result.Subtotal, result.Discount, result.Total, tt.line.Subtotal(), discount, tt.total)
+			}
+		})
+	}
+}
+
+func TestDiscountBuyXGetY(t *testing.T) {
+	p := promotion(1, models.PromotionBuyXGetY, 0)
+	p.BuyQuantity, p.FreeQuantity = 2, 1 // 2 achetés, 1 offert: groupes de 3
+
+	for quantity, free := range map[int]int{1: 0, 2: 0, 3: 1, 5: 1, 6: 2, 8: 2, 9: 3} {
+		if got, want := Discount(p, line(quantity)), models.Money(free*1000); got != want {
+			t.Errorf("%d unités: remise %d, attendu %d", quantity, got, want)
+		}
+	}
+
+	p.FreeQuantity = 0
+	if got := Discount(p, line(9)); got != 0 {
+		t.Errorf("sans unité offerte: remise %d", got)
+	}
+}
+
+func TestDiscountBundle(t *testing.T) {
+	p := promotion(1, models.PromotionBundle, 2500)
+	p.BundleQuantity = 3 // 3 pour 25,00 au lieu de 30,00
+
+	for quantity, want := range map[int]models.Money{2: 0, 3: 500, 5: 500, 6: 1000, 7: 1000} {
+		if got := Discount(p, line(quantity)); got != want {
+			t.Errorf("%d unités: remise %d, attendu %d", quantity, got, want)
+		}
+	}
+
+	p.Value = 3000 // Lot au prix normal: pas de remise
+	if got := Discount(p, line(6)); got != 0 {
+		t.Errorf("lot sans économie: remise %d", got)
+	}
+	p.BundleQuantity = 0
+	if got := Discount(p, line(6)); got != 0 {
+		t.Errorf("lot sans quantité: remise %d", got)
+	}
+}
+
+func TestAddCoupon(t *testing.T) {
+	tests := []struct {
+		name       string
+		promotions []models.Promotion
+		couponType models.PromotionType
+		value      models.Money
+		discount   models.Money
+		total      models.Money
+	}{
+		{
+			name:       "pourcentage après promotions",
+			promotions: []models.Promotion{promotion(1, models.PromotionPercent, 2000)},
+			couponType: models.PromotionPercent,
+			value:      1000, // 10 % de 80,00
+			discount:   800,
+			total:      7200,
+		},
+		{
+			name:       "montant fixe",
+			couponType: models.PromotionFixed,
+			value:      1250,
+			discount:   1250,
+			total:      8750,
+		},
+		{
+			name:       "montant fixe plafonné au reste",
+			promotions: []models.Promotion{promotion(1, models.PromotionFixed, 9500)},
+			couponType: models.PromotionFixed,
+			value:      1000,
+			discount:   500,
+			total:      0,
+		},
+		{
+			name:       "pourcentage plafonné à 100",
+			couponType: models.PromotionPercent,
+			value:      15000,
+			discount:   10000,
+			total:      0,
+		},
+		{
+			name:       "ligne déjà entièrement remisée",
+			promotions: []models.Promotion{promotion(1, models.PromotionPercent, 10000)},
+			couponType: models.PromotionFixed,
+			value:      1000,
+			discount:   0,
+			total:      0,
+		},
+		{
+			name:       "pourcentage sur une ligne entièrement remisée",
+			promotions: []models.Promotion{promotion(1, models.PromotionFixed, 20000)},
+			couponType: models.PromotionPercent,
+			value:      5000,
+			discount:   0,
+			total:      0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Evaluate(tt.promotions, line(10), now)
+			before := result.Discount
+			discount := result.AddCoupon(tt.couponType, tt.value)
+			if discount != tt.discount || result.Total != tt.total {
+				t.Fatalf("remise %d, total %d; attendu %d, %d", discount, result.Total, tt.discount, tt.total)
+			}
+			if result.Discount != before+discount || result.Subtotal-result.Discount != result.Total {
+				t.Fatalf("remises incohérentes: sous-total %d, remise %d, total %d", result.Subtotal, result.Discount, result.Total)
+			}
+		})
+	}
+}
\ No newline at end of file
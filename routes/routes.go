@@ -1,9 +1,12 @@
 package routes
 
 import (
+	"electronic-shop-api/config"
 	"electronic-shop-api/handlers"
 	"electronic-shop-api/middleware"
 	"electronic-shop-api/models"
+	"electronic-shop-api/storage"
+	"path/filepath"
 
 	"github.com/gin-gonic/gin"
 )
@@ -23,6 +26,11 @@ func SetupRoutes(router *gin.Engine) {
 		})
 	})
 
+	// Images produits stockées sur disque (seul le préfixe public est exposé)
+	if config.AppConfig.StorageDriver == "local" {
+		router.Static("/uploads/"+storage.PublicPrefix, filepath.Join(config.AppConfig.StorageLocalDir, storage.PublicPrefix))
+	}
+
 	// Auth
 	router.POST("/register", handlers.Register)
 	router.POST("/login", handlers.Login)
@@ -33,6 +41,7 @@ func SetupRoutes(router *gin.Engine) {
 		public.GET("/shops", handlers.GetPublicShops)
 		public.GET("/:shopID/products", handlers.GetPublicProducts)
 		public.GET("/:shopID/products/:productID", handlers.GetPublicProduct)
+		public.GET("/:shopID/categories", handlers.GetPublicCategories)
 	}
 
 	// ========================================
@@ -50,20 +59,193 @@ func SetupRoutes(router *gin.Engine) {
 		products.Use(middleware.RequireRole(models.RoleAdmin, models.RoleSuperAdmin))
 		{
 			products.GET("", handlers.GetProducts)
+			products.GET("/export", handlers.ExportProducts)
+			products.GET("/lookup", handlers.LookupProduct)
+			products.POST("/barcodes", handlers.GenerateBarcodes)
+			products.POST("/labels", handlers.PrintLabels)
+			products.POST("/import", handlers.ImportProducts)
+			products.POST("/bulk", handlers.BulkUpdateProducts)
+			products.GET("/import", handlers.GetImportJobs)
+			products.GET("/import/:jobID", handlers.GetImportJob)
 			products.GET("/:id", handlers.GetProduct)
+			products.GET("/:id/barcode", handlers.GetProductBarcode)
+			products.GET("/:id/images", handlers.GetProductImages)
+			products.POST("/:id/images", handlers.UploadProductImages)
+			products.PUT("/:id/images/order", handlers.ReorderProductImages)
+			products.DELETE("/:id/images/:imageID", handlers.DeleteProductImage)
+			products.GET("/:id/price-history", handlers.GetPriceHistory)
+			products.GET("/:id/price-schedules", handlers.GetPriceSchedules)
+			products.POST("/:id/price-schedules", handlers.CreatePriceSchedule)
+			products.DELETE("/:id/price-schedules/:scheduleID", handlers.CancelPriceSchedule)
 			products.POST("", handlers.CreateProduct)
 			products.PUT("/:id", handlers.UpdateProduct)
 			products.DELETE("/:id", handlers.DeleteProduct)
 		}
 
+		// Catégories (lecture Admin+, écriture SuperAdmin)
+		categories := protected.Group("/categories")
+		categories.Use(middleware.RequireRole(models.RoleAdmin, models.RoleSuperAdmin))
+		{
+			categories.GET("", handlers.GetCategories)
+			categories.POST("", middleware.RequireRole(models.RoleSuperAdmin), handlers.CreateCategory)
+			categories.PUT("/:id", middleware.RequireRole(models.RoleSuperAdmin), handlers.UpdateCategory)
+			categories.DELETE("/:id", middleware.RequireRole(models.RoleSuperAdmin), handlers.DeleteCategory)
+		}
+
+		// Fiches techniques par catégorie (lecture Admin+, écriture SuperAdmin)
+		attributes := protected.Group("/attributes")
+		attributes.Use(middleware.RequireRole(models.RoleAdmin, models.RoleSuperAdmin))
+		{
+			attributes.GET("", handlers.GetCategoryAttributes)
+			attributes.POST("", middleware.RequireRole(models.RoleSuperAdmin), handlers.CreateCategoryAttribute)
+			attributes.PUT("/:id", middleware.RequireRole(models.RoleSuperAdmin), handlers.UpdateCategoryAttribute)
+			attributes.DELETE("/:id", middleware.RequireRole(models.RoleSuperAdmin), handlers.DeleteCategoryAttribute)
+		}
+
+		// Promotions (lecture Admin+, écriture SuperAdmin)
+		promotions := protected.Group("/promotions")
+		promotions.Use(middleware.RequireRole(models.RoleAdmin, models.RoleSuperAdmin))
+		{
+			promotions.GET("", handlers.GetPromotions)
+			promotions.POST("", middleware.RequireRole(models.RoleSuperAdmin), handlers.CreatePromotion)
+			promotions.PUT("/:id", middleware.RequireRole(models.RoleSuperAdmin), handlers.UpdatePromotion)
+			promotions.DELETE("/:id", middleware.RequireRole(models.RoleSuperAdmin), handlers.DeletePromotion)
+		}
+
+		// Codes promo (lecture Admin+, écriture SuperAdmin)
+		coupons := protected.Group("/coupons")
+		coupons.Use(middleware.RequireRole(models.RoleAdmin, models.RoleSuperAdmin))
+		{
+			coupons.GET("", handlers.GetCoupons)
+			coupons.GET("/:id/redemptions", handlers.GetCouponRedemptions)
+			coupons.POST("", middleware.RequireRole(models.RoleSuperAdmin), handlers.CreateCoupon)
+			coupons.PUT("/:id", middleware.RequireRole(models.RoleSuperAdmin), handlers.UpdateCoupon)
+			coupons.DELETE("/:id", middleware.RequireRole(models.RoleSuperAdmin), handlers.DeleteCoupon)
+		}
+
+		// Taux de taxe (lecture Admin+, écriture SuperAdmin)
+		taxRates := protected.Group("/tax-rates")
+		taxRates.Use(middleware.RequireRole(models.RoleAdmin, models.RoleSuperAdmin))
+		{
+			taxRates.GET("", handlers.GetTaxRates)
+			taxRates.POST("", middleware.RequireRole(models.RoleSuperAdmin), handlers.CreateTaxRate)
+			taxRates.PUT("/:id", middleware.RequireRole(models.RoleSuperAdmin), handlers.UpdateTaxRate)
+			taxRates.DELETE("/:id", middleware.RequireRole(models.RoleSuperAdmin), handlers.DeleteTaxRate)
+		}
+
+		// Taux de change (lecture Admin+, écriture SuperAdmin)
+		exchangeRates := protected.Group("/exchange-rates")
+		exchangeRates.Use(middleware.RequireRole(models.RoleAdmin, models.RoleSuperAdmin))
+		{
+			exchangeRates.GET("", handlers.GetExchangeRates)
+			exchangeRates.POST("", middleware.RequireRole(models.RoleSuperAdmin), handlers.SetExchangeRate)
+			exchangeRates.POST("/import", middleware.RequireRole(models.RoleSuperAdmin), handlers.ImportExchangeRates)
+			exchangeRates.DELETE("/:id", middleware.RequireRole(models.RoleSuperAdmin), handlers.DeleteExchangeRate)
+		}
+
 		// Transactions (Admin + SuperAdmin)
 		transactions := protected.Group("/transactions")
 		transactions.Use(middleware.RequireRole(models.RoleAdmin, models.RoleSuperAdmin))
 		{
 			transactions.GET("", handlers.GetTransactions)
+			transactions.GET("/export", handlers.ExportTransactions)
 			transactions.GET("/:id", handlers.GetTransaction)
 			transactions.POST("", handlers.CreateTransaction)
 			transactions.DELETE("/:id", handlers.DeleteTransaction)
+			transactions.POST("/:id/attachments", handlers.UploadExpenseAttachment)
+			transactions.GET("/:id/attachments/:attachmentID", handlers.DownloadExpenseAttachment)
+			transactions.DELETE("/:id/attachments/:attachmentID", handlers.DeleteExpenseAttachment)
+		}
+
+		// Clients (Admin + SuperAdmin, fusion et suppression SuperAdmin)
+		customers := protected.Group("/customers")
+		customers.Use(middleware.RequireRole(models.RoleAdmin, models.RoleSuperAdmin))
+		{
+			customers.GET("", handlers.GetCustomers)
+			customers.GET("/:id", handlers.GetCustomer)
+			customers.GET("/:id/transactions", handlers.GetCustomerTransactions)
+			customers.GET("/:id/receivables", handlers.GetCustomerReceivables)
+			customers.POST("/:id/payments", handlers.RecordCustomerPayment)
+			customers.GET("/:id/loyalty", handlers.GetCustomerLoyalty)
+			customers.POST("/:id/loyalty/adjust", middleware.RequireRole(models.RoleSuperAdmin), handlers.AdjustCustomerLoyalty)
+			customers.POST("", handlers.CreateCustomer)
+			customers.PUT("/:id", handlers.UpdateCustomer)
+			customers.POST("/:id/merge", middleware.RequireRole(models.RoleSuperAdmin), handlers.MergeCustomer)
+			customers.DELETE("/:id", middleware.RequireRole(models.RoleSuperAdmin), handlers.DeleteCustomer)
+		}
+
+		// Ventes à tempérament (Admin+)
+		layaways := protected.Group("/layaways")
+		layaways.Use(middleware.RequireRole(models.RoleAdmin, models.RoleSuperAdmin))
+		{
+			layaways.GET("", handlers.GetLayaways)
+			layaways.GET("/overdue", handlers.GetOverdueInstallments)
+			layaways.GET("/:id", handlers.GetLayaway)
+			layaways.POST("", handlers.CreateLayaway)
+			layaways.POST("/:id/payments", handlers.RecordLayawayPayment)
+			layaways.POST("/:id/cancel", handlers.CancelLayaway)
+		}
+
+		// Cartes cadeaux et avoirs (Admin+, ajustement SuperAdmin)
+		giftCards := protected.Group("/gift-cards")
+		giftCards.Use(middleware.RequireRole(models.RoleAdmin, models.RoleSuperAdmin))
+		{
+			giftCards.GET("", handlers.GetGiftCards)
+			giftCards.GET("/:code", handlers.GetGiftCard)
+			giftCards.POST("", handlers.CreateGiftCard)
+			giftCards.POST("/:code/adjust", middleware.RequireRole(models.RoleSuperAdmin), handlers.AdjustGiftCard)
+		}
+
+		// Programme de fidélité (lecture Admin+, écriture SuperAdmin)
+		loyalty := protected.Group("/loyalty")
+		loyalty.Use(middleware.RequireRole(models.RoleAdmin, models.RoleSuperAdmin))
+		{
+			loyalty.GET("", handlers.GetLoyaltyProgram)
+			loyalty.PUT("", middleware.RequireRole(models.RoleSuperAdmin), handlers.UpdateLoyaltyProgram)
+		}
+
+		// Moyens de paiement (lecture Admin+, écriture SuperAdmin)
+		paymentMethods := protected.Group("/payment-methods")
+		paymentMethods.Use(middleware.RequireRole(models.RoleAdmin, models.RoleSuperAdmin))
+		{
+			paymentMethods.GET("", handlers.GetPaymentMethods)
+			paymentMethods.POST("", middleware.RequireRole(models.RoleSuperAdmin), handlers.CreatePaymentMethod)
+			paymentMethods.PUT("/:id", middleware.RequireRole(models.RoleSuperAdmin), handlers.UpdatePaymentMethod)
+			paymentMethods.DELETE("/:id", middleware.RequireRole(models.RoleSuperAdmin), handlers.DeletePaymentMethod)
+		}
+
+		// Sessions de caisse (Admin + SuperAdmin)
+		registerSessions := protected.Group("/register-sessions")
+		registerSessions.Use(middleware.RequireRole(models.RoleAdmin, models.RoleSuperAdmin))
+		{
+			registerSessions.GET("", handlers.GetRegisterSessions)
+			registerSessions.GET("/current", handlers.GetCurrentRegisterSession)
+			registerSessions.GET("/z-report", handlers.GetDailyZReport)
+			registerSessions.GET("/z-report/export", handlers.ExportDailyZReport)
+			registerSessions.POST("/open", handlers.OpenRegisterSession)
+			registerSessions.POST("/:id/close", handlers.CloseRegisterSession)
+			registerSessions.GET("/:id/z-report", handlers.GetRegisterSessionZReport)
+			registerSessions.GET("/:id/z-report/export", handlers.ExportRegisterSessionZReport)
+		}
+
+		// Catégories de dépenses (lecture Admin+, écriture SuperAdmin)
+		expenseCategories := protected.Group("/expense-categories")
+		expenseCategories.Use(middleware.RequireRole(models.RoleAdmin, models.RoleSuperAdmin))
+		{
+			expenseCategories.GET("", handlers.GetExpenseCategories)
+			expenseCategories.POST("", middleware.RequireRole(models.RoleSuperAdmin), handlers.CreateExpenseCategory)
+			expenseCategories.PUT("/:id", middleware.RequireRole(models.RoleSuperAdmin), handlers.UpdateExpenseCategory)
+			expenseCategories.DELETE("/:id", middleware.RequireRole(models.RoleSuperAdmin), handlers.DeleteExpenseCategory)
+		}
+
+		// Dépenses récurrentes (SuperAdmin uniquement)
+		recurringExpenses := protected.Group("/recurring-expenses")
+		recurringExpenses.Use(middleware.RequireRole(models.RoleSuperAdmin))
+		{
+			recurringExpenses.GET("", handlers.GetRecurringExpenses)
+			recurringExpenses.POST("", handlers.CreateRecurringExpense)
+			recurringExpenses.PUT("/:id", handlers.UpdateRecurringExpense)
+			recurringExpenses.DELETE("/:id", handlers.DeleteRecurringExpense)
 		}
 
 		// Reports (SuperAdmin uniquement)
@@ -71,7 +253,21 @@ func SetupRoutes(router *gin.Engine) {
 		reports.Use(middleware.RequireRole(models.RoleSuperAdmin))
 		{
 			reports.GET("/dashboard", handlers.GetDashboard)
+			reports.GET("/dashboard/export", handlers.ExportDashboard)
+			reports.GET("/timeseries", handlers.GetTimeSeries)
+			reports.GET("/profit-and-loss", handlers.GetProfitAndLoss)
+			reports.GET("/profit-and-loss/export", handlers.ExportProfitAndLoss)
+			reports.GET("/expenses", handlers.GetExpenseReport)
+			reports.GET("/payments", handlers.GetTakingsReport)
+			reports.GET("/payments/export", handlers.ExportTakingsReport)
+			reports.GET("/receivables", handlers.GetReceivablesReport)
+			reports.GET("/receivables/export", handlers.ExportReceivablesReport)
+			reports.GET("/gift-cards", handlers.GetGiftCardLiability)
+			reports.GET("/gift-cards/export", handlers.ExportGiftCardLiability)
 			reports.GET("/low-stock", handlers.GetLowStockProducts)
+			reports.GET("/low-stock/export", handlers.ExportLowStockProducts)
+			reports.GET("/coupons", handlers.GetCouponReport)
+			reports.GET("/tax", handlers.GetTaxReport)
 		}
 
 		// Shop Management (SuperAdmin uniquement)
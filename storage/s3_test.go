@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"electronic-shop-api/config"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 - Service S3 minimal en mémoire (style de chemin MinIO: /bucket/clé)
+type fakeS3 struct {
+	mu       sync.Mutex
+	buckets  map[string]bool
+	policies map[string]string
+	objects  map[string]fakeObject // bucket/clé -> objet
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{buckets: map[string]bool{}, policies: map[string]string{}, objects: map[string]fakeObject{}}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	bucket, key, _ := strings.Cut(path, "/")
+	if key == "" {
+		f.serveBucket(w, r, bucket)
+		return
+	}
+	if !f.buckets[bucket] {
+		f.error(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := readPayload(r)
+		if err != nil {
+			f.error(w, r, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		f.objects[path] = fakeObject{data: data, contentType: r.Header.Get("Content-Type")}
+		w.Header().Set("ETag", `"fake"`)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		object, ok := f.objects[path]
+		if !ok {
+			f.error(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(object.data)))
+		w.Header().Set("ETag", `"fake"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(object.data)
+		}
+	case http.MethodDelete:
+		delete(f.objects, path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.error(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func (f *fakeS3) serveBucket(w http.ResponseWriter, r *http.Request, bucket string) {
+	switch {
+	case r.Method == http.MethodHead:
+		if !f.buckets[bucket] {
+			f.error(w, r, http.StatusNotFound, "NoSuchBucket")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPut && r.URL.Query().Has("policy"):
+		data, err := readPayload(r)
+		if err != nil {
+			f.error(w, r, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		f.policies[bucket] = string(data)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		f.buckets[bucket] = true
+		w.WriteHeader(http.StatusOK)
+	default:
+		f.error(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func (f *fakeS3) error(w http.ResponseWriter, r *http.Request, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>`+code+`</Code><Message>`+code+`</Message></Error>`)
+	}
+}
+
+// readPayload lit le corps d'une requête, décodé s'il est envoyé par morceaux signés (aws-chunked)
+func readPayload(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var data bytes.Buffer
+	reader := bufio.NewReader(r.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return data.Bytes(), nil
+		}
+		if _, err := io.CopyN(&data, reader, size); err != nil {
+			return nil, err
+		}
+		if _, err := reader.Discard(2); err != nil { // \r\n
+			return nil, err
+		}
+	}
+}
+
+// newTestS3 démarre le faux service et s'y connecte
+func newTestS3(t *testing.T, publicURL string) (*S3, *fakeS3, string) {
+	t.Helper()
+	fake := newFakeS3()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	endpoint := strings.TrimPrefix(server.URL, "http://")
+	s3, err := NewS3(config.Config{
+		StoragePublicURL: publicURL,
+		S3Endpoint:       endpoint,
+		S3Bucket:         "shop",
+		S3AccessKey:      "minio",
+		S3SecretKey:      "minio-secret",
+		S3Region:         "us-east-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s3, fake, endpoint
+}
+
+func TestNewS3CreatesBucketWithPublicPolicy(t *testing.T) {
+	_, fake, _ := newTestS3(t, "")
+
+	if !fake.buckets["shop"] {
+		t.Fatal("bucket non créé")
+	}
+	policy := fake.policies["shop"]
+	if !strings.Contains(policy, `"arn:aws:s3:::shop/`+PublicPrefix+`*"`) || !strings.Contains(policy, "s3:GetObject") {
+		t.Fatalf("politique inattendue: %s", policy)
+	}
+	if strings.Contains(policy, ReceiptsPrefix) {
+		t.Fatalf("justificatifs rendus publics: %s", policy)
+	}
+}
+
+func TestS3PutOpenDelete(t *testing.T) {
+	s3, fake, _ := newTestS3(t, "")
+	ctx := context.Background()
+	key := PublicPrefix + "shop-1/product-1/abc-medium.jpg"
+	content := []byte("contenu de l'image")
+
+	if err := s3.Put(ctx, "/"+key, bytes.NewReader(content), int64(len(content)), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	if object := fake.objects["shop/"+key]; object.contentType != "image/jpeg" {
+		t.Fatalf("type enregistré: %q", object.contentType)
+	}
+
+	r, err := s3.Open(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Fatalf("contenu lu: %q", data)
+	}
+
+	if err := s3.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s3.Open(ctx, key); err == nil {
+		t.Fatal("fichier supprimé encore lisible")
+	}
+	// Supprimer un fichier absent n'est pas une erreur
+	if err := s3.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestS3RejectsInvalidKeys(t *testing.T) {
+	s3, fake, _ := newTestS3(t, "")
+	ctx := context.Background()
+
+	for _, key := range []string{"", "/", "../shop.db", "products/../../secret", `products\x.jpg`} {
+		if err := s3.Put(ctx, key, strings.NewReader("x"), 1, "text/plain"); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("Put(%q): %v, attendu ErrInvalidKey", key, err)
+		}
+		if _, err := s3.Open(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("Open(%q): %v, attendu ErrInvalidKey", key, err)
+		}
+		if err := s3.Delete(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("Delete(%q): %v, attendu ErrInvalidKey", key, err)
+		}
+	}
+	if len(fake.objects) != 0 {
+		t.Fatalf("objets enregistrés: %d", len(fake.objects))
+	}
+}
+
+func TestS3URL(t *testing.T) {
+	s3, _, endpoint := newTestS3(t, "")
+	if got, want := s3.URL("/products/a.jpg"), "http://"+endpoint+"/shop/products/a.jpg"; got != want {
+		t.Fatalf("URL: %s, attendu %s", got, want)
+	}
+
+	cdn, _, _ := newTestS3(t, "https://cdn.example.com/")
+	if got, want := cdn.URL("products/a.jpg"), "https://cdn.example.com/products/a.jpg"; got != want {
+		t.Fatalf("URL: %s, attendu %s", got, want)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"electronic-shop-api/config"
+	"errors"
+	"io"
+	"log"
+	"strings"
+)
+
+// Storage - Stockage de fichiers (disque local ou S3 compatible)
+type Storage interface {
+	// Put enregistre un fichier sous la clé donnée
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open ouvre un fichier en lecture
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete supprime un fichier (sans erreur s'il n'existe pas)
+	Delete(ctx context.Context, key string) error
+	// URL retourne l'URL publique d'un fichier
+	URL(key string) string
+}
+
+// PublicPrefix - Seuls les fichiers sous ce préfixe sont accessibles publiquement
+const PublicPrefix = "products/"
+
+// ReceiptsPrefix - Justificatifs de dépenses: jamais publics, servis via l'API
+const ReceiptsPrefix = "receipts/"
+
+// ErrInvalidKey - Clé vide ou tentant de sortir du stockage
+var ErrInvalidKey = errors.New("clé de fichier invalide")
+
+// store est l'instance globale du stockage
+var store Storage
+
+// Init initialise le stockage selon la configuration
+func Init() {
+	var err error
+
+	switch config.AppConfig.StorageDriver {
+	case "s3":
+		store, err = NewS3(config.AppConfig)
+	case "local", "":
+		store, err = NewLocal(config.AppConfig.StorageLocalDir, localPublicURL())
+	default:
+		err = errors.New("driver de stockage inconnu: " + config.AppConfig.StorageDriver)
+	}
+	if err != nil {
+		log.Fatal("❌ Échec d'initialisation du stockage:", err)
+	}
+
+	log.Printf("✅ Stockage %s initialisé", config.AppConfig.StorageDriver)
+}
+
+// Get retourne l'instance du stockage
+func Get() Storage {
+	return store
+}
+
+// localPublicURL retourne l'URL de base des fichiers servis par l'API
+func localPublicURL() string {
+	if config.AppConfig.StoragePublicURL != "" {
+		return config.AppConfig.StoragePublicURL
+	}
+	return "http://localhost:" + config.AppConfig.ServerPort + "/uploads"
+}
+
+// cleanKey refuse les clés vides, absolues ou contenant ".."
+func cleanKey(key string) (string, error) {
+	key = strings.TrimPrefix(key, "/")
+	if key == "" || strings.Contains(key, "..") || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+	return key, nil
+}
\ No newline at end of file